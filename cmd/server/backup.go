@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"form2mail/internal/backup"
+	"form2mail/internal/config"
+)
+
+// backupTimeout bounds the admin API calls of the backup and restore
+// commands.
+const backupTimeout = 10 * time.Minute
+
+// runBackup handles "form2mail backup -o FILE": it fetches a snapshot from
+// the running instance's admin API and writes it encrypted with BACKUP_KEY.
+func runBackup(cfg config.Config, args []string) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	server := flags.String("url", "http://localhost:"+cfg.ServerPort, "base URL of the running instance")
+	output := flags.String("o", "", "file to write the encrypted backup to (required)")
+	flags.Parse(args)
+	if *output == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	key := backupKey(cfg)
+
+	resp := adminRequest(cfg, http.MethodGet, *server+"/admin/backup", nil)
+	defer resp.Body.Close()
+	var archive backup.Archive
+	if err := json.NewDecoder(resp.Body).Decode(&archive); err != nil {
+		log.Fatalf("Failed to read backup from %s: %v", *server, err)
+	}
+
+	// Write next to the target first, so a failure never leaves a
+	// truncated backup in its place
+	tmp, err := os.CreateTemp(filepath.Dir(*output), ".form2mail-backup-*")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := backup.Seal(tmp, key, archive); err != nil {
+		log.Fatal(err)
+	}
+	if err := tmp.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Rename(tmp.Name(), *output); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Backed up %d submission(s) and %d form(s) to %s", len(archive.Submissions), len(archive.Forms.Forms), *output)
+}
+
+// runRestore handles "form2mail restore [-dry-run] FILE": it decrypts the
+// backup with BACKUP_KEY and restores it into the running instance.
+func runRestore(cfg config.Config, args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	server := flags.String("url", "http://localhost:"+cfg.ServerPort, "base URL of the running instance")
+	dryRun := flags.Bool("dry-run", false, "only check the backup against the instance")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: form2mail restore [-url URL] [-dry-run] FILE")
+		os.Exit(2)
+	}
+	key := backupKey(cfg)
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	archive, err := backup.Open(f, key)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	body, err := json.Marshal(archive)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	target := *server + "/admin/restore"
+	if *dryRun {
+		target += "?dry_run=true"
+	}
+	resp := adminRequest(cfg, http.MethodPost, target, body)
+	defer resp.Body.Close()
+	io.Copy(os.Stdout, resp.Body)
+}
+
+func backupKey(cfg config.Config) []byte {
+	if cfg.BackupKey == "" {
+		log.Fatal("BACKUP_KEY must be set to back up or restore")
+	}
+	key, err := backup.ParseKey(cfg.BackupKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+// adminRequest calls the admin API with ADMIN_TOKEN and exits unless it
+// answers 200.
+func adminRequest(cfg config.Config, method, url string, body []byte) *http.Response {
+	if cfg.AdminToken == "" {
+		log.Fatal("ADMIN_TOKEN must be set to back up or restore")
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.AdminToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := (&http.Client{Timeout: backupTimeout}).Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		log.Fatalf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package main
+
+// Link the PostgreSQL driver for DATABASE_URL=postgres://...
+import _ "github.com/jackc/pgx/v5/stdlib"
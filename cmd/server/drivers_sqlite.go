@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// Link the pure-Go SQLite driver for DATABASE_URL=sqlite:...
+import _ "modernc.org/sqlite"
@@ -1,35 +1,666 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // embed zone data; the Alpine image has none
 
+	"form2mail/internal/address"
+	"form2mail/internal/admin"
+	"form2mail/internal/alert"
+	"form2mail/internal/bridge"
+	"form2mail/internal/captcha"
 	"form2mail/internal/config"
+	"form2mail/internal/deadletter"
+	"form2mail/internal/dns"
+	"form2mail/internal/duplicate"
 	"form2mail/internal/email"
+	"form2mail/internal/enrich"
+	"form2mail/internal/form"
+	"form2mail/internal/forward"
 	"form2mail/internal/handler"
+	"form2mail/internal/logging"
+	"form2mail/internal/metrics"
+	"form2mail/internal/notify"
+	"form2mail/internal/outbound"
+	"form2mail/internal/outbox"
+	"form2mail/internal/quiet"
+	"form2mail/internal/ratelimit"
+	"form2mail/internal/receipt"
+	"form2mail/internal/reload"
+	"form2mail/internal/scan"
+	"form2mail/internal/schedule"
+	"form2mail/internal/shortlink"
+	"form2mail/internal/spam"
+	"form2mail/internal/storage"
+	"form2mail/internal/summary"
+	"form2mail/internal/telegram"
+	"form2mail/internal/upload"
+	"form2mail/internal/usage"
 )
 
+// jobNames are the background jobs whose schedules SCHEDULES can set.
+var jobNames = []string{
+	"config-reload",
+	"daily-summary",
+	"empty-trash",
+	"health-check",
+	"prune-short-links",
+	"prune-uploads",
+	"purge-submissions",
+	"quiet-hours",
+	"smtp-credentials",
+}
+
 func main() {
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Log structured records; what other packages write with the log
+	// package goes through the same logger at info level
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	if err := cfg.LoadSecretFiles(); err != nil {
+		log.Fatal(err)
+	}
 
-	// Validate required config
-	if cfg.SMTPUser == "" || cfg.SMTPPassword == "" || cfg.RecipientEmail == "" {
-		log.Fatal("SMTP_USER, SMTP_PASSWORD, and RECIPIENT_EMAIL must be set")
+	// The backup and restore commands talk to a running instance
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			runBackup(cfg, os.Args[2:])
+			return
+		case "restore":
+			runRestore(cfg, os.Args[2:])
+			return
+		}
+	}
+
+	// Validate the configuration, reporting every problem at once
+	problems := []error{cfg.Validate()}
+	if err := email.ParsePreheader(cfg.Preheader); err != nil {
+		problems = append(problems, err)
+	}
+	schedules := make(map[string]schedule.Schedule, len(cfg.Schedules))
+	for job, spec := range cfg.Schedules {
+		if !slices.Contains(jobNames, job) {
+			problems = append(problems, fmt.Errorf("SCHEDULES: unknown job %q, expected one of %s", job, strings.Join(jobNames, ", ")))
+			continue
+		}
+		s, err := schedule.Parse(spec, cfg.Location)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("SCHEDULES: %s: %v", job, err))
+			continue
+		}
+		schedules[job] = s
+	}
+	switch cfg.UploadDestination {
+	case form.UploadLink, form.UploadAttach, form.UploadDrop:
+	default:
+		problems = append(problems, errors.New("UPLOAD_DESTINATION must be one of link, attach, or drop"))
+	}
+	if cfg.DatabaseURL != "" {
+		if err := storage.CheckDatabaseURL(cfg.DatabaseURL); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		for problem := range strings.SplitSeq(err.Error(), "\n") {
+			logger.Error("Invalid configuration", "problem", problem)
+		}
+		os.Exit(1)
+	}
+	// scheduleOf returns the schedule SCHEDULES sets for job, or fallback
+	scheduleOf := func(job string, fallback schedule.Schedule) schedule.Schedule {
+		if s, ok := schedules[job]; ok {
+			return s
+		}
+		return fallback
+	}
+
+	// Route outbound API calls through OUTBOUND_PROXY, still honoring NO_PROXY
+	transport := outbound.Transport(cfg.OutboundProxy)
+
+	// Look up host names through DNS_SERVERS instead of /etc/resolv.conf.
+	// Every outbound connection goes through the default resolver.
+	if len(cfg.DNSServers) > 0 {
+		resolver, err := dns.NewResolver(cfg.DNSServers, cfg.DNSTimeout)
+		if err != nil {
+			log.Fatalf("DNS_SERVERS: %v", err)
+		}
+		net.DefaultResolver = resolver
+	}
+
+	if cfg.DryRun {
+		log.Println("DRY_RUN enabled: emails will be logged instead of delivered")
+	}
+
+	// Open the outbox used for crash recovery
+	var box *outbox.Outbox
+	if cfg.OutboxDir != "" {
+		var err error
+		box, err = outbox.Open(cfg.OutboxDir)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	// Initialize email sender
-	emailSender := email.NewSender(cfg)
+	emailSender := email.NewSender(cfg, box)
+	emailSender.UseLogger(logger)
+	emailSender.UseTransport(transport)
+
+	// Keep messages that could not be delivered for inspection and resending
+	var deadLetters *deadletter.Store
+	if cfg.DeadLetterDir != "" {
+		deadLetters, err = deadletter.Open(cfg.DeadLetterDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		emailSender.UseDeadLetters(deadLetters)
+	}
+
+	// Embed logos and banners referenced as cid:<file name>
+	if cfg.InlineImagesDir != "" {
+		images, err := email.LoadInlineImages(cfg.InlineImagesDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		emailSender.UseInlineImages(images)
+	}
+
+	// Render notification and confirmation bodies from the operator's templates
+	if cfg.TemplateDir != "" {
+		templates, err := email.LoadTemplates(cfg.TemplateDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		emailSender.UseTemplates(templates)
+	}
+
+	// Alert the operator when deliveries keep failing
+	var notifiers []alert.Notifier
+	if cfg.AlertEmail != "" {
+		if cfg.AlertSMTPHost == cfg.SMTPHost && cfg.DeliversSMTP() && cfg.MailProvider == config.MailProviderSMTP {
+			log.Printf("Warning: ALERT_SMTP_HOST is the same as SMTP_HOST, so alerts may fail together with deliveries")
+		}
+		alertSender := email.NewSender(cfg.AlertSenderConfig(), nil)
+		alertSender.UseTransport(transport)
+		notifiers = append(notifiers, alert.NewEmailNotifier(alertSender, cfg.AlertEmail))
+	}
+	if cfg.AlertWebhookURL != "" {
+		webhook := alert.NewWebhookNotifier(cfg.AlertWebhookURL, cfg.DryRun)
+		webhook.UseTransport(transport)
+		notifiers = append(notifiers, webhook)
+	}
+	var bot *telegram.Bot
+	if cfg.TelegramBotToken != "" {
+		bot = telegram.New(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.TelegramSilent, cfg.DryRun)
+		bot.UseTransport(transport)
+		if cfg.TelegramAlerts {
+			notifiers = append(notifiers, bot)
+		}
+	}
+	if len(notifiers) > 0 {
+		monitor := alert.NewMonitor(cfg.AlertThreshold, cfg.AlertCooldown, notifiers...)
+		emailSender.OnDelivery(monitor.Record)
+	}
+
+	// Run the background jobs below on their schedules
+	scheduler := schedule.New(cfg.ScheduleJitter)
+
+	// Pick up rotated SMTP credentials or API keys from the secret files
+	userFile, secretFile := cfg.CredentialFiles()
+	if secretFile != "" && cfg.CredentialsPoll > 0 {
+		scheduler.Add("smtp-credentials", scheduleOf("smtp-credentials", schedule.Every(cfg.CredentialsPoll)), func(context.Context) error {
+			return emailSender.CheckCredentialFiles(userFile, secretFile)
+		})
+	}
+
+	// Probe the delivery providers so outages show before a submission fails
+	if cfg.HealthCheckInterval > 0 && !cfg.DryRun {
+		scheduler.Add("health-check", scheduleOf("health-check", schedule.Every(cfg.HealthCheckInterval)), emailSender.ProbeHealth)
+	}
+
+	// Re-deliver messages interrupted by a previous crash
+	go func() {
+		if err := emailSender.ReplayOutbox(); err != nil {
+			log.Printf("Failed to replay outbox: %v", err)
+		}
+	}()
+
+	// Load named form definitions
+	forms := form.NewRegistry()
+	if cfg.FormsFile != "" {
+		var err error
+		forms, err = form.Load(cfg.FormsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := handler.CheckForms(cfg, forms); err != nil {
+		log.Fatal(err)
+	}
+
+	appMetrics := metrics.New()
+
+	// Break delivery latency down by provider and phase
+	emailSender.OnLatency(func(l email.Latency) {
+		appMetrics.ObserveDelivery(l.Provider, l.Phase, l.Duration, l.TraceID)
+	})
+	opts := handler.Options{Metrics: appMetrics}
+
+	// Detect repeated identical submissions
+	if cfg.DuplicateWindow > 0 {
+		opts.Duplicates = duplicate.New(cfg.DuplicateWindow)
+	}
+
+	// Answer retried requests with their original response
+	if cfg.ReplayWindow > 0 {
+		opts.Responses = duplicate.NewResponses(cfg.ReplayWindow)
+	}
+
+	// Cap submissions per email address and day
+	if cfg.EmailDailyLimit > 0 {
+		opts.EmailCap = ratelimit.NewDailyCap(cfg.EmailDailyLimit, cfg.Location)
+	}
+
+	// Throttle submissions per client IP
+	if cfg.IPRateLimit > 0 {
+		opts.IPRate = ratelimit.NewRate(cfg.IPRateLimit, cfg.IPRateBurst)
+	}
+
+	// Sign submissions so their content can later be proven unaltered
+	if cfg.ReceiptKey != "" {
+		signer, err := receipt.NewSigner(cfg.ReceiptKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Receipts = signer
+		log.Printf("Signing submission receipts, public key %s", signer.PublicKey())
+	}
+
+	// Enrich notifications with context about the submitter
+	if cfg.EnrichSender {
+		enricher := enrich.New(cfg.EnrichTimeout)
+		enricher.UseTransport(transport)
+		opts.Enricher = enricher
+	}
+
+	// Reject addresses at domains that take no mail
+	if cfg.EmailMXCheck {
+		opts.MX = address.NewChecker(cfg.EmailMXTimeout, cfg.EmailMXCacheTTL)
+	}
+
+	// Require a solved captcha with each submission
+	switch cfg.CaptchaProvider {
+	case config.CaptchaFriendly:
+		verifier := captcha.NewFriendlyCaptcha(cfg.CaptchaSecret, cfg.CaptchaSiteKey, cfg.CaptchaEU, cfg.CaptchaTimeout)
+		verifier.UseTransport(transport)
+		opts.Captcha = verifier
+	case config.CaptchaReCAPTCHA:
+		verifier := captcha.NewReCAPTCHA(cfg.CaptchaSecret, cfg.RecaptchaMinScore, cfg.RecaptchaAction, cfg.CaptchaTimeout)
+		verifier.UseTransport(transport)
+		opts.Captcha = verifier
+	case config.CaptchaHCaptcha:
+		verifier := captcha.NewHCaptcha(cfg.CaptchaSecret, cfg.CaptchaSiteKey, cfg.CaptchaTimeout)
+		verifier.UseTransport(transport)
+		opts.Captcha = verifier
+	case config.CaptchaTurnstile:
+		verifier := captcha.NewTurnstile(cfg.CaptchaSecret, cfg.CaptchaTimeout)
+		verifier.UseTransport(transport)
+		opts.Captcha = verifier
+	}
+	if opts.Captcha != nil && cfg.CaptchaReplayWindow > 0 {
+		opts.Captcha = captcha.NoReplay(opts.Captcha, cfg.CaptchaReplayWindow)
+	}
+
+	// Drop submissions from known spam sources
+	if len(cfg.BlockedIPs) > 0 {
+		blocklist, err := spam.ParseBlocklist(cfg.BlockedIPs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Blocklist = blocklist
+	}
+
+	// Score submissions by keywords typical of spam in their language
+	if cfg.SpamKeywordsFile != "" {
+		keywords, err := spam.LoadKeywords(cfg.SpamKeywordsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Keywords = keywords
+	}
+
+	// Check links in submissions against a URL reputation service
+	if cfg.ScanProvider == config.ScanSafeBrowsing {
+		scanner := scan.NewSafeBrowsing(cfg.ScanAPIKey, cfg.ScanTimeout)
+		scanner.UseTransport(transport)
+		opts.Scanner = scanner
+	}
+
+	// Mail the owner a daily digest per form
+	if cfg.DailySummary {
+		covered := forms.IDs()
+		if cfg.FormsFile == "" {
+			covered = []string{""}
+		}
+		opts.Summary = summary.NewTracker(cfg.Location, covered...)
+		scheduler.Add("daily-summary", scheduleOf("daily-summary", schedule.Daily(cfg.DailySummaryHour, cfg.Location)), func(context.Context) error {
+			return opts.Summary.Send(emailSender, cfg.RecipientEmail)
+		})
+	}
+
+	// Post submissions to the webhooks of their form
+	opts.Forwarder = forward.New(cfg.WebhookTimeout, cfg.DryRun)
+	opts.Forwarder.UseTransport(transport)
+	if cfg.SlackWebhookURL != "" {
+		slack := notify.NewSlack(cfg.SlackWebhookURL, cfg.DryRun)
+		slack.UseTransport(transport)
+		opts.Channels = append(opts.Channels, slack)
+	}
+	if bot != nil {
+		opts.Channels = append(opts.Channels, notify.Telegram(bot))
+	}
+	opts.Forwarder.UseRetries(cfg.WebhookRetryAttempts, cfg.WebhookRetryDelay)
+	if len(cfg.WebhookURLs) > 0 {
+		hooks := make([]form.Webhook, 0, len(cfg.WebhookURLs))
+		for _, u := range cfg.WebhookURLs {
+			hooks = append(hooks, form.Webhook{URL: u, Secret: cfg.WebhookSecret})
+		}
+		opts.Forwarder.UseWebhooks(hooks)
+	}
+
+	// Count usage per tenant for quotas and billing
+	if len(forms.Tenants()) > 0 {
+		tracker, err := usage.NewTracker(cfg.Location, cfg.UsageFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Usage = tracker
+	}
+
+	// Hold notifications during forms' quiet hours
+	if forms.HasQuietHours() {
+		opts.Quiet = quiet.NewQueue()
+		scheduler.Add("quiet-hours", scheduleOf("quiet-hours", schedule.Every(time.Minute)), opts.Quiet.ReleaseDue)
+	}
+
+	// Store uploads and link to them from notifications
+	if cfg.UploadDir != "" {
+		uploads, err := upload.Open(cfg.UploadDir, []byte(cfg.UploadSecret))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if cfg.UploadProcessImages {
+			uploads.ProcessImages(upload.ImageOptions{MaxDimension: cfg.UploadImageMaxSize, Quality: cfg.UploadImageQuality})
+		}
+		opts.Uploads = uploads
+		scheduler.Add("prune-uploads", scheduleOf("prune-uploads", schedule.Every(time.Hour)), func(context.Context) error {
+			return uploads.PruneExpired(cfg.UploadRetention)
+		})
+
+		// Put uploads routed to S3 by the forms' upload rules
+		if cfg.S3AccessKeyID != "" {
+			endpoint := cfg.S3Endpoint
+			if endpoint == "" {
+				endpoint = "https://s3." + cfg.S3Region + ".amazonaws.com"
+			}
+			opts.S3, err = upload.NewS3(endpoint, cfg.S3Region, cfg.S3AccessKeyID, cfg.S3SecretAccessKey, cfg.S3Timeout)
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts.S3.UseTransport(transport)
+		}
+	}
+
+	// Shorten the links in notifications
+	if cfg.ShortLinkDir != "" {
+		links, err := shortlink.Open(cfg.ShortLinkDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Links = links
+		scheduler.Add("prune-short-links", scheduleOf("prune-short-links", schedule.Every(time.Hour)), links.PruneExpired)
+	}
+
+	// Keep submissions for the feed, in a database to outlive restarts
+	switch {
+	case cfg.DatabaseURL != "":
+		db, err := storage.OpenSQL(context.Background(), cfg.DatabaseURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+		opts.Store = db
+	case cfg.Storage == config.StorageMemory:
+		opts.Store = storage.NewMemory(cfg.StorageMaxEntries)
+	}
+	if opts.Store != nil && cfg.StorageRetention > 0 {
+		scheduler.Add("purge-submissions", scheduleOf("purge-submissions", schedule.Every(time.Hour)), func(ctx context.Context) error {
+			return storage.PurgeExpired(ctx, opts.Store, cfg.StorageRetention)
+		})
+	}
+	if opts.Store != nil && cfg.TrashRetention > 0 {
+		scheduler.Add("empty-trash", scheduleOf("empty-trash", schedule.Every(time.Hour)), func(ctx context.Context) error {
+			return storage.EmptyTrash(ctx, opts.Store, cfg.TrashRetention)
+		})
+	}
 
 	// Initialize handler
-	contactHandler := handler.NewContactHandler(emailSender, cfg.CORSOrigin)
+	contactHandler := handler.NewContactHandler(emailSender, cfg, forms, opts)
+
+	// Hold again the notifications held for quiet hours before a restart
+	if cfg.DatabaseURL != "" {
+		go func() {
+			n, err := contactHandler.RearmHeld(context.Background())
+			if err != nil {
+				log.Printf("Failed to re-arm held notifications: %v", err)
+			} else if n > 0 {
+				log.Printf("Re-armed %d notification(s) held for quiet hours", n)
+			}
+		}()
+	}
+
+	// Reload configuration files when they change, e.g. mounted ConfigMaps
+	watcher := reload.NewWatcher()
+	if cfg.FormsFile != "" {
+		watcher.Add("forms file", cfg.FormsFile, func() error {
+			next, err := form.Load(cfg.FormsFile)
+			if err != nil {
+				return err
+			}
+			return contactHandler.ReloadForms(next)
+		})
+	}
 
 	// Register routes
 	http.Handle("/contact", contactHandler)
+	http.Handle("/forms/{formID}", contactHandler)
+	// Server-rendered forms for visitors without JavaScript
+	http.Handle("/f/{formID}", handler.NewFallbackFormHandler(contactHandler))
+
+	// Expose Prometheus metrics
+	if cfg.MetricsEnabled {
+		http.Handle("GET /metrics", appMetrics.Handler())
+	}
+
+	// Serve Atom feeds of recent submissions
+	if opts.Store != nil {
+		feedHandler := admin.Compress(admin.ETag(handler.NewFeedHandler(opts.Store, forms, cfg)))
+		http.Handle("GET /feed", feedHandler)
+		http.Handle("GET /feed/{formID}", feedHandler)
+	}
+
+	// Delivery state of a submission by its reference, for clients to poll
+	if opts.Store != nil {
+		http.Handle("GET /v1/submissions/{referenceID}/status", handler.NewDeliveryStatusHandler(opts.Store))
+	}
+
+	// Public aggregate counts for a trust widget
+	if cfg.PublicStats && opts.Store != nil {
+		statsHandler := handler.NewStatsHandler(opts.Store, forms, cfg.Location, cfg.PublicStatsTTL)
+		http.Handle("GET /stats", statsHandler)
+		http.Handle("GET /stats/{formID}", statsHandler)
+	}
+
+	// Download links for uploaded files
+	if opts.Uploads != nil {
+		http.Handle("GET /uploads/{id}", handler.NewUploadHandler(opts.Uploads))
+	}
+
+	// Short links in notifications
+	if opts.Links != nil {
+		http.Handle("GET /s/{code}", handler.NewShortLinkHandler(opts.Links))
+	}
+
+	// Lock out clients that keep guessing the admin token
+	var adminLockout *ratelimit.Lockout
+	if cfg.AdminLockoutThreshold > 0 {
+		adminLockout = ratelimit.NewLockout(cfg.AdminLockoutThreshold, cfg.AdminLockoutDuration, cfg.AdminLockoutMax)
+	}
+	adminAuth := admin.NewGuard(cfg.AdminToken, adminLockout, cfg.TrustedProxies())
+
+	// Maintenance endpoints
+	if cfg.AdminToken != "" {
+		drainHandler := admin.NewDrainHandler(contactHandler)
+		http.Handle("POST /admin/drain", adminAuth.Require(http.HandlerFunc(drainHandler.Drain)))
+		http.Handle("GET /admin/drain", adminAuth.Require(http.HandlerFunc(drainHandler.Status)))
+		http.Handle("POST /admin/resume", adminAuth.Require(http.HandlerFunc(drainHandler.Resume)))
+		http.Handle("GET /admin/status", adminAuth.Require(admin.NewStatusHandler(emailSender, contactHandler, scheduler)))
+		if opts.Usage != nil {
+			http.Handle("GET /admin/usage", adminAuth.Require(admin.NewUsageHandler(opts.Usage, forms)))
+		}
+		formsHandler := admin.NewFormsHandler(forms, contactHandler, cfg.FormsFile)
+		http.Handle("GET /admin/forms", adminAuth.Require(http.HandlerFunc(formsHandler.Export)))
+		http.Handle("POST /admin/forms", adminAuth.Require(http.HandlerFunc(formsHandler.Import)))
+		if opts.Store != nil {
+			backupHandler := admin.NewBackupHandler(opts.Store, formsHandler)
+			http.Handle("GET /admin/backup", adminAuth.Require(admin.Compress(http.HandlerFunc(backupHandler.Backup))))
+			http.Handle("POST /admin/restore", adminAuth.Require(http.HandlerFunc(backupHandler.Restore)))
+		}
+		http.Handle("POST /admin/credentials/reload", adminAuth.Require(admin.NewCredentialsHandler(emailSender, userFile, secretFile)))
+		if opts.Links != nil {
+			http.Handle("DELETE /admin/links/{code}", adminAuth.Require(admin.NewLinksHandler(opts.Links)))
+		}
+		if deadLetters != nil {
+			deadLettersHandler := admin.NewDeadLettersHandler(deadLetters, emailSender)
+			http.Handle("GET /admin/dead-letters", adminAuth.Require(http.HandlerFunc(deadLettersHandler.List)))
+			http.Handle("GET /admin/dead-letters/{id}", adminAuth.Require(http.HandlerFunc(deadLettersHandler.Get)))
+			http.Handle("POST /admin/dead-letters/{id}/resend", adminAuth.Require(http.HandlerFunc(deadLettersHandler.Resend)))
+			http.Handle("DELETE /admin/dead-letters/{id}", adminAuth.Require(http.HandlerFunc(deadLettersHandler.Delete)))
+		}
+	}
+
+	// Admin API over stored submissions
+	if cfg.AdminToken != "" && opts.Store != nil {
+		graphqlHandler, err := admin.NewGraphQLHandler(opts.Store, cfg.Location, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		http.Handle("POST /admin/graphql", adminAuth.Require(admin.Compress(graphqlHandler)))
+		submissionsHandler := admin.NewSubmissionsHandler(opts.Store, contactHandler, cfg.TrashRetention > 0)
+		http.Handle("GET /admin/submissions", adminAuth.Require(admin.Compress(http.HandlerFunc(submissionsHandler.List))))
+		http.Handle("GET /admin/submissions/{id}", adminAuth.Require(http.HandlerFunc(submissionsHandler.Get)))
+		http.Handle("POST /admin/submissions/{id}/handled", adminAuth.Require(http.HandlerFunc(submissionsHandler.MarkHandled)))
+		http.Handle("DELETE /admin/submissions/{id}/handled", adminAuth.Require(http.HandlerFunc(submissionsHandler.Reopen)))
+		http.Handle("POST /admin/submissions/{id}/resend", adminAuth.Require(http.HandlerFunc(submissionsHandler.Resend)))
+		http.Handle("DELETE /admin/submissions/{id}", adminAuth.Require(http.HandlerFunc(submissionsHandler.Delete)))
+		http.Handle("GET /admin/submissions/{id}/pdf", adminAuth.Require(admin.NewPDFHandler(opts.Store, cfg.Location)))
+		bulkHandler := admin.NewBulkHandler(opts.Store, contactHandler, cfg.TrashRetention > 0)
+		http.Handle("POST /admin/submissions/delete", adminAuth.Require(http.HandlerFunc(bulkHandler.Delete)))
+		http.Handle("POST /admin/trash/restore", adminAuth.Require(http.HandlerFunc(bulkHandler.Restore)))
+		http.Handle("POST /admin/submissions/resend", adminAuth.Require(http.HandlerFunc(bulkHandler.Resend)))
+		http.Handle("POST /admin/submissions/export", adminAuth.Require(admin.Compress(http.HandlerFunc(bulkHandler.Export))))
+		if opts.Receipts != nil {
+			http.Handle("GET /admin/submissions/{id}/receipt", adminAuth.Require(admin.NewReceiptHandler(opts.Store, opts.Receipts)))
+		}
+	}
+
+	// Match replies to confirmations back to their submissions
+	if cfg.InboundToken != "" && opts.Store != nil {
+		http.Handle("POST /inbound/reply", handler.NewInboundHandler(opts.Store, cfg.InboundToken))
+		http.Handle("POST /inbound/dsn", handler.NewDSNHandler(opts.Store, cfg.InboundToken))
+	}
+
+	// Bridge inbound webhooks from third-party services to email
+	if cfg.WebhooksFile != "" {
+		endpoints, err := bridge.Load(cfg.WebhooksFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		http.Handle("/webhook/{id}", handler.NewWebhookHandler(emailSender, endpoints, cfg.RecipientEmail))
+		watcher.Add("webhooks file", cfg.WebhooksFile, func() error {
+			next, err := bridge.Load(cfg.WebhooksFile)
+			if err != nil {
+				return err
+			}
+			endpoints.Replace(next)
+			return nil
+		})
+	}
+
+	// Serve the site containing the form from the same process
+	if cfg.StaticDir != "" {
+		http.Handle("/", handler.NewStaticHandler(cfg.StaticDir))
+	}
+
+	if watcher.Len() > 0 && cfg.ConfigReloadInterval > 0 {
+		scheduler.Add("config-reload", scheduleOf("config-reload", schedule.Every(cfg.ConfigReloadInterval)), func(context.Context) error {
+			watcher.Check()
+			return nil
+		})
+	}
+
+	// Scheduled jobs stop with the server
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	scheduler.Start(ctx)
 
 	// Start server
-	log.Printf("Server starting on port %s...", cfg.ServerPort)
-	if err := http.ListenAndServe(":"+cfg.ServerPort, nil); err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: ":" + cfg.ServerPort, Handler: handler.LogRequests(http.DefaultServeMux, logger, cfg.TrustedProxies())}
+	go func() {
+		logger.Info("Server starting", "port", cfg.ServerPort)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM, stop accepting connections, then let open
+	// requests and background deliveries finish within SHUTDOWN_TIMEOUT.
+	// A second signal kills the process right away.
+	<-ctx.Done()
+	stop()
+	logger.Info("Shutting down, waiting for submissions in flight", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Failed to wait for open requests", "error", err)
+	}
+	if n := emailSender.FlushDeferred(shutdownCtx); n > 0 {
+		logger.Info("Retried greylisted messages before shutting down", "count", n)
+	}
+	if err := contactHandler.Drain(shutdownCtx); err != nil {
+		logger.Warn("Shutting down with submissions still awaiting delivery", "queue_depth", contactHandler.QueueDepth())
 	}
+	logger.Info("Server stopped")
 }
@@ -0,0 +1,172 @@
+// Package address checks that submitters gave an email address that can
+// receive mail: one written as RFC 5322 allows and, optionally, one whose
+// domain publishes where to deliver it.
+package address
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Valid reports whether addr is a bare address, without display name or
+// angle brackets, that mail can be sent to over the internet: RFC 5322
+// syntax within the length limits of RFC 5321, and a domain name with at
+// least two labels rather than an IP literal.
+func Valid(addr string) bool {
+	if len(addr) > 254 {
+		return false
+	}
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil || parsed.Name != "" || parsed.Address != addr {
+		return false
+	}
+	at := strings.LastIndex(addr, "@")
+	if at > 64 {
+		return false
+	}
+	labels := strings.Split(addr[at+1:], ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if !validLabel(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// validLabel reports whether label is a DNS host name label. Non-ASCII
+// letters are let through for internationalized domains.
+func validLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if r != '-' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r < 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// maxCached bounds the domains a Checker remembers, so submissions from
+// made-up domains cannot grow the cache without end.
+const maxCached = 10000
+
+// Checker looks up whether domains accept mail, caching the answers.
+type Checker struct {
+	resolver *net.Resolver
+	timeout  time.Duration
+	ttl      time.Duration
+	clock    clock.Clock
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	accepts bool
+	expires time.Time
+}
+
+// NewChecker returns a Checker giving up on a lookup after timeout and
+// remembering each answer for ttl. Lookups go through net.DefaultResolver,
+// so DNS_SERVERS applies.
+func NewChecker(timeout, ttl time.Duration) *Checker {
+	return &Checker{
+		resolver: net.DefaultResolver,
+		timeout:  timeout,
+		ttl:      ttl,
+		clock:    clock.System,
+		cache:    make(map[string]cached),
+	}
+}
+
+// UseClock makes c expire its answers by the time of clk instead of the
+// system clock. It must be called before c is used.
+func (c *Checker) UseClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Accepts reports whether the domain of addr accepts mail: it has MX
+// records other than the null MX of RFC 7505 or, lacking any, an address
+// record mail falls back to. An error means the lookup failed, e.g. timed
+// out, and the answer is unknown; it is not cached.
+func (c *Checker) Accepts(ctx context.Context, addr string) (bool, error) {
+	domain := strings.ToLower(addr[strings.LastIndex(addr, "@")+1:])
+
+	c.mu.Lock()
+	entry, ok := c.cache[domain]
+	if ok && c.clock.Now().After(entry.expires) {
+		delete(c.cache, domain)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok {
+		return entry.accepts, nil
+	}
+
+	accepts, err := c.lookup(ctx, domain)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	if len(c.cache) >= maxCached {
+		now := c.clock.Now()
+		for d, e := range c.cache {
+			if now.After(e.expires) {
+				delete(c.cache, d)
+			}
+		}
+		if len(c.cache) >= maxCached {
+			clear(c.cache)
+		}
+	}
+	c.cache[domain] = cached{accepts: accepts, expires: c.clock.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return accepts, nil
+}
+
+func (c *Checker) lookup(ctx context.Context, domain string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	mxs, err := c.resolver.LookupMX(ctx, domain)
+	if err == nil && len(mxs) > 0 {
+		for _, mx := range mxs {
+			if mx.Host != "." && mx.Host != "" {
+				return true, nil
+			}
+		}
+		// Only a null MX: the domain declares it takes no mail
+		return false, nil
+	}
+	if err != nil && !notFound(err) {
+		return false, err
+	}
+
+	// No MX records: mail goes to the domain's own address, if any
+	addrs, err := c.resolver.LookupIPAddr(ctx, domain)
+	if notFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(addrs) > 0, nil
+}
+
+// notFound reports whether err says the domain or its records do not exist,
+// rather than that the lookup failed.
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
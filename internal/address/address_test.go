@@ -0,0 +1,122 @@
+package address
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"ada@example.com", true},
+		{"ada.lovelace+forms@mail.example.co.uk", true},
+		{"o'brien@example.com", true},
+		{`"ada lovelace"@example.com`, false},
+		{"ada@müller.example", true},
+		{"ada@xn--mller-kva.example", true},
+		{"ada@example", false},
+		{"ada@localhost", false},
+		{"ada@[192.0.2.1]", false},
+		{"ada@192.0.2.1", true},
+		{"ada@-example.com", false},
+		{"ada@example-.com", false},
+		{"ada@exa_mple.com", false},
+		{"ada@example..com", false},
+		{"ada@", false},
+		{"@example.com", false},
+		{"ada", false},
+		{"", false},
+		{"Ada <ada@example.com>", false},
+		{"<ada@example.com>", false},
+		{"ada@example.com ", false},
+		{"ada@example.com\r\nBcc: eve@example.com", false},
+		{strings.Repeat("a", 64) + "@example.com", true},
+		{strings.Repeat("a", 65) + "@example.com", false},
+		{"ada@" + strings.Repeat("a", 63) + ".com", true},
+		{"ada@" + strings.Repeat("a", 64) + ".com", false},
+		{"ada@" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := Valid(tt.addr); got != tt.want {
+				t.Errorf("Valid(%q) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckerDoesNotCacheFailures(t *testing.T) {
+	lookups := 0
+	c := NewChecker(time.Second, time.Hour)
+	c.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			lookups++
+			return nil, errors.New("resolver down")
+		},
+	}
+	for range 2 {
+		if _, err := c.Accepts(context.Background(), "ada@example.com"); err == nil {
+			t.Fatal("Accepts succeeded without a resolver")
+		}
+	}
+	if lookups < 2 {
+		t.Errorf("failed lookup was answered from the cache")
+	}
+	if len(c.cache) != 0 {
+		t.Errorf("cache holds %v", c.cache)
+	}
+}
+
+func TestCheckerCaches(t *testing.T) {
+	now := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	c := NewChecker(time.Second, time.Hour)
+	c.UseClock(now)
+	c.cache["example.com"] = cached{accepts: true, expires: now.Now().Add(time.Hour)}
+	c.cache["expired.example"] = cached{accepts: true, expires: now.Now().Add(-time.Second)}
+	c.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("resolver down")
+		},
+	}
+	if ok, err := c.Accepts(context.Background(), "ada@EXAMPLE.com"); !ok || err != nil {
+		t.Errorf("cached domain: Accepts = %v, %v", ok, err)
+	}
+	if _, err := c.Accepts(context.Background(), "ada@expired.example"); err == nil {
+		t.Error("expired answer was used")
+	}
+	now.Advance(time.Hour + time.Second)
+	if _, err := c.Accepts(context.Background(), "ada@example.com"); err == nil {
+		t.Error("answer was used after its TTL")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no such host", &net.DNSError{Err: "no such host", IsNotFound: true}, true},
+		{"wrapped", errors.Join(errors.New("lookup"), &net.DNSError{IsNotFound: true}), true},
+		{"timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, false},
+		{"other", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notFound(tt.err); got != tt.want {
+				t.Errorf("notFound = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
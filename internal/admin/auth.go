@@ -0,0 +1,94 @@
+// Package admin provides the authenticated administration endpoints.
+package admin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"form2mail/internal/handler"
+	"form2mail/internal/logging"
+	"form2mail/internal/ratelimit"
+)
+
+// Guard only lets requests through that carry the admin token as a bearer
+// token. With a lockout, clients that keep presenting wrong tokens are
+// refused for a growing time, and every failure is written to the audit
+// log.
+type Guard struct {
+	token   string
+	lockout *ratelimit.Lockout
+	proxies int
+}
+
+// NewGuard checks requests against token. lockout may be nil to disable
+// lockouts; proxies is the number of trusted reverse proxies the client IP
+// is taken from X-Forwarded-For past, as for handler.ClientIP.
+func NewGuard(token string, lockout *ratelimit.Lockout, proxies int) *Guard {
+	return &Guard{token: token, lockout: lockout, proxies: proxies}
+}
+
+// Require wraps next so only authenticated requests reach it.
+func (g *Guard) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		ip := handler.ClientIP(r, g.proxies)
+
+		// Failures count per IP and per presented token, so neither
+		// guessing from one address nor replaying one token from many
+		// gets far
+		keys := []string{"ip:" + ip}
+		if ok {
+			sum := sha256.Sum256([]byte(got))
+			keys = append(keys, "token:"+hex.EncodeToString(sum[:8]))
+		}
+		if g.lockout != nil {
+			for _, key := range keys {
+				if locked, wait := g.lockout.Locked(key); locked {
+					w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+					http.Error(w, "Too many failed attempts", http.StatusTooManyRequests)
+					return
+				}
+			}
+		}
+
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(g.token)) != 1 {
+			g.fail(r, keys)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="form2mail admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if g.lockout != nil {
+			if n := g.lockout.Reset(keys[0]); n > 0 {
+				logging.FromContext(r.Context()).Info("[audit] Admin authentication succeeded after failures", "failures", n)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fail records a failed attempt in the audit log and counts it toward the
+// lockout of keys, the first of which is the client IP's.
+func (g *Guard) fail(r *http.Request, keys []string) {
+	logger := logging.FromContext(r.Context()).With("method", r.Method, "path", r.URL.Path)
+	if g.lockout == nil {
+		logger.Warn("[audit] Admin authentication failed")
+		return
+	}
+	for i, key := range keys {
+		failures, lockout := g.lockout.Fail(key)
+		switch {
+		case i == 0:
+			logger.Warn("[audit] Admin authentication failed", "failures", failures)
+			if lockout > 0 {
+				logger.Warn("[audit] Locking out client from the admin API", "lockout", lockout.String())
+			}
+		case lockout > 0:
+			logger.Warn("[audit] Locking out token from the admin API", "lockout", lockout.String())
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/handler"
+	"form2mail/internal/ratelimit"
+)
+
+func TestGuardAuditsToRequestLogger(t *testing.T) {
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	guard := NewGuard("secret", ratelimit.NewLockout(2, time.Minute, time.Hour), 0)
+	srv := handler.LogRequests(guard.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})), logger, 0)
+
+	tests := []struct {
+		token  string
+		status int
+		logged string
+	}{
+		{"wrong", http.StatusUnauthorized, `msg="[audit] Admin authentication failed" request_id=r1 client_ip=192.0.2.1 method=GET path=/admin/status failures=1`},
+		{"secret", http.StatusOK, `msg="[audit] Admin authentication succeeded after failures" request_id=r2 client_ip=192.0.2.1 failures=1`},
+		{"wrong", http.StatusUnauthorized, `failures=1`},
+		{"wrong again", http.StatusUnauthorized, `msg="[audit] Locking out client from the admin API" request_id=r4 client_ip=192.0.2.1 method=GET path=/admin/status lockout=1m0s`},
+		{"secret", http.StatusTooManyRequests, ""},
+	}
+	for i, tt := range tests {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		req.Header.Set("X-Request-ID", "r"+string(rune('1'+i)))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("request %d: status %d, want %d", i+1, rec.Code, tt.status)
+		}
+		if tt.logged != "" && !strings.Contains(logs.String(), tt.logged) {
+			t.Errorf("request %d logged:\n%s\nwant a line with %s", i+1, logs.String(), tt.logged)
+		}
+		if tt.logged == "" && strings.Contains(logs.String(), "[audit]") {
+			t.Errorf("request %d logged:\n%s\nwant no audit line", i+1, logs.String())
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"form2mail/internal/backup"
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// maxBackup limits the size of archives accepted for restore.
+const maxBackup = 256 << 20
+
+// BackupHandler takes and restores snapshots of the stored submissions and
+// form definitions for the backup and restore commands, which encrypt
+// them on the operator's side:
+//
+//	GET  /admin/backup   take a snapshot
+//	POST /admin/restore  restore a snapshot
+type BackupHandler struct {
+	store storage.Store
+	forms *FormsHandler
+}
+
+// NewBackupHandler restores forms through forms, like an import.
+func NewBackupHandler(store storage.Store, forms *FormsHandler) *BackupHandler {
+	return &BackupHandler{store: store, forms: forms}
+}
+
+type restoreResult struct {
+	Status    string `json:"status"`
+	Restored  int    `json:"restored"`
+	Skipped   int    `json:"skipped"`
+	Forms     int    `json:"forms"`
+	Tenants   int    `json:"tenants"`
+	Persisted bool   `json:"persisted"`
+}
+
+// Backup handles GET /admin/backup. Submissions in the trash are included,
+// so they can still be restored from the trash after a restore.
+func (h *BackupHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	submissions, err := h.store.List(r.Context(), storage.Filter{AnyForm: true})
+	var trashed []storage.Submission
+	if err == nil {
+		trashed, err = h.store.List(r.Context(), storage.Filter{AnyForm: true, Trash: true})
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list submissions for backup", "error", err)
+		http.Error(w, "Failed to list submissions", http.StatusInternalServerError)
+		return
+	}
+	submissions = append(submissions, trashed...)
+	slices.SortStableFunc(submissions, func(a, b storage.Submission) int {
+		return a.ReceivedAt.Compare(b.ReceivedAt)
+	})
+	doc, err := h.forms.forms.Document()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	archive := backup.Archive{CreatedAt: time.Now().UTC(), Submissions: submissions, Forms: doc}
+	if archive.Submissions == nil {
+		archive.Submissions = []storage.Submission{}
+	}
+	logging.FromContext(r.Context()).Info("Backing up", "submissions", len(submissions), "forms", len(doc.Forms))
+	writeJSON(w, http.StatusOK, archive)
+}
+
+// Restore handles POST /admin/restore. Submissions whose IDs are already
+// stored are skipped, so a restore can be repeated; the forms of the
+// archive replace the current ones unless it has none. With
+// ?dry_run=true the archive is only validated.
+func (h *BackupHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	var archive backup.Archive
+	if !decodeRequest(w, r, &archive, maxBackup) {
+		return
+	}
+
+	var next *form.Registry
+	if len(archive.Forms.Forms) > 0 || len(archive.Forms.Tenants) > 0 {
+		data, err := json.Marshal(archive.Forms)
+		if err == nil {
+			next, err = form.Parse(data)
+		}
+		if err == nil {
+			err = h.forms.reloader.ValidateForms(next)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	result := restoreResult{Status: "valid"}
+	if next != nil {
+		result.Forms, result.Tenants = len(next.IDs()), len(next.Tenants())
+	}
+	for _, sub := range archive.Submissions {
+		_, err := h.store.Get(r.Context(), sub.ID)
+		switch {
+		case err == nil:
+			result.Skipped++
+			continue
+		case !errors.Is(err, storage.ErrNotFound):
+			http.Error(w, "Failed to look up submissions", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("dry_run") == "true" {
+			result.Restored++
+			continue
+		}
+		if err := h.store.Save(r.Context(), sub); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to restore submission", "submission", sub.ID, "error", err)
+			http.Error(w, "Failed to restore submissions", http.StatusInternalServerError)
+			return
+		}
+		result.Restored++
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	if next != nil {
+		persisted, err := h.forms.apply(next)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Persisted = persisted
+	}
+	logging.FromContext(r.Context()).Info("Restored from backup", "submissions", result.Restored, "skipped", result.Skipped, "forms", result.Forms)
+	result.Status = "restored"
+	writeJSON(w, http.StatusOK, result)
+}
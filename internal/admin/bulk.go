@@ -0,0 +1,266 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// maxBulkRequest limits the size of bulk request bodies, which for exports
+// hold up to maxExportIDs IDs.
+const (
+	maxBulkRequest = 1 << 20
+	maxExportIDs   = 10000
+)
+
+// Resender delivers the notification of a stored submission again and
+// records the outcome.
+type Resender interface {
+	Resend(sub storage.Submission) error
+}
+
+// BulkHandler applies an operation to many stored submissions at once, to
+// clean up after a provider outage without scripting one call per
+// submission:
+//
+//	POST /admin/submissions/delete  move the submissions matching a filter to the trash
+//	POST /admin/submissions/resend  resend the failed notifications matching a filter
+//	POST /admin/submissions/export  export the submissions with the given IDs
+//	POST /admin/trash/restore       take the submissions matching a filter out of the trash
+//
+// Delete, resend, and restore take ?dry_run=true to only count the matches.
+type BulkHandler struct {
+	store    storage.Store
+	resender Resender
+	// trash is unset when TRASH_RETENTION is 0, making deletions permanent
+	trash bool
+	clock clock.Clock
+}
+
+func NewBulkHandler(store storage.Store, resender Resender, trash bool) *BulkHandler {
+	return &BulkHandler{store: store, resender: resender, trash: trash, clock: clock.System}
+}
+
+// UseClock makes h trash submissions at the time of c instead of the
+// system clock.
+func (h *BulkHandler) UseClock(c clock.Clock) {
+	h.clock = c
+}
+
+// bulkFilter is the JSON form of storage.Filter. A missing form_id matches
+// every form, since "" is the default form's ID.
+type bulkFilter struct {
+	FormID     *string        `json:"form_id"`
+	Email      string         `json:"email"`
+	ClientIP   string         `json:"client_ip"`
+	Tag        string         `json:"tag"`
+	AssignedTo string         `json:"assigned_to"`
+	Handled    *bool          `json:"handled"`
+	Status     storage.Status `json:"status"`
+	Since      time.Time      `json:"since"`
+	Until      time.Time      `json:"until"`
+}
+
+func (f bulkFilter) filter() storage.Filter {
+	filter := storage.Filter{
+		AnyForm:    f.FormID == nil,
+		Email:      f.Email,
+		ClientIP:   f.ClientIP,
+		Tag:        f.Tag,
+		AssignedTo: f.AssignedTo,
+		Handled:    f.Handled,
+		Status:     f.Status,
+		Since:      f.Since,
+		Until:      f.Until,
+	}
+	if f.FormID != nil {
+		filter.FormID = *f.FormID
+	}
+	return filter
+}
+
+type resendFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+type resendResult struct {
+	Matched int             `json:"matched"`
+	Resent  int             `json:"resent"`
+	Failed  []resendFailure `json:"failed"`
+}
+
+// Delete handles POST /admin/submissions/delete. Submissions go to the
+// trash, from which Restore brings them back until the empty-trash job
+// deletes them; ?permanent=true deletes them right away. The filter must
+// have at least one condition, so a mistaken empty request cannot delete
+// everything.
+func (h *BulkHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	var f bulkFilter
+	if !decodeRequest(w, r, &f, maxBulkRequest) {
+		return
+	}
+	if f == (bulkFilter{}) {
+		http.Error(w, "Filter must have at least one condition", http.StatusBadRequest)
+		return
+	}
+	filter := f.filter()
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		matches, err := h.store.List(r.Context(), filter)
+		if err != nil {
+			http.Error(w, "Failed to list submissions", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "valid", "matched": len(matches)})
+		return
+	}
+	if h.trash && r.URL.Query().Get("permanent") != "true" {
+		n, err := h.store.Trash(r.Context(), filter, h.clock.Now())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to move submissions to the trash", "error", err)
+			http.Error(w, "Failed to delete submissions", http.StatusInternalServerError)
+			return
+		}
+		logging.FromContext(r.Context()).Info("Moved submissions to the trash through the admin API", "count", n)
+		writeJSON(w, http.StatusOK, map[string]any{"status": "trashed", "trashed": n})
+		return
+	}
+	n, err := h.store.Delete(r.Context(), filter)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete submissions", "error", err)
+		http.Error(w, "Failed to delete submissions", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Deleted submissions through the admin API", "count", n)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "deleted", "deleted": n})
+}
+
+// Restore handles POST /admin/trash/restore. The filter selects from the
+// trash; an empty one restores everything in it.
+func (h *BulkHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	var f bulkFilter
+	if !decodeRequest(w, r, &f, maxBulkRequest) {
+		return
+	}
+	filter := f.filter()
+	filter.Trash = true
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		matches, err := h.store.List(r.Context(), filter)
+		if err != nil {
+			http.Error(w, "Failed to list submissions", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "valid", "matched": len(matches)})
+		return
+	}
+	n, err := h.store.Untrash(r.Context(), filter)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to restore submissions from the trash", "error", err)
+		http.Error(w, "Failed to restore submissions", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Restored submissions from the trash through the admin API", "count", n)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "restored", "restored": n})
+}
+
+// Resend handles POST /admin/submissions/resend. Only failed
+// notifications are resent, oldest first, one at a time so the provider
+// is not flooded the moment it recovers; the response lists those that
+// failed again.
+func (h *BulkHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	var f bulkFilter
+	if !decodeRequest(w, r, &f, maxBulkRequest) {
+		return
+	}
+	if f.Status != "" && f.Status != storage.StatusFailed {
+		http.Error(w, "Only failed submissions can be resent", http.StatusBadRequest)
+		return
+	}
+	f.Status = storage.StatusFailed
+	matches, err := h.store.List(r.Context(), f.filter())
+	if err != nil {
+		http.Error(w, "Failed to list submissions", http.StatusInternalServerError)
+		return
+	}
+
+	result := resendResult{Matched: len(matches), Failed: []resendFailure{}}
+	if r.URL.Query().Get("dry_run") == "true" {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		sub := matches[i]
+		if err := h.resender.Resend(sub); err != nil {
+			result.Failed = append(result.Failed, resendFailure{ID: sub.ID, Error: err.Error()})
+			continue
+		}
+		result.Resent++
+	}
+	logging.FromContext(r.Context()).Info("Resent failed notifications through the admin API", "resent", result.Resent, "matched", result.Matched)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Export handles POST /admin/submissions/export with {"ids": [...]}. IDs
+// that do not exist are listed as missing.
+func (h *BulkHandler) Export(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if !decodeRequest(w, r, &req, maxBulkRequest) {
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxExportIDs {
+		http.Error(w, fmt.Sprintf("ids must list 1 to %d submissions", maxExportIDs), http.StatusBadRequest)
+		return
+	}
+
+	export := struct {
+		Submissions []storage.Submission `json:"submissions"`
+		Missing     []string             `json:"missing"`
+	}{Submissions: []storage.Submission{}, Missing: []string{}}
+	seen := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		sub, err := h.store.Get(r.Context(), id)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			export.Missing = append(export.Missing, id)
+		case err != nil:
+			http.Error(w, "Failed to load submissions", http.StatusInternalServerError)
+			return
+		default:
+			export.Submissions = append(export.Submissions, sub)
+		}
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="submissions.json"`)
+	writeJSON(w, http.StatusOK, export)
+}
+
+// decodeRequest reads a JSON request body of up to limit bytes into v,
+// answering 400 or 413 if it cannot.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any, limit int64) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		return false
+	case err != nil:
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
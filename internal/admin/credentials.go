@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"form2mail/internal/email"
+)
+
+// CredentialsHandler rotates the mail provider's credentials at runtime via
+// POST /admin/credentials/reload. A JSON body {"user": ..., "password": ...}
+// for SMTP, or {"api_key": ...} for SendGrid and Mailgun, supplies new
+// credentials directly; an empty body re-reads the secret files.
+type CredentialsHandler struct {
+	sender     *email.Sender
+	userFile   string
+	secretFile string
+}
+
+// NewCredentialsHandler returns a handler that re-reads userFile and
+// secretFile, as returned by config.Config.CredentialFiles.
+func NewCredentialsHandler(sender *email.Sender, userFile, secretFile string) *CredentialsHandler {
+	return &CredentialsHandler{
+		sender:     sender,
+		userFile:   userFile,
+		secretFile: secretFile,
+	}
+}
+
+func (h *CredentialsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var creds email.Credentials
+	err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&creds)
+	switch {
+	case errors.Is(err, io.EOF):
+		if h.secretFile == "" {
+			http.Error(w, "No credentials given and neither SMTP_PASSWORD_FILE nor MAIL_API_KEY_FILE is set", http.StatusBadRequest)
+			return
+		}
+		creds, err = h.sender.ReadCredentialFiles(h.userFile, h.secretFile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case err != nil:
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.sender.RotateCredentials(creds); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "rotated"})
+}
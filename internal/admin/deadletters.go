@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"form2mail/internal/deadletter"
+)
+
+// DeadLetterResender delivers a dead letter again.
+type DeadLetterResender interface {
+	ResendDeadLetter(id string) error
+}
+
+// DeadLettersHandler serves the messages that could not be delivered:
+//
+//	GET    /admin/dead-letters              list them, without their content
+//	GET    /admin/dead-letters/{id}         download one as message/rfc822
+//	POST   /admin/dead-letters/{id}/resend  deliver one again
+//	DELETE /admin/dead-letters/{id}         discard one
+type DeadLettersHandler struct {
+	store    *deadletter.Store
+	resender DeadLetterResender
+}
+
+func NewDeadLettersHandler(store *deadletter.Store, resender DeadLetterResender) *DeadLettersHandler {
+	return &DeadLettersHandler{store: store, resender: resender}
+}
+
+type deadLetterSummary struct {
+	ID        string    `json:"id"`
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+	Providers []string  `json:"providers"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
+	Resends   int       `json:"resends"`
+	Size      int       `json:"size"`
+}
+
+// List handles GET /admin/dead-letters.
+func (h *DeadLettersHandler) List(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.store.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summaries := make([]deadLetterSummary, 0, len(entries))
+	for _, e := range entries {
+		summaries = append(summaries, deadLetterSummary{
+			ID:        e.ID,
+			To:        e.To,
+			Subject:   subjectOf(e.Message),
+			Providers: e.Providers,
+			Error:     e.Error,
+			FailedAt:  e.FailedAt,
+			Resends:   e.Resends,
+			Size:      len(e.Message),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dead_letters": summaries})
+}
+
+// Get handles GET /admin/dead-letters/{id}.
+func (h *DeadLettersHandler) Get(w http.ResponseWriter, r *http.Request) {
+	e, err := h.store.Get(r.PathValue("id"))
+	if errors.Is(err, deadletter.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "message/rfc822")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.ID + ".eml"}))
+	w.Write(e.Message)
+}
+
+// Resend handles POST /admin/dead-letters/{id}/resend. A message that
+// fails again answers 502 and stays in the store.
+func (h *DeadLettersHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	err := h.resender.ResendDeadLetter(r.PathValue("id"))
+	if errors.Is(err, deadletter.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+}
+
+// Delete handles DELETE /admin/dead-letters/{id}.
+func (h *DeadLettersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	err := h.store.Remove(r.PathValue("id"))
+	if errors.Is(err, deadletter.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// subjectOf returns the decoded Subject of msg, or "" if it has none.
+func subjectOf(msg []byte) string {
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		return ""
+	}
+	subject := parsed.Header.Get("Subject")
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		return decoded
+	}
+	return subject
+}
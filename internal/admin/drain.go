@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// defaultDrainWait is how long POST /admin/drain waits without ?wait=.
+const defaultDrainWait = 30 * time.Second
+
+// Drainer stops and resumes intake of new submissions.
+type Drainer interface {
+	Drain(ctx context.Context) error
+	Resume()
+	Draining() bool
+	QueueDepth() int
+}
+
+// DrainHandler serves the maintenance endpoints:
+//
+//	POST /admin/drain   stop accepting submissions and wait for the queue to empty
+//	POST /admin/resume  accept submissions again
+//	GET  /admin/drain   report the current state
+type DrainHandler struct {
+	drainer Drainer
+}
+
+func NewDrainHandler(drainer Drainer) *DrainHandler {
+	return &DrainHandler{drainer: drainer}
+}
+
+type drainStatus struct {
+	Status     string `json:"status"`
+	QueueDepth int    `json:"queue_depth"`
+}
+
+// Drain handles POST /admin/drain. It answers 200 once the queue is empty,
+// or 202 if it is still draining after the wait time (?wait=30s).
+func (h *DrainHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	wait := defaultDrainWait
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "Invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		wait = d
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	err := h.drainer.Drain(ctx)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, drainStatus{Status: "drained"})
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeJSON(w, http.StatusAccepted, drainStatus{Status: "draining", QueueDepth: h.drainer.QueueDepth()})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Resume handles POST /admin/resume.
+func (h *DrainHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.drainer.Resume()
+	writeJSON(w, http.StatusOK, drainStatus{Status: "accepting", QueueDepth: h.drainer.QueueDepth()})
+}
+
+// Status handles GET /admin/drain.
+func (h *DrainHandler) Status(w http.ResponseWriter, r *http.Request) {
+	status := "accepting"
+	if h.drainer.Draining() {
+		status = "draining"
+		if h.drainer.QueueDepth() == 0 {
+			status = "drained"
+		}
+	}
+	writeJSON(w, http.StatusOK, drainStatus{Status: status, QueueDepth: h.drainer.QueueDepth()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"form2mail/internal/form"
+)
+
+// maxFormsDocument limits the size of imported form documents.
+const maxFormsDocument = 1 << 20
+
+// FormsReloader checks and applies new form definitions.
+type FormsReloader interface {
+	ValidateForms(next *form.Registry) error
+	ReloadForms(next *form.Registry) error
+}
+
+// FormsHandler exports and imports form definitions as forms file
+// documents, to copy them between instances:
+//
+//	GET  /admin/forms  export all forms and tenants, or ?id=... with their tenants
+//	POST /admin/forms  replace all forms, or only those in the document with ?mode=merge
+//
+// Imports are written to the forms file, if there is one, so they survive
+// a restart.
+type FormsHandler struct {
+	forms    *form.Registry
+	reloader FormsReloader
+	path     string
+}
+
+// NewFormsHandler serves forms and applies imports through reloader. path
+// is the forms file; "" keeps imports in memory.
+func NewFormsHandler(forms *form.Registry, reloader FormsReloader, path string) *FormsHandler {
+	return &FormsHandler{forms: forms, reloader: reloader, path: path}
+}
+
+type importResult struct {
+	Status    string `json:"status"`
+	Forms     int    `json:"forms"`
+	Tenants   int    `json:"tenants"`
+	Persisted bool   `json:"persisted"`
+}
+
+// Export handles GET /admin/forms.
+func (h *FormsHandler) Export(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.forms.Document(r.URL.Query()["id"]...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to encode forms", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="forms.json"`)
+	w.Write(append(data, '\n'))
+}
+
+// Import handles POST /admin/forms. With ?dry_run=true the document is only
+// validated.
+func (h *FormsHandler) Import(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFormsDocument))
+	if err != nil {
+		http.Error(w, "Document too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var next *form.Registry
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "replace":
+		next, err = form.Parse(data)
+	case "merge":
+		var doc form.Document
+		if err = json.Unmarshal(data, &doc); err == nil {
+			next, err = h.forms.Merge(doc)
+		}
+	default:
+		http.Error(w, fmt.Sprintf("Unknown mode %q", mode), http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		err = h.reloader.ValidateForms(next)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	result := importResult{Status: "valid", Forms: len(next.IDs()), Tenants: len(next.Tenants())}
+	if r.URL.Query().Get("dry_run") == "true" {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	if result.Persisted, err = h.apply(next); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result.Status = "imported"
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apply writes validated definitions to the forms file, if there is one,
+// and swaps them in. The file is written first, so a failure leaves
+// everything as it was.
+func (h *FormsHandler) apply(next *form.Registry) (persisted bool, err error) {
+	if h.path != "" {
+		if err := h.write(next); err != nil {
+			return false, err
+		}
+		persisted = true
+	}
+	return persisted, h.reloader.ReloadForms(next)
+}
+
+// write replaces the forms file with the definitions of forms, atomically
+// so the file watcher never reads a partial file.
+func (h *FormsHandler) write(forms *form.Registry) error {
+	doc, err := forms.Document()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode forms: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".forms-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write forms file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write forms file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write forms file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("failed to write forms file: %w", err)
+	}
+	return nil
+}
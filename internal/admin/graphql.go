@@ -0,0 +1,489 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/storage"
+)
+
+// maxPageSize caps the number of submissions returned per query.
+const maxPageSize = 100
+
+const schema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+scalar Time
+
+type Query {
+	submission(id: ID!): Submission
+	# formId "" selects the default /contact form; omit it to match all forms.
+	# status is one of pending, held, delivered, failed.
+	# trashed: true selects deleted submissions that are still in the trash.
+	submissions(formId: String, email: String, tag: String, assignedTo: String, handled: Boolean, status: String, since: Time, until: Time, trashed: Boolean = false, first: Int = 20, offset: Int = 0): SubmissionPage!
+	stats(formId: String, tag: String, assignedTo: String, handled: Boolean, status: String, since: Time, until: Time, trashed: Boolean = false): Stats!
+}
+
+# Mutations return the updated submission, or null if it does not exist.
+type Mutation {
+	addTags(id: ID!, tags: [String!]!): Submission
+	removeTags(id: ID!, tags: [String!]!): Submission
+	# An empty or missing assignee unassigns the submission.
+	assign(id: ID!, to: String): Submission
+	setHandled(id: ID!, handled: Boolean!): Submission
+}
+
+type Submission {
+	id: ID!
+	formId: String!
+	name: String!
+	email: String!
+	subject: String!
+	message: String!
+	clientIp: String!
+	userAgent: String!
+	receivedAt: Time!
+	source: [SourceField!]!
+	replies: [Reply!]!
+	tags: [String!]!
+	assignedTo: String
+	handled: Boolean!
+	handledAt: Time
+	status: String
+	# failure tells why the notification failed, if it did.
+	failure: DeliveryFailure
+	# deletedAt is when the submission was moved to the trash.
+	deletedAt: Time
+	# deliveryReports are the delivery status notifications returned for
+	# the notification, with DSN_NOTIFY.
+	deliveryReports: [DeliveryReport!]!
+}
+
+type DeliveryReport {
+	recipient: String!
+	# action is one of failed, delayed, delivered, relayed, expanded.
+	action: String!
+	status: String!
+	diagnostic: String!
+	receivedAt: Time!
+}
+
+type DeliveryFailure {
+	error: String!
+	failedAt: Time!
+	# transcript is the SMTP dialogue, if SMTP_TRANSCRIPTS is enabled.
+	transcript: [String!]!
+}
+
+type Reply {
+	from: String!
+	subject: String!
+	text: String!
+	receivedAt: Time!
+}
+
+type SourceField {
+	key: String!
+	value: String!
+}
+
+type SubmissionPage {
+	totalCount: Int!
+	hasMore: Boolean!
+	items: [Submission!]!
+}
+
+type Stats {
+	total: Int!
+	byForm: [FormCount!]!
+	byDay: [DayCount!]!
+}
+
+type FormCount {
+	formId: String!
+	count: Int!
+}
+
+type DayCount {
+	date: String!
+	count: Int!
+}
+`
+
+// NewGraphQLHandler returns an HTTP handler serving the GraphQL API over
+// store. Days in aggregations follow loc, and submissions are marked
+// handled at the time of c; nil means the system clock.
+func NewGraphQLHandler(store storage.Store, loc *time.Location, c clock.Clock) (http.Handler, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	if c == nil {
+		c = clock.System
+	}
+	s, err := graphql.ParseSchema(schema, &rootResolver{store: store, loc: loc, clock: c}, graphql.UseFieldResolvers())
+	if err != nil {
+		return nil, err
+	}
+	return &relay.Handler{Schema: s}, nil
+}
+
+type rootResolver struct {
+	store storage.Store
+	loc   *time.Location
+	clock clock.Clock
+}
+
+type filterArgs struct {
+	FormID     *string
+	Email      *string
+	Tag        *string
+	AssignedTo *string
+	Handled    *bool
+	Status     *string
+	Since      *graphql.Time
+	Until      *graphql.Time
+	// Trashed has a default in the schema, so it is never null
+	Trashed bool
+}
+
+func (a filterArgs) filter() storage.Filter {
+	f := storage.Filter{AnyForm: a.FormID == nil}
+	if a.FormID != nil {
+		f.FormID = *a.FormID
+	}
+	if a.Email != nil {
+		f.Email = *a.Email
+	}
+	if a.Tag != nil {
+		f.Tag = *a.Tag
+	}
+	if a.AssignedTo != nil {
+		f.AssignedTo = *a.AssignedTo
+	}
+	f.Handled = a.Handled
+	if a.Status != nil {
+		f.Status = storage.Status(*a.Status)
+	}
+	if a.Since != nil {
+		f.Since = a.Since.Time
+	}
+	if a.Until != nil {
+		f.Until = a.Until.Time
+	}
+	f.Trash = a.Trashed
+	return f
+}
+
+func (r *rootResolver) Submission(ctx context.Context, args struct{ ID graphql.ID }) (*submissionResolver, error) {
+	sub, err := r.store.Get(ctx, string(args.ID))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &submissionResolver{sub: sub}, nil
+}
+
+func (r *rootResolver) Submissions(ctx context.Context, args struct {
+	filterArgs
+	First  int32
+	Offset int32
+}) (*pageResolver, error) {
+	filter := args.filter()
+	total, err := r.store.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	first := min(max(int(args.First), 0), maxPageSize)
+	// One past the page tells whether there is more
+	filter.Limit, filter.Offset = first+1, max(int(args.Offset), 0)
+	subs, err := r.store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &pageResolver{TotalCount: int32(total), HasMore: len(subs) > first}
+	for _, sub := range subs[:min(first, len(subs))] {
+		page.Items = append(page.Items, &submissionResolver{sub: sub})
+	}
+	return page, nil
+}
+
+func (r *rootResolver) Stats(ctx context.Context, args filterArgs) (*statsResolver, error) {
+	return &statsResolver{store: r.store, filter: args.filter(), loc: r.loc}, nil
+}
+
+// update applies a tracking change and resolves the updated submission.
+func (r *rootResolver) update(ctx context.Context, id graphql.ID, fn func(*storage.Tracking)) (*submissionResolver, error) {
+	sub, err := r.store.UpdateTracking(ctx, string(id), fn)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &submissionResolver{sub: sub}, nil
+}
+
+type tagsArgs struct {
+	ID   graphql.ID
+	Tags []string
+}
+
+func (r *rootResolver) AddTags(ctx context.Context, args tagsArgs) (*submissionResolver, error) {
+	return r.update(ctx, args.ID, func(t *storage.Tracking) {
+		for _, tag := range args.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" && !t.HasTag(tag) {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	})
+}
+
+func (r *rootResolver) RemoveTags(ctx context.Context, args tagsArgs) (*submissionResolver, error) {
+	return r.update(ctx, args.ID, func(t *storage.Tracking) {
+		t.Tags = slices.DeleteFunc(t.Tags, func(have string) bool {
+			return slices.ContainsFunc(args.Tags, func(tag string) bool {
+				return strings.EqualFold(strings.TrimSpace(tag), have)
+			})
+		})
+	})
+}
+
+func (r *rootResolver) Assign(ctx context.Context, args struct {
+	ID graphql.ID
+	To *string
+}) (*submissionResolver, error) {
+	return r.update(ctx, args.ID, func(t *storage.Tracking) {
+		t.AssignedTo = ""
+		if args.To != nil {
+			t.AssignedTo = strings.TrimSpace(*args.To)
+		}
+	})
+}
+
+func (r *rootResolver) SetHandled(ctx context.Context, args struct {
+	ID      graphql.ID
+	Handled bool
+}) (*submissionResolver, error) {
+	return r.update(ctx, args.ID, func(t *storage.Tracking) {
+		switch {
+		case !args.Handled:
+			t.HandledAt = time.Time{}
+		case !t.Handled():
+			// Keep the original time when marked handled twice
+			t.HandledAt = r.clock.Now()
+		}
+	})
+}
+
+type submissionResolver struct {
+	sub storage.Submission
+}
+
+func (s *submissionResolver) ID() graphql.ID    { return graphql.ID(s.sub.ID) }
+func (s *submissionResolver) FormID() string    { return s.sub.FormID }
+func (s *submissionResolver) Name() string      { return s.sub.Name }
+func (s *submissionResolver) Email() string     { return s.sub.Email }
+func (s *submissionResolver) Subject() string   { return s.sub.Subject }
+func (s *submissionResolver) Message() string   { return s.sub.Message }
+func (s *submissionResolver) ClientIP() string  { return s.sub.ClientIP }
+func (s *submissionResolver) UserAgent() string { return s.sub.UserAgent }
+func (s *submissionResolver) ReceivedAt() graphql.Time {
+	return graphql.Time{Time: s.sub.ReceivedAt}
+}
+
+func (s *submissionResolver) Source() []*sourceField {
+	fields := make([]*sourceField, 0, len(s.sub.Source))
+	for key, value := range s.sub.Source {
+		fields = append(fields, &sourceField{Key: key, Value: value})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	return fields
+}
+
+func (s *submissionResolver) Replies() []*replyResolver {
+	replies := make([]*replyResolver, len(s.sub.Replies))
+	for i, reply := range s.sub.Replies {
+		replies[i] = &replyResolver{reply: reply}
+	}
+	return replies
+}
+
+func (s *submissionResolver) Tags() []string {
+	if s.sub.Tags == nil {
+		return []string{}
+	}
+	return s.sub.Tags
+}
+
+func (s *submissionResolver) AssignedTo() *string {
+	if s.sub.AssignedTo == "" {
+		return nil
+	}
+	return &s.sub.AssignedTo
+}
+
+func (s *submissionResolver) Handled() bool { return s.sub.Handled() }
+
+func (s *submissionResolver) HandledAt() *graphql.Time {
+	if !s.sub.Handled() {
+		return nil
+	}
+	return &graphql.Time{Time: s.sub.HandledAt}
+}
+
+func (s *submissionResolver) Status() *string {
+	if s.sub.Status == "" {
+		return nil
+	}
+	status := string(s.sub.Status)
+	return &status
+}
+
+func (s *submissionResolver) DeletedAt() *graphql.Time {
+	if !s.sub.Trashed() {
+		return nil
+	}
+	return &graphql.Time{Time: s.sub.DeletedAt}
+}
+
+func (s *submissionResolver) Failure() *failureResolver {
+	if s.sub.Failure == nil {
+		return nil
+	}
+	return &failureResolver{failure: *s.sub.Failure}
+}
+
+type failureResolver struct {
+	failure storage.Failure
+}
+
+func (f *failureResolver) Error() string { return f.failure.Error }
+func (f *failureResolver) FailedAt() graphql.Time {
+	return graphql.Time{Time: f.failure.FailedAt}
+}
+func (f *failureResolver) Transcript() []string {
+	if f.failure.Transcript == nil {
+		return []string{}
+	}
+	return f.failure.Transcript
+}
+
+func (s *submissionResolver) DeliveryReports() []*deliveryReportResolver {
+	reports := make([]*deliveryReportResolver, len(s.sub.DeliveryReports))
+	for i, report := range s.sub.DeliveryReports {
+		reports[i] = &deliveryReportResolver{report: report}
+	}
+	return reports
+}
+
+type deliveryReportResolver struct {
+	report storage.DeliveryReport
+}
+
+func (d *deliveryReportResolver) Recipient() string  { return d.report.Recipient }
+func (d *deliveryReportResolver) Action() string     { return d.report.Action }
+func (d *deliveryReportResolver) Status() string     { return d.report.Status }
+func (d *deliveryReportResolver) Diagnostic() string { return d.report.Diagnostic }
+func (d *deliveryReportResolver) ReceivedAt() graphql.Time {
+	return graphql.Time{Time: d.report.ReceivedAt}
+}
+
+type replyResolver struct {
+	reply storage.Reply
+}
+
+func (r *replyResolver) From() string    { return r.reply.From }
+func (r *replyResolver) Subject() string { return r.reply.Subject }
+func (r *replyResolver) Text() string    { return r.reply.Text }
+func (r *replyResolver) ReceivedAt() graphql.Time {
+	return graphql.Time{Time: r.reply.ReceivedAt}
+}
+
+type sourceField struct {
+	Key   string
+	Value string
+}
+
+type pageResolver struct {
+	TotalCount int32
+	HasMore    bool
+	Items      []*submissionResolver
+}
+
+// statsResolver counts the total in the store; only the breakdowns, when
+// asked for, list the matching submissions.
+type statsResolver struct {
+	store  storage.Store
+	filter storage.Filter
+	loc    *time.Location
+
+	once   sync.Once
+	byForm []*formCount
+	byDay  []*dayCount
+	err    error
+}
+
+func (s *statsResolver) Total(ctx context.Context) (int32, error) {
+	n, err := s.store.Count(ctx, s.filter)
+	return int32(n), err
+}
+
+func (s *statsResolver) ByForm(ctx context.Context) ([]*formCount, error) {
+	s.once.Do(func() { s.err = s.tally(ctx) })
+	return s.byForm, s.err
+}
+
+func (s *statsResolver) ByDay(ctx context.Context) ([]*dayCount, error) {
+	s.once.Do(func() { s.err = s.tally(ctx) })
+	return s.byDay, s.err
+}
+
+func (s *statsResolver) tally(ctx context.Context) error {
+	subs, err := s.store.List(ctx, s.filter)
+	if err != nil {
+		return err
+	}
+
+	byForm := make(map[string]int32)
+	byDay := make(map[string]int32)
+	for _, sub := range subs {
+		byForm[sub.FormID]++
+		byDay[sub.ReceivedAt.In(s.loc).Format(time.DateOnly)]++
+	}
+
+	for formID, count := range byForm {
+		s.byForm = append(s.byForm, &formCount{FormID: formID, Count: count})
+	}
+	sort.Slice(s.byForm, func(i, j int) bool { return s.byForm[i].FormID < s.byForm[j].FormID })
+	for date, count := range byDay {
+		s.byDay = append(s.byDay, &dayCount{Date: date, Count: count})
+	}
+	sort.Slice(s.byDay, func(i, j int) bool { return s.byDay[i].Date < s.byDay[j].Date })
+	return nil
+}
+
+type formCount struct {
+	FormID string
+	Count  int32
+}
+
+type dayCount struct {
+	Date  string
+	Count int32
+}
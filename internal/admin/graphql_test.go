@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/storage"
+)
+
+func TestGraphQLHandler(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory(0)
+	received := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+	for _, sub := range []storage.Submission{
+		{ID: "a1", Name: "Ada", Email: "ada@example.com", ReceivedAt: received, Status: storage.StatusDelivered,
+			Source: map[string]string{"utm_source": "news", "page": "/pricing"}},
+		{ID: "b2", FormID: "support", Email: "bea@example.com", ReceivedAt: received.Add(time.Hour), Status: storage.StatusFailed,
+			Failure: &storage.Failure{Error: "550 no such user", FailedAt: received}},
+		{ID: "c3", FormID: "support", Email: "cid@example.com", ReceivedAt: received.Add(2 * time.Hour),
+			DeletedAt: received.Add(3 * time.Hour)},
+	} {
+		if err := store.Save(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	h, err := NewGraphQLHandler(store, berlin, clock.NewManual(received.Add(24*time.Hour)))
+	if err != nil {
+		t.Fatalf("schema does not match the resolvers: %v", err)
+	}
+
+	// Each query runs after the ones before it, so mutations carry over
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "submission",
+			query: `{ submission(id: "a1") { name email status tags assignedTo handled deletedAt source { key value } } }`,
+			want:  `{"submission":{"name":"Ada","email":"ada@example.com","status":"delivered","tags":[],"assignedTo":null,"handled":false,"deletedAt":null,"source":[{"key":"page","value":"/pricing"},{"key":"utm_source","value":"news"}]}}`,
+		},
+		{
+			name:  "missing submission",
+			query: `{ submission(id: "zz") { id } }`,
+			want:  `{"submission":null}`,
+		},
+		{
+			name:  "failure",
+			query: `{ submission(id: "b2") { status failure { error transcript } } }`,
+			want:  `{"submission":{"status":"failed","failure":{"error":"550 no such user","transcript":[]}}}`,
+		},
+		{
+			name:  "page",
+			query: `{ submissions(first: 1) { totalCount hasMore items { id } } }`,
+			want:  `{"submissions":{"totalCount":2,"hasMore":true,"items":[{"id":"b2"}]}}`,
+		},
+		{
+			name:  "offset",
+			query: `{ submissions(first: 1, offset: 1) { totalCount hasMore items { id } } }`,
+			want:  `{"submissions":{"totalCount":2,"hasMore":false,"items":[{"id":"a1"}]}}`,
+		},
+		{
+			name:  "default form",
+			query: `{ submissions(formId: "") { items { id } } }`,
+			want:  `{"submissions":{"items":[{"id":"a1"}]}}`,
+		},
+		{
+			name:  "trash",
+			query: `{ submissions(trashed: true) { items { id deletedAt } } }`,
+			want:  `{"submissions":{"items":[{"id":"c3","deletedAt":"2025-03-02T02:30:00Z"}]}}`,
+		},
+		{
+			name:  "stats by local day",
+			query: `{ stats { total byForm { formId count } byDay { date count } } }`,
+			want:  `{"stats":{"total":2,"byForm":[{"formId":"","count":1},{"formId":"support","count":1}],"byDay":[{"date":"2025-03-02","count":2}]}}`,
+		},
+		{
+			name:  "add tags",
+			query: `mutation { addTags(id: "a1", tags: ["Sales", " sales ", "", "urgent"]) { tags } }`,
+			want:  `{"addTags":{"tags":["Sales","urgent"]}}`,
+		},
+		{
+			name:  "remove tags",
+			query: `mutation { removeTags(id: "a1", tags: ["URGENT"]) { tags } }`,
+			want:  `{"removeTags":{"tags":["Sales"]}}`,
+		},
+		{
+			name:  "assign",
+			query: `mutation { assign(id: "a1", to: " Bob ") { assignedTo } }`,
+			want:  `{"assign":{"assignedTo":"Bob"}}`,
+		},
+		{
+			name:  "set handled",
+			query: `mutation { setHandled(id: "a1", handled: true) { handled handledAt } }`,
+			want:  `{"setHandled":{"handled":true,"handledAt":"2025-03-02T23:30:00Z"}}`,
+		},
+		{
+			name:  "filter by tracking",
+			query: `{ submissions(tag: "sales", assignedTo: "bob", handled: true) { items { id } } }`,
+			want:  `{"submissions":{"items":[{"id":"a1"}]}}`,
+		},
+		{
+			name:  "mutation of a missing submission",
+			query: `mutation { assign(id: "zz", to: "Bob") { id } }`,
+			want:  `{"assign":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(map[string]string{"query": tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/graphql", strings.NewReader(string(body))))
+
+			var resp struct {
+				Data   json.RawMessage `json:"data"`
+				Errors []any           `json:"errors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body, err)
+			}
+			if len(resp.Errors) > 0 {
+				t.Fatalf("errors: %v", resp.Errors)
+			}
+			if string(resp.Data) != tt.want {
+				t.Errorf("data = %s\nwant   %s", resp.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLPagesInStore(t *testing.T) {
+	store := newPagingStore(t)
+	h, err := NewGraphQLHandler(store, time.UTC, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		lists []storage.Filter
+	}{
+		{
+			name:  "page",
+			query: `{ submissions(first: 2, offset: 1) { totalCount hasMore items { id } } }`,
+			want:  `{"submissions":{"totalCount":5,"hasMore":true,"items":[{"id":"s4"},{"id":"s3"}]}}`,
+			lists: []storage.Filter{{AnyForm: true, Limit: 3, Offset: 1}},
+		},
+		{
+			name:  "last page",
+			query: `{ submissions(first: 2, offset: 3) { hasMore items { id } } }`,
+			want:  `{"submissions":{"hasMore":false,"items":[{"id":"s2"},{"id":"s1"}]}}`,
+			lists: []storage.Filter{{AnyForm: true, Limit: 3, Offset: 3}},
+		},
+		{
+			name:  "total only",
+			query: `{ stats { total } }`,
+			want:  `{"stats":{"total":5}}`,
+		},
+		{
+			name:  "breakdowns",
+			query: `{ stats { byForm { count } byDay { count } } }`,
+			want:  `{"stats":{"byForm":[{"count":5}],"byDay":[{"count":5}]}}`,
+			lists: []storage.Filter{{AnyForm: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(map[string]string{"query": tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/graphql", strings.NewReader(string(body))))
+
+			var resp struct {
+				Data   json.RawMessage `json:"data"`
+				Errors []any           `json:"errors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body, err)
+			}
+			if len(resp.Errors) > 0 {
+				t.Fatalf("errors: %v", resp.Errors)
+			}
+			if string(resp.Data) != tt.want {
+				t.Errorf("data = %s\nwant   %s", resp.Data, tt.want)
+			}
+			if lists := store.listed(); !reflect.DeepEqual(lists, tt.lists) {
+				t.Errorf("listed with %+v, want %+v", lists, tt.lists)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"form2mail/internal/shortlink"
+)
+
+// LinksHandler serves DELETE /admin/links/{code}, which revokes a short
+// link, e.g. one in a notification that was forwarded by mistake.
+type LinksHandler struct {
+	links *shortlink.Store
+}
+
+func NewLinksHandler(links *shortlink.Store) *LinksHandler {
+	return &LinksHandler{links: links}
+}
+
+func (h *LinksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := h.links.Revoke(r.PathValue("code"))
+	if errors.Is(err, shortlink.ErrNotFound) {
+		http.Error(w, "Short link not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"form2mail/internal/pdf"
+	"form2mail/internal/storage"
+)
+
+// PDFHandler serves GET /admin/submissions/{id}/pdf: a stored submission
+// rendered as a PDF for archival or forwarding.
+type PDFHandler struct {
+	store storage.Store
+	loc   *time.Location
+}
+
+// NewPDFHandler returns a PDFHandler over store. Times are shown in loc.
+func NewPDFHandler(store storage.Store, loc *time.Location) *PDFHandler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &PDFHandler{store: store, loc: loc}
+}
+
+func (h *PDFHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="submission-%s.pdf"`, sub.ID))
+	w.Write(h.render(sub))
+}
+
+func (h *PDFHandler) render(sub storage.Submission) []byte {
+	doc := pdf.New("Exported " + h.format(time.Now()) + " by form2mail")
+
+	title := "Submission"
+	if sub.Subject != "" {
+		title += ": " + sub.Subject
+	}
+	doc.Title(title)
+
+	doc.Heading("Submitter")
+	doc.Field("Name", sub.Name)
+	doc.Field("Email", sub.Email)
+	doc.Field("Subject", sub.Subject)
+
+	doc.Heading("Message")
+	doc.Paragraph(sub.Message)
+
+	if len(sub.Fields) > 0 {
+		doc.Heading("Fields")
+		for _, key := range sortedKeys(sub.Fields) {
+			doc.Field(key, sub.Fields[key])
+		}
+	}
+
+	doc.Heading("Details")
+	doc.Field("Submission ID", sub.ID)
+	doc.Field("Form", formName(sub.FormID))
+	doc.Field("Received", h.format(sub.ReceivedAt))
+	doc.Field("Client IP", sub.ClientIP)
+	if sub.Status != "" {
+		doc.Field("Delivery", string(sub.Status))
+	}
+	doc.Field("User agent", sub.UserAgent)
+	for _, key := range sortedKeys(sub.Source) {
+		doc.Field(key, sub.Source[key])
+	}
+	if sub.Receipt != nil {
+		doc.Field("Signature", "ed25519="+sub.Receipt.Signature)
+	}
+
+	if len(sub.Tags) > 0 || sub.AssignedTo != "" || sub.Handled() {
+		doc.Heading("Tracking")
+		if len(sub.Tags) > 0 {
+			doc.Field("Tags", strings.Join(sub.Tags, ", "))
+		}
+		if sub.AssignedTo != "" {
+			doc.Field("Assigned to", sub.AssignedTo)
+		}
+		if sub.Handled() {
+			doc.Field("Handled", h.format(sub.HandledAt))
+		}
+	}
+
+	doc.Heading("Timeline")
+	for _, event := range h.timeline(sub) {
+		doc.Field(h.format(event.at), event.text)
+	}
+
+	for i, reply := range sub.Replies {
+		doc.Heading(fmt.Sprintf("Reply %d", i+1))
+		doc.Field("From", reply.From)
+		doc.Field("Subject", reply.Subject)
+		doc.Field("Received", h.format(reply.ReceivedAt))
+		doc.Paragraph(reply.Text)
+	}
+
+	return doc.Bytes()
+}
+
+type event struct {
+	at   time.Time
+	text string
+}
+
+// timeline lists what happened to the submission, oldest first.
+func (h *PDFHandler) timeline(sub storage.Submission) []event {
+	events := []event{{sub.ReceivedAt, "Received from " + sub.ClientIP}}
+	for _, reply := range sub.Replies {
+		events = append(events, event{reply.ReceivedAt, "Reply from " + reply.From})
+	}
+	if sub.Handled() {
+		events = append(events, event{sub.HandledAt, "Marked handled"})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+	return events
+}
+
+func (h *PDFHandler) format(t time.Time) string {
+	return t.In(h.loc).Format("2006-01-02 15:04:05 MST")
+}
+
+func formName(id string) string {
+	if id == "" {
+		return "default (/contact)"
+	}
+	return id
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
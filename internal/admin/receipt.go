@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"form2mail/internal/receipt"
+	"form2mail/internal/storage"
+)
+
+// ReceiptHandler serves GET /admin/submissions/{id}/receipt: the signed
+// receipt of a stored submission together with the key to verify it.
+type ReceiptHandler struct {
+	store  storage.Store
+	signer *receipt.Signer
+}
+
+// NewReceiptHandler returns a ReceiptHandler over store. The public key of
+// signer is included in every response.
+func NewReceiptHandler(store storage.Store, signer *receipt.Signer) *ReceiptHandler {
+	return &ReceiptHandler{store: store, signer: signer}
+}
+
+func (h *ReceiptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sub.Receipt == nil {
+		http.Error(w, "Submission has no receipt", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"algorithm":  "ed25519",
+		"payload":    sub.Receipt.Payload,
+		"signature":  sub.Receipt.Signature,
+		"public_key": h.signer.PublicKey(),
+	})
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"net/http"
+
+	"form2mail/internal/email"
+	"form2mail/internal/schedule"
+)
+
+// StatusHandler serves GET /admin/status: the health of each delivery
+// provider from the background probes, plus the intake state and the
+// background jobs. Pass ?probe=true to probe the providers before
+// answering.
+type StatusHandler struct {
+	sender    *email.Sender
+	drainer   Drainer
+	scheduler *schedule.Scheduler
+}
+
+func NewStatusHandler(sender *email.Sender, drainer Drainer, scheduler *schedule.Scheduler) *StatusHandler {
+	return &StatusHandler{sender: sender, drainer: drainer, scheduler: scheduler}
+}
+
+type status struct {
+	Status     string                 `json:"status"`
+	Accepting  bool                   `json:"accepting"`
+	QueueDepth int                    `json:"queue_depth"`
+	Providers  []email.ProviderHealth `json:"providers"`
+	Jobs       []schedule.Status      `json:"jobs"`
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	providers := h.sender.Health()
+	if r.URL.Query().Get("probe") == "true" {
+		providers = h.sender.CheckHealth()
+	}
+
+	// Degraded while any provider fails its probe, down when all do
+	healthy := 0
+	for _, p := range providers {
+		if p.Healthy {
+			healthy++
+		}
+	}
+	overall := "ok"
+	code := http.StatusOK
+	switch {
+	case healthy == 0 && len(providers) > 0:
+		overall = "down"
+		code = http.StatusServiceUnavailable
+	case healthy < len(providers):
+		overall = "degraded"
+	}
+
+	writeJSON(w, code, status{
+		Status:     overall,
+		Accepting:  !h.drainer.Draining(),
+		QueueDepth: h.drainer.QueueDepth(),
+		Providers:  providers,
+		Jobs:       h.scheduler.Jobs(),
+	})
+}
@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// defaultPageSize is the number of submissions listed when no limit is given.
+const defaultPageSize = 20
+
+// SubmissionsHandler serves the stored submissions one at a time, for
+// tools that would rather not speak GraphQL:
+//
+//	GET    /admin/submissions               list them, newest first
+//	GET    /admin/submissions/{id}          get one
+//	POST   /admin/submissions/{id}/handled  mark one handled
+//	DELETE /admin/submissions/{id}/handled  reopen one
+//	POST   /admin/submissions/{id}/resend   send its notification again
+//	DELETE /admin/submissions/{id}          move one to the trash
+type SubmissionsHandler struct {
+	store    storage.Store
+	resender Resender
+	// trash is unset when TRASH_RETENTION is 0, making deletions permanent
+	trash bool
+	clock clock.Clock
+}
+
+func NewSubmissionsHandler(store storage.Store, resender Resender, trash bool) *SubmissionsHandler {
+	return &SubmissionsHandler{store: store, resender: resender, trash: trash, clock: clock.System}
+}
+
+// UseClock makes h mark submissions handled and trash them at the time of
+// c instead of the system clock.
+func (h *SubmissionsHandler) UseClock(c clock.Clock) {
+	h.clock = c
+}
+
+// List handles GET /admin/submissions. It takes the filters of the bulk
+// operations as query parameters, plus trashed=true to list the trash, and
+// pages with limit (default 20, at most 100) and offset.
+func (h *SubmissionsHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, limit, offset, err := queryFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	total, err := h.store.Count(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to list submissions", http.StatusInternalServerError)
+		return
+	}
+	// One past the page tells whether there is more
+	filter.Limit, filter.Offset = limit+1, offset
+	subs, err := h.store.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to list submissions", http.StatusInternalServerError)
+		return
+	}
+
+	page := append([]storage.Submission{}, subs[:min(limit, len(subs))]...)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total":       total,
+		"has_more":    len(subs) > limit,
+		"submissions": page,
+	})
+}
+
+// Get handles GET /admin/submissions/{id}. Submissions in the trash are
+// served too; their deleted_at is set.
+func (h *SubmissionsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// MarkHandled handles POST /admin/submissions/{id}/handled. A submission
+// marked handled twice keeps the original time.
+func (h *SubmissionsHandler) MarkHandled(w http.ResponseWriter, r *http.Request) {
+	h.track(w, r, func(t *storage.Tracking) {
+		if !t.Handled() {
+			t.HandledAt = h.clock.Now()
+		}
+	})
+}
+
+// Reopen handles DELETE /admin/submissions/{id}/handled.
+func (h *SubmissionsHandler) Reopen(w http.ResponseWriter, r *http.Request) {
+	h.track(w, r, func(t *storage.Tracking) {
+		t.HandledAt = time.Time{}
+	})
+}
+
+// track applies update to the tracking state of the submission in the
+// request path and answers with the updated submission.
+func (h *SubmissionsHandler) track(w http.ResponseWriter, r *http.Request, update func(*storage.Tracking)) {
+	sub, err := h.store.UpdateTracking(r.Context(), r.PathValue("id"), update)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// Resend handles POST /admin/submissions/{id}/resend. Unlike the bulk
+// resend, it sends the notification whatever its status, e.g. for an owner
+// who deleted the email. A notification that fails again answers 502.
+func (h *SubmissionsHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.resender.Resend(sub); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Resent notification through the admin API", "submission", sub.ID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+}
+
+// Delete handles DELETE /admin/submissions/{id}. Like the bulk delete, it
+// moves the submission to the trash unless ?permanent=true is given; one
+// already in the trash is deleted for good.
+func (h *SubmissionsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter := storage.Filter{ID: sub.ID, AnyForm: true, Trash: sub.Trashed()}
+	logger := logging.FromContext(r.Context()).With("submission", sub.ID)
+
+	if h.trash && !sub.Trashed() && r.URL.Query().Get("permanent") != "true" {
+		if _, err := h.store.Trash(r.Context(), filter, h.clock.Now()); err != nil {
+			logger.Error("Failed to move submission to the trash", "error", err)
+			http.Error(w, "Failed to delete submission", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Moved submission to the trash through the admin API")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "trashed"})
+		return
+	}
+	if _, err := h.store.Delete(r.Context(), filter); err != nil {
+		logger.Error("Failed to delete submission", "error", err)
+		http.Error(w, "Failed to delete submission", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Deleted submission through the admin API")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// queryFilter reads a storage.Filter and the page bounds from the query
+// parameters of a list request. As in bulkFilter, a missing form_id matches
+// every form.
+func queryFilter(q url.Values) (filter storage.Filter, limit, offset int, err error) {
+	filter = storage.Filter{
+		AnyForm:    !q.Has("form_id"),
+		FormID:     q.Get("form_id"),
+		Email:      q.Get("email"),
+		ClientIP:   q.Get("client_ip"),
+		Tag:        q.Get("tag"),
+		AssignedTo: q.Get("assigned_to"),
+		Status:     storage.Status(q.Get("status")),
+		Trash:      q.Get("trashed") == "true",
+	}
+	if v := q.Get("handled"); v != "" {
+		handled, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, 0, 0, fmt.Errorf("handled must be true or false, got %q", v)
+		}
+		filter.Handled = &handled
+	}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, 0, 0, fmt.Errorf("%s must be an RFC 3339 time, got %q", name, v)
+			}
+			*t = parsed
+		}
+	}
+	limit = defaultPageSize
+	for name, n := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if v := q.Get(name); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 0 {
+				return filter, 0, 0, fmt.Errorf("%s must be a non-negative number, got %q", name, v)
+			}
+			*n = parsed
+		}
+	}
+	return filter, min(limit, maxPageSize), offset, nil
+}
@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"form2mail/internal/storage"
+)
+
+// pagingStore records the filters submissions are listed with.
+type pagingStore struct {
+	storage.Store
+	mu    sync.Mutex
+	lists []storage.Filter
+}
+
+func (s *pagingStore) List(ctx context.Context, filter storage.Filter) ([]storage.Submission, error) {
+	s.mu.Lock()
+	s.lists = append(s.lists, filter)
+	s.mu.Unlock()
+	return s.Store.List(ctx, filter)
+}
+
+func (s *pagingStore) listed() []storage.Filter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lists := s.lists
+	s.lists = nil
+	return lists
+}
+
+// newPagingStore holds the submissions s1 (oldest) to s5.
+func newPagingStore(t *testing.T) *pagingStore {
+	t.Helper()
+	store := &pagingStore{Store: storage.NewMemory(0)}
+	received := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		sub := storage.Submission{ID: "s" + string(rune('1'+i)), ReceivedAt: received.Add(time.Duration(i) * time.Hour)}
+		if err := store.Save(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestSubmissionsListPagesInStore(t *testing.T) {
+	store := newPagingStore(t)
+	h := NewSubmissionsHandler(store, nil, true)
+
+	tests := []struct {
+		query   string
+		total   int
+		hasMore bool
+		ids     []string
+		limit   int
+		offset  int
+	}{
+		{"", 5, false, []string{"s5", "s4", "s3", "s2", "s1"}, defaultPageSize + 1, 0},
+		{"limit=2", 5, true, []string{"s5", "s4"}, 3, 0},
+		{"limit=2&offset=2", 5, true, []string{"s3", "s2"}, 3, 2},
+		{"limit=2&offset=3", 5, false, []string{"s2", "s1"}, 3, 3},
+		{"limit=2&offset=9", 5, false, []string{}, 3, 9},
+		{"limit=0", 5, true, []string{}, 1, 0},
+		{"limit=500", 5, false, []string{"s5", "s4", "s3", "s2", "s1"}, maxPageSize + 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.List(w, httptest.NewRequest("GET", "/admin/submissions?"+tt.query, nil))
+
+			var resp struct {
+				Total       int                  `json:"total"`
+				HasMore     bool                 `json:"has_more"`
+				Submissions []storage.Submission `json:"submissions"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body, err)
+			}
+			var ids []string
+			for _, sub := range resp.Submissions {
+				ids = append(ids, sub.ID)
+			}
+			if resp.Total != tt.total || resp.HasMore != tt.hasMore || !slices.Equal(ids, tt.ids) || resp.Submissions == nil {
+				t.Errorf("got total %d, has_more %v, %v; want %d, %v, %v", resp.Total, resp.HasMore, ids, tt.total, tt.hasMore, tt.ids)
+			}
+			lists := store.listed()
+			if len(lists) != 1 || lists[0].Limit != tt.limit || lists[0].Offset != tt.offset {
+				t.Errorf("listed with %+v, want one page with limit %d and offset %d", lists, tt.limit, tt.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"form2mail/internal/form"
+	"form2mail/internal/usage"
+)
+
+// UsageHandler serves GET /admin/usage: each tenant's submissions and
+// emails in a month, with its quotas. Pass ?month=2006-01 for an earlier
+// month; the current one is the default.
+type UsageHandler struct {
+	tracker *usage.Tracker
+	forms   *form.Registry
+}
+
+// NewUsageHandler returns a UsageHandler reporting tracker's counts for the
+// tenants in forms.
+func NewUsageHandler(tracker *usage.Tracker, forms *form.Registry) *UsageHandler {
+	return &UsageHandler{tracker: tracker, forms: forms}
+}
+
+type tenantUsage struct {
+	Tenant             string `json:"tenant"`
+	Submissions        int    `json:"submissions"`
+	Emails             int    `json:"emails"`
+	MonthlySubmissions int    `json:"monthly_submissions"`
+	MonthlyEmails      int    `json:"monthly_emails"`
+	OverQuota          bool   `json:"over_quota"`
+}
+
+type usageReport struct {
+	Month   string        `json:"month"`
+	Tenants []tenantUsage `json:"tenants"`
+}
+
+func (h *UsageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = h.tracker.CurrentMonth()
+	} else if _, err := time.Parse("2006-01", month); err != nil {
+		http.Error(w, "month must be YYYY-MM", http.StatusBadRequest)
+		return
+	}
+
+	counts := h.tracker.Report(month)
+	report := usageReport{Month: month, Tenants: []tenantUsage{}}
+	for _, t := range h.forms.Tenants() {
+		c := counts[t.ID]
+		report.Tenants = append(report.Tenants, tenantUsage{
+			Tenant:             t.ID,
+			Submissions:        c.Submissions,
+			Emails:             c.Emails,
+			MonthlySubmissions: t.MonthlySubmissions,
+			MonthlyEmails:      t.MonthlyEmails,
+			OverQuota:          t.Exceeded(c.Submissions, c.Emails),
+		})
+	}
+	writeJSON(w, http.StatusOK, report)
+}
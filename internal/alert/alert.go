@@ -0,0 +1,91 @@
+// Package alert tells the operator when email delivery keeps failing.
+package alert
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Notifier delivers an alert over a channel independent of the mail
+// provider being monitored.
+type Notifier interface {
+	Notify(subject, text string) error
+}
+
+// Monitor counts consecutive delivery failures and alerts once they reach
+// the threshold, then again at most once per cooldown while failures
+// continue, and once more when deliveries recover.
+type Monitor struct {
+	threshold int
+	cooldown  time.Duration
+	notifiers []Notifier
+	clock     clock.Clock
+
+	mu        sync.Mutex
+	failures  int
+	alerted   bool
+	lastAlert time.Time
+}
+
+// NewMonitor returns a Monitor alerting through notifiers.
+func NewMonitor(threshold int, cooldown time.Duration, notifiers ...Notifier) *Monitor {
+	return &Monitor{
+		threshold: threshold,
+		cooldown:  cooldown,
+		notifiers: notifiers,
+		clock:     clock.System,
+	}
+}
+
+// UseClock makes m time its cooldown by c instead of the system clock. It
+// must be called before m is used.
+func (m *Monitor) UseClock(c clock.Clock) {
+	m.clock = c
+}
+
+// Record registers the outcome of a delivery; err is nil on success.
+func (m *Monitor) Record(err error) {
+	m.mu.Lock()
+	if err == nil {
+		recovered := m.alerted
+		failures := m.failures
+		m.failures = 0
+		m.alerted = false
+		m.mu.Unlock()
+
+		if recovered {
+			m.notify("form2mail: email delivery recovered",
+				fmt.Sprintf("Email delivery succeeded again after %d consecutive failures.", failures))
+		}
+		return
+	}
+
+	m.failures++
+	due := m.failures >= m.threshold && (!m.alerted || m.clock.Now().Sub(m.lastAlert) >= m.cooldown)
+	if due {
+		m.alerted = true
+		m.lastAlert = m.clock.Now()
+	}
+	failures := m.failures
+	m.mu.Unlock()
+
+	if due {
+		m.notify("form2mail: email delivery failing",
+			fmt.Sprintf("%d consecutive email deliveries have failed. Submissions may not be reaching the owner.\n\nLast error: %v", failures, err))
+	}
+}
+
+// notify sends in the background so a slow channel never delays requests.
+func (m *Monitor) notify(subject, text string) {
+	for _, n := range m.notifiers {
+		go func(n Notifier) {
+			if err := n.Notify(subject, text); err != nil {
+				log.Printf("Failed to send operator alert: %v", err)
+			}
+		}(n)
+	}
+}
@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+type alert struct{ subject, text string }
+
+// recorder is a Notifier collecting the alerts sent to it.
+type recorder chan alert
+
+func (r recorder) Notify(subject, text string) error {
+	r <- alert{subject, text}
+	return nil
+}
+
+// next returns the next alert, or fails t if none is sent.
+func (r recorder) next(t *testing.T) alert {
+	t.Helper()
+	select {
+	case a := <-r:
+		return a
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert sent")
+		return alert{}
+	}
+}
+
+// none fails t if an alert is waiting.
+func (r recorder) none(t *testing.T) {
+	t.Helper()
+	// notify sends in the background, so give it a moment
+	select {
+	case a := <-r:
+		t.Errorf("unexpected alert %q", a.subject)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	r := make(recorder, 10)
+	now := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := NewMonitor(3, time.Hour, r)
+	m.UseClock(now)
+	failed := errors.New("535 authentication failed")
+
+	m.Record(failed)
+	m.Record(failed)
+	r.none(t)
+	m.Record(failed)
+	a := r.next(t)
+	if a.subject != "form2mail: email delivery failing" || !strings.Contains(a.text, "3 consecutive") || !strings.Contains(a.text, "535 authentication failed") {
+		t.Errorf("alert %+v", a)
+	}
+
+	// Within the cooldown further failures stay quiet
+	now.Advance(59 * time.Minute)
+	m.Record(failed)
+	r.none(t)
+	now.Advance(time.Minute)
+	m.Record(failed)
+	if a := r.next(t); !strings.Contains(a.text, "5 consecutive") {
+		t.Errorf("repeated alert %+v", a)
+	}
+
+	m.Record(nil)
+	a = r.next(t)
+	if a.subject != "form2mail: email delivery recovered" || !strings.Contains(a.text, "after 5 consecutive failures") {
+		t.Errorf("recovery %+v", a)
+	}
+	m.Record(nil)
+	r.none(t)
+
+	// Failures below the threshold recover without a word
+	m.Record(failed)
+	m.Record(nil)
+	r.none(t)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type %s", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			http.Error(w, "no such hook", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookNotifier(srv.URL+"/hook", false).Notify("delivery failing", "3 failures"); err != nil {
+		t.Fatal(err)
+	}
+	if got["subject"] != "delivery failing" || got["text"] != "delivery failing\n3 failures" {
+		t.Errorf("payload %v", got)
+	}
+	if err := NewWebhookNotifier(srv.URL+"/gone", false).Notify("s", "t"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Notify = %v, want the 404", err)
+	}
+
+	got = nil
+	if err := NewWebhookNotifier(srv.URL+"/hook", true).Notify("s", "t"); err != nil || got != nil {
+		t.Errorf("dry run = %v, posted %v", err, got)
+	}
+}
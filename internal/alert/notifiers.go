@@ -0,0 +1,79 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"form2mail/internal/email"
+)
+
+// EmailNotifier mails alerts to the operator. The sender must use a
+// different SMTP server than the one being monitored.
+type EmailNotifier struct {
+	sender *email.Sender
+	to     string
+}
+
+func NewEmailNotifier(sender *email.Sender, to string) *EmailNotifier {
+	return &EmailNotifier{sender: sender, to: to}
+}
+
+func (n *EmailNotifier) Notify(subject, text string) error {
+	body := fmt.Sprintf("<html><body><p>%s</p></body></html>",
+		strings.ReplaceAll(html.EscapeString(text), "\n", "<br>"))
+	return n.sender.Send(n.to, subject, body)
+}
+
+// WebhookNotifier posts alerts as JSON. The "text" field makes the payload
+// compatible with Slack and Mattermost incoming webhooks.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+	dryRun bool
+}
+
+func NewWebhookNotifier(url string, dryRun bool) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		dryRun: dryRun,
+	}
+}
+
+// UseTransport makes n post alerts through rt instead of
+// http.DefaultTransport. It must be called before n is used.
+func (n *WebhookNotifier) UseTransport(rt http.RoundTripper) {
+	n.client.Transport = rt
+}
+
+func (n *WebhookNotifier) Notify(subject, text string) error {
+	payload, err := json.Marshal(map[string]string{
+		"subject": subject,
+		"text":    subject + "\n" + text,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	if n.dryRun {
+		log.Printf("[dry run] Would post alert to %s: %s", n.url, payload)
+		return nil
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package backup encrypts snapshots of an instance's stored submissions
+// and form definitions, for migrations and disaster recovery.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"form2mail/internal/form"
+	"form2mail/internal/storage"
+)
+
+// Archive is a snapshot of an instance, as served by GET /admin/backup.
+type Archive struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Submissions are ordered oldest first, so restoring them in order
+	// keeps the store's order.
+	Submissions []storage.Submission `json:"submissions"`
+	Forms       form.Document        `json:"forms"`
+}
+
+// magic starts every encrypted archive; its last byte is the format
+// version. It is authenticated along with the contents.
+var magic = []byte("F2MBAK\x00\x01")
+
+// ErrDecrypt is returned by Open for archives that were encrypted with a
+// different key or have been altered.
+var ErrDecrypt = errors.New("backup cannot be decrypted: wrong key or damaged archive")
+
+// ParseKey decodes a base64-encoded 32-byte key, e.g. from
+// openssl rand -base64 32.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("backup key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("backup key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Seal writes archive to w, gzipped and encrypted with AES-256-GCM under
+// key.
+func Seal(w io.Writer, key []byte, archive Archive) error {
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(zw).Encode(archive); err != nil {
+		return fmt.Errorf("failed to encode backup: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append(append([]byte(nil), magic...), nonce...)
+	out = aead.Seal(out, nonce, plain.Bytes(), magic)
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// Open reads an archive written by Seal.
+func Open(r io.Reader, key []byte) (Archive, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to read backup: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return Archive{}, err
+	}
+	if len(data) < len(magic) || !bytes.HasPrefix(data, magic[:len(magic)-1]) {
+		return Archive{}, errors.New("not a form2mail backup")
+	}
+	if data[len(magic)-1] != magic[len(magic)-1] {
+		return Archive{}, fmt.Errorf("unsupported backup format version %d", data[len(magic)-1])
+	}
+	data = data[len(magic):]
+	if len(data) < aead.NonceSize() {
+		return Archive{}, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], magic)
+	if err != nil {
+		return Archive{}, ErrDecrypt
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to decompress backup: %w", err)
+	}
+	var archive Archive
+	if err := json.NewDecoder(zr).Decode(&archive); err != nil {
+		return Archive{}, fmt.Errorf("failed to decode backup: %w", err)
+	}
+	return archive, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/form"
+	"form2mail/internal/storage"
+)
+
+var key = bytes.Repeat([]byte{7}, 32)
+
+func sealed(t *testing.T) ([]byte, Archive) {
+	t.Helper()
+	archive := Archive{
+		CreatedAt:   time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Submissions: []storage.Submission{{ID: "a1", Email: "ada@example.com", Message: "Hello"}, {ID: "b2"}},
+		Forms:       form.Document{Forms: []form.Definition{{ID: "support", Recipient: "help@example.com"}}},
+	}
+	var b bytes.Buffer
+	if err := Seal(&b, key, archive); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes(), archive
+}
+
+func TestSealOpen(t *testing.T) {
+	data, archive := sealed(t)
+	if bytes.Contains(data, []byte("ada@example.com")) {
+		t.Error("sealed archive contains the plain text")
+	}
+	got, err := Open(bytes.NewReader(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(archive.CreatedAt) || len(got.Submissions) != 2 || got.Submissions[0].Email != "ada@example.com" ||
+		len(got.Forms.Forms) != 1 || got.Forms.Forms[0].Recipient != "help@example.com" {
+		t.Errorf("Open = %+v", got)
+	}
+
+	again, _ := sealed(t)
+	if bytes.Equal(data, again) {
+		t.Error("two archives share a nonce")
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	data, _ := sealed(t)
+	flip := func(i int) []byte {
+		altered := bytes.Clone(data)
+		altered[i] ^= 1
+		return altered
+	}
+	tests := []struct {
+		name string
+		data []byte
+		key  []byte
+		err  error
+		msg  string
+	}{
+		{"wrong key", data, bytes.Repeat([]byte{8}, 32), ErrDecrypt, ""},
+		{"altered contents", flip(len(data) - 1), key, ErrDecrypt, ""},
+		{"altered nonce", flip(len(magic)), key, ErrDecrypt, ""},
+		{"truncated", data[:len(magic)+4], key, ErrDecrypt, ""},
+		{"not a backup", []byte("PK\x03\x04 zip file"), key, nil, "not a form2mail backup"},
+		{"empty", nil, key, nil, "not a form2mail backup"},
+		{"newer version", append(append([]byte(nil), magic[:len(magic)-1]...), append([]byte{2}, data[len(magic):]...)...), key, nil, "unsupported backup format version 2"},
+		{"invalid key", data, []byte("short"), nil, "invalid backup key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(bytes.NewReader(tt.data), tt.key)
+			switch {
+			case err == nil:
+				t.Fatal("Open succeeded")
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Errorf("Open = %v, want %v", err, tt.err)
+			case tt.msg != "" && !strings.Contains(err.Error(), tt.msg):
+				t.Errorf("Open = %v, want an error mentioning %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		ok      bool
+	}{
+		{"32 bytes", base64.StdEncoding.EncodeToString(key), true},
+		{"16 bytes", base64.StdEncoding.EncodeToString(key[:16]), false},
+		{"not base64", "%%%", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.encoded)
+			if (err == nil) != tt.ok || (tt.ok && !bytes.Equal(got, key)) {
+				t.Errorf("ParseKey = %x, %v", got, err)
+			}
+		})
+	}
+}
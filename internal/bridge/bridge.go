@@ -0,0 +1,155 @@
+// Package bridge turns JSON posted by third-party services into emails.
+package bridge
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"sync"
+	"text/template"
+)
+
+const (
+	defaultSubject  = "Webhook {{.ID}} received"
+	defaultTemplate = `<html>
+<body>
+	<h2>Webhook {{.ID}} received</h2>
+	<pre>{{.JSON}}</pre>
+</body>
+</html>`
+)
+
+// Endpoint configures a single inbound webhook served at /webhook/{id}.
+type Endpoint struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	// Recipient defaults to RECIPIENT_EMAIL.
+	Recipient string `json:"recipient"`
+	// Subject is a text/template and Template an html/template, both
+	// executed with Data.
+	Subject  string `json:"subject"`
+	Template string `json:"template"`
+
+	subject *template.Template
+	body    *htmltemplate.Template
+}
+
+// Data is passed to the templates. Payload holds the decoded JSON, so
+// fields can be reached as {{.Payload.type}}.
+type Data struct {
+	ID      string
+	Payload any
+	JSON    string
+}
+
+// Registry holds endpoints by ID. It is safe for concurrent use, including
+// Replace.
+type Registry struct {
+	mu        sync.RWMutex
+	endpoints map[string]*Endpoint
+}
+
+type file struct {
+	Webhooks []*Endpoint `json:"webhooks"`
+}
+
+// Load reads endpoint definitions from the JSON file at path and compiles
+// their templates.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks file: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks file: %w", err)
+	}
+
+	r := &Registry{endpoints: make(map[string]*Endpoint, len(f.Webhooks))}
+	for i, e := range f.Webhooks {
+		if e.ID == "" {
+			return nil, fmt.Errorf("webhook #%d has no id", i+1)
+		}
+		if e.Token == "" {
+			return nil, fmt.Errorf("webhook %q has no token", e.ID)
+		}
+		if _, ok := r.endpoints[e.ID]; ok {
+			return nil, fmt.Errorf("duplicate webhook id %q", e.ID)
+		}
+		if err := e.compile(); err != nil {
+			return nil, fmt.Errorf("webhook %q: %w", e.ID, err)
+		}
+		r.endpoints[e.ID] = e
+	}
+	return r, nil
+}
+
+// Get returns the endpoint for id.
+func (r *Registry) Get(id string) (*Endpoint, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.endpoints[id]
+	return e, ok
+}
+
+// Replace swaps in the endpoints of next, e.g. after the webhooks file
+// changed.
+func (r *Registry) Replace(next *Registry) {
+	next.mu.RLock()
+	endpoints := next.endpoints
+	next.mu.RUnlock()
+
+	r.mu.Lock()
+	r.endpoints = endpoints
+	r.mu.Unlock()
+}
+
+// Authorized reports whether token matches the endpoint's token.
+func (e *Endpoint) Authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(e.Token)) == 1
+}
+
+// Render returns the email subject and HTML body for payload.
+func (e *Endpoint) Render(payload any) (string, string, error) {
+	pretty, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to format payload: %w", err)
+	}
+	data := Data{ID: e.ID, Payload: payload, JSON: string(pretty)}
+
+	var subject, body bytes.Buffer
+	if err := e.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := e.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render body: %w", err)
+	}
+	return subject.String(), body.String(), nil
+}
+
+func (e *Endpoint) compile() error {
+	subject := e.Subject
+	if subject == "" {
+		subject = defaultSubject
+	}
+	body := e.Template
+	if body == "" {
+		body = defaultTemplate
+	}
+
+	var err error
+	if e.subject, err = template.New("subject").Option("missingkey=zero").Parse(subject); err != nil {
+		return fmt.Errorf("invalid subject template: %w", err)
+	}
+	if e.body, err = htmltemplate.New("body").Option("missingkey=zero").Parse(body); err != nil {
+		return fmt.Errorf("invalid body template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package bridge
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"not json":          `{"webhooks": [`,
+		"no id":             `{"webhooks": [{"token": "t"}]}`,
+		"no token":          `{"webhooks": [{"id": "stripe"}]}`,
+		"duplicate id":      `{"webhooks": [{"id": "stripe", "token": "a"}, {"id": "stripe", "token": "b"}]}`,
+		"bad subject":       `{"webhooks": [{"id": "stripe", "token": "t", "subject": "{{.ID"}]}`,
+		"bad body template": `{"webhooks": [{"id": "stripe", "token": "t", "template": "{{if}}"}]}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeFile(t, content)); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+func TestRender(t *testing.T) {
+	r, err := Load(writeFile(t, `{"webhooks": [
+		{"id": "stripe", "token": "s3cret", "subject": "Stripe: {{.Payload.type}} {{.Payload.missing}}",
+		 "template": "<p>{{.Payload.data.customer}}</p>"},
+		{"id": "plain", "token": "t"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload any
+	if err := json.Unmarshal([]byte(`{"type": "charge.failed", "data": {"customer": "<b>Ada</b>"}}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	stripe, ok := r.Get("stripe")
+	if !ok {
+		t.Fatal("stripe endpoint missing")
+	}
+	subject, body, err := stripe.Render(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Stripe: charge.failed <no value>" {
+		t.Errorf("subject %q", subject)
+	}
+	if body != "<p>&lt;b&gt;Ada&lt;/b&gt;</p>" {
+		t.Errorf("body %q, want the payload escaped", body)
+	}
+
+	plain, _ := r.Get("plain")
+	subject, body, err = plain.Render(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Webhook plain received" || !strings.Contains(body, `&#34;type&#34;: &#34;charge.failed&#34;`) {
+		t.Errorf("default rendering %q\n%s", subject, body)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r, err := Load(writeFile(t, `{"webhooks": [{"id": "stripe", "token": "s3cret"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := r.Get("stripe")
+	if !ok {
+		t.Fatal("stripe endpoint missing")
+	}
+	for token, want := range map[string]bool{"s3cret": true, "s3cre": false, "s3cret2": false, "": false} {
+		if got := e.Authorized(token); got != want {
+			t.Errorf("Authorized(%q) = %v", token, got)
+		}
+	}
+	if _, ok := r.Get("github"); ok {
+		t.Error("unknown endpoint found")
+	}
+
+	next, err := Load(writeFile(t, `{"webhooks": [{"id": "github", "token": "t"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Replace(next)
+	if _, ok := r.Get("stripe"); ok {
+		t.Error("replaced endpoint still found")
+	}
+	if _, ok := r.Get("github"); !ok {
+		t.Error("new endpoint missing")
+	}
+
+	var none *Registry
+	if _, ok := none.Get("stripe"); ok {
+		t.Error("nil registry found an endpoint")
+	}
+}
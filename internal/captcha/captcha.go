@@ -0,0 +1,19 @@
+// Package captcha verifies captcha solutions sent along with submissions.
+package captcha
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRejected is returned when the provider rejects a solution.
+var ErrRejected = errors.New("captcha rejected")
+
+// Verifier checks a captcha solution with its provider.
+type Verifier interface {
+	// Field is the form field the provider's widget puts its solution in.
+	Field() string
+	// Verify returns ErrRejected for a missing or invalid solution. Other
+	// errors mean the provider could not be asked.
+	Verify(ctx context.Context, solution, remoteIP string) error
+}
@@ -0,0 +1,91 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	friendlyCaptchaURL   = "https://api.friendlycaptcha.com/api/v1/siteverify"
+	friendlyCaptchaEUURL = "https://eu-api.friendlycaptcha.eu/api/v1/siteverify"
+)
+
+// FriendlyCaptcha verifies solutions from the Friendly Captcha widget.
+type FriendlyCaptcha struct {
+	client   *http.Client
+	endpoint string
+	secret   string
+	siteKey  string
+}
+
+// NewFriendlyCaptcha returns a verifier using the given API key and optional
+// sitekey. With eu set, verification goes to the EU-hosted endpoint.
+func NewFriendlyCaptcha(secret, siteKey string, eu bool, timeout time.Duration) *FriendlyCaptcha {
+	endpoint := friendlyCaptchaURL
+	if eu {
+		endpoint = friendlyCaptchaEUURL
+	}
+	return &FriendlyCaptcha{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: endpoint,
+		secret:   secret,
+		siteKey:  siteKey,
+	}
+}
+
+// UseTransport makes f reach Friendly Captcha through rt, e.g. one set up
+// for OUTBOUND_PROXY. It must be called before f is used.
+func (f *FriendlyCaptcha) UseTransport(rt http.RoundTripper) {
+	f.client.Transport = rt
+}
+
+// Field returns the field name used by the Friendly Captcha widget.
+func (f *FriendlyCaptcha) Field() string {
+	return "frc-captcha-solution"
+}
+
+// Verify checks the solution with the siteverify API.
+func (f *FriendlyCaptcha) Verify(ctx context.Context, solution, remoteIP string) error {
+	if solution == "" {
+		return ErrRejected
+	}
+
+	form := url.Values{"solution": {solution}, "secret": {f.secret}}
+	if f.siteKey != "" {
+		form.Set("sitekey", f.siteKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool     `json:"success"`
+		Errors  []string `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("friendly captcha: %s: %w", resp.Status, err)
+	}
+	if !result.Success {
+		// A bad API key or sitekey is our problem, not the submitter's
+		for _, e := range result.Errors {
+			if strings.HasPrefix(e, "secret_") || strings.HasPrefix(e, "sitekey_") || e == "bad_request" {
+				return fmt.Errorf("friendly captcha: %s", strings.Join(result.Errors, ", "))
+			}
+		}
+		return ErrRejected
+	}
+	return nil
+}
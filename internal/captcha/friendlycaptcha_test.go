@@ -0,0 +1,68 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFriendlyCaptchaClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		rejected bool
+		ok       bool
+	}{
+		{"success", http.StatusOK, `{"success": true}`, false, true},
+		{"invalid solution", http.StatusOK, `{"success": false, "errors": ["solution_invalid"]}`, true, false},
+		{"used solution", http.StatusOK, `{"success": false, "errors": ["solution_timeout_or_duplicate"]}`, true, false},
+		{"wrong API key", http.StatusUnauthorized, `{"success": false, "errors": ["secret_invalid"]}`, false, false},
+		{"missing API key", http.StatusBadRequest, `{"success": false, "errors": ["secret_missing"]}`, false, false},
+		{"wrong sitekey", http.StatusBadRequest, `{"success": false, "errors": ["sitekey_invalid"]}`, false, false},
+		{"bad request", http.StatusBadRequest, `{"success": false, "errors": ["bad_request"]}`, false, false},
+		{"down", http.StatusServiceUnavailable, `<html>Service Unavailable</html>`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSiteverifyServer(t, tt.status, tt.body)
+			f := NewFriendlyCaptcha("key", "site", false, time.Second)
+			f.endpoint = srv.URL
+			err := f.Verify(context.Background(), "solution", "192.0.2.1")
+			if tt.ok {
+				if err != nil {
+					t.Errorf("Verify = %v, want success", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Verify succeeded")
+			}
+			if errors.Is(err, ErrRejected) != tt.rejected {
+				t.Errorf("Verify = %v, rejected %v, want %v", err, errors.Is(err, ErrRejected), tt.rejected)
+			}
+		})
+	}
+}
+
+func TestFriendlyCaptchaRequest(t *testing.T) {
+	srv := newSiteverifyServer(t, http.StatusOK, `{"success": true}`)
+	f := NewFriendlyCaptcha("key", "site", false, time.Second)
+	f.endpoint = srv.URL
+	if err := f.Verify(context.Background(), "solution", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Verify(context.Background(), "", ""); !errors.Is(err, ErrRejected) {
+		t.Errorf("Verify without a solution = %v, want ErrRejected", err)
+	}
+	forms := srv.posted()
+	if len(forms) != 1 || forms[0].Get("solution") != "solution" || forms[0].Get("secret") != "key" || forms[0].Get("sitekey") != "site" {
+		t.Errorf("posted %v, want one request with solution, secret, and sitekey", forms)
+	}
+
+	if eu := NewFriendlyCaptcha("key", "", true, time.Second); eu.endpoint != friendlyCaptchaEUURL {
+		t.Errorf("EU endpoint %s", eu.endpoint)
+	}
+}
@@ -0,0 +1,50 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const hCaptchaURL = "https://api.hcaptcha.com/siteverify"
+
+// HCaptcha verifies tokens from the hCaptcha widget.
+type HCaptcha struct {
+	client   *http.Client
+	endpoint string
+	secret   string
+	siteKey  string
+}
+
+// NewHCaptcha returns a verifier using the given secret key and optional
+// sitekey the tokens must belong to.
+func NewHCaptcha(secret, siteKey string, timeout time.Duration) *HCaptcha {
+	return &HCaptcha{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: hCaptchaURL,
+		secret:   secret,
+		siteKey:  siteKey,
+	}
+}
+
+// UseTransport makes c reach hCaptcha through rt, e.g. one set up for
+// OUTBOUND_PROXY. It must be called before c is used.
+func (c *HCaptcha) UseTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
+// Field returns the field name used by the hCaptcha widget.
+func (c *HCaptcha) Field() string {
+	return "h-captcha-response"
+}
+
+// Verify checks the token with the siteverify API.
+func (c *HCaptcha) Verify(ctx context.Context, solution, remoteIP string) error {
+	var extra url.Values
+	if c.siteKey != "" {
+		extra = url.Values{"sitekey": {c.siteKey}}
+	}
+	_, err := siteverify(ctx, c.client, c.endpoint, "hcaptcha", c.secret, solution, remoteIP, extra)
+	return err
+}
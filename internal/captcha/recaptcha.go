@@ -0,0 +1,60 @@
+package captcha
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const recaptchaURL = "https://www.google.com/recaptcha/api/siteverify"
+
+// ReCAPTCHA verifies tokens from Google reCAPTCHA. v2 tokens only pass or
+// fail; v3 tokens carry a score from 0 (a bot) to 1 (a person), which must
+// reach minScore.
+type ReCAPTCHA struct {
+	client   *http.Client
+	endpoint string
+	secret   string
+	minScore float64
+	// action, if set, is the action v3 tokens must have been issued for.
+	action string
+}
+
+// NewReCAPTCHA returns a verifier using the given secret key. minScore
+// applies to v3 tokens; action, if set, must match theirs.
+func NewReCAPTCHA(secret string, minScore float64, action string, timeout time.Duration) *ReCAPTCHA {
+	return &ReCAPTCHA{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: recaptchaURL,
+		secret:   secret,
+		minScore: minScore,
+		action:   action,
+	}
+}
+
+// UseTransport makes c reach Google through rt, e.g. one set up for
+// OUTBOUND_PROXY. It must be called before c is used.
+func (c *ReCAPTCHA) UseTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
+// Field returns the field name used by the reCAPTCHA widget.
+func (c *ReCAPTCHA) Field() string {
+	return "g-recaptcha-response"
+}
+
+// Verify checks the token with the siteverify API.
+func (c *ReCAPTCHA) Verify(ctx context.Context, solution, remoteIP string) error {
+	result, err := siteverify(ctx, c.client, c.endpoint, "recaptcha", c.secret, solution, remoteIP, nil)
+	if err != nil {
+		return err
+	}
+	if result.Score != nil && *result.Score < c.minScore {
+		return fmt.Errorf("%w: score %.1f below %.1f", ErrRejected, *result.Score, c.minScore)
+	}
+	if c.action != "" && result.Score != nil && result.Action != c.action {
+		return fmt.Errorf("%w: token for action %q", ErrRejected, result.Action)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReCAPTCHAScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		body   string
+		ok     bool
+	}{
+		{"v2", "", `{"success": true}`, true},
+		{"v2 ignores the action", "contact", `{"success": true}`, true},
+		{"v3 above the minimum", "", `{"success": true, "score": 0.9, "action": "login"}`, true},
+		{"v3 at the minimum", "", `{"success": true, "score": 0.5}`, true},
+		{"v3 below the minimum", "", `{"success": true, "score": 0.1}`, false},
+		{"v3 for the action", "contact", `{"success": true, "score": 0.9, "action": "contact"}`, true},
+		{"v3 for another action", "contact", `{"success": true, "score": 0.9, "action": "login"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSiteverifyServer(t, http.StatusOK, tt.body)
+			c := NewReCAPTCHA("secret", 0.5, tt.action, time.Second)
+			c.endpoint = srv.URL
+			err := c.Verify(context.Background(), "token", "")
+			if tt.ok && err != nil {
+				t.Errorf("Verify = %v, want success", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrRejected) {
+				t.Errorf("Verify = %v, want ErrRejected", err)
+			}
+		})
+	}
+}
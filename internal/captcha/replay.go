@@ -0,0 +1,49 @@
+package captcha
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"form2mail/internal/duplicate"
+)
+
+// ErrReplayed is returned for a solution that was already used. It wraps
+// ErrRejected.
+var ErrReplayed = fmt.Errorf("%w: solution already used", ErrRejected)
+
+// noReplay rejects solutions seen within a window before asking the wrapped
+// verifier, so one solved challenge cannot be reused for many submissions.
+type noReplay struct {
+	Verifier
+	seen *duplicate.Detector
+}
+
+// NoReplay wraps v so each solution is accepted at most once within window,
+// which should cover how long the provider considers a solution valid.
+func NoReplay(v Verifier, window time.Duration) Verifier {
+	return &noReplay{Verifier: v, seen: duplicate.New(window)}
+}
+
+func (n *noReplay) Verify(ctx context.Context, solution, remoteIP string) error {
+	if solution == "" {
+		return n.Verifier.Verify(ctx, solution, remoteIP)
+	}
+
+	// Claim the solution before verifying it, so concurrent replays of the
+	// same solution cannot both pass
+	sum := sha256.Sum256([]byte(solution))
+	key := hex.EncodeToString(sum[:])
+	if !n.seen.Claim(key) {
+		return ErrReplayed
+	}
+
+	err := n.Verifier.Verify(ctx, solution, remoteIP)
+	if ctx.Err() != nil {
+		// The submission is abandoned, so the client may retry with it
+		n.seen.Release(key)
+	}
+	return err
+}
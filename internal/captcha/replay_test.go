@@ -0,0 +1,101 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubVerifier accepts "good" solutions and counts its calls. With block
+// set, it waits for the context to end first.
+type stubVerifier struct {
+	calls atomic.Int32
+	block bool
+}
+
+func (s *stubVerifier) Field() string { return "captcha" }
+
+func (s *stubVerifier) Verify(ctx context.Context, solution, remoteIP string) error {
+	s.calls.Add(1)
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if solution != "good" {
+		return ErrRejected
+	}
+	return nil
+}
+
+func TestNoReplay(t *testing.T) {
+	stub := &stubVerifier{}
+	v := NoReplay(stub, time.Minute)
+	ctx := context.Background()
+
+	if err := v.Verify(ctx, "good", ""); err != nil {
+		t.Fatalf("first use = %v", err)
+	}
+	err := v.Verify(ctx, "good", "")
+	if !errors.Is(err, ErrReplayed) || !errors.Is(err, ErrRejected) {
+		t.Errorf("replay = %v, want ErrReplayed wrapping ErrRejected", err)
+	}
+	// A solution the provider rejected stays used too
+	if err := v.Verify(ctx, "bad", ""); !errors.Is(err, ErrRejected) || errors.Is(err, ErrReplayed) {
+		t.Errorf("bad solution = %v", err)
+	}
+	if err := v.Verify(ctx, "bad", ""); !errors.Is(err, ErrReplayed) {
+		t.Errorf("bad solution again = %v, want ErrReplayed", err)
+	}
+	if err := v.Verify(ctx, "", ""); !errors.Is(err, ErrRejected) {
+		t.Errorf("missing solution = %v", err)
+	}
+	if got := stub.calls.Load(); got != 3 {
+		t.Errorf("provider asked %d times, want 3: not for replays", got)
+	}
+	if v.Field() != "captcha" {
+		t.Errorf("Field = %q, want the wrapped verifier's", v.Field())
+	}
+}
+
+func TestNoReplayReleasesCanceledSolution(t *testing.T) {
+	stub := &stubVerifier{block: true}
+	v := NoReplay(stub, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := v.Verify(ctx, "good", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Verify = %v, want the deadline", err)
+	}
+
+	// The client gave up before the provider answered, so its retry with
+	// the same solution is verified rather than refused as a replay
+	stub.block = false
+	if err := v.Verify(context.Background(), "good", ""); err != nil {
+		t.Errorf("retry after cancel = %v, want success", err)
+	}
+	if err := v.Verify(context.Background(), "good", ""); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replay after the retry = %v, want ErrReplayed", err)
+	}
+}
+
+func TestNoReplayConcurrent(t *testing.T) {
+	v := NoReplay(&stubVerifier{}, time.Minute)
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v.Verify(context.Background(), "good", "") == nil {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := passed.Load(); got != 1 {
+		t.Errorf("%d concurrent uses passed, want 1", got)
+	}
+}
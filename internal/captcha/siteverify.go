@@ -0,0 +1,64 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// siteverifyResult is the answer of the siteverify API that reCAPTCHA,
+// hCaptcha, and Turnstile share.
+type siteverifyResult struct {
+	Success bool `json:"success"`
+	// Score is only set for reCAPTCHA v3 tokens
+	Score  *float64 `json:"score"`
+	Action string   `json:"action"`
+	Errors []string `json:"error-codes"`
+}
+
+// siteverify posts the token in response, together with secret and
+// remoteIP, to a siteverify endpoint. Extra fields are sent along. A token
+// the provider does not accept is ErrRejected; name prefixes other errors.
+func siteverify(ctx context.Context, client *http.Client, endpoint, name, secret, response, remoteIP string, extra url.Values) (*siteverifyResult, error) {
+	if response == "" {
+		return nil, ErrRejected
+	}
+
+	form := url.Values{"secret": {secret}, "response": {response}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	for key, values := range extra {
+		form[key] = values
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result siteverifyResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", name, resp.Status, err)
+	}
+	if !result.Success {
+		// A bad secret or sitekey is our problem, not the submitter's
+		for _, e := range result.Errors {
+			switch {
+			case strings.HasSuffix(e, "-input-secret"), e == "sitekey-secret-mismatch", e == "bad-request", e == "internal-error":
+				return nil, fmt.Errorf("%s: %s", name, strings.Join(result.Errors, ", "))
+			}
+		}
+		return nil, ErrRejected
+	}
+	return &result, nil
+}
@@ -0,0 +1,142 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// siteverifyServer answers every request with status and body and records
+// the forms posted to it.
+type siteverifyServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	forms []url.Values
+}
+
+func newSiteverifyServer(t *testing.T, status int, body string) *siteverifyServer {
+	t.Helper()
+	s := &siteverifyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		s.mu.Lock()
+		s.forms = append(s.forms, r.PostForm)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *siteverifyServer) posted() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forms
+}
+
+func TestSiteverifyClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		rejected bool
+		ok       bool
+	}{
+		{"success", http.StatusOK, `{"success": true}`, false, true},
+		{"invalid token", http.StatusOK, `{"success": false, "error-codes": ["invalid-input-response"]}`, true, false},
+		{"expired token", http.StatusOK, `{"success": false, "error-codes": ["timeout-or-duplicate"]}`, true, false},
+		{"no error codes", http.StatusOK, `{"success": false}`, true, false},
+		{"wrong secret", http.StatusOK, `{"success": false, "error-codes": ["invalid-input-secret"]}`, false, false},
+		{"missing secret", http.StatusOK, `{"success": false, "error-codes": ["missing-input-secret"]}`, false, false},
+		{"sitekey of another secret", http.StatusOK, `{"success": false, "error-codes": ["sitekey-secret-mismatch"]}`, false, false},
+		{"secret among other codes", http.StatusOK, `{"success": false, "error-codes": ["invalid-input-response", "invalid-input-secret"]}`, false, false},
+		{"bad request", http.StatusOK, `{"success": false, "error-codes": ["bad-request"]}`, false, false},
+		{"provider error", http.StatusOK, `{"success": false, "error-codes": ["internal-error"]}`, false, false},
+		{"down", http.StatusBadGateway, `<html>Bad Gateway</html>`, false, false},
+	}
+	providers := []struct {
+		name string
+		new  func(endpoint string) Verifier
+	}{
+		{"hcaptcha", func(endpoint string) Verifier {
+			c := NewHCaptcha("secret", "", time.Second)
+			c.endpoint = endpoint
+			return c
+		}},
+		{"turnstile", func(endpoint string) Verifier {
+			c := NewTurnstile("secret", time.Second)
+			c.endpoint = endpoint
+			return c
+		}},
+		{"recaptcha", func(endpoint string) Verifier {
+			c := NewReCAPTCHA("secret", 0.5, "", time.Second)
+			c.endpoint = endpoint
+			return c
+		}},
+	}
+	for _, p := range providers {
+		for _, tt := range tests {
+			t.Run(p.name+"/"+tt.name, func(t *testing.T) {
+				srv := newSiteverifyServer(t, tt.status, tt.body)
+				err := p.new(srv.URL).Verify(context.Background(), "token", "192.0.2.1")
+				if tt.ok {
+					if err != nil {
+						t.Errorf("Verify = %v, want success", err)
+					}
+					return
+				}
+				if err == nil {
+					t.Fatal("Verify succeeded")
+				}
+				if errors.Is(err, ErrRejected) != tt.rejected {
+					t.Errorf("Verify = %v, rejected %v, want %v", err, errors.Is(err, ErrRejected), tt.rejected)
+				}
+			})
+		}
+	}
+}
+
+func TestSiteverifyRequest(t *testing.T) {
+	srv := newSiteverifyServer(t, http.StatusOK, `{"success": true}`)
+	c := NewHCaptcha("s3cret", "site-key", time.Second)
+	c.endpoint = srv.URL
+
+	if err := c.Verify(context.Background(), "token", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(context.Background(), "", "192.0.2.1"); !errors.Is(err, ErrRejected) {
+		t.Errorf("Verify without a token = %v, want ErrRejected", err)
+	}
+
+	forms := srv.posted()
+	if len(forms) != 1 {
+		t.Fatalf("%d requests, want 1 for the token only", len(forms))
+	}
+	want := url.Values{"secret": {"s3cret"}, "response": {"token"}, "remoteip": {"192.0.2.1"}, "sitekey": {"site-key"}}
+	for key := range want {
+		if forms[0].Get(key) != want.Get(key) {
+			t.Errorf("%s = %q, want %q", key, forms[0].Get(key), want.Get(key))
+		}
+	}
+}
+
+func TestSiteverifyUnreachable(t *testing.T) {
+	srv := newSiteverifyServer(t, http.StatusOK, `{"success": true}`)
+	c := NewTurnstile("secret", time.Second)
+	c.endpoint = srv.URL
+	srv.Close()
+
+	err := c.Verify(context.Background(), "token", "")
+	if err == nil || errors.Is(err, ErrRejected) {
+		t.Errorf("Verify = %v, want an error other than ErrRejected", err)
+	}
+}
@@ -0,0 +1,42 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const turnstileURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+// Turnstile verifies tokens from Cloudflare Turnstile.
+type Turnstile struct {
+	client   *http.Client
+	endpoint string
+	secret   string
+}
+
+// NewTurnstile returns a verifier using the given secret key.
+func NewTurnstile(secret string, timeout time.Duration) *Turnstile {
+	return &Turnstile{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: turnstileURL,
+		secret:   secret,
+	}
+}
+
+// UseTransport makes c reach Cloudflare through rt, e.g. one set up for
+// OUTBOUND_PROXY. It must be called before c is used.
+func (c *Turnstile) UseTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
+// Field returns the field name used by the Turnstile widget.
+func (c *Turnstile) Field() string {
+	return "cf-turnstile-response"
+}
+
+// Verify checks the token with the siteverify API.
+func (c *Turnstile) Verify(ctx context.Context, solution, remoteIP string) error {
+	_, err := siteverify(ctx, c.client, c.endpoint, "turnstile", c.secret, solution, remoteIP, nil)
+	return err
+}
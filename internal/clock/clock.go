@@ -0,0 +1,209 @@
+// Package clock supplies the time and the random IDs of the submission
+// pipeline. Production code uses the system clock and crypto/rand; tests
+// swap in a Manual clock and Seeded IDs so submissions, Message-IDs, and
+// whole emails come out the same on every run.
+package clock
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	mathrand "math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time and waits on it.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has passed.
+	AfterFunc(d time.Duration, f func()) Timer
+	// After sends the time on the channel once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Timer is a pending call of AfterFunc.
+type Timer interface {
+	// Stop keeps the call from happening and reports whether it was still
+	// pending.
+	Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// System is the real time.
+var System Clock = systemClock{}
+
+// Manual is a clock that only moves when told to. Its timers fire as
+// Advance or Set move it past their time. It is safe for concurrent use.
+type Manual struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	m  *Manual
+	at time.Time
+	f  func()
+}
+
+// NewManual returns a clock standing at t.
+func NewManual(t time.Time) *Manual {
+	return &Manual{now: t}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Advance moves the clock forward by d.
+func (m *Manual) Advance(d time.Duration) {
+	m.mu.Lock()
+	m.now = m.now.Add(d)
+	m.fire()
+}
+
+// Set moves the clock to t.
+func (m *Manual) Set(t time.Time) {
+	m.mu.Lock()
+	m.now = t
+	m.fire()
+}
+
+// fire starts the timers that are due and unlocks m.
+func (m *Manual) fire() {
+	var due []*manualTimer
+	m.timers = slices.DeleteFunc(m.timers, func(t *manualTimer) bool {
+		if t.at.After(m.now) {
+			return false
+		}
+		due = append(due, t)
+		return true
+	})
+	m.mu.Unlock()
+	for _, t := range due {
+		go t.f()
+	}
+}
+
+func (m *Manual) AfterFunc(d time.Duration, f func()) Timer {
+	m.mu.Lock()
+	t := &manualTimer{m: m, at: m.now.Add(d), f: f}
+	m.timers = append(m.timers, t)
+	m.fire()
+	return t
+}
+
+func (m *Manual) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	m.AfterFunc(d, func() { ch <- m.Now() })
+	return ch
+}
+
+// Timers returns the number of timers waiting for the clock to move.
+func (m *Manual) Timers() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.timers)
+}
+
+func (t *manualTimer) Stop() bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	n := len(t.m.timers)
+	t.m.timers = slices.DeleteFunc(t.m.timers, func(other *manualTimer) bool { return other == t })
+	return len(t.m.timers) < n
+}
+
+// Sleep waits for d on c. The system clock sleeps; a Manual clock is moved
+// forward by d instead, so tests run through waits at once.
+func Sleep(c Clock, d time.Duration) {
+	if m, ok := c.(*Manual); ok {
+		m.Advance(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// Jitter draws random durations, such as the spread of retries, from a
+// random source. It is safe for concurrent use.
+type Jitter struct {
+	mu     sync.Mutex
+	random *mathrand.Rand
+}
+
+// SystemJitter draws from a randomly seeded source.
+var SystemJitter = &Jitter{}
+
+// SeededJitter returns a Jitter that repeats for the same seed, for tests.
+func SeededJitter(seed uint64) *Jitter {
+	return &Jitter{random: mathrand.New(mathrand.NewPCG(seed, seed))}
+}
+
+// N returns a random duration in [0, max), or 0 if max is not positive.
+func (j *Jitter) N(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	if j.random == nil {
+		return mathrand.N(max)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return time.Duration(j.random.Int64N(int64(max)))
+}
+
+// IDs generates random hexadecimal identifiers from a random source. It is
+// safe for concurrent use.
+type IDs struct {
+	mu     sync.Mutex
+	random io.Reader
+}
+
+// NewIDs draws IDs from random; nil means crypto/rand.
+func NewIDs(random io.Reader) *IDs {
+	if random == nil {
+		random = rand.Reader
+	}
+	return &IDs{random: random}
+}
+
+// Seeded returns IDs that repeat for the same seed, for tests and replays.
+// They are guessable and must not be used in production.
+func Seeded(seed uint64) *IDs {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	return NewIDs(mathrand.NewChaCha8(key))
+}
+
+// Random is the default source backed by crypto/rand.
+var Random = NewIDs(nil)
+
+// New returns an ID of n random bytes, hex-encoded. If the source fails it
+// falls back to the current time, which is unique but guessable.
+func (g *IDs) New(n int) string {
+	b := make([]byte, n)
+	if _, err := g.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Read fills b with random bytes from the source.
+func (g *IDs) Read(b []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return io.ReadFull(g.random, b)
+}
@@ -0,0 +1,90 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSleepAdvancesManual(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManual(start)
+	Sleep(m, time.Hour)
+	if got := m.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now = %v after sleeping an hour from %v", got, start)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *Jitter
+		same bool
+	}{
+		{"same seed", SeededJitter(1), SeededJitter(1), true},
+		{"other seed", SeededJitter(1), SeededJitter(2), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			same := true
+			for range 10 {
+				a, b := tt.a.N(time.Second), tt.b.N(time.Second)
+				if a < 0 || a >= time.Second {
+					t.Fatalf("N = %v, out of range", a)
+				}
+				same = same && a == b
+			}
+			if same != tt.same {
+				t.Errorf("sequences equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+	for _, j := range []*Jitter{SystemJitter, SeededJitter(1)} {
+		if d := j.N(0); d != 0 {
+			t.Errorf("N(0) = %v, want 0", d)
+		}
+	}
+}
+
+func TestManualTimers(t *testing.T) {
+	m := NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	fired := make(chan string, 3)
+	m.AfterFunc(time.Minute, func() { fired <- "minute" })
+	stopped := m.AfterFunc(time.Second, func() { fired <- "stopped" })
+	after := m.After(time.Hour)
+
+	if !stopped.Stop() {
+		t.Error("Stop of a pending timer = false")
+	}
+	if stopped.Stop() {
+		t.Error("second Stop = true")
+	}
+	m.Advance(59 * time.Second)
+	select {
+	case name := <-fired:
+		t.Fatalf("%s fired early", name)
+	default:
+	}
+	m.Advance(time.Second)
+	if name := <-fired; name != "minute" {
+		t.Errorf("fired %s, want minute", name)
+	}
+	if n := m.Timers(); n != 1 {
+		t.Errorf("Timers = %d, want 1", n)
+	}
+
+	// Sleeping on the manual clock fires what comes due meanwhile
+	Sleep(m, time.Hour)
+	select {
+	case at := <-after:
+		if want := time.Date(2025, 3, 1, 13, 1, 0, 0, time.UTC); !at.Equal(want) {
+			t.Errorf("After sent %v, want %v", at, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("After did not fire")
+	}
+
+	m.AfterFunc(0, func() { fired <- "now" })
+	if name := <-fired; name != "now" {
+		t.Errorf("fired %s, want now", name)
+	}
+}
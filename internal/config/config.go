@@ -1,35 +1,737 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delivery modes selectable via DELIVERY_MODE.
+const (
+	DeliverySMTP    = "smtp"
+	DeliveryMaildir = "maildir"
+	DeliveryBoth    = "both"
+)
+
+// Mail providers selectable via MAIL_PROVIDER, which messages are sent
+// through when DELIVERY_MODE is smtp or both.
+const (
+	MailProviderSMTP     = "smtp"
+	MailProviderSendGrid = "sendgrid"
+	MailProviderMailgun  = "mailgun"
+)
+
+// Log formats selectable via LOG_FORMAT.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// StorageMemory keeps submissions in memory; selectable via STORAGE.
+const StorageMemory = "memory"
+
+// Reactions to a failed save, selectable via STORAGE_FAILURE.
+const (
+	// StorageFailureDeliver still delivers the submission by email.
+	StorageFailureDeliver = "deliver"
+	// StorageFailureReject answers 500 so the submitter can retry.
+	StorageFailureReject = "reject"
+)
+
+// Outcomes that count as notifying the owner, selectable via NOTIFY_POLICY.
+const (
+	// NotifyPolicyEmail requires the email notification; the other channels
+	// are best effort.
+	NotifyPolicyEmail = "email"
+	// NotifyPolicyAny requires any one channel.
+	NotifyPolicyAny = "any"
+	// NotifyPolicyAll requires every channel.
+	NotifyPolicyAll = "all"
+)
+
+// Captcha providers selectable via CAPTCHA_PROVIDER.
+const (
+	// CaptchaFriendly verifies Friendly Captcha solutions.
+	CaptchaFriendly = "friendlycaptcha"
+	// CaptchaReCAPTCHA verifies Google reCAPTCHA v2 and v3 tokens. Setting
+	// RECAPTCHA_SECRET selects it.
+	CaptchaReCAPTCHA = "recaptcha"
+	// CaptchaHCaptcha verifies hCaptcha tokens.
+	CaptchaHCaptcha = "hcaptcha"
+	// CaptchaTurnstile verifies Cloudflare Turnstile tokens.
+	CaptchaTurnstile = "turnstile"
+)
+
+// Captcha modes selectable via CAPTCHA_MODE.
+const (
+	// CaptchaAlways requires a solved captcha with every submission.
+	CaptchaAlways = "always"
+	// CaptchaChallenge requires one only from suspicious clients, which are
+	// told so with 428 Precondition Required.
+	CaptchaChallenge = "challenge"
+)
+
+// Metadata headers selectable via NOTIFICATION_HEADERS.
+const (
+	HeaderForm  = "form"
+	HeaderIP    = "ip"
+	HeaderScore = "score"
+)
+
+// Actions selectable via SPAM_ACTION.
+const (
+	SpamFlag = "flag"
+	SpamDrop = "drop"
+)
+
+// ScanSafeBrowsing checks links with Google Safe Browsing; selectable via
+// SCAN_PROVIDER.
+const ScanSafeBrowsing = "safebrowsing"
+
+// Actions selectable via SCAN_ACTION.
+const (
+	// ScanFlag delivers the submission with its malicious links defanged
+	// and marked in the notification.
+	ScanFlag = "flag"
+	// ScanReject refuses the submission with 422.
+	ScanReject = "reject"
+)
+
+// Response modes selectable via RESPONSE_MODE.
+const (
+	// ResponseSync answers 200 once the notification is sent.
+	ResponseSync = "sync"
+	// ResponseAsync answers 202 once the submission is accepted and sends
+	// the notification in the background.
+	ResponseAsync = "async"
+)
+
+// Actions selectable via DUPLICATE_ACTION.
+const (
+	DuplicateReject = "reject"
+	DuplicateFlag   = "flag"
+)
 
 type Config struct {
-	SMTPHost       string
-	SMTPPort       string
-	SMTPUser       string
-	SMTPPassword   string
-	RecipientEmail string
-	FromEmail      string
-	ServerPort     string
-	CORSOrigin     string
-}
-
-func Load() Config {
-	return Config{
-		SMTPHost:       getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:       getEnv("SMTP_PORT", "587"),
-		SMTPUser:       getEnv("SMTP_USER", ""),
-		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
-		RecipientEmail: getEnv("RECIPIENT_EMAIL", ""),
-		FromEmail:      getEnv("FROM_EMAIL", ""),
-		ServerPort:     getEnv("SERVER_PORT", "8080"),
-		CORSOrigin:     getEnv("CORS_ORIGIN", "*"),
-	}
-}
-
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
+	SMTPHost              string
+	SMTPPort              string
+	SMTPUser              string
+	SMTPPassword          string
+	RecipientEmail        string
+	FromEmail             string
+	FromName              string
+	SendingDomains        []string
+	ServerPort            string
+	LogFormat             string
+	LogLevel              string
+	CORSOrigin            string
+	DeliveryMode          string
+	MailProvider          string
+	SendGridAPIKey        string
+	SendGridAPIURL        string
+	MailgunAPIKey         string
+	MailgunDomain         string
+	MailgunAPIURL         string
+	MailAPIKeyFile        string
+	MailAPITimeout        time.Duration
+	MaildirPath           string
+	DryRun                bool
+	OutboxDir             string
+	OutboxMaxAttempts     int
+	DatabaseURL           string
+	DeadLetterDir         string
+	ConfigFile            string
+	FormsFile             string
+	NotificationHeaders   []string
+	TrustProxy            bool
+	TrustedProxyHops      int
+	DuplicateWindow       time.Duration
+	DuplicateAction       string
+	ReplayWindow          time.Duration
+	EmailDailyLimit       int
+	IPRateLimit           int
+	IPRateBurst           int
+	AllowedEmailDomains   []string
+	EmailMXCheck          bool
+	EmailMXTimeout        time.Duration
+	EmailMXCacheTTL       time.Duration
+	EnrichSender          bool
+	EnrichTimeout         time.Duration
+	Timezone              string
+	Location              *time.Location // parsed Timezone, nil if invalid
+	WebhooksFile          string
+	WebhookTimeout        time.Duration
+	WebhookURLs           []string
+	WebhookSecret         string
+	WebhookRetryAttempts  int
+	WebhookRetryDelay     time.Duration
+	Storage               string
+	StorageMaxEntries     int
+	StorageRetention      time.Duration
+	TrashRetention        time.Duration
+	StorageFailure        string
+	UsageFile             string
+	FeedToken             string
+	FeedLimit             int
+	PublicStats           bool
+	PublicStatsTTL        time.Duration
+	AdminToken            string
+	AdminLockoutThreshold int
+	AdminLockoutDuration  time.Duration
+	AdminLockoutMax       time.Duration
+	AlertEmail            string
+	AlertSMTPHost         string
+	AlertSMTPPort         string
+	AlertSMTPUser         string
+	AlertSMTPPassword     string
+	AlertWebhookURL       string
+	SlackWebhookURL       string
+	NotifyPolicy          string
+	TelegramBotToken      string
+	TelegramChatID        string
+	TelegramSilent        bool
+	TelegramAlerts        bool
+	AlertThreshold        int
+	AlertCooldown         time.Duration
+	QueueHighWater        int
+	QueueRetryAfter       time.Duration
+	MetricsEnabled        bool
+	MaintenanceRetryAfter time.Duration
+	ShutdownTimeout       time.Duration
+	SMTPUserFile          string
+	SMTPPasswordFile      string
+	CredentialsPoll       time.Duration
+	ConfigReloadInterval  time.Duration
+	HealthCheckInterval   time.Duration
+	OutboundProxy         string
+	SMTPProxy             string
+	SMTPIPVersion         string
+	SMTPDialTimeout       time.Duration
+	SMTPAttemptTimeout    time.Duration
+	SMTPSessionTimeout    time.Duration
+	DNSServers            []string
+	DNSTimeout            time.Duration
+	ReceiptKey            string
+	BackupKey             string
+	CaptchaProvider       string
+	CaptchaSecret         string
+	CaptchaSiteKey        string
+	CaptchaEU             bool
+	CaptchaTimeout        time.Duration
+	CaptchaReplayWindow   time.Duration
+	CaptchaMode           string
+	CaptchaChallengeScore int
+	RecaptchaMinScore     float64
+	RecaptchaAction       string
+	StaticDir             string
+	SMTPMaxConnections    int
+	JSONMaxDepth          int
+	JSONMaxFields         int
+	JSONMaxValueSize      int
+	ReplyAddress          string
+	JournalEmail          string
+	GreylistRetryDelay    time.Duration
+	GreylistRetryWindow   time.Duration
+	DeliveryRetryAttempts int
+	DeliveryRetryDelay    time.Duration
+	DeliveryRetryJitter   time.Duration
+	InboundToken          string
+	SpamTrapFields        []string
+	SpamTrapScore         int
+	HoneypotField         string
+	SpamThreshold         int
+	SpamAction            string
+	SpamKeywordsFile      string
+	BlockedIPs            []string
+	TarpitDelay           time.Duration
+	TarpitMaxConnections  int
+	ScanProvider          string
+	ScanAPIKey            string
+	ScanAction            string
+	ScanTimeout           time.Duration
+	DailySummary          bool
+	DailySummaryHour      int
+	PublicURL             string
+	UploadDir             string
+	UploadSecret          string
+	UploadMaxSize         int64
+	UploadMaxFiles        int
+	UploadMaxFileSize     int64
+	UploadAllowedTypes    []string
+	UploadDestination     string
+	UploadLinkTTL         time.Duration
+	UploadRetention       time.Duration
+	UploadProcessImages   bool
+	UploadImageMaxSize    int
+	UploadImageQuality    int
+	S3Endpoint            string
+	S3Region              string
+	S3AccessKeyID         string
+	S3SecretAccessKey     string
+	S3Timeout             time.Duration
+	ShortLinkDir          string
+	Schedules             map[string]string
+	ResponseMode          string
+	Preheader             string
+	SMTPTranscripts       bool
+	DSNNotify             string
+	DSNReturn             string
+	ScheduleJitter        time.Duration
+	InlineImagesDir       string
+	TemplateDir           string
+	ConfirmationImage     string
+
+	// invalid are the settings Load could not parse
+	invalid []error
+}
+
+// Load reads the configuration from the environment and, if CONFIG_FILE
+// names one, a YAML or TOML file, whose settings the environment overrides.
+// It only fails if the file cannot be read; values that do not parse keep
+// their defaults and are reported by Validate.
+func Load() (Config, error) {
+	l := &loader{}
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile != "" {
+		file, err := readConfigFile(configFile)
+		if err != nil {
+			return Config{}, err
+		}
+		l.file = file
+	}
+
+	cfg := Config{
+		ConfigFile:            configFile,
+		SMTPHost:              l.get("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:              l.get("SMTP_PORT", "587"),
+		SMTPUser:              l.get("SMTP_USER", ""),
+		SMTPPassword:          l.get("SMTP_PASSWORD", ""),
+		RecipientEmail:        l.get("RECIPIENT_EMAIL", ""),
+		FromEmail:             l.get("FROM_EMAIL", ""),
+		FromName:              l.get("FROM_NAME", ""),
+		SendingDomains:        l.getList("SENDING_DOMAINS", nil),
+		ServerPort:            l.get("SERVER_PORT", "8080"),
+		LogFormat:             l.get("LOG_FORMAT", LogFormatText),
+		LogLevel:              l.get("LOG_LEVEL", "info"),
+		CORSOrigin:            l.get("CORS_ORIGIN", "*"),
+		DeliveryMode:          l.get("DELIVERY_MODE", DeliverySMTP),
+		MailProvider:          strings.ToLower(l.get("MAIL_PROVIDER", MailProviderSMTP)),
+		SendGridAPIKey:        l.get("SENDGRID_API_KEY", ""),
+		SendGridAPIURL:        l.get("SENDGRID_API_URL", "https://api.sendgrid.com"),
+		MailgunAPIKey:         l.get("MAILGUN_API_KEY", ""),
+		MailgunDomain:         l.get("MAILGUN_DOMAIN", ""),
+		MailgunAPIURL:         l.get("MAILGUN_API_URL", "https://api.mailgun.net"),
+		MailAPIKeyFile:        l.get("MAIL_API_KEY_FILE", ""),
+		MailAPITimeout:        l.getDuration("MAIL_API_TIMEOUT", 30*time.Second),
+		MaildirPath:           l.get("MAILDIR_PATH", ""),
+		DryRun:                l.getBool("DRY_RUN", false),
+		OutboxDir:             l.get("OUTBOX_DIR", ""),
+		OutboxMaxAttempts:     l.getInt("OUTBOX_MAX_ATTEMPTS", 5),
+		DatabaseURL:           l.get("DATABASE_URL", ""),
+		DeadLetterDir:         l.get("DEAD_LETTER_DIR", ""),
+		FormsFile:             l.get("FORMS_FILE", ""),
+		NotificationHeaders:   l.getList("NOTIFICATION_HEADERS", []string{HeaderForm, HeaderIP}),
+		TrustProxy:            l.getBool("TRUST_PROXY", false),
+		TrustedProxyHops:      l.getInt("TRUSTED_PROXY_HOPS", 1),
+		DuplicateWindow:       l.getDuration("DUPLICATE_WINDOW", 10*time.Minute),
+		DuplicateAction:       l.get("DUPLICATE_ACTION", DuplicateReject),
+		ReplayWindow:          l.getDuration("REPLAY_WINDOW", 10*time.Minute),
+		EmailDailyLimit:       l.getInt("EMAIL_DAILY_LIMIT", 0),
+		IPRateLimit:           l.getInt("IP_RATE_LIMIT", 0),
+		IPRateBurst:           l.getInt("IP_RATE_BURST", 5),
+		AllowedEmailDomains:   l.getList("ALLOWED_EMAIL_DOMAINS", nil),
+		EmailMXCheck:          l.getBool("EMAIL_MX_CHECK", false),
+		EmailMXTimeout:        l.getDuration("EMAIL_MX_TIMEOUT", 2*time.Second),
+		EmailMXCacheTTL:       l.getDuration("EMAIL_MX_CACHE_TTL", time.Hour),
+		EnrichSender:          l.getBool("ENRICH_SENDER", false),
+		EnrichTimeout:         l.getDuration("ENRICH_TIMEOUT", 3*time.Second),
+		Timezone:              l.get("TIMEZONE", "Local"),
+		WebhooksFile:          l.get("WEBHOOKS_FILE", ""),
+		WebhookTimeout:        l.getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookURLs:           l.getList("WEBHOOK_URLS", nil),
+		WebhookSecret:         l.get("WEBHOOK_SECRET", ""),
+		WebhookRetryAttempts:  l.getInt("WEBHOOK_RETRY_ATTEMPTS", 3),
+		WebhookRetryDelay:     l.getDuration("WEBHOOK_RETRY_DELAY", time.Second),
+		Storage:               l.get("STORAGE", ""),
+		StorageMaxEntries:     l.getInt("STORAGE_MAX_ENTRIES", 1000),
+		StorageRetention:      l.getDuration("STORAGE_RETENTION", 0),
+		TrashRetention:        l.getDuration("TRASH_RETENTION", 30*24*time.Hour),
+		StorageFailure:        l.get("STORAGE_FAILURE", StorageFailureDeliver),
+		UsageFile:             l.get("USAGE_FILE", ""),
+		FeedToken:             l.get("FEED_TOKEN", ""),
+		FeedLimit:             l.getInt("FEED_LIMIT", 50),
+		PublicStats:           l.getBool("PUBLIC_STATS", false),
+		PublicStatsTTL:        l.getDuration("PUBLIC_STATS_TTL", time.Hour),
+		AdminToken:            l.get("ADMIN_TOKEN", ""),
+		AdminLockoutThreshold: l.getInt("ADMIN_LOCKOUT_THRESHOLD", 5),
+		AdminLockoutDuration:  l.getDuration("ADMIN_LOCKOUT_DURATION", time.Minute),
+		AdminLockoutMax:       l.getDuration("ADMIN_LOCKOUT_MAX", time.Hour),
+		AlertEmail:            l.get("ALERT_EMAIL", ""),
+		AlertSMTPHost:         l.get("ALERT_SMTP_HOST", ""),
+		AlertSMTPPort:         l.get("ALERT_SMTP_PORT", "587"),
+		AlertSMTPUser:         l.get("ALERT_SMTP_USER", ""),
+		AlertSMTPPassword:     l.get("ALERT_SMTP_PASSWORD", ""),
+		AlertWebhookURL:       l.get("ALERT_WEBHOOK_URL", ""),
+		SlackWebhookURL:       l.get("SLACK_WEBHOOK_URL", ""),
+		NotifyPolicy:          l.get("NOTIFY_POLICY", NotifyPolicyEmail),
+		TelegramBotToken:      l.get("TELEGRAM_BOT_TOKEN", ""),
+		TelegramChatID:        l.get("TELEGRAM_CHAT_ID", ""),
+		TelegramSilent:        l.getBool("TELEGRAM_SILENT", false),
+		TelegramAlerts:        l.getBool("TELEGRAM_ALERTS", false),
+		AlertThreshold:        l.getInt("ALERT_THRESHOLD", 3),
+		AlertCooldown:         l.getDuration("ALERT_COOLDOWN", time.Hour),
+		QueueHighWater:        l.getInt("QUEUE_HIGH_WATER", 0),
+		QueueRetryAfter:       l.getDuration("QUEUE_RETRY_AFTER", 30*time.Second),
+		MetricsEnabled:        l.getBool("METRICS_ENABLED", false),
+		MaintenanceRetryAfter: l.getDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+		ShutdownTimeout:       l.getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		SMTPUserFile:          l.get("SMTP_USER_FILE", ""),
+		SMTPPasswordFile:      l.get("SMTP_PASSWORD_FILE", ""),
+		CredentialsPoll:       l.getDuration("SMTP_CREDENTIALS_POLL", 30*time.Second),
+		ConfigReloadInterval:  l.getDuration("CONFIG_RELOAD_INTERVAL", 30*time.Second),
+		HealthCheckInterval:   l.getDuration("HEALTH_CHECK_INTERVAL", 5*time.Minute),
+		OutboundProxy:         l.get("OUTBOUND_PROXY", ""),
+		SMTPProxy:             l.get("SMTP_PROXY", ""),
+		SMTPIPVersion:         l.get("SMTP_IP_VERSION", ""),
+		SMTPDialTimeout:       l.getDuration("SMTP_DIAL_TIMEOUT", 30*time.Second),
+		SMTPAttemptTimeout:    l.getDuration("SMTP_ATTEMPT_TIMEOUT", 10*time.Second),
+		SMTPSessionTimeout:    l.getDuration("SMTP_SESSION_TIMEOUT", 2*time.Minute),
+		DNSServers:            l.getList("DNS_SERVERS", nil),
+		DNSTimeout:            l.getDuration("DNS_TIMEOUT", 5*time.Second),
+		ReceiptKey:            l.get("RECEIPT_KEY", ""),
+		BackupKey:             l.get("BACKUP_KEY", ""),
+		CaptchaProvider:       l.get("CAPTCHA_PROVIDER", l.recaptchaDefault(CaptchaReCAPTCHA)),
+		CaptchaSecret:         l.get("CAPTCHA_SECRET", l.recaptchaDefault(l.lookup("RECAPTCHA_SECRET"))),
+		CaptchaSiteKey:        l.get("CAPTCHA_SITEKEY", ""),
+		CaptchaEU:             l.getBool("CAPTCHA_EU", false),
+		CaptchaTimeout:        l.getDuration("CAPTCHA_TIMEOUT", 5*time.Second),
+		CaptchaReplayWindow:   l.getDuration("CAPTCHA_REPLAY_WINDOW", time.Hour),
+		CaptchaMode:           l.get("CAPTCHA_MODE", CaptchaAlways),
+		CaptchaChallengeScore: l.getInt("CAPTCHA_CHALLENGE_SCORE", 5),
+		RecaptchaMinScore:     l.getFloat("RECAPTCHA_MIN_SCORE", 0.5),
+		RecaptchaAction:       l.get("RECAPTCHA_ACTION", ""),
+		StaticDir:             l.get("STATIC_DIR", ""),
+		SMTPMaxConnections:    l.getInt("SMTP_MAX_CONNECTIONS", 10),
+		JSONMaxDepth:          l.getInt("JSON_MAX_DEPTH", 4),
+		JSONMaxFields:         l.getInt("JSON_MAX_FIELDS", 100),
+		JSONMaxValueSize:      l.getInt("JSON_MAX_VALUE_SIZE", 64*1024),
+		ReplyAddress:          l.get("REPLY_ADDRESS", ""),
+		JournalEmail:          l.get("JOURNAL_EMAIL", ""),
+		GreylistRetryDelay:    l.getDuration("GREYLIST_RETRY_DELAY", 5*time.Minute),
+		GreylistRetryWindow:   l.getDuration("GREYLIST_RETRY_WINDOW", 4*time.Hour),
+		DeliveryRetryAttempts: l.getInt("DELIVERY_RETRY_ATTEMPTS", 3),
+		DeliveryRetryDelay:    l.getDuration("DELIVERY_RETRY_DELAY", time.Second),
+		DeliveryRetryJitter:   l.getDuration("DELIVERY_RETRY_JITTER", 500*time.Millisecond),
+		InboundToken:          l.get("INBOUND_TOKEN", ""),
+		SpamTrapFields:        l.getList("SPAM_TRAP_FIELDS", nil),
+		SpamTrapScore:         l.getInt("SPAM_TRAP_SCORE", 10),
+		HoneypotField:         l.get("HONEYPOT_FIELD", ""),
+		SpamThreshold:         l.getInt("SPAM_THRESHOLD", 10),
+		SpamAction:            l.get("SPAM_ACTION", SpamFlag),
+		SpamKeywordsFile:      l.get("SPAM_KEYWORDS_FILE", ""),
+		BlockedIPs:            l.getList("BLOCKED_IPS", nil),
+		TarpitDelay:           l.getDuration("TARPIT_DELAY", 0),
+		TarpitMaxConnections:  l.getInt("TARPIT_MAX_CONNECTIONS", 100),
+		ScanProvider:          l.get("SCAN_PROVIDER", ""),
+		ScanAPIKey:            l.get("SCAN_API_KEY", ""),
+		ScanAction:            l.get("SCAN_ACTION", ScanFlag),
+		ScanTimeout:           l.getDuration("SCAN_TIMEOUT", 5*time.Second),
+		DailySummary:          l.getBool("DAILY_SUMMARY", false),
+		DailySummaryHour:      l.getInt("DAILY_SUMMARY_HOUR", 8),
+		PublicURL:             l.get("PUBLIC_URL", ""),
+		UploadDir:             l.get("UPLOAD_DIR", ""),
+		UploadSecret:          l.get("UPLOAD_SECRET", ""),
+		UploadMaxSize:         int64(l.getInt("UPLOAD_MAX_SIZE", 10<<20)),
+		UploadMaxFiles:        l.getInt("UPLOAD_MAX_FILES", 5),
+		UploadMaxFileSize:     int64(l.getInt("UPLOAD_MAX_FILE_SIZE", 0)),
+		UploadAllowedTypes:    l.getList("UPLOAD_ALLOWED_TYPES", nil),
+		UploadDestination:     l.get("UPLOAD_DESTINATION", "link"),
+		UploadLinkTTL:         l.getDuration("UPLOAD_LINK_TTL", 7*24*time.Hour),
+		UploadRetention:       l.getDuration("UPLOAD_RETENTION", 30*24*time.Hour),
+		UploadProcessImages:   l.getBool("UPLOAD_PROCESS_IMAGES", false),
+		UploadImageMaxSize:    l.getInt("UPLOAD_IMAGE_MAX_SIZE", 2048),
+		UploadImageQuality:    l.getInt("UPLOAD_IMAGE_QUALITY", 85),
+		S3Endpoint:            l.get("S3_ENDPOINT", ""),
+		S3Region:              l.get("S3_REGION", "us-east-1"),
+		S3AccessKeyID:         l.get("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:     l.get("S3_SECRET_ACCESS_KEY", ""),
+		S3Timeout:             l.getDuration("S3_TIMEOUT", 30*time.Second),
+		ShortLinkDir:          l.get("SHORT_LINK_DIR", ""),
+		Schedules:             l.getMap("SCHEDULES"),
+		ResponseMode:          l.get("RESPONSE_MODE", ResponseSync),
+		Preheader:             l.getPreheader(),
+		SMTPTranscripts:       l.getBool("SMTP_TRANSCRIPTS", false),
+		DSNNotify:             strings.ToUpper(l.get("DSN_NOTIFY", "")),
+		DSNReturn:             strings.ToUpper(l.get("DSN_RET", "HDRS")),
+		ScheduleJitter:        l.getDuration("SCHEDULE_JITTER", 0),
+		InlineImagesDir:       l.get("INLINE_IMAGES_DIR", ""),
+		TemplateDir:           l.get("TEMPLATE_DIR", ""),
+		ConfirmationImage:     l.get("CONFIRMATION_IMAGE", ""),
+	}
+	if loc, err := time.LoadLocation(cfg.Timezone); err == nil {
+		cfg.Location = loc
+	}
+	l.unknownSettings()
+	cfg.invalid = l.invalid
+	return cfg, nil
+}
+
+// TrustedProxies returns how many reverse proxies in front of the server
+// add to X-Forwarded-For, or 0 without TRUST_PROXY.
+func (c Config) TrustedProxies() int {
+	if !c.TrustProxy {
+		return 0
+	}
+	return c.TrustedProxyHops
+}
+
+// DeliversSMTP reports whether messages should be sent through the mail
+// provider: SMTP, or the HTTP API MAIL_PROVIDER selects.
+func (c Config) DeliversSMTP() bool {
+	return c.DeliveryMode == DeliverySMTP || c.DeliveryMode == DeliveryBoth
+}
+
+// DeliversMaildir reports whether messages should be written to MaildirPath.
+func (c Config) DeliversMaildir() bool {
+	return c.DeliveryMode == DeliveryMaildir || c.DeliveryMode == DeliveryBoth
+}
+
+// SendingDomainAllowed reports whether mail may be sent from addr: its domain
+// must be one of SENDING_DOMAINS, or that of FROM_EMAIL if none are set.
+func (c Config) SendingDomainAllowed(addr string) bool {
+	domains := c.SendingDomains
+	if len(domains) == 0 {
+		_, domain, _ := strings.Cut(c.FromEmail, "@")
+		domains = []string{domain}
+	}
+	_, domain, ok := strings.Cut(addr, "@")
+	if !ok || domain == "" {
+		return false
+	}
+	for _, allowed := range domains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSecretFiles replaces SMTPUser and SMTPPassword with the contents of
+// SMTP_USER_FILE and SMTP_PASSWORD_FILE when those are set, and the API
+// key of MAIL_PROVIDER with that of MAIL_API_KEY_FILE.
+func (c *Config) LoadSecretFiles() error {
+	if c.MailAPIKeyFile != "" {
+		key, err := ReadSecretFile(c.MailAPIKeyFile)
+		if err != nil {
+			return err
+		}
+		switch c.MailProvider {
+		case MailProviderSendGrid:
+			c.SendGridAPIKey = key
+		case MailProviderMailgun:
+			c.MailgunAPIKey = key
+		}
+	}
+	if c.SMTPUserFile != "" {
+		user, err := ReadSecretFile(c.SMTPUserFile)
+		if err != nil {
+			return err
+		}
+		c.SMTPUser = user
+	}
+	if c.SMTPPasswordFile != "" {
+		password, err := ReadSecretFile(c.SMTPPasswordFile)
+		if err != nil {
+			return err
+		}
+		c.SMTPPassword = password
+	}
+	return nil
+}
+
+// CredentialFiles returns the secret files the credentials of MAIL_PROVIDER
+// are rotated from: SMTP_USER_FILE and SMTP_PASSWORD_FILE for SMTP, and
+// MAIL_API_KEY_FILE, with no user file, for the API providers. secretFile
+// is empty if the credentials are not kept in files.
+func (c Config) CredentialFiles() (userFile, secretFile string) {
+	if c.MailProvider == MailProviderSMTP {
+		return c.SMTPUserFile, c.SMTPPasswordFile
+	}
+	return "", c.MailAPIKeyFile
+}
+
+// ReadSecretFile returns the trimmed contents of a secret file such as those
+// mounted by Docker or Kubernetes.
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// AlertSenderConfig returns a copy of c that delivers through the separate
+// alert SMTP server, so alerts do not depend on the monitored provider.
+func (c Config) AlertSenderConfig() Config {
+	alert := c
+	alert.SMTPHost = c.AlertSMTPHost
+	alert.SMTPPort = c.AlertSMTPPort
+	alert.SMTPUser = c.AlertSMTPUser
+	alert.SMTPPassword = c.AlertSMTPPassword
+	alert.RecipientEmail = c.AlertEmail
+	alert.DeliveryMode = DeliverySMTP
+	alert.MailProvider = MailProviderSMTP
+	alert.OutboxDir = ""
+	// Alerts are not correspondence with customers
+	alert.JournalEmail = ""
+	return alert
+}
+
+// loader reads settings from the environment and then from CONFIG_FILE, so
+// variables override the file, and collects the values that do not parse.
+type loader struct {
+	file    *fileSettings
+	invalid []error
+	// read are the settings looked up, to tell misspelled ones in the file
+	read map[string]bool
+}
+
+func (l *loader) lookup(key string) string {
+	l.markRead(key)
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if l.file != nil {
+		return l.file.values[key]
+	}
+	return ""
+}
+
+// parse converts the setting key with parseValue, or returns defaultValue
+// if it is not set or does not parse, noting the latter.
+func parse[T any](l *loader, key string, defaultValue T, kind string, parseValue func(string) (T, error)) T {
+	raw := l.lookup(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := parseValue(raw)
+	if err != nil {
+		l.invalid = append(l.invalid, fmt.Errorf("%s must be %s, got %q", key, kind, raw))
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) get(key, defaultValue string) string {
+	if value := l.lookup(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (l *loader) getBool(key string, defaultValue bool) bool {
+	return parse(l, key, defaultValue, "true or false", strconv.ParseBool)
+}
+
+func (l *loader) getInt(key string, defaultValue int) int {
+	return parse(l, key, defaultValue, "a whole number", strconv.Atoi)
+}
+
+func (l *loader) getFloat(key string, defaultValue float64) float64 {
+	return parse(l, key, defaultValue, "a number", func(raw string) (float64, error) {
+		return strconv.ParseFloat(raw, 64)
+	})
+}
+
+// recaptchaDefault returns value if RECAPTCHA_SECRET is set, which
+// enables reCAPTCHA without CAPTCHA_PROVIDER and CAPTCHA_SECRET.
+func (l *loader) recaptchaDefault(value string) string {
+	if l.lookup("RECAPTCHA_SECRET") == "" {
+		return ""
+	}
+	return value
+}
+
+func (l *loader) getDuration(key string, defaultValue time.Duration) time.Duration {
+	return parse(l, key, defaultValue, "a duration such as 30s or 5m", time.ParseDuration)
+}
+
+// getList reads a comma-separated list. Set the variable to "none" for an
+// empty list.
+func (l *loader) getList(key string, defaultValue []string) []string {
+	value := l.lookup(key)
 	if value == "" {
 		return defaultValue
 	}
+	if value == "none" {
+		return nil
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getPreheader reads PREHEADER. Unset or "none" leaves the preview text to
+// the mail client.
+func (l *loader) getPreheader() string {
+	value := l.get("PREHEADER", "")
+	if value == "none" {
+		return ""
+	}
 	return value
 }
+
+// getMap reads semicolon-separated name=value pairs. Values may contain
+// commas and spaces, as cron expressions do. In CONFIG_FILE, the pairs are
+// the keys of a section instead.
+func (l *loader) getMap(key string) map[string]string {
+	l.markRead(key)
+	m := make(map[string]string)
+	if os.Getenv(key) == "" && l.file != nil && l.file.tables[key] != nil {
+		maps.Copy(m, l.file.tables[key])
+		return m
+	}
+	for item := range strings.SplitSeq(l.lookup(key), ";") {
+		name, value, _ := strings.Cut(item, "=")
+		if name = strings.TrimSpace(name); name != "" {
+			m[name] = strings.TrimSpace(value)
+		}
+	}
+	return m
+}
+
+func (l *loader) markRead(key string) {
+	if l.read == nil {
+		l.read = map[string]bool{}
+	}
+	l.read[key] = true
+}
+
+// unknownSettings notes the settings of CONFIG_FILE that were never looked
+// up, such as a misspelled smtp.hots, instead of silently ignoring them.
+// Keys of a map setting's section, such as those under schedules, count as
+// read with it.
+func (l *loader) unknownSettings() {
+	if l.file == nil {
+		return
+	}
+	var unknown []string
+	for name, key := range l.file.keys {
+		if !l.read[name] && !l.read[l.file.sections[name]] {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		l.invalid = append(l.invalid, fmt.Errorf("CONFIG_FILE: unknown setting %s", key))
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setenv sets the variables for the test, starting from a minimal valid
+// configuration, and clears CONFIG_FILE unless env sets it.
+func setenv(t *testing.T, env map[string]string) {
+	t.Helper()
+	base := map[string]string{
+		"CONFIG_FILE":     "",
+		"RECIPIENT_EMAIL": "owner@example.com",
+		"SMTP_USER":       "form2mail",
+		"SMTP_PASSWORD":   "secret",
+	}
+	for key, value := range env {
+		base[key] = value
+	}
+	for key, value := range base {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(c Config) bool
+	}{
+		{"defaults", nil, func(c Config) bool {
+			return c.SMTPHost == "smtp.gmail.com" && c.SMTPPort == "587" && c.ServerPort == "8080" && c.CORSOrigin == "*" &&
+				c.DeliveryMode == DeliverySMTP && c.MailProvider == MailProviderSMTP && c.SpamThreshold == 10 && c.OutboxMaxAttempts == 5
+		}},
+		{"strings", map[string]string{"SMTP_HOST": "mail.example.com", "FROM_NAME": "Acme"}, func(c Config) bool {
+			return c.SMTPHost == "mail.example.com" && c.FromName == "Acme"
+		}},
+		{"provider is lowercased", map[string]string{"MAIL_PROVIDER": "SendGrid"}, func(c Config) bool {
+			return c.MailProvider == MailProviderSendGrid
+		}},
+		{"numbers and durations", map[string]string{"IP_RATE_LIMIT": "12", "MAIL_API_TIMEOUT": "5s", "DRY_RUN": "true"}, func(c Config) bool {
+			return c.IPRateLimit == 12 && c.MailAPITimeout == 5*time.Second && c.DryRun
+		}},
+		{"lists", map[string]string{"BLOCKED_IPS": " 203.0.113.7, ,198.51.100.0/24 "}, func(c Config) bool {
+			return slices.Equal(c.BlockedIPs, []string{"203.0.113.7", "198.51.100.0/24"})
+		}},
+		{"none is an empty list", map[string]string{"SENDING_DOMAINS": "none"}, func(c Config) bool {
+			return c.SendingDomains == nil
+		}},
+		{"maps", map[string]string{"SCHEDULES": "daily-summary = 0 8 * * 1-5; purge=@daily"}, func(c Config) bool {
+			return len(c.Schedules) == 2 && c.Schedules["daily-summary"] == "0 8 * * 1-5" && c.Schedules["purge"] == "@daily"
+		}},
+		{"unparsable values keep defaults", map[string]string{"IP_RATE_LIMIT": "many", "MAIL_API_TIMEOUT": "5"}, func(c Config) bool {
+			return c.IPRateLimit == 0 && c.MailAPITimeout == 30*time.Second
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, tt.env)
+			c, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(c) {
+				t.Errorf("Load = %+v", c)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("smtp:\n  host: file.example.com\n  port: 2525\nfrom_name: File\nschedules:\n  purge: \"@daily\"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, map[string]string{"CONFIG_FILE": path, "FROM_NAME": "Environment"})
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SMTPHost != "file.example.com" || c.SMTPPort != "2525" {
+		t.Errorf("SMTP server %s:%s, want the file's", c.SMTPHost, c.SMTPPort)
+	}
+	if c.FromName != "Environment" {
+		t.Errorf("FROM_NAME = %q, want the environment's", c.FromName)
+	}
+	if c.Schedules["purge"] != "@daily" {
+		t.Errorf("SCHEDULES = %v", c.Schedules)
+	}
+
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Load(); err == nil {
+		t.Error("Load succeeded with a missing CONFIG_FILE")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{"valid", nil, nil},
+		{"no recipient", map[string]string{"RECIPIENT_EMAIL": ""}, []string{"RECIPIENT_EMAIL must be set"}},
+		{"missing credentials", map[string]string{"SMTP_PASSWORD": ""}, []string{"SMTP_USER and SMTP_PASSWORD must be set"}},
+		{"unknown delivery mode", map[string]string{"DELIVERY_MODE": "pigeon"}, []string{"DELIVERY_MODE must be one of"}},
+		{"unknown provider", map[string]string{"MAIL_PROVIDER": "postmark"}, []string{"MAIL_PROVIDER must be one of"}},
+		{"SendGrid without key", map[string]string{"MAIL_PROVIDER": "sendgrid"}, []string{"SENDGRID_API_KEY must be set"}},
+		{"Maildir without path", map[string]string{"DELIVERY_MODE": "maildir"}, []string{"MAILDIR_PATH must be set"}},
+		{"invalid time zone", map[string]string{"TIMEZONE": "Mars/Olympus"}, []string{`TIMEZONE "Mars/Olympus"`}},
+		{"invalid duplicate action", map[string]string{"DUPLICATE_ACTION": "ignore"}, []string{"DUPLICATE_ACTION must be reject or flag"}},
+		{"invalid spam action", map[string]string{"SPAM_ACTION": "bounce"}, []string{"SPAM_ACTION must be flag or drop"}},
+		{"outbox attempts", map[string]string{"OUTBOX_DIR": "/tmp/outbox", "OUTBOX_MAX_ATTEMPTS": "0"}, []string{"OUTBOX_MAX_ATTEMPTS"}},
+		{"unparsable values", map[string]string{"IP_RATE_LIMIT": "many", "DRY_RUN": "yes please"}, []string{
+			`IP_RATE_LIMIT must be a whole number, got "many"`,
+			`DRY_RUN must be true or false, got "yes please"`,
+		}},
+		{"all problems at once", map[string]string{"SMTP_USER": "", "SPAM_ACTION": "bounce", "DUPLICATE_ACTION": "ignore"}, []string{
+			"SMTP_USER and SMTP_PASSWORD", "SPAM_ACTION", "DUPLICATE_ACTION",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, tt.env)
+			c, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate = %v, want no problems", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate found no problems")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUnknownFileSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want []string
+	}{
+		{"known", "config.yaml", "smtp:\n  host: smtp.example.com\nschedules:\n  daily-summary: \"0 8 * * *\"\n  purge: \"@daily\"\n", nil},
+		{"misspelled key", "config.yaml", "smtp:\n  hots: smtp.example.com\n", []string{"CONFIG_FILE: unknown setting smtp.hots"}},
+		{"misspelled flat key", "config.toml", "recipient_emial = \"owner@example.com\"\n", []string{"CONFIG_FILE: unknown setting recipient_emial"}},
+		{"several", "config.yaml", "spam_treshold: 2\nsmtp_host: smtp.example.com\nfrom_nmae: Shop\n", []string{
+			"CONFIG_FILE: unknown setting from_nmae", "CONFIG_FILE: unknown setting spam_treshold",
+		}},
+		{"overridden by the environment", "config.yaml", "from_name: File\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			setenv(t, map[string]string{"CONFIG_FILE": path, "FROM_NAME": "Environment"})
+			c, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate = %v, want no problems", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate found no problems")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, does not mention %q", err, want)
+				}
+			}
+			if strings.Contains(err.Error(), "smtp_host") {
+				t.Errorf("Validate = %v, reports a known setting", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileSettings are the settings read from CONFIG_FILE. Keys are the names
+// of the environment variables they stand for: smtp.host, or host in an
+// smtp section, is SMTP_HOST.
+type fileSettings struct {
+	values map[string]string
+	// tables are the sections by name, with their keys as written, for
+	// settings that are maps such as SCHEDULES.
+	tables map[string]map[string]string
+	// keys are the settings' keys as written, e.g. smtp.host, and
+	// sections the section each setting is in, for telling which settings
+	// no variable stands for.
+	keys     map[string]string
+	sections map[string]string
+}
+
+// readConfigFile reads the YAML or TOML file at path, told apart by its
+// extension. Settings are strings, numbers, booleans, or lists of those,
+// and may be grouped in mappings or tables; other values are refused.
+func readConfigFile(path string) (*fileSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+	}
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		_, err = toml.Decode(string(data), &doc)
+	default:
+		return nil, fmt.Errorf("CONFIG_FILE %q must end in .yaml, .yml, or .toml", path)
+	}
+	s := &fileSettings{
+		values:   map[string]string{},
+		tables:   map[string]map[string]string{},
+		keys:     map[string]string{},
+		sections: map[string]string{},
+	}
+	if err == nil {
+		err = s.walk(nil, doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONFIG_FILE %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// walk records the settings of the mapping m found at path.
+func (s *fileSettings) walk(path []string, m map[string]any) error {
+	for key, value := range m {
+		at := append(slices.Clone(path), key)
+		switch value := value.(type) {
+		case map[string]any:
+			if err := s.walk(at, value); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				v, ok := scalar(item)
+				if !ok {
+					return fmt.Errorf("%s: lists may only hold strings, numbers, and booleans", strings.Join(at, "."))
+				}
+				items[i] = v
+			}
+			s.setList(at, items)
+		default:
+			v, ok := scalar(value)
+			if !ok {
+				return fmt.Errorf("%s: expected a string, number, boolean, list, or section", strings.Join(at, "."))
+			}
+			s.set(at, v)
+		}
+	}
+	return nil
+}
+
+// scalar returns a decoded value as the variable would hold it.
+func scalar(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	}
+	return "", false
+}
+
+// set records value for the key at path, a list of values as the
+// comma-separated list the variable would hold.
+func (s *fileSettings) set(path []string, value string) {
+	name := envName(path)
+	s.values[name] = value
+	s.keys[name] = strings.Join(path, ".")
+	if len(path) > 1 {
+		table := envName(path[:len(path)-1])
+		if s.tables[table] == nil {
+			s.tables[table] = map[string]string{}
+		}
+		s.tables[table][path[len(path)-1]] = value
+		s.sections[name] = table
+	}
+}
+
+func (s *fileSettings) setList(path []string, items []string) {
+	if len(items) == 0 {
+		s.set(path, "none")
+		return
+	}
+	s.set(path, strings.Join(items, ","))
+}
+
+// envName returns the variable a key path stands for.
+func envName(path []string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(path, "_"), "-", "_"))
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readTestFile(t *testing.T, name, data string) (*fileSettings, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return readConfigFile(path)
+}
+
+func TestConfigFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want map[string]string
+	}{
+		{
+			name: "YAML quoting",
+			file: "config.yaml",
+			data: "from_name: \"Bob's \\\"Shop\\\"\"\nsubject_prefix: '[Web] it''s'\ncors_origin: https://example.com/#top\nrecipient_email: owner@example.com # the owner\nsmtp_password: \"p#ss: word\"\n",
+			want: map[string]string{
+				"FROM_NAME":       `Bob's "Shop"`,
+				"SUBJECT_PREFIX":  "[Web] it's",
+				"CORS_ORIGIN":     "https://example.com/#top",
+				"RECIPIENT_EMAIL": "owner@example.com",
+				"SMTP_PASSWORD":   "p#ss: word",
+			},
+		},
+		{
+			name: "YAML nesting and lists",
+			file: "config.yml",
+			data: "# settings\nsmtp:\n  host: smtp.example.com\n  port: 587\n  tls:\n    skip-verify: false\nspam:\n  threshold: 2.5\nblocked_ips:\n  - 203.0.113.7\n  - \"198.51.100.0/24\"\nallowed_domains: [example.com, 'example.org']\ncaptcha_fields: []\nschedules:\n  daily-summary: \"0 8 * * 1-5\"\npreheader: ~\n",
+			want: map[string]string{
+				"SMTP_HOST":               "smtp.example.com",
+				"SMTP_PORT":               "587",
+				"SMTP_TLS_SKIP_VERIFY":    "false",
+				"SPAM_THRESHOLD":          "2.5",
+				"BLOCKED_IPS":             "203.0.113.7,198.51.100.0/24",
+				"ALLOWED_DOMAINS":         "example.com,example.org",
+				"CAPTCHA_FIELDS":          "none",
+				"SCHEDULES_DAILY_SUMMARY": "0 8 * * 1-5",
+				"PREHEADER":               "",
+			},
+		},
+		{
+			name: "TOML",
+			file: "config.toml",
+			data: "recipient_email = \"owner@example.com\" # the owner\nfrom_name = 'Bob\\s Shop'\nblocked_ips = [\n  \"203.0.113.7\", # office\n  \"198.51.100.0/24\",\n]\nmax_size = 10_485_760\n\n[smtp]\nhost = \"smtp.example.com\"\nport = 587\ntls.required = true\n\n[\"schedules\"]\n\"daily-summary\" = \"0 8 * * 1-5\"\n",
+			want: map[string]string{
+				"RECIPIENT_EMAIL":         "owner@example.com",
+				"FROM_NAME":               `Bob\s Shop`,
+				"BLOCKED_IPS":             "203.0.113.7,198.51.100.0/24",
+				"MAX_SIZE":                "10485760",
+				"SMTP_HOST":               "smtp.example.com",
+				"SMTP_PORT":               "587",
+				"SMTP_TLS_REQUIRED":       "true",
+				"SCHEDULES_DAILY_SUMMARY": "0 8 * * 1-5",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := readTestFile(t, tt.file, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if got, ok := s.values[key]; !ok || got != want {
+					t.Errorf("%s = %q (set %v), want %q", key, got, ok, want)
+				}
+			}
+			if len(s.values) != len(tt.want) {
+				t.Errorf("read %d settings, want %d: %v", len(s.values), len(tt.want), s.values)
+			}
+		})
+	}
+}
+
+func TestConfigFileTables(t *testing.T) {
+	s, err := readTestFile(t, "config.yaml", "schedules:\n  daily-summary: \"0 8 * * *\"\n  purge: \"@daily\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := s.tables["SCHEDULES"]
+	if len(table) != 2 || table["daily-summary"] != "0 8 * * *" || table["purge"] != "@daily" {
+		t.Errorf("SCHEDULES table = %v", table)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want string
+	}{
+		{"YAML syntax", "config.yaml", "smtp:\n  host: a\n port: 587\n", "line 2"},
+		{"YAML tab indent", "config.yaml", "smtp:\n\thost: a\n", "line 2"},
+		{"YAML unterminated string", "config.yaml", "recipient_email: ok\nfrom_name: \"Bob\n", "line 2"},
+		{"YAML nested list", "config.yaml", "blocked_ips:\n  - [a, b]\n", "blocked_ips: lists may only hold"},
+		{"YAML list of mappings", "config.yaml", "smtp:\n  hosts:\n    - name: a\n", "smtp.hosts: lists may only hold"},
+		{"TOML syntax", "config.toml", "a = 1\nb = \n", "line 2"},
+		{"TOML duplicate key", "config.toml", "a = 1\na = 2\n", "line 2"},
+		{"TOML array of tables", "config.toml", "[[smtp]]\nhost = \"a\"\n", "smtp: expected"},
+		{"unknown extension", "config.json", "{}", "must end in .yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readTestFile(t, tt.file, tt.data)
+			if err == nil {
+				t.Fatal("file was accepted")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigExampleFile(t *testing.T) {
+	s, err := readConfigFile(filepath.Join("..", "..", "config.example.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.values["SMTP_HOST"] == "" || s.values["RECIPIENT_EMAIL"] == "" {
+		t.Errorf("example settings missing: %v", s.values)
+	}
+
+	// Every setting in the example must be one Load reads
+	setenv(t, map[string]string{"CONFIG_FILE": filepath.Join("..", "..", "config.example.yaml")})
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range c.invalid {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Validate reports every setting that is missing or invalid at once, so a
+// misconfigured deployment can be fixed in one go. Settings that need other
+// packages to check, such as PREHEADER and SCHEDULES, are left to the
+// caller.
+func (c Config) Validate() error {
+	problems := append([]error{}, c.invalid...)
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.RecipientEmail == "" {
+		fail("RECIPIENT_EMAIL must be set")
+	}
+	if !c.DeliversSMTP() && !c.DeliversMaildir() {
+		fail("DELIVERY_MODE must be one of smtp, maildir, or both")
+	}
+	if c.DeliversSMTP() {
+		switch c.MailProvider {
+		case MailProviderSMTP:
+			if c.SMTPUser == "" || c.SMTPPassword == "" {
+				fail("SMTP_USER and SMTP_PASSWORD must be set when delivering via SMTP")
+			}
+		case MailProviderSendGrid:
+			if c.SendGridAPIKey == "" {
+				fail("SENDGRID_API_KEY must be set when MAIL_PROVIDER is sendgrid")
+			}
+		case MailProviderMailgun:
+			if c.MailgunAPIKey == "" || c.MailgunDomain == "" {
+				fail("MAILGUN_API_KEY and MAILGUN_DOMAIN must be set when MAIL_PROVIDER is mailgun")
+			}
+		default:
+			fail("MAIL_PROVIDER must be one of smtp, sendgrid, or mailgun")
+		}
+	}
+	if c.DeliversMaildir() && c.MaildirPath == "" {
+		fail("MAILDIR_PATH must be set when delivering to a Maildir")
+	}
+
+	if c.Location == nil {
+		fail("TIMEZONE %q is not a valid IANA time zone", c.Timezone)
+	}
+	if c.DuplicateAction != DuplicateReject && c.DuplicateAction != DuplicateFlag {
+		fail("DUPLICATE_ACTION must be reject or flag")
+	}
+	if c.SpamAction != SpamFlag && c.SpamAction != SpamDrop {
+		fail("SPAM_ACTION must be flag or drop")
+	}
+	if c.ResponseMode != ResponseSync && c.ResponseMode != ResponseAsync {
+		fail("RESPONSE_MODE must be sync or async")
+	}
+	if c.TrustProxy && c.TrustedProxyHops < 1 {
+		fail("TRUSTED_PROXY_HOPS must be at least 1")
+	}
+	if c.OutboxDir != "" && c.OutboxMaxAttempts < 1 {
+		fail("OUTBOX_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.DeliveryRetryAttempts < 1 {
+		fail("DELIVERY_RETRY_ATTEMPTS must be at least 1")
+	}
+	if c.NotifyPolicy != NotifyPolicyEmail && c.NotifyPolicy != NotifyPolicyAny && c.NotifyPolicy != NotifyPolicyAll {
+		fail("NOTIFY_POLICY must be email, any, or all")
+	}
+	if c.SlackWebhookURL != "" {
+		if u, err := url.Parse(c.SlackWebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			fail("SLACK_WEBHOOK_URL must be an https:// URL")
+		}
+	}
+	if c.WebhookRetryAttempts < 1 {
+		fail("WEBHOOK_RETRY_ATTEMPTS must be at least 1")
+	}
+	for _, hook := range c.WebhookURLs {
+		if u, err := url.Parse(hook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("WEBHOOK_URLS: invalid webhook url %q", hook)
+		}
+	}
+	if c.DailySummaryHour < 0 || c.DailySummaryHour > 23 {
+		fail("DAILY_SUMMARY_HOUR must be between 0 and 23")
+	}
+
+	if c.Storage != "" && c.Storage != StorageMemory {
+		fail("STORAGE must be empty or memory")
+	}
+	if c.Storage != "" && c.DatabaseURL != "" {
+		fail("STORAGE must be empty when DATABASE_URL is set")
+	}
+	if c.StorageFailure != StorageFailureDeliver && c.StorageFailure != StorageFailureReject {
+		fail("STORAGE_FAILURE must be deliver or reject")
+	}
+
+	switch c.CaptchaProvider {
+	case "", CaptchaFriendly, CaptchaReCAPTCHA, CaptchaHCaptcha, CaptchaTurnstile:
+	default:
+		fail("CAPTCHA_PROVIDER must be empty, friendlycaptcha, recaptcha, hcaptcha, or turnstile")
+	}
+	if c.RecaptchaMinScore < 0 || c.RecaptchaMinScore > 1 {
+		fail("RECAPTCHA_MIN_SCORE must be between 0 and 1")
+	}
+	if c.CaptchaMode != CaptchaAlways && c.CaptchaMode != CaptchaChallenge {
+		fail("CAPTCHA_MODE must be always or challenge")
+	}
+	if c.CaptchaProvider != "" && c.CaptchaSecret == "" {
+		fail("CAPTCHA_SECRET must be set when CAPTCHA_PROVIDER is set")
+	}
+
+	if c.ReplyAddress != "" && !strings.Contains(c.ReplyAddress, "@") {
+		fail("REPLY_ADDRESS must be an email address")
+	}
+
+	if c.ScanProvider != "" && c.ScanProvider != ScanSafeBrowsing {
+		fail("SCAN_PROVIDER must be empty or safebrowsing")
+	}
+	if c.ScanProvider != "" && c.ScanAPIKey == "" {
+		fail("SCAN_API_KEY must be set when SCAN_PROVIDER is set")
+	}
+	if c.ScanAction != ScanFlag && c.ScanAction != ScanReject {
+		fail("SCAN_ACTION must be flag or reject")
+	}
+
+	if c.JournalEmail != "" && !strings.Contains(c.JournalEmail, "@") {
+		fail("JOURNAL_EMAIL must be an email address")
+	}
+
+	if c.UploadDir != "" && (c.UploadSecret == "" || c.PublicURL == "") {
+		fail("UPLOAD_SECRET and PUBLIC_URL must be set when UPLOAD_DIR is set")
+	}
+	if c.UploadImageQuality < 1 || c.UploadImageQuality > 100 {
+		fail("UPLOAD_IMAGE_QUALITY must be between 1 and 100")
+	}
+	if c.ShortLinkDir != "" && c.PublicURL == "" {
+		fail("PUBLIC_URL must be set when SHORT_LINK_DIR is set")
+	}
+	if (c.S3AccessKeyID == "") != (c.S3SecretAccessKey == "") {
+		fail("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set together")
+	}
+
+	if c.StaticDir != "" {
+		if info, err := os.Stat(c.StaticDir); err != nil || !info.IsDir() {
+			fail("STATIC_DIR %q is not a directory", c.StaticDir)
+		}
+	}
+
+	if c.SMTPProxy != "" {
+		if u, err := url.Parse(c.SMTPProxy); err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") || u.Host == "" {
+			fail("SMTP_PROXY must be a socks5:// URL")
+		}
+	}
+	if c.SMTPIPVersion != "" && c.SMTPIPVersion != "4" && c.SMTPIPVersion != "6" {
+		fail("SMTP_IP_VERSION must be 4 or 6")
+	}
+	if c.DSNNotify != "" {
+		for notify := range strings.SplitSeq(c.DSNNotify, ",") {
+			if notify != "SUCCESS" && notify != "FAILURE" && notify != "DELAY" {
+				fail("DSN_NOTIFY: unknown condition %q, expected SUCCESS, FAILURE, or DELAY", notify)
+			}
+		}
+		if c.DSNReturn != "HDRS" && c.DSNReturn != "FULL" {
+			fail("DSN_RET must be HDRS or FULL")
+		}
+	}
+	if c.OutboundProxy != "" {
+		if u, err := url.Parse(c.OutboundProxy); err != nil || u.Host == "" {
+			fail("OUTBOUND_PROXY must be a proxy URL such as http://proxy:3128")
+		}
+	}
+
+	if c.AlertEmail != "" && c.AlertSMTPHost == "" {
+		fail("ALERT_SMTP_HOST must be set when ALERT_EMAIL is set, since alerts must not depend on the monitored SMTP server")
+	}
+	if (c.TelegramBotToken == "") != (c.TelegramChatID == "") {
+		fail("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set together")
+	}
+	if c.TelegramAlerts && c.TelegramBotToken == "" {
+		fail("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set when TELEGRAM_ALERTS is enabled")
+	}
+
+	return errors.Join(problems...)
+}
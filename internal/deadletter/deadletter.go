@@ -0,0 +1,142 @@
+// Package deadletter keeps messages that could not be delivered, so the
+// operator can look into them and send them again once the cause is fixed.
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for an ID the store has no message for.
+var ErrNotFound = errors.New("dead letter not found")
+
+// Entry is an undeliverable message.
+type Entry struct {
+	ID string `json:"id"`
+	To string `json:"to"`
+	// Providers are those the message was not delivered through yet, in
+	// order; a copy already written to the Maildir is not written again.
+	Providers []string `json:"providers"`
+	Message   []byte   `json:"message"`
+	// EnvelopeID is the envelope ID DSNs were requested for, if any.
+	EnvelopeID string `json:"envelope_id,omitempty"`
+	// Error is the last delivery failure.
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+	// Resends counts the failed attempts to send the message again.
+	Resends int `json:"resends"`
+}
+
+// Store keeps entries as JSON files in a directory, one per message.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open creates the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Put records e, replacing an entry with the same ID.
+func (s *Store) Put(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+	tmp := s.path(e.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	if err := os.Rename(tmp, s.path(e.ID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// Get returns the entry for id.
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id))
+}
+
+// List returns all entries, most recently failed first.
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	entries := make([]Entry, 0, len(files))
+	for _, file := range files {
+		e, err := s.read(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].FailedAt.After(entries[j].FailedAt)
+	})
+	return entries, nil
+}
+
+// Remove deletes the entry for id.
+func (s *Store) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) read(file string) (Entry, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read dead letter: %w", err)
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, fmt.Errorf("failed to decode dead letter %s: %w", filepath.Base(file), err)
+	}
+	return e, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, safeName(id)+".json")
+}
+
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator || r == '.' {
+			return '_'
+		}
+		return r
+	}, id)
+}
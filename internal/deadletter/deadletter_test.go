@@ -0,0 +1,86 @@
+package deadletter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dead")
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{ID: "old", To: "owner@example.com", Providers: []string{"smtp"}, Message: []byte("Subject: a\r\n\r\na"), Error: "550", FailedAt: at},
+		{ID: "new", To: "ada@example.com", Providers: []string{"smtp", "maildir"}, Message: []byte("Subject: b\r\n\r\nb"), Error: "421", FailedAt: at.Add(time.Hour)},
+		{ID: "../escape", To: "eve@example.com", FailedAt: at.Add(-time.Hour)},
+	}
+	for _, e := range entries {
+		if err := s.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range list {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) != 3 || ids[0] != "new" || ids[1] != "old" || ids[2] != "../escape" {
+		t.Errorf("List = %v, want most recently failed first", ids)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "___escape.json")); err != nil {
+		t.Errorf("ID with a path was not kept in the directory: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"get", func() error {
+			e, err := s.Get("new")
+			if err == nil && (string(e.Message) != "Subject: b\r\n\r\nb" || len(e.Providers) != 2) {
+				return errors.New("entry changed on the way")
+			}
+			return err
+		}, nil},
+		{"replace", func() error {
+			e := entries[0]
+			e.Resends = 2
+			if err := s.Put(e); err != nil {
+				return err
+			}
+			if got, _ := s.Get("old"); got.Resends != 2 {
+				return errors.New("entry was not replaced")
+			}
+			return nil
+		}, nil},
+		{"get missing", func() error { _, err := s.Get("missing"); return err }, ErrNotFound},
+		{"remove", func() error { return s.Remove("old") }, nil},
+		{"get removed", func() error { _, err := s.Get("old"); return err }, ErrNotFound},
+		{"remove missing", func() error { return s.Remove("old") }, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Errorf("error %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.List(); err == nil {
+		t.Error("List succeeded with a corrupt entry")
+	}
+}
@@ -0,0 +1,99 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxMessage is the largest DNS message, as its TCP length prefix allows.
+const maxMessage = 65535
+
+// dohConn carries DNS queries written with the TCP framing, a two-byte
+// length before each message, over DNS over HTTPS (RFC 8484): every
+// complete query is POSTed, and the answer is read back framed the same
+// way.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	deadline time.Time
+	query    bytes.Buffer
+	answer   bytes.Reader
+}
+
+func (c *dohConn) Write(p []byte) (int, error) {
+	c.query.Write(p)
+	for c.query.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.query.Bytes()))
+		if c.query.Len() < 2+n {
+			break
+		}
+		c.query.Next(2)
+		answer, err := c.exchange(c.query.Next(n))
+		if err != nil {
+			return 0, err
+		}
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(answer)))
+		c.answer.Reset(append(framed, answer...))
+	}
+	return len(p), nil
+}
+
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DNS over HTTPS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS: %s answered %s", c.url, resp.Status)
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, maxMessage+1))
+	if err != nil {
+		return nil, fmt.Errorf("DNS over HTTPS: %w", err)
+	}
+	if len(answer) > maxMessage {
+		return nil, errors.New("DNS over HTTPS: answer too large")
+	}
+	return answer, nil
+}
+
+func (c *dohConn) Read(p []byte) (int, error) { return c.answer.Read(p) }
+
+func (c *dohConn) Close() error { return nil }
+
+func (c *dohConn) LocalAddr() net.Addr  { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr { return dohAddr(c.url) }
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+// dohAddr is the address of a DNS over HTTPS endpoint.
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
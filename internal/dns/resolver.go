@@ -0,0 +1,154 @@
+// Package dns builds a resolver that queries configured nameservers
+// instead of those in /etc/resolv.conf, over plain DNS, DNS over TLS, or
+// DNS over HTTPS.
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// transport is how a nameserver is queried.
+type transport int
+
+const (
+	transportPlain transport = iota
+	transportTLS
+	transportHTTPS
+)
+
+// server is a configured nameserver.
+type server struct {
+	transport transport
+	// addr is the IP address and port to connect to.
+	addr string
+	// name is the TLS server name, the host of the entry or its fragment.
+	name string
+	// url is the DNS over HTTPS endpoint, whose host is name.
+	url    *url.URL
+	client *http.Client
+}
+
+// NewResolver returns a resolver that sends every query to one of servers,
+// taking turns so a retried query goes to the next one. Entries are
+//
+//	1.1.1.1, [2606:4700::1111]:53                plain DNS, over UDP and TCP
+//	tls://1.1.1.1, tls://9.9.9.9#dns.quad9.net   DNS over TLS (port 853)
+//	https://1.1.1.1/dns-query                    DNS over HTTPS
+//
+// Nameservers are given by IP address, since they cannot be looked up
+// themselves; a fragment sets the name their TLS certificate is checked
+// for, which is the address otherwise. timeout bounds connecting and each
+// DNS over HTTPS request.
+func NewResolver(servers []string, timeout time.Duration) (*net.Resolver, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers")
+	}
+	r := &resolver{dialer: net.Dialer{Timeout: timeout}}
+	for _, entry := range servers {
+		s, err := parseServer(entry)
+		if err != nil {
+			return nil, err
+		}
+		if s.transport == transportHTTPS {
+			s.client = r.httpClient(s.addr, timeout)
+		}
+		r.servers = append(r.servers, s)
+	}
+	return &net.Resolver{PreferGo: true, Dial: r.dial}, nil
+}
+
+// httpClient returns a client for DNS over HTTPS that connects to addr
+// whatever the host of the request, which must not be looked up. It
+// ignores proxies for the same reason.
+func (r *resolver) httpClient(addr string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return r.dialer.DialContext(ctx, network, addr)
+			},
+			ForceAttemptHTTP2: true,
+			IdleConnTimeout:   90 * time.Second,
+		},
+	}
+}
+
+func parseServer(entry string) (server, error) {
+	entry = strings.TrimSpace(entry)
+	scheme, rest, ok := strings.Cut(entry, "://")
+	if !ok {
+		scheme, rest = "", entry
+	}
+	rest, name, _ := strings.Cut(rest, "#")
+
+	var s server
+	switch scheme {
+	case "":
+		s.transport = transportPlain
+	case "tls":
+		s.transport = transportTLS
+	case "https":
+		s.transport = transportHTTPS
+		u, err := url.Parse("https://" + rest)
+		if err != nil {
+			return server{}, fmt.Errorf("invalid nameserver %q: %w", entry, err)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		s.url, rest = u, u.Host
+	default:
+		return server{}, fmt.Errorf("invalid nameserver %q: scheme must be tls:// or https://", entry)
+	}
+
+	port := map[transport]string{transportPlain: "53", transportTLS: "853", transportHTTPS: "443"}[s.transport]
+	host := rest
+	if addr, err := netip.ParseAddr(strings.Trim(rest, "[]")); err == nil {
+		host = addr.String()
+	} else if h, p, err := net.SplitHostPort(rest); err == nil {
+		host, port = h, p
+	}
+	if _, err := netip.ParseAddr(host); err != nil {
+		return server{}, fmt.Errorf("invalid nameserver %q: must be an IP address", entry)
+	}
+	s.addr = net.JoinHostPort(host, port)
+	s.name = host
+	if name != "" {
+		s.name = name
+	}
+	if s.url != nil {
+		s.url.Host = strings.TrimSuffix(net.JoinHostPort(s.name, port), ":443")
+	}
+	return s, nil
+}
+
+type resolver struct {
+	servers []server
+	next    atomic.Uint32
+	dialer  net.Dialer
+}
+
+// dial connects to the next nameserver, ignoring the address from
+// /etc/resolv.conf that the resolver asks for. Connections that are not
+// packet connections carry queries with the TCP framing, which is what
+// DNS over TLS uses and what dohConn translates.
+func (r *resolver) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	s := r.servers[int(r.next.Add(1)-1)%len(r.servers)]
+	switch s.transport {
+	case transportTLS:
+		d := &tls.Dialer{NetDialer: &r.dialer, Config: &tls.Config{ServerName: s.name}}
+		return d.DialContext(ctx, "tcp", s.addr)
+	case transportHTTPS:
+		return &dohConn{ctx: ctx, client: s.client, url: s.url.String()}, nil
+	}
+	return r.dialer.DialContext(ctx, network, s.addr)
+}
@@ -0,0 +1,197 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		entry     string
+		transport transport
+		addr      string
+		name      string
+		url       string
+	}{
+		{"1.1.1.1", transportPlain, "1.1.1.1:53", "1.1.1.1", ""},
+		{" 192.0.2.1:5353 ", transportPlain, "192.0.2.1:5353", "192.0.2.1", ""},
+		{"2606:4700::1111", transportPlain, "[2606:4700::1111]:53", "2606:4700::1111", ""},
+		{"[2606:4700::1111]:53", transportPlain, "[2606:4700::1111]:53", "2606:4700::1111", ""},
+		{"tls://1.1.1.1", transportTLS, "1.1.1.1:853", "1.1.1.1", ""},
+		{"tls://9.9.9.9#dns.quad9.net", transportTLS, "9.9.9.9:853", "dns.quad9.net", ""},
+		{"tls://[2620:fe::fe]:8853#dns.quad9.net", transportTLS, "[2620:fe::fe]:8853", "dns.quad9.net", ""},
+		{"https://1.1.1.1/dns-query", transportHTTPS, "1.1.1.1:443", "1.1.1.1", "https://1.1.1.1/dns-query"},
+		{"https://1.1.1.1", transportHTTPS, "1.1.1.1:443", "1.1.1.1", "https://1.1.1.1/dns-query"},
+		{"https://8.8.8.8:8443/resolve#dns.google", transportHTTPS, "8.8.8.8:8443", "dns.google", "https://dns.google:8443/resolve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			s, err := parseServer(tt.entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.transport != tt.transport || s.addr != tt.addr || s.name != tt.name {
+				t.Errorf("got transport %d, addr %s, name %s", s.transport, s.addr, s.name)
+			}
+			var url string
+			if s.url != nil {
+				url = s.url.String()
+			}
+			if url != tt.url {
+				t.Errorf("url %q, want %q", url, tt.url)
+			}
+		})
+	}
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	for _, servers := range [][]string{
+		nil,
+		{"dns.google"},
+		{"tls://dns.quad9.net"},
+		{"https://cloudflare-dns.com/dns-query"},
+		{"udp://1.1.1.1"},
+		{"1.1.1.1", "resolver"},
+	} {
+		if _, err := NewResolver(servers, time.Second); err == nil {
+			t.Errorf("NewResolver(%q) succeeded", servers)
+		}
+	}
+}
+
+// answer returns the response to a DNS query: an A record with ip for A
+// questions, and no records for others.
+func answer(query []byte, ip net.IP) []byte {
+	// The question is the name's labels, then its type and class
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += 1 + int(query[end])
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	resp := append([]byte{}, query[:end]...)
+	binary.BigEndian.PutUint16(resp[2:], 0x8180) // response, recursion available
+	binary.BigEndian.PutUint16(resp[6:], 0)
+	binary.BigEndian.PutUint16(resp[8:], 0)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+	if qtype == 1 {
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		resp = append(resp, ip.To4()...)
+	}
+	return resp
+}
+
+func TestResolverPlain(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(answer(buf[:n], net.IPv4(192, 0, 2, 10)), from)
+		}
+	}()
+
+	r, err := NewResolver([]string{conn.LocalAddr().String()}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := r.LookupHost(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(addrs, []string{"192.0.2.10"}) {
+		t.Errorf("LookupHost = %v", addrs)
+	}
+}
+
+func TestResolverDoH(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "not a DNS message", http.StatusBadRequest)
+			return
+		}
+		query, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(answer(query, net.IPv4(192, 0, 2, 20)))
+	}))
+	defer srv.Close()
+
+	r := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		return &dohConn{ctx: ctx, client: srv.Client(), url: srv.URL + "/dns-query"}, nil
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := r.LookupHost(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(addrs, []string{"192.0.2.20"}) {
+		t.Errorf("LookupHost = %v", addrs)
+	}
+}
+
+func TestDoHErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	c := &dohConn{ctx: context.Background(), client: srv.Client(), url: srv.URL}
+	query := []byte{0, 2, 0xab, 0xcd}
+	if _, err := c.Write(query); err == nil {
+		t.Error("Write succeeded against an endpoint answering 403")
+	}
+	// A partial message waits for the rest before it is sent
+	c = &dohConn{ctx: context.Background(), client: srv.Client(), url: srv.URL}
+	if n, err := c.Write(query[:3]); err != nil || n != 3 {
+		t.Errorf("partial Write = %d, %v", n, err)
+	}
+}
+
+func TestResolverTakesTurns(t *testing.T) {
+	r := &resolver{}
+	for _, entry := range []string{"https://192.0.2.1", "https://192.0.2.2", "https://192.0.2.3"} {
+		s, err := parseServer(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.servers = append(r.servers, s)
+	}
+	var got []string
+	for range 4 {
+		conn, err := r.dial(context.Background(), "tcp", "ignored:53")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, conn.RemoteAddr().String())
+	}
+	want := []string{"https://192.0.2.1/dns-query", "https://192.0.2.2/dns-query", "https://192.0.2.3/dns-query", "https://192.0.2.1/dns-query"}
+	if !slices.Equal(got, want) {
+		t.Errorf("dialed %v, want %v", got, want)
+	}
+}
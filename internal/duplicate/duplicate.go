@@ -0,0 +1,91 @@
+// Package duplicate detects identical submissions repeated within a window.
+package duplicate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Detector remembers submission fingerprints for a fixed window.
+type Detector struct {
+	window    time.Duration
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+	clock     clock.Clock
+}
+
+// New returns a Detector that remembers keys for window.
+func New(window time.Duration) *Detector {
+	return &Detector{
+		window: window,
+		seen:   make(map[string]time.Time),
+		clock:  clock.System,
+	}
+}
+
+// UseClock makes d tell the time by c instead of the system clock. It must
+// be called before d is used.
+func (d *Detector) UseClock(c clock.Clock) {
+	d.clock = c
+}
+
+// Fingerprint hashes the normalized parts of a submission, so differences in
+// case and whitespace do not defeat detection.
+func Fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(strings.Join(strings.Fields(strings.ToLower(part)), " ")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Claim records keys and reports whether none of them was seen within the
+// window. Keys are recorded only when the claim succeeds.
+func (d *Detector) Claim(keys ...string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	d.sweep(now)
+
+	for _, key := range keys {
+		if at, ok := d.seen[key]; ok && now.Sub(at) < d.window {
+			return false
+		}
+	}
+	for _, key := range keys {
+		d.seen[key] = now
+	}
+	return true
+}
+
+// Release forgets keys, e.g. when the claimed submission failed to send and
+// the user should be allowed to retry.
+func (d *Detector) Release(keys ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, key := range keys {
+		delete(d.seen, key)
+	}
+}
+
+// sweep drops expired keys at most once per window.
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	for key, at := range d.seen {
+		if now.Sub(at) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+	d.lastSweep = now
+}
@@ -0,0 +1,151 @@
+package duplicate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Response is a response recorded for replay.
+type Response struct {
+	Status      int
+	ContentType string
+	// Location is sent as the Location header, if set.
+	Location string
+	Body     []byte
+}
+
+// Responses remembers the responses to requests for a fixed window, so a
+// client retrying a request, e.g. after a network timeout, gets the
+// original response instead of having it processed again.
+type Responses struct {
+	window    time.Duration
+	mu        sync.Mutex
+	entries   map[string]*Entry
+	lastSweep time.Time
+	clock     clock.Clock
+}
+
+// Entry is a request claimed through Start whose response is recorded
+// once it is finished.
+type Entry struct {
+	keys []string
+	// fingerprint identifies the content of the request.
+	fingerprint string
+	done        chan struct{}
+	// resp and ok are set before done is closed; at is when it was closed.
+	resp Response
+	ok   bool
+	at   time.Time
+}
+
+// NewResponses returns a Responses that remembers responses for window.
+func NewResponses(window time.Duration) *Responses {
+	return &Responses{
+		window:  window,
+		entries: make(map[string]*Entry),
+		clock:   clock.System,
+	}
+}
+
+// UseClock makes rs tell the time by c instead of the system clock. It must
+// be called before rs is used.
+func (rs *Responses) UseClock(c clock.Clock) {
+	rs.clock = c
+}
+
+// Start claims keys for a new request with the given content fingerprint
+// and returns its entry with started set. If a request with one of the keys
+// is in progress or finished within the window, Start returns that
+// request's entry and the key it was found by instead and records nothing.
+func (rs *Responses) Start(fingerprint string, keys ...string) (e *Entry, found string, started bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := rs.clock.Now()
+	rs.sweep(now)
+
+	for _, key := range keys {
+		if e, ok := rs.entries[key]; ok && !rs.expired(e, now) {
+			return e, key, false
+		}
+	}
+	e = &Entry{keys: keys, fingerprint: fingerprint, done: make(chan struct{})}
+	for _, key := range keys {
+		rs.entries[key] = e
+	}
+	return e, "", true
+}
+
+// Matches reports whether e's request had the given content fingerprint.
+func (e *Entry) Matches(fingerprint string) bool {
+	return e.fingerprint == fingerprint
+}
+
+// Finish records resp as the response to e's request.
+func (rs *Responses) Finish(e *Entry, resp Response) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if e.finished() {
+		return
+	}
+	e.resp, e.ok, e.at = resp, true, rs.clock.Now()
+	close(e.done)
+}
+
+// Abandon forgets e's request unless it was finished, so a retry is
+// processed anew, e.g. when it failed.
+func (rs *Responses) Abandon(e *Entry) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if e.finished() {
+		return
+	}
+	for _, key := range e.keys {
+		if rs.entries[key] == e {
+			delete(rs.entries, key)
+		}
+	}
+	e.at = rs.clock.Now()
+	close(e.done)
+}
+
+// Wait waits until e's request is finished or abandoned, or ctx is done,
+// and returns its response. ok is false unless the request was finished.
+func (e *Entry) Wait(ctx context.Context) (resp Response, ok bool) {
+	select {
+	case <-e.done:
+		return e.resp, e.ok
+	case <-ctx.Done():
+		return Response{}, false
+	}
+}
+
+// finished reports whether e was finished or abandoned. It requires the
+// lock of the Responses.
+func (e *Entry) finished() bool {
+	return !e.at.IsZero()
+}
+
+// expired reports whether e was finished longer than the window ago.
+// Requests in progress do not expire.
+func (rs *Responses) expired(e *Entry, now time.Time) bool {
+	return e.finished() && now.Sub(e.at) >= rs.window
+}
+
+// sweep drops expired entries at most once per window.
+func (rs *Responses) sweep(now time.Time) {
+	if now.Sub(rs.lastSweep) < rs.window {
+		return
+	}
+	for key, e := range rs.entries {
+		if rs.expired(e, now) {
+			delete(rs.entries, key)
+		}
+	}
+	rs.lastSweep = now
+}
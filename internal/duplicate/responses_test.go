@@ -0,0 +1,114 @@
+package duplicate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+func TestResponsesStart(t *testing.T) {
+	rs := NewResponses(time.Minute)
+	first, _, started := rs.Start("fp1", "key:a", "content:x")
+	if !started {
+		t.Fatal("first request not started")
+	}
+	rs.Finish(first, Response{Status: 200, Body: []byte("ok")})
+
+	tests := []struct {
+		name    string
+		keys    []string
+		found   string
+		started bool
+	}{
+		{"same key", []string{"key:a"}, "key:a", false},
+		{"same content", []string{"key:b", "content:x"}, "content:x", false},
+		{"new keys", []string{"key:c", "content:y"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, found, started := rs.Start("fp2", tt.keys...)
+			if started != tt.started || found != tt.found {
+				t.Fatalf("Start = %q, %v; want %q, %v", found, started, tt.found, tt.started)
+			}
+			if !started && (e != first || e.Matches("fp2") || !e.Matches("fp1")) {
+				t.Error("Start returned the wrong entry")
+			}
+		})
+	}
+}
+
+func TestResponsesAbandon(t *testing.T) {
+	rs := NewResponses(time.Minute)
+	e, _, _ := rs.Start("fp", "key:a")
+	waiter, _, started := rs.Start("fp", "key:a")
+	if started || waiter != e {
+		t.Fatal("retry in progress did not get the original entry")
+	}
+	rs.Abandon(e)
+	if _, ok := waiter.Wait(context.Background()); ok {
+		t.Error("abandoned request has a response")
+	}
+	if _, _, started := rs.Start("fp", "key:a"); !started {
+		t.Error("retry of an abandoned request was not started")
+	}
+}
+
+func TestResponsesExpire(t *testing.T) {
+	c := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	rs := NewResponses(time.Minute)
+	rs.UseClock(c)
+	e, _, _ := rs.Start("fp", "key:a")
+	rs.Finish(e, Response{Status: 200})
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		started bool
+	}{
+		{"within the window", 59 * time.Second, false},
+		{"after the window", time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Advance(tt.advance)
+			if _, _, started := rs.Start("fp", "key:a"); started != tt.started {
+				t.Errorf("started = %v, want %v", started, tt.started)
+			}
+		})
+	}
+}
+
+func TestDetector(t *testing.T) {
+	c := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	d := New(time.Minute)
+	d.UseClock(c)
+	key := Fingerprint("contact", "Ada@Example.com", "Hello  there")
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		claimed bool
+	}{
+		{"first", 0, key, true},
+		{"repeated", 0, key, false},
+		{"case and spaces differ", 0, Fingerprint("contact", "ada@example.com", "hello there"), false},
+		{"other content", 0, Fingerprint("contact", "ada@example.com", "bye"), true},
+		{"after the window", time.Minute, key, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Advance(tt.advance)
+			if claimed := d.Claim(tt.key); claimed != tt.claimed {
+				t.Errorf("Claim = %v, want %v", claimed, tt.claimed)
+			}
+		})
+	}
+
+	d.Release(key)
+	if !d.Claim(key) {
+		t.Error("released key could not be claimed again")
+	}
+}
@@ -0,0 +1,64 @@
+package e2e_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/e2e"
+	"form2mail/internal/handler"
+)
+
+func TestHarness(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		opts      handler.Options
+	}{
+		{"defaults", nil, handler.Options{}},
+		{"configured", func(c *config.Config) { c.RecipientEmail = "sales@example.com" }, handler.Options{}},
+		{"manual clock", func(c *config.Config) { c.Location = time.UTC }, handler.Options{Clock: clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)), IDs: clock.Seeded(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := e2e.New(tt.configure, nil, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			resp, err := h.Post("/contact", url.Values{"name": {"Ada"}, "email": {"ada@example.com"}, "message": {"Hello"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d, want 200", resp.StatusCode)
+			}
+			messages, err := h.SMTP.Wait(2, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recipients := map[string]bool{}
+			for _, m := range messages {
+				if m.User != "form2mail" || m.From != e2e.FromEmail {
+					t.Errorf("message sent as %q from %q", m.User, m.From)
+				}
+				recipients[m.To[0]] = true
+			}
+			if !recipients[h.Config.RecipientEmail] || !recipients["ada@example.com"] {
+				t.Errorf("messages went to %v", recipients)
+			}
+			if tt.opts.Clock != nil {
+				for _, m := range messages {
+					if date := m.Header("Date"); date != "Sat, 01 Mar 2025 12:00:00 +0000" {
+						t.Errorf("Date = %q, want the manual clock's", date)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package e2e wires the contact handler to an in-process SMTP server, so a
+// submission can be followed from the HTTP POST to the received message.
+// It is meant for contributors' and embedders' end-to-end tests:
+//
+//	h, err := e2e.New(nil, nil, handler.Options{})
+//	defer h.Close()
+//	resp, err := h.Post("/contact", url.Values{"name": {"Ada"}, ...})
+//	messages, err := h.SMTP.Wait(2, 5*time.Second)
+//
+// For golden-file comparisons, set Options.Clock to a clock.Manual and
+// Options.IDs to clock.Seeded: submission IDs, dates, and Message-IDs, and
+// so the messages byte for byte, then repeat on every run.
+package e2e
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/email"
+	"form2mail/internal/form"
+	"form2mail/internal/handler"
+	"form2mail/internal/smtptest"
+)
+
+// Addresses the harness sends from and to unless configure changes them.
+const (
+	RecipientEmail = "owner@example.com"
+	FromEmail      = "form2mail@example.com"
+)
+
+// Harness serves the form endpoints over HTTP and delivers to SMTP.
+type Harness struct {
+	SMTP    *smtptest.Server
+	HTTP    *httptest.Server
+	Config  config.Config
+	Sender  *email.Sender
+	Contact *handler.ContactHandler
+}
+
+// New starts an SMTP server and an HTTP server with the contact handler
+// routed as in cmd/server. The configuration is read from the environment
+// like the server's, then pointed at the SMTP server with RecipientEmail
+// and FromEmail, and finally passed to configure, which may be nil. forms
+// may be nil for the default /contact form only.
+func New(configure func(*config.Config), forms *form.Registry, opts handler.Options) (*Harness, error) {
+	smtpServer, err := smtptest.NewServer()
+	if err != nil {
+		return nil, err
+	}
+	smtpServer.User, smtpServer.Password = "form2mail", "secret"
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	cfg.DeliveryMode = config.DeliverySMTP
+	cfg.MailProvider = config.MailProviderSMTP
+	cfg.DryRun = false
+	cfg.SMTPHost = smtpServer.Host()
+	cfg.SMTPPort = smtpServer.Port()
+	cfg.SMTPUser = smtpServer.User
+	cfg.SMTPPassword = smtpServer.Password
+	cfg.SMTPProxy = ""
+	cfg.RecipientEmail = RecipientEmail
+	cfg.FromEmail = FromEmail
+	if configure != nil {
+		configure(&cfg)
+	}
+	if forms == nil {
+		forms = form.NewRegistry()
+	}
+
+	sender := email.NewSender(cfg, nil)
+	if opts.Clock != nil || opts.IDs != nil {
+		// Messages then repeat along with the submissions
+		c := opts.Clock
+		if c == nil {
+			c = clock.System
+		}
+		sender.UseClock(c, opts.IDs)
+		sender.UseJitter(clock.SeededJitter(1))
+	}
+	if opts.Clock != nil {
+		// So do webhook signatures, usage months, and MX answers
+		if opts.Forwarder != nil {
+			opts.Forwarder.UseClock(opts.Clock)
+		}
+		if opts.Usage != nil {
+			opts.Usage.UseClock(opts.Clock)
+		}
+		if opts.MX != nil {
+			opts.MX.UseClock(opts.Clock)
+		}
+	}
+	contact := handler.NewContactHandler(sender, cfg, forms, opts)
+	mux := http.NewServeMux()
+	mux.Handle("/contact", contact)
+	mux.Handle("/forms/{formID}", contact)
+
+	return &Harness{
+		SMTP:    smtpServer,
+		HTTP:    httptest.NewServer(mux),
+		Config:  cfg,
+		Sender:  sender,
+		Contact: contact,
+	}, nil
+}
+
+// Post submits values as a URL-encoded form to path, e.g. "/contact".
+func (h *Harness) Post(path string, values url.Values) (*http.Response, error) {
+	return h.HTTP.Client().Post(h.HTTP.URL+path, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+// Close stops both servers.
+func (h *Harness) Close() {
+	h.HTTP.Close()
+	h.SMTP.Close()
+}
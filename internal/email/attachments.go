@@ -0,0 +1,54 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Attachment is an uploaded file linked from the notification, or, with
+// Location set instead of URL, stored where only the owner can reach it.
+type Attachment struct {
+	Name     string
+	Size     int64
+	URL      string
+	Expires  time.Time
+	Location string
+}
+
+// File is an uploaded file attached to the notification.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// attachmentsHTML renders the "Attachments" section of the notification.
+func (s *Sender) attachmentsHTML(attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<h3>Attachments</h3>\n")
+	for _, a := range attachments {
+		if a.URL == "" {
+			fmt.Fprintf(&b, "\t\t\t<p>%s (%s, stored at %s)</p>\n", html.EscapeString(a.Name), formatSize(a.Size), html.EscapeString(a.Location))
+			continue
+		}
+		fmt.Fprintf(&b, "\t\t\t<p><a href=\"%s\">%s</a> (%s, link valid until %s)</p>\n",
+			html.EscapeString(a.URL), html.EscapeString(a.Name), formatSize(a.Size), s.formatTime(a.Expires))
+	}
+	return b.String()
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,128 @@
+package email
+
+import (
+	"fmt"
+
+	"form2mail/internal/config"
+)
+
+// Credentials authenticate against the mail provider: User and Password
+// against the SMTP server, APIKey against the SendGrid or Mailgun API.
+type Credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	APIKey   string `json:"api_key,omitempty"`
+}
+
+func (s *Sender) credentials() Credentials {
+	return *s.creds.Load()
+}
+
+// VerifyCredentials checks that the provider accepts creds without sending
+// anything: it opens and closes an authenticated SMTP session, or asks the
+// API whether it accepts the key.
+func (s *Sender) VerifyCredentials(creds Credentials) error {
+	ctx, cancel := s.attemptContext()
+	defer cancel()
+	if p, ok := s.provider.(keyedProvider); ok {
+		return p.probeKey(ctx, creds.APIKey)
+	}
+	client, err := s.dialSMTP(ctx, creds, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Quit()
+}
+
+// RotateCredentials switches to creds once a verification probe succeeds.
+// Sessions and requests already in progress finish with the old
+// credentials; new ones use the new credentials. On failure the old
+// credentials stay active. The API providers only take an APIKey, the
+// SMTP server only a User and Password.
+func (s *Sender) RotateCredentials(creds Credentials) error {
+	if p, ok := s.provider.(keyedProvider); ok {
+		return s.rotateAPIKey(p, creds)
+	}
+	if creds.APIKey != "" {
+		return fmt.Errorf("api_key only applies to MAIL_PROVIDER sendgrid or mailgun")
+	}
+	if creds.User == "" {
+		creds.User = s.credentials().User
+	}
+	if creds.Password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+	if creds == s.credentials() {
+		return nil
+	}
+
+	if err := s.VerifyCredentials(creds); err != nil {
+		return fmt.Errorf("verification probe failed, keeping current credentials: %w", err)
+	}
+	s.creds.Store(&creds)
+	s.logger.Info("SMTP credentials rotated", "user", creds.User)
+	return nil
+}
+
+func (s *Sender) rotateAPIKey(p keyedProvider, creds Credentials) error {
+	if creds.User != "" || creds.Password != "" {
+		return fmt.Errorf("MAIL_PROVIDER %s takes an api_key, not a user and password", s.providerName)
+	}
+	if creds.APIKey == "" {
+		return fmt.Errorf("api_key must not be empty")
+	}
+	if creds.APIKey == p.apiKey().get() {
+		return nil
+	}
+
+	if err := s.VerifyCredentials(creds); err != nil {
+		return fmt.Errorf("verification probe failed, keeping current API key: %w", err)
+	}
+	p.apiKey().set(creds.APIKey)
+	s.logger.Info("API key rotated", "provider", s.providerName)
+	return nil
+}
+
+// ReadCredentialFiles reads credentials from secret files such as those
+// mounted by Docker or Kubernetes. With an API provider, secretFile holds
+// the API key and userFile is not used; with SMTP, secretFile holds the
+// password and an empty userFile keeps the current user.
+func (s *Sender) ReadCredentialFiles(userFile, secretFile string) (Credentials, error) {
+	if _, ok := s.provider.(keyedProvider); ok {
+		key, err := config.ReadSecretFile(secretFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		return Credentials{APIKey: key}, nil
+	}
+	creds := Credentials{User: s.credentials().User}
+	if userFile != "" {
+		user, err := config.ReadSecretFile(userFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.User = user
+	}
+	password, err := config.ReadSecretFile(secretFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	creds.Password = password
+	return creds, nil
+}
+
+// CheckCredentialFiles reads the secret files and rotates to their contents
+// if they differ from the active credentials. Polled on a schedule, a
+// failed probe is retried on the next poll, so rotating the file before the
+// provider accepts the new password or key is safe.
+func (s *Sender) CheckCredentialFiles(userFile, secretFile string) error {
+	creds, err := s.ReadCredentialFiles(userFile, secretFile)
+	if err != nil {
+		return fmt.Errorf("failed to read credential files: %w", err)
+	}
+	if err := s.RotateCredentials(creds); err != nil {
+		return fmt.Errorf("failed to rotate credentials: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package email
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"form2mail/internal/config"
+)
+
+func TestRotateAPIKey(t *testing.T) {
+	var mu sync.Mutex
+	var sentWith []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.URL.Path == "/v3/mail/send" {
+			mu.Lock()
+			sentWith = append(sentWith, auth)
+			mu.Unlock()
+		}
+		if auth != "Bearer old" && auth != "Bearer new" {
+			http.Error(w, `{"errors":[{"message":"bad key"}]}`, http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s := NewSender(config.Config{
+		MailProvider:   config.MailProviderSendGrid,
+		SendGridAPIKey: "old",
+		SendGridAPIURL: srv.URL,
+		DeliveryMode:   config.DeliverySMTP,
+		FromEmail:      "form2mail@example.com",
+	}, nil)
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		creds Credentials
+		want  string
+	}{
+		{"wrong key", Credentials{APIKey: "wrong"}, "verification probe failed"},
+		{"SMTP credentials", Credentials{User: "u", Password: "p"}, "takes an api_key"},
+		{"no key", Credentials{}, "api_key must not be empty"},
+		{"same key", Credentials{APIKey: "old"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.RotateCredentials(tt.creds)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("RotateCredentials = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RotateCredentials = %v, want an error mentioning %q", err, tt.want)
+			}
+			if got := s.provider.(keyedProvider).apiKey().get(); got != "old" {
+				t.Errorf("key = %q, want the old one kept", got)
+			}
+		})
+	}
+
+	if err := s.Send("owner@example.com", "before", "<p>hi</p>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckCredentialFiles("", keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send("owner@example.com", "after", "<p>hi</p>"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sentWith) != 2 || sentWith[0] != "Bearer old" || sentWith[1] != "Bearer new" {
+		t.Errorf("messages sent with %q, want the old key and then the new one", sentWith)
+	}
+}
+
+func TestRotateSMTPCredentialsRefusesAPIKey(t *testing.T) {
+	s := NewSender(config.Config{MailProvider: config.MailProviderSMTP, SMTPUser: "u", SMTPPassword: "p"}, nil)
+	if err := s.RotateCredentials(Credentials{APIKey: "key"}); err == nil || !strings.Contains(err.Error(), "api_key only applies") {
+		t.Errorf("RotateCredentials = %v, want the API key refused", err)
+	}
+	if s.credentials().Password != "p" {
+		t.Error("SMTP credentials changed")
+	}
+}
@@ -0,0 +1,67 @@
+package email
+
+import (
+	"log/slog"
+
+	"form2mail/internal/deadletter"
+)
+
+// UseDeadLetters keeps the messages whose delivery failed for good in
+// store, so they can be sent again with ResendDeadLetter.
+func (s *Sender) UseDeadLetters(store *deadletter.Store) {
+	s.deadLetters = store
+}
+
+// bury keeps msg as a dead letter after its delivery through providers
+// failed with err.
+func (s *Sender) bury(logger *slog.Logger, id, to string, providers []string, msg []byte, envID string, err error) {
+	if s.deadLetters == nil {
+		return
+	}
+	e := deadletter.Entry{
+		ID:         id,
+		To:         to,
+		Providers:  providers,
+		Message:    msg,
+		EnvelopeID: envID,
+		Error:      err.Error(),
+		FailedAt:   s.clock.Now(),
+	}
+	if err := s.deadLetters.Put(e); err != nil {
+		logger.Error("Failed to keep undeliverable message", "error", err)
+		return
+	}
+	logger.Info("Kept undeliverable message as a dead letter", "dead_letter", id)
+}
+
+// ResendDeadLetter delivers the dead letter id through the providers it
+// was not delivered through and removes it. If delivery fails again, the
+// dead letter is kept with the new error.
+func (s *Sender) ResendDeadLetter(id string) error {
+	if s.deadLetters == nil {
+		return deadletter.ErrNotFound
+	}
+	e, err := s.deadLetters.Get(id)
+	if err != nil {
+		return err
+	}
+	logger := s.logger.With("dead_letter", id, "to", e.To)
+	if s.config.DryRun {
+		logger.Info("[dry run] Would resend dead letter")
+		return nil
+	}
+
+	undelivered, err := s.deliverThrough(logger, e.Providers, e.To, e.Message, "", e.EnvelopeID)
+	s.report(err)
+	if err != nil {
+		e.Providers, e.Error, e.FailedAt = undelivered, err.Error(), s.clock.Now()
+		e.Resends++
+		if putErr := s.deadLetters.Put(e); putErr != nil {
+			logger.Error("Failed to update dead letter", "error", putErr)
+		}
+		logger.Error("Failed to resend dead letter", "error", err)
+		return err
+	}
+	logger.Info("Resent dead letter")
+	return s.deadLetters.Remove(id)
+}
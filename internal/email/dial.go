@@ -0,0 +1,129 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// fallbackDelay is how long a connection attempt runs alone before the
+// next address is tried alongside it, as RFC 8305 recommends.
+const fallbackDelay = 250 * time.Millisecond
+
+// dialDirect connects to addr with happy eyeballs (RFC 8305): the host's
+// addresses are tried alternating between IPv6 and IPv4, each
+// fallbackDelay after the previous one or as soon as it failed, and the
+// first connection wins. A host whose IPv6 route is broken thus connects
+// over IPv4 within moments instead of after a timeout. SMTP_IP_VERSION
+// restricts the addresses to one family, SMTP_ATTEMPT_TIMEOUT bounds each
+// attempt, and SMTP_DIAL_TIMEOUT all of them together.
+func (s *Sender) dialDirect(ctx context.Context, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	// Attempts still running are canceled once one has connected
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if s.config.SMTPDialTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.config.SMTPDialTimeout)
+		defer cancel()
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips = interleave(ips, s.config.SMTPIPVersion)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s has no IPv%s address", host, s.config.SMTPIPVersion)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(ips))
+	next, pending := 0, 0
+	start := func() {
+		d := net.Dialer{Timeout: s.config.SMTPAttemptTimeout}
+		target := net.JoinHostPort(ips[next].String(), port)
+		go func() {
+			conn, err := d.DialContext(ctx, "tcp", target)
+			results <- result{conn, err}
+		}()
+		next++
+		pending++
+	}
+
+	start()
+	var first error
+	for pending > 0 {
+		var fallback <-chan time.Time
+		if next < len(ips) {
+			fallback = time.After(fallbackDelay)
+		}
+		select {
+		case <-fallback:
+			start()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// Close the connections of attempts that still succeed
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			if first == nil {
+				first = r.err
+			}
+			if next < len(ips) && ctx.Err() == nil {
+				start()
+			}
+		}
+	}
+	if len(ips) > 1 {
+		return nil, fmt.Errorf("none of the %d addresses of %s answered, first: %w", len(ips), host, first)
+	}
+	return nil, first
+}
+
+// interleave orders ips alternating between the address families, starting
+// with the family of the first, and keeps only version ("4" or "6") if it
+// is set.
+func interleave(ips []net.IPAddr, version string) []net.IPAddr {
+	var v4, v6 []net.IPAddr
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch version {
+	case "4":
+		return v4
+	case "6":
+		return v6
+	}
+	primary, secondary := v6, v4
+	if len(ips) > 0 && ips[0].IP.To4() != nil {
+		primary, secondary = v4, v6
+	}
+	out := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			out = append(out, primary[i])
+		}
+		if i < len(secondary) {
+			out = append(out, secondary[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// envelopeIDPrefix starts the envelope IDs of notifications, so returned
+// delivery status notifications can be told from others.
+const envelopeIDPrefix = "F2M-"
+
+// envelopeID returns the envelope ID to request delivery status
+// notifications (RFC 3461) for when sending about sub to to, or "" if
+// none are requested. Only the owner's notifications of stored
+// submissions get one, since only they can be matched back.
+func (s *Sender) envelopeID(sub Submission, to string) string {
+	if s.config.DSNNotify == "" || sub.ID == "" || to != s.recipient(sub) {
+		return ""
+	}
+	return envelopeIDPrefix + sub.ID
+}
+
+// supportsDSN reports whether client's server offers DSN, logging once if
+// it does not.
+func (s *Sender) supportsDSN(client *smtp.Client) bool {
+	if ok, _ := client.Extension("DSN"); ok {
+		s.noDSN.Store(false)
+		return true
+	}
+	if !s.noDSN.Swap(true) {
+		s.logger.Warn("SMTP server does not offer DSN, sending notifications without DSN_NOTIFY", "host", s.config.SMTPHost)
+	}
+	return false
+}
+
+// mailDSN sends MAIL FROM with the RET and ENVID parameters, which
+// net/smtp's Mail cannot add. Like Mail, it declares 8BITMIME and SMTPUTF8
+// when the server offers them.
+func mailDSN(client *smtp.Client, from, ret, envID string) error {
+	cmd := fmt.Sprintf("MAIL FROM:<%s> RET=%s ENVID=%s", from, ret, xtext(envID))
+	if ok, _ := client.Extension("8BITMIME"); ok {
+		cmd += " BODY=8BITMIME"
+	}
+	if ok, _ := client.Extension("SMTPUTF8"); ok {
+		cmd += " SMTPUTF8"
+	}
+	return smtpCommand(client, 250, cmd)
+}
+
+// rcptDSN sends RCPT TO asking for the notifications in notify, e.g.
+// "SUCCESS,FAILURE" or "NEVER".
+func rcptDSN(client *smtp.Client, to, notify string) error {
+	return smtpCommand(client, 25, fmt.Sprintf("RCPT TO:<%s> NOTIFY=%s ORCPT=rfc822;%s", to, notify, xtext(to)))
+}
+
+func smtpCommand(client *smtp.Client, code int, cmd string) error {
+	if strings.ContainsAny(cmd, "\r\n") {
+		return errors.New("smtp: a line must not contain CR or LF")
+	}
+	id, err := client.Text.Cmd("%s", cmd)
+	if err != nil {
+		return err
+	}
+	client.Text.StartResponse(id)
+	defer client.Text.EndResponse(id)
+	_, _, err = client.Text.ReadResponse(code)
+	return err
+}
+
+// xtext encodes s as the xtext of RFC 3461.
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// DeliveryStatus is a delivery status notification (RFC 3464) returned for
+// a notification.
+type DeliveryStatus struct {
+	// SubmissionID is the submission the notification was about, taken
+	// from the original envelope ID; "" if it is not one of ours.
+	SubmissionID string
+	Recipients   []RecipientStatus
+}
+
+// RecipientStatus is the outcome for one recipient.
+type RecipientStatus struct {
+	Recipient string
+	// Action is one of failed, delayed, delivered, relayed, or expanded.
+	Action string
+	// Status is the enhanced status code, e.g. "5.1.1".
+	Status     string
+	Diagnostic string
+}
+
+// ErrNotDSN is returned by ParseDSN for messages that are not delivery
+// status notifications.
+var ErrNotDSN = errors.New("not a delivery status notification")
+
+// ParseDSN reads a delivery status notification from the raw message r.
+func ParseDSN(r io.Reader) (DeliveryStatus, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return DeliveryStatus{}, fmt.Errorf("invalid message: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["boundary"] == "" {
+		return DeliveryStatus{}, ErrNotDSN
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			return DeliveryStatus{}, ErrNotDSN
+		}
+		if err != nil {
+			return DeliveryStatus{}, fmt.Errorf("invalid report: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != "message/delivery-status" && partType != "message/global-delivery-status" {
+			continue
+		}
+		var body io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		return parseDeliveryStatus(body)
+	}
+}
+
+// parseDeliveryStatus reads the per-message fields and then the fields of
+// each recipient, separated by blank lines.
+func parseDeliveryStatus(r io.Reader) (DeliveryStatus, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDeliveryStatus))
+	if err != nil {
+		return DeliveryStatus{}, fmt.Errorf("invalid delivery status: %w", err)
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var status DeliveryStatus
+	for i, group := range bytes.Split(bytes.TrimSpace(data), []byte("\n\n")) {
+		fields, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(group, '\n', '\n')))).ReadMIMEHeader()
+		if err != nil && len(fields) == 0 {
+			return DeliveryStatus{}, fmt.Errorf("invalid delivery status: %w", err)
+		}
+		if i == 0 {
+			if id, ok := strings.CutPrefix(strings.TrimSpace(fields.Get("Original-Envelope-Id")), envelopeIDPrefix); ok {
+				status.SubmissionID = id
+			}
+			continue
+		}
+		recipient := typedValue(fields.Get("Final-Recipient"))
+		if original := typedValue(fields.Get("Original-Recipient")); original != "" {
+			recipient = original
+		}
+		status.Recipients = append(status.Recipients, RecipientStatus{
+			Recipient:  recipient,
+			Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+			Status:     strings.TrimSpace(fields.Get("Status")),
+			Diagnostic: typedValue(fields.Get("Diagnostic-Code")),
+		})
+	}
+	if len(status.Recipients) == 0 {
+		return DeliveryStatus{}, errors.New("delivery status lists no recipients")
+	}
+	return status, nil
+}
+
+// maxDeliveryStatus limits the size of the delivery-status part.
+const maxDeliveryStatus = 1 << 20
+
+// typedValue strips the type from a field like "rfc822; user@example.com".
+func typedValue(v string) string {
+	if _, value, ok := strings.Cut(v, ";"); ok {
+		v = value
+	}
+	return strings.TrimSpace(v)
+}
@@ -0,0 +1,30 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Field is an extra submitted field as shown in the notification.
+type Field struct {
+	Label string
+	// Group is the section heading the field is listed under, if any.
+	Group string
+	Value string
+}
+
+// fieldsHTML renders extra fields, starting a section for each group.
+func fieldsHTML(fields []Field) string {
+	var b strings.Builder
+	group := ""
+	for _, f := range fields {
+		if f.Group != group {
+			group = f.Group
+			fmt.Fprintf(&b, "<h3>%s</h3>\n", html.EscapeString(group))
+		}
+		value := strings.ReplaceAll(html.EscapeString(f.Value), "\n", "<br>")
+		fmt.Fprintf(&b, "\t\t\t<p><strong>%s:</strong> %s</p>\n", html.EscapeString(f.Label), value)
+	}
+	return b.String()
+}
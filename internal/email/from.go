@@ -0,0 +1,23 @@
+package email
+
+import (
+	"net/mail"
+
+	"form2mail/internal/message"
+)
+
+// from returns the From header value for a message sent as name <addr>,
+// and the bare address. Empty values fall back to FROM_NAME and FROM_EMAIL.
+func (s *Sender) from(name, addr string) (header, address string) {
+	if addr == "" {
+		addr = s.config.FromEmail
+	}
+	if name == "" {
+		name = s.config.FromName
+	}
+	if name == "" {
+		return addr, addr
+	}
+	// mail.Address quotes and encodes the name as needed, e.g. for umlauts
+	return (&mail.Address{Name: message.SanitizeHeader(name), Address: addr}).String(), addr
+}
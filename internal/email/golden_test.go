@@ -0,0 +1,80 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/golden"
+	"form2mail/internal/smtptest"
+)
+
+func TestMessagesGolden(t *testing.T) {
+	received := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	sub := Submission{
+		ID:         "sub123",
+		Name:       "Ada Lovelace",
+		Email:      "ada@example.com",
+		Subject:    "Analytical engine",
+		Message:    "Hello,\nI would like a quote for the engine.\n\nAda",
+		ClientIP:   "192.0.2.1",
+		ReceivedAt: received,
+	}
+	tests := []struct {
+		name string
+		send func(s *Sender, sub Submission) error
+		sub  func(sub Submission) Submission
+	}{
+		{"notification", (*Sender).SendContactNotification, func(sub Submission) Submission { return sub }},
+		{"notification_fields_files", (*Sender).SendContactNotification, func(sub Submission) Submission {
+			sub.FormID = "quote"
+			sub.Subject = "Größe und Preis"
+			sub.Fields = []Field{{Label: "Company", Value: "Babbage & Co"}, {Label: "Budget", Group: "Order", Value: "£ 500"}}
+			sub.Files = []File{{Name: "plan übersicht.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4 plan")}}
+			sub.Headers = map[string]string{"X-Campaign": "spring"}
+			return sub
+		}},
+		{"confirmation", (*Sender).SendConfirmation, func(sub Submission) Submission { return sub }},
+		{"confirmation_localized", (*Sender).SendConfirmation, func(sub Submission) Submission {
+			sub.Confirmation = Confirmation{
+				Subject:     "Danke für Ihre Nachricht",
+				Greeting:    "Hallo Ada!",
+				Intro:       "Wir melden uns in Kürze.",
+				YourMessage: "Ihre Nachricht:",
+				Closing:     "Viele Grüße",
+			}
+			return sub
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := smtptest.NewServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+			s := NewSender(config.Config{
+				SMTPHost:           server.Host(),
+				SMTPPort:           server.Port(),
+				SMTPAttemptTimeout: 5 * time.Second,
+				MailProvider:       config.MailProviderSMTP,
+				DeliveryMode:       config.DeliverySMTP,
+				FromEmail:          "form2mail@example.com",
+				FromName:           "Example Contact",
+				RecipientEmail:     "owner@example.com",
+				Location:           time.UTC,
+			}, nil)
+			s.UseClock(clock.NewManual(received.Add(time.Second)), clock.Seeded(1))
+
+			if err := tt.send(s, tt.sub(sub)); err != nil {
+				t.Fatal(err)
+			}
+			messages, err := server.Wait(1, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.Check(t, "testdata/"+tt.name+".eml", messages[0].Data)
+		})
+	}
+}
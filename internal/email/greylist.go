@@ -0,0 +1,191 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// maxGreylistDelay caps the time between two retries of a greylisted
+// message.
+const maxGreylistDelay = time.Hour
+
+// DeferredError reports a message the SMTP server turned away for now, as
+// servers that greylist unknown senders do. The Sender keeps retrying it in
+// the background; Done receives the final outcome, or the last error if the
+// message was left in the outbox on shutdown.
+type DeferredError struct {
+	Err     error
+	RetryAt time.Time
+	Done    <-chan error
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("delivery deferred until %s: %v", e.RetryAt.Format(time.RFC3339), e.Err)
+}
+
+func (e *DeferredError) Unwrap() error {
+	return e.Err
+}
+
+// deferrals tracks the greylisted messages of a Sender, so they can be
+// waited for and retried early on shutdown.
+type deferrals struct {
+	mu      sync.Mutex
+	pending map[*deferral]bool
+	// running counts retries scheduled or in progress.
+	running sync.WaitGroup
+	// count is the number of messages greylisted and not yet settled.
+	count atomic.Int64
+	// flushing is set on shutdown: retries then run at once and are the
+	// last ones.
+	flushing atomic.Bool
+}
+
+// deferral is the next retry of a greylisted message.
+type deferral struct {
+	timer clock.Timer
+	// attempt retries the message; with final set, it is not rescheduled.
+	attempt func(final bool)
+}
+
+// greylisted reports whether err is a temporary rejection of the kind
+// greylisting answers with: 450 or 451, usually to RCPT or DATA.
+func greylisted(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply) && (reply.Code == 450 || reply.Code == 451)
+}
+
+// defers reports whether a delivery that failed with err is retried by
+// deferDelivery rather than failing.
+func (s *Sender) defers(err error) bool {
+	return greylisted(err) && s.config.DeliversSMTP() && s.config.GreylistRetryDelay > 0
+}
+
+// deferDelivery schedules retries of a greylisted message until it is
+// accepted or GREYLIST_RETRY_WINDOW has passed since the first attempt.
+// The delay doubles with each retry, starting at GREYLIST_RETRY_DELAY,
+// since greylisting servers accept a sender once it has waited long
+// enough.
+func (s *Sender) deferDelivery(logger *slog.Logger, id, to string, msg []byte, traceID, envID string, err error) *DeferredError {
+	done := make(chan error, 1)
+	deadline := s.clock.Now().Add(s.config.GreylistRetryWindow)
+	delay := s.config.GreylistRetryDelay
+	deferred := &DeferredError{Err: err, RetryAt: s.clock.Now().Add(delay), Done: done}
+	logger.Info("Message was greylisted, retrying later", "retry_in", delay.String(), "error", err)
+	s.deferrals.count.Add(1)
+
+	d := &deferral{}
+	d.attempt = func(final bool) {
+		// Only SMTP greylists; a Maildir copy was already written
+		err := s.deliverVia(s.providerName, to, msg, traceID, envID)
+		final = final || s.deferrals.flushing.Load()
+		if greylisted(err) {
+			delay = min(2*delay, maxGreylistDelay)
+			if !final && s.clock.Now().Add(delay).Before(deadline) {
+				logger.Info("Message is still greylisted, retrying later", "retry_in", delay.String(), "error", err)
+				s.schedule(d, delay)
+				return
+			}
+			if final && s.outbox != nil {
+				// Its outbox entry stays, so the next start delivers it
+				logger.Warn("Shutting down with a greylisted message left in the outbox", "error", err)
+				s.deferrals.count.Add(-1)
+				done <- err
+				return
+			}
+			logger.Warn("Giving up on greylisted message", "window", s.config.GreylistRetryWindow.String())
+		}
+		s.report(err)
+		s.settle(id, err)
+		if err != nil {
+			logger.Error("Failed to send email", "provider", s.providerName, "error", err)
+			s.bury(logger, id, to, []string{s.providerName}, msg, envID, err)
+		} else {
+			logger.Info("Email sent", "provider", s.providerName)
+		}
+		s.deferrals.count.Add(-1)
+		done <- err
+	}
+	s.schedule(d, delay)
+	return deferred
+}
+
+// schedule retries d after delay, or right away once the Sender is
+// flushing.
+func (s *Sender) schedule(d *deferral, delay time.Duration) {
+	if s.deferrals.flushing.Load() {
+		delay = 0
+	}
+	s.deferrals.mu.Lock()
+	defer s.deferrals.mu.Unlock()
+	if s.deferrals.pending == nil {
+		s.deferrals.pending = make(map[*deferral]bool)
+	}
+	s.deferrals.pending[d] = true
+	s.deferrals.running.Add(1)
+	d.timer = s.clock.AfterFunc(delay, func() {
+		defer s.deferrals.running.Done()
+		if s.take(d) {
+			d.attempt(false)
+		}
+	})
+}
+
+// take removes d from the pending retries and reports whether it was
+// still pending, so each retry runs once.
+func (s *Sender) take(d *deferral) bool {
+	s.deferrals.mu.Lock()
+	defer s.deferrals.mu.Unlock()
+	if !s.deferrals.pending[d] {
+		return false
+	}
+	delete(s.deferrals.pending, d)
+	return true
+}
+
+// Deferred returns the number of greylisted messages awaiting a retry.
+func (s *Sender) Deferred() int {
+	return int(s.deferrals.count.Load())
+}
+
+// FlushDeferred retries every greylisted message right away, one last
+// time, as on shutdown, and waits for the retries until ctx is done.
+// Messages still greylisted stay in the outbox for the next start, if
+// there is one, and fail otherwise. It returns how many messages were
+// retried.
+func (s *Sender) FlushDeferred(ctx context.Context) int {
+	s.deferrals.flushing.Store(true)
+	s.deferrals.mu.Lock()
+	var due []*deferral
+	for d := range s.deferrals.pending {
+		if d.timer.Stop() {
+			delete(s.deferrals.pending, d)
+			due = append(due, d)
+		}
+	}
+	s.deferrals.mu.Unlock()
+
+	for _, d := range due {
+		d.attempt(true)
+		s.deferrals.running.Done()
+	}
+
+	idle := make(chan struct{})
+	go func() {
+		s.deferrals.running.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+	}
+	return len(due)
+}
@@ -0,0 +1,158 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/outbox"
+	"form2mail/internal/smtptest"
+)
+
+func greylistSender(t *testing.T, delay time.Duration, box *outbox.Outbox) (*Sender, *smtptest.Server) {
+	t.Helper()
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.Reject("450 4.7.1 Greylisted, try again later")
+	return NewSender(config.Config{
+		SMTPHost:              server.Host(),
+		SMTPPort:              server.Port(),
+		SMTPAttemptTimeout:    5 * time.Second,
+		MailProvider:          config.MailProviderSMTP,
+		DeliveryMode:          config.DeliverySMTP,
+		DeliveryRetryAttempts: 1,
+		FromEmail:             "form2mail@example.com",
+		GreylistRetryDelay:    delay,
+		GreylistRetryWindow:   4 * time.Hour,
+	}, box), server
+}
+
+func TestGreylistRetry(t *testing.T) {
+	s, server := greylistSender(t, 50*time.Millisecond, nil)
+	var deferred *DeferredError
+	if err := s.Send("owner@example.com", "Hi", "<p>Hi</p>"); !errors.As(err, &deferred) {
+		t.Fatalf("Send = %v, want a deferred delivery", err)
+	}
+	if s.Deferred() != 1 {
+		t.Errorf("Deferred = %d, want 1", s.Deferred())
+	}
+	server.Reject("")
+	select {
+	case err := <-deferred.Done:
+		if err != nil {
+			t.Errorf("retry failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not retried")
+	}
+	if s.Deferred() != 0 {
+		t.Errorf("Deferred = %d after the retry, want 0", s.Deferred())
+	}
+}
+
+func TestGreylistRetryFollowsClock(t *testing.T) {
+	s, server := greylistSender(t, 10*time.Minute, nil)
+	now := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	s.UseClock(now, clock.Seeded(1))
+	var deferred *DeferredError
+	if err := s.Send("owner@example.com", "Hi", "<p>Hi</p>"); !errors.As(err, &deferred) {
+		t.Fatalf("Send = %v, want a deferred delivery", err)
+	}
+	if want := now.Now().Add(10 * time.Minute); !deferred.RetryAt.Equal(want) {
+		t.Errorf("RetryAt = %v, want %v", deferred.RetryAt, want)
+	}
+
+	// Each retry waits for the clock, twice as long as the one before
+	for i, wait := range []time.Duration{10 * time.Minute, 20 * time.Minute} {
+		attempts := server.Rejected()
+		now.Advance(wait - time.Second)
+		time.Sleep(20 * time.Millisecond)
+		if n := server.Rejected(); n != attempts {
+			t.Fatalf("retry %d ran before its time", i+1)
+		}
+		now.Advance(time.Second)
+		waitFor(t, func() bool { return server.Rejected() > attempts && now.Timers() == 1 })
+	}
+
+	server.Reject("")
+	now.Advance(40 * time.Minute)
+	select {
+	case err := <-deferred.Done:
+		if err != nil {
+			t.Errorf("retry failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not retried")
+	}
+}
+
+// waitFor waits for cond to hold, failing the test after 5 seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestFlushDeferred(t *testing.T) {
+	tests := []struct {
+		name      string
+		outbox    bool
+		accept    bool
+		delivered bool
+		stuck     int
+	}{
+		{"accepted", true, true, true, 0},
+		{"still greylisted, kept in the outbox", true, false, false, 1},
+		{"still greylisted, without outbox", false, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var box *outbox.Outbox
+			if tt.outbox {
+				var err error
+				if box, err = outbox.Open(t.TempDir()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s, server := greylistSender(t, time.Hour, box)
+			var deferred *DeferredError
+			if err := s.Send("owner@example.com", "Hi", "<p>Hi</p>"); !errors.As(err, &deferred) {
+				t.Fatalf("Send = %v, want a deferred delivery", err)
+			}
+			if tt.accept {
+				server.Reject("")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if n := s.FlushDeferred(ctx); n != 1 {
+				t.Errorf("FlushDeferred retried %d, want 1", n)
+			}
+			select {
+			case err := <-deferred.Done:
+				if (err == nil) != tt.delivered {
+					t.Errorf("outcome %v, want delivered %v", err, tt.delivered)
+				}
+			default:
+				t.Fatal("FlushDeferred returned before the retry was over")
+			}
+			if s.Deferred() != 0 {
+				t.Errorf("Deferred = %d, want 0", s.Deferred())
+			}
+			if box != nil {
+				if entries, _ := box.Stuck(); len(entries) != tt.stuck {
+					t.Errorf("%d outbox entries left, want %d", len(entries), tt.stuck)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ProviderHealth is the outcome of the latest probe of a delivery provider.
+type ProviderHealth struct {
+	Provider  string    `json:"provider"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Latency is how long the probe took.
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// healthState holds the latest probe results by provider.
+type healthState struct {
+	mu      sync.RWMutex
+	results map[string]ProviderHealth
+}
+
+// providers returns the delivery providers in use: the SMTP host or the
+// HTTP API MAIL_PROVIDER selects, and/or ProviderMaildir.
+func (s *Sender) providers() []string {
+	var providers []string
+	if s.config.DeliversSMTP() {
+		providers = append(providers, s.providerName)
+	}
+	if s.config.DeliversMaildir() {
+		providers = append(providers, ProviderMaildir)
+	}
+	return providers
+}
+
+// probe checks that provider can currently accept messages without
+// delivering anything: an authenticated SMTP session answering NOOP, an
+// API accepting the key, or a writable Maildir.
+func (s *Sender) probe(provider string) error {
+	if provider == ProviderMaildir {
+		return probeMaildir(s.config.MaildirPath)
+	}
+	if p, ok := s.provider.(prober); ok {
+		ctx, cancel := s.attemptContext()
+		defer cancel()
+		return p.Probe(ctx)
+	}
+	return nil
+}
+
+func probeMaildir(dir string) error {
+	tmp := filepath.Join(dir, "tmp")
+	if err := os.MkdirAll(tmp, 0o700); err != nil {
+		return fmt.Errorf("failed to create maildir: %w", err)
+	}
+	f, err := os.CreateTemp(tmp, "probe-*")
+	if err != nil {
+		return fmt.Errorf("maildir is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// CheckHealth probes every provider in use and records the results.
+func (s *Sender) CheckHealth() []ProviderHealth {
+	var results []ProviderHealth
+	for _, provider := range s.providers() {
+		start := time.Now()
+		err := s.probe(provider)
+		result := ProviderHealth{Provider: provider, Healthy: err == nil, CheckedAt: start, Latency: time.Since(start)}
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		s.health.mu.Lock()
+		// Providers count as healthy until their first probe
+		previous, ok := s.health.results[provider]
+		if wasHealthy := !ok || previous.Healthy; wasHealthy != result.Healthy {
+			if result.Healthy {
+				s.logger.Info("Delivery provider is healthy again", "provider", provider)
+			} else {
+				s.logger.Warn("Delivery provider is unhealthy", "provider", provider, "error", err)
+			}
+		}
+		if s.health.results == nil {
+			s.health.results = make(map[string]ProviderHealth)
+		}
+		s.health.results[provider] = result
+		s.health.mu.Unlock()
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// Health returns the latest probe result of every provider in use.
+// Providers not probed yet are reported healthy with a zero CheckedAt.
+func (s *Sender) Health() []ProviderHealth {
+	s.health.mu.RLock()
+	defer s.health.mu.RUnlock()
+
+	var results []ProviderHealth
+	for _, provider := range s.providers() {
+		result, ok := s.health.results[provider]
+		if !ok {
+			result = ProviderHealth{Provider: provider, Healthy: true}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// healthy reports whether provider passed its latest probe, or has not been
+// probed yet.
+func (s *Sender) healthy(provider string) bool {
+	s.health.mu.RLock()
+	defer s.health.mu.RUnlock()
+	result, ok := s.health.results[provider]
+	return !ok || result.Healthy
+}
+
+// ProbeHealth probes the providers as a scheduled job. Failed probes are
+// not errors of the job; they show in Health.
+func (s *Sender) ProbeHealth(ctx context.Context) error {
+	s.CheckHealth()
+	return nil
+}
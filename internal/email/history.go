@@ -0,0 +1,71 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// History summarizes earlier submissions from the same email address and
+// IP address.
+type History struct {
+	EmailCount int
+	EmailLast  time.Time
+	IPCount    int
+	IPLast     time.Time
+	// Recent lists the latest earlier submissions from either source.
+	Recent []HistoryEntry
+}
+
+// HistoryEntry is an earlier submission listed in the notification.
+type HistoryEntry struct {
+	ReceivedAt time.Time
+	FormID     string
+	Subject    string
+}
+
+// historyHTML renders the "Recent activity" section of the notification.
+func (s *Sender) historyHTML(h *History) string {
+	if h == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<h3>Recent activity</h3>\n")
+	if h.EmailCount == 0 && h.IPCount == 0 {
+		b.WriteString("\t\t\t<p>First submission from this email address and IP.</p>\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\t\t\t<p><strong>From this email:</strong> %s</p>\n", s.activitySummary(h.EmailCount, h.EmailLast))
+	fmt.Fprintf(&b, "\t\t\t<p><strong>From this IP:</strong> %s</p>\n", s.activitySummary(h.IPCount, h.IPLast))
+
+	if len(h.Recent) > 0 {
+		b.WriteString("\t\t\t<ul>\n")
+		for _, e := range h.Recent {
+			subject := e.Subject
+			if subject == "" {
+				subject = "(no subject)"
+			}
+			form := ""
+			if e.FormID != "" {
+				form = fmt.Sprintf(" [%s]", html.EscapeString(e.FormID))
+			}
+			fmt.Fprintf(&b, "\t\t\t\t<li>%s%s: %s</li>\n", s.formatTime(e.ReceivedAt), form, html.EscapeString(subject))
+		}
+		b.WriteString("\t\t\t</ul>\n")
+	}
+	return b.String()
+}
+
+func (s *Sender) activitySummary(count int, last time.Time) string {
+	switch count {
+	case 0:
+		return "no earlier submissions"
+	case 1:
+		return fmt.Sprintf("1 earlier submission, on %s", s.formatTime(last))
+	default:
+		return fmt.Sprintf("%d earlier submissions, last on %s", count, s.formatTime(last))
+	}
+}
@@ -0,0 +1,67 @@
+package email
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"form2mail/internal/message"
+)
+
+// cidPattern finds references to inline images in HTML bodies.
+var cidPattern = regexp.MustCompile(`cid:([A-Za-z0-9._-]+)`)
+
+// InlineImage is an image embedded into messages that reference it as
+// cid:<name>.
+type InlineImage struct {
+	ContentType string
+	Data        []byte
+}
+
+// LoadInlineImages reads the images in dir, keyed by file name.
+func LoadInlineImages(dir string) (map[string]InlineImage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inline images: %w", err)
+	}
+	images := make(map[string]InlineImage)
+	for _, e := range entries {
+		if e.IsDir() || !cidPattern.MatchString("cid:"+e.Name()) {
+			continue
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(e.Name()))
+		if !strings.HasPrefix(contentType, "image/") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inline image: %w", err)
+		}
+		images[e.Name()] = InlineImage{ContentType: contentType, Data: data}
+	}
+	return images, nil
+}
+
+// UseInlineImages embeds images into every message whose body references
+// them.
+func (s *Sender) UseInlineImages(images map[string]InlineImage) {
+	s.images = images
+}
+
+// inlineParts returns the known images body refers to, in order of first
+// reference.
+func (s *Sender) inlineParts(body string) []message.Part {
+	var parts []message.Part
+	seen := make(map[string]bool)
+	for _, m := range cidPattern.FindAllStringSubmatch(body, -1) {
+		name := m[1]
+		if img, ok := s.images[name]; ok && !seen[name] {
+			seen[name] = true
+			parts = append(parts, message.Part{ContentType: img.ContentType, Filename: name, ContentID: name, Data: img.Data})
+		}
+	}
+	return parts
+}
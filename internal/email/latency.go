@@ -0,0 +1,57 @@
+package email
+
+import "time"
+
+// Delivery phases reported to latency observers. An SMTP delivery goes
+// through dial, tls (if offered), auth, and data; a Maildir delivery is a
+// single write.
+const (
+	PhaseDial  = "dial"
+	PhaseTLS   = "tls"
+	PhaseAuth  = "auth"
+	PhaseData  = "data"
+	PhaseWrite = "write"
+)
+
+// ProviderMaildir is the provider reported for Maildir deliveries. SMTP
+// deliveries report the SMTP host.
+const ProviderMaildir = "maildir"
+
+// Latency is how long one phase of a delivery took. Phase is empty for the
+// delivery as a whole.
+type Latency struct {
+	Provider string
+	Phase    string
+	Duration time.Duration
+	// TraceID is the trace the submission arrived with, if any.
+	TraceID string
+}
+
+// OnLatency registers fn to be called with the duration of every phase of
+// every delivery, and of each delivery as a whole.
+func (s *Sender) OnLatency(fn func(Latency)) {
+	s.onLatency = append(s.onLatency, fn)
+}
+
+// phaseTimer reports the phases of one delivery. A nil timer reports
+// nothing, e.g. when only checking credentials.
+type phaseTimer struct {
+	sender   *Sender
+	provider string
+	traceID  string
+}
+
+func (s *Sender) timer(provider, traceID string) *phaseTimer {
+	return &phaseTimer{sender: s, provider: provider, traceID: traceID}
+}
+
+// since reports phase as having run from start until now.
+func (t *phaseTimer) since(phase string, start time.Time) {
+	if t == nil {
+		return
+	}
+	l := Latency{Provider: t.provider, Phase: phase, Duration: time.Since(start), TraceID: t.traceID}
+	for _, fn := range t.sender.onLatency {
+		fn(l)
+	}
+}
@@ -0,0 +1,67 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maildirCounter makes file names unique within a single process.
+var maildirCounter atomic.Uint64
+
+// writeMaildir delivers msg into the Maildir at dir following the
+// tmp-then-rename protocol, so readers such as Dovecot never see a
+// partially written message. now goes into the file name.
+func writeMaildir(dir string, msg []byte, now time.Time) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+
+	name := maildirName(now)
+	tmpPath := filepath.Join(dir, "tmp", name)
+	newPath := filepath.Join(dir, "new", name)
+
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create maildir file: %w", err)
+	}
+	if _, err := f.Write(msg); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write maildir file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync maildir file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close maildir file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move message into maildir: %w", err)
+	}
+	return nil
+}
+
+// maildirName returns a unique file name in the conventional
+// "time.MusecPpidQn.host" format.
+func maildirName(now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	// "/" and ":" are not allowed in maildir names
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s",
+		now.Unix(), now.Nanosecond()/1000, os.Getpid(), maildirCounter.Add(1), host)
+}
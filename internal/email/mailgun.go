@@ -0,0 +1,71 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mailgun sends messages through Mailgun's HTTP API, which takes them in
+// their MIME form, so they go out exactly as built for SMTP.
+type Mailgun struct {
+	client *http.Client
+	// url is the API's base URL; EU domains use https://api.eu.mailgun.net.
+	url    string
+	domain string
+	key    *apiKey
+}
+
+// Send posts msg to the domain's messages.mime endpoint. Every recipient
+// goes into the to field, which is the envelope: the message's own To
+// header is what recipients see.
+func (m *Mailgun) Send(ctx context.Context, msg Message) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, rcpt := range msg.recipients() {
+		form.WriteField("to", rcpt)
+	}
+	part, err := form.CreateFormFile("message", "message.eml")
+	if err != nil {
+		return err
+	}
+	part.Write(msg.Data)
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint("/v3/"+url.PathEscape(m.domain)+"/messages.mime"), &body)
+	if err != nil {
+		return fmt.Errorf("invalid MAILGUN_API_URL: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetBasicAuth("api", m.key.get())
+	return doAPI(m.client, ProviderMailgun, req)
+}
+
+// Probe looks up the sending domain, which fails if the key is wrong or
+// the domain is not set up.
+func (m *Mailgun) Probe(ctx context.Context) error {
+	return m.probeKey(ctx, m.key.get())
+}
+
+func (m *Mailgun) probeKey(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.endpoint("/v3/domains/"+url.PathEscape(m.domain)), nil)
+	if err != nil {
+		return fmt.Errorf("invalid MAILGUN_API_URL: %w", err)
+	}
+	req.SetBasicAuth("api", key)
+	return doAPI(m.client, ProviderMailgun, req)
+}
+
+func (m *Mailgun) apiKey() *apiKey {
+	return m.key
+}
+
+func (m *Mailgun) endpoint(path string) string {
+	return strings.TrimSuffix(m.url, "/") + path
+}
@@ -0,0 +1,73 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"form2mail/internal/config"
+	"form2mail/internal/outbox"
+	"form2mail/internal/smtptest"
+)
+
+func TestReplayOutbox(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	box, err := outbox.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSender(config.Config{
+		SMTPHost:              server.Host(),
+		SMTPPort:              server.Port(),
+		SMTPAttemptTimeout:    5 * time.Second,
+		MailProvider:          config.MailProviderSMTP,
+		DeliveryMode:          config.DeliverySMTP,
+		DeliveryRetryAttempts: 1,
+		FromEmail:             "form2mail@example.com",
+		OutboxMaxAttempts:     3,
+	}, box)
+
+	msg := []byte("From: form2mail@example.com\r\nTo: owner@example.com\r\nSubject: hi\r\n\r\nhi\r\n")
+	// attempts are those recorded before the restart
+	tests := []struct {
+		id        string
+		attempts  int
+		delivered bool
+	}{
+		{"fresh", 1, true},
+		{"retried", 2, true},
+		{"used up", 3, false},
+	}
+	for _, tt := range tests {
+		if err := box.Begin(outbox.Entry{ID: tt.id, To: "owner@example.com", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := box.Stuck()
+		for _, e := range entries {
+			for e.ID == tt.id && e.Attempts < tt.attempts {
+				e, _ = box.Attempt(e)
+			}
+		}
+	}
+
+	if err := s.ReplayOutbox(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if box.Delivered(tt.id) != tt.delivered {
+				t.Errorf("delivered = %v, want %v", box.Delivered(tt.id), tt.delivered)
+			}
+		})
+	}
+	if n := len(server.Messages()); n != 2 {
+		t.Errorf("%d messages delivered, want 2", n)
+	}
+	if entries, _ := box.Stuck(); len(entries) != 0 {
+		t.Errorf("outbox still holds %d entries", len(entries))
+	}
+}
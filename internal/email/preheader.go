@@ -0,0 +1,55 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// maxPreheaderLength is how many characters of preview text are kept.
+// Inboxes show less than this; the rest would only end up in the body.
+const maxPreheaderLength = 150
+
+// ParsePreheader checks a preheader template, so a broken one is reported
+// at startup rather than on every notification.
+func ParsePreheader(text string) error {
+	if _, err := template.New("preheader").Parse(text); err != nil {
+		return fmt.Errorf("invalid preheader: %w", err)
+	}
+	return nil
+}
+
+// preheaderHTML renders the preview text of sub's notification, from the
+// form's template or else PREHEADER, as a hidden block for the top of the
+// body. Inboxes show it next to the subject instead of the first text of
+// the body.
+func (s *Sender) preheaderHTML(sub Submission) string {
+	text := sub.Preheader
+	if text == "" {
+		text = s.config.Preheader
+	}
+	if text == "" {
+		return ""
+	}
+	tmpl, err := template.New("preheader").Parse(text)
+	if err != nil {
+		s.loggerFor(sub).Warn("Failed to parse preheader, leaving it out", "error", err)
+		return ""
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, sub); err != nil {
+		s.loggerFor(sub).Warn("Failed to render preheader, leaving it out", "error", err)
+		return ""
+	}
+	preview := strings.Join(strings.Fields(b.String()), " ")
+	if preview == "" {
+		return ""
+	}
+	if utf8.RuneCountInString(preview) > maxPreheaderLength {
+		preview = string([]rune(preview)[:maxPreheaderLength-1]) + "…"
+	}
+	// The padding keeps inboxes from filling up the preview with the body
+	return fmt.Sprintf(`<div style="display:none;max-height:0;overflow:hidden;mso-hide:all">%s%s</div>`, html.EscapeString(preview), strings.Repeat("&#847;&zwnj;&nbsp;", 30))
+}
@@ -0,0 +1,168 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"form2mail/internal/config"
+)
+
+// Providers reported for deliveries through the HTTP APIs. SMTP deliveries
+// report the SMTP host.
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderMailgun  = "mailgun"
+)
+
+// maxErrorBody limits how much of an API's error response is kept in the
+// error.
+const maxErrorBody = 1024
+
+// Provider sends messages to their recipients: through the SMTP server, or
+// through the HTTP API of an email service for deployments that have no
+// SMTP credentials. MAIL_PROVIDER selects one.
+type Provider interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// prober is implemented by providers that can check they would accept a
+// message without sending one.
+type prober interface {
+	Probe(ctx context.Context) error
+}
+
+// keyedProvider is implemented by the API providers, whose key can be
+// rotated at runtime.
+type keyedProvider interface {
+	// probeKey checks that the API accepts key.
+	probeKey(ctx context.Context, key string) error
+	apiKey() *apiKey
+}
+
+// apiKey is an API key that can be replaced while requests are using it.
+type apiKey struct {
+	value atomic.Pointer[string]
+}
+
+func newAPIKey(key string) *apiKey {
+	k := &apiKey{}
+	k.value.Store(&key)
+	return k
+}
+
+func (k *apiKey) get() string {
+	return *k.value.Load()
+}
+
+func (k *apiKey) set(key string) {
+	k.value.Store(&key)
+}
+
+// Message is a message handed to a Provider.
+type Message struct {
+	// To is the recipient the message is addressed to. Bcc are further
+	// envelope recipients, such as the journal address, that the message
+	// does not show.
+	To  string
+	Bcc []string
+	// Data is the message in RFC 5322 form.
+	Data []byte
+	// EnvelopeID requests delivery status notifications for To, where the
+	// provider supports them.
+	EnvelopeID string
+	// TraceID is the trace the submission arrived with, if any.
+	TraceID string
+}
+
+// recipients returns To followed by Bcc.
+func (m Message) recipients() []string {
+	return append([]string{m.To}, m.Bcc...)
+}
+
+// newProvider returns the provider MAIL_PROVIDER selects and the name its
+// health and latency are reported under.
+func newProvider(s *Sender, cfg config.Config) (Provider, string) {
+	client := &http.Client{Timeout: cfg.MailAPITimeout}
+	s.apiClient = client
+	switch cfg.MailProvider {
+	case config.MailProviderSendGrid:
+		return &SendGrid{client: client, url: cfg.SendGridAPIURL, key: newAPIKey(cfg.SendGridAPIKey)}, ProviderSendGrid
+	case config.MailProviderMailgun:
+		return &Mailgun{client: client, url: cfg.MailgunAPIURL, domain: cfg.MailgunDomain, key: newAPIKey(cfg.MailgunAPIKey)}, ProviderMailgun
+	}
+	return &smtpProvider{s}, cfg.SMTPHost
+}
+
+// UseTransport makes the API providers send through rt instead of
+// http.DefaultTransport. SMTP connections are not affected.
+func (s *Sender) UseTransport(rt http.RoundTripper) {
+	s.apiClient.Transport = rt
+}
+
+// smtpProvider sends through the SMTP server the Sender is configured for.
+type smtpProvider struct {
+	s *Sender
+}
+
+func (p *smtpProvider) Send(ctx context.Context, msg Message) error {
+	return p.s.sendSMTP(ctx, msg, p.s.timer(p.s.config.SMTPHost, msg.TraceID))
+}
+
+// Probe opens an authenticated session and sends NOOP.
+func (p *smtpProvider) Probe(ctx context.Context) error {
+	s := p.s
+	// Probes share the session limit with real deliveries
+	if s.smtpSlots != nil {
+		select {
+		case s.smtpSlots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-s.smtpSlots }()
+	}
+
+	client, err := s.dialSMTP(ctx, s.credentials(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Noop(); err != nil {
+		return fmt.Errorf("NOOP failed: %w", err)
+	}
+	return client.Quit()
+}
+
+// APIError is a request an email service's HTTP API refused.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	// Body is the start of the response, which usually says what was wrong.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s API answered %d %s", e.Provider, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s API answered %d %s: %s", e.Provider, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// doAPI sends req with client and returns an *APIError unless the service
+// answered with a 2xx status.
+func doAPI(client *http.Client, provider string, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s API request failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &APIError{Provider: provider, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
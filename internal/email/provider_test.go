@@ -0,0 +1,116 @@
+package email
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"form2mail/internal/config"
+)
+
+// silentServer accepts connections and never greets, like a hung SMTP
+// server.
+func silentServer(t *testing.T) (host, port string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port
+}
+
+func TestSMTPSendHonorsContext(t *testing.T) {
+	host, port := silentServer(t)
+	s := NewSender(config.Config{
+		SMTPHost:           host,
+		SMTPPort:           port,
+		SMTPAttemptTimeout: time.Second,
+		MailProvider:       config.MailProviderSMTP,
+		DeliveryMode:       config.DeliverySMTP,
+	}, nil)
+
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 100*time.Millisecond)
+		}},
+		{"canceled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- s.provider.Send(ctx, Message{To: "owner@example.com", Data: []byte("Subject: hi\r\n\r\nhi\r\n")})
+			}()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Fatal("Send succeeded against a silent server")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Send did not return after the context was done")
+			}
+		})
+	}
+}
+
+func TestSenderSendTimesOutSilentServer(t *testing.T) {
+	host, port := silentServer(t)
+	s := NewSender(config.Config{
+		SMTPHost:           host,
+		SMTPPort:           port,
+		SMTPAttemptTimeout: time.Second,
+		SMTPSessionTimeout: 200 * time.Millisecond,
+		SMTPMaxConnections: 1,
+		FromEmail:          "form2mail@example.com",
+		MailProvider:       config.MailProviderSMTP,
+		DeliveryMode:       config.DeliverySMTP,
+	}, nil)
+
+	// The second send only gets the session slot if the first gave it back
+	for i := range 2 {
+		done := make(chan error, 1)
+		go func() {
+			done <- s.Send("owner@example.com", "hi", "<p>hi</p>")
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatalf("send %d succeeded against a silent server", i+1)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("send %d did not give up after SMTP_SESSION_TIMEOUT", i+1)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.VerifyCredentials(Credentials{User: "u", Password: "p"}) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("VerifyCredentials succeeded against a silent server")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("VerifyCredentials did not give up after SMTP_SESSION_TIMEOUT")
+	}
+}
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"regexp"
+	"strings"
+)
+
+// replyTagPattern finds the submission ID in a tagged reply address.
+var replyTagPattern = regexp.MustCompile(`(?i)\+F2M-([0-9a-f]+)@`)
+
+// ReplyAddress tags base with a submission ID using plus addressing, so
+// inbox@example.com becomes inbox+F2M-<id>@example.com.
+func ReplyAddress(base, id string) string {
+	at := strings.LastIndex(base, "@")
+	if at < 0 {
+		return base
+	}
+	return base[:at] + "+F2M-" + id + base[at:]
+}
+
+// ReplyTag extracts the submission ID from a tagged reply address among
+// the given recipients.
+func ReplyTag(recipients string) (string, bool) {
+	m := replyTagPattern.FindStringSubmatch(recipients)
+	if m == nil {
+		return "", false
+	}
+	return strings.ToLower(m[1]), true
+}
@@ -0,0 +1,54 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"form2mail/internal/enrich"
+)
+
+// reputationHTML renders the "Sender" section of the notification email.
+func reputationHTML(info *enrich.Info) string {
+	if info == nil || info.Domain == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<h3>Sender</h3>\n")
+
+	kind := "Corporate domain"
+	if info.FreeMail {
+		kind = "Free-mail provider"
+	}
+	fmt.Fprintf(&b, "\t\t\t<p><strong>Mailbox:</strong> %s (%s)</p>\n", kind, html.EscapeString(info.Domain))
+
+	if info.HasGravatar != nil {
+		gravatar := "No"
+		if *info.HasGravatar {
+			gravatar = "Yes"
+		}
+		fmt.Fprintf(&b, "\t\t\t<p><strong>Gravatar:</strong> %s</p>\n", gravatar)
+	}
+
+	if !info.DomainCreated.IsZero() {
+		fmt.Fprintf(&b, "\t\t\t<p><strong>Domain registered:</strong> %s (%s)</p>\n",
+			info.DomainCreated.Format(time.DateOnly), domainAge(info.DomainCreated))
+	}
+	return b.String()
+}
+
+func domainAge(created time.Time) string {
+	days := int(time.Since(created).Hours() / 24)
+	switch {
+	case days < 1:
+		return "today"
+	case days < 60:
+		return fmt.Sprintf("%d days ago", days)
+	case days < 730:
+		return fmt.Sprintf("%d months ago", days/30)
+	default:
+		return fmt.Sprintf("%d years ago", days/365)
+	}
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/textproto"
+	"syscall"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// maxRetryDelay caps the time between two attempts at a delivery.
+const maxRetryDelay = time.Minute
+
+// retryable reports whether a delivery that failed with err may succeed if
+// tried again shortly: the SMTP server answered with a 4xx code, the
+// connection could not be opened or dropped, or an HTTP API was busy or
+// down. 5xx answers and requests an API refused fail for good.
+func retryable(err error) bool {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code >= 400 && reply.Code < 500
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	// A certificate that does not verify will not verify next time either
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// deliverRetrying delivers msg via provider, making up to
+// DELIVERY_RETRY_ATTEMPTS attempts while it fails retryably. The delay
+// before a retry starts at DELIVERY_RETRY_DELAY and doubles with each one,
+// plus up to DELIVERY_RETRY_JITTER, so messages that failed together do
+// not all retry at once. Greylisted messages are left to deferDelivery,
+// whose retries are minutes apart.
+func (s *Sender) deliverRetrying(logger *slog.Logger, provider, to string, msg []byte, traceID, envID string) error {
+	delay := s.config.DeliveryRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.deliverVia(provider, to, msg, traceID, envID)
+		if err == nil || attempt >= s.config.DeliveryRetryAttempts || !retryable(err) || s.defers(err) {
+			return err
+		}
+		wait := delay + s.jitter.N(s.config.DeliveryRetryJitter)
+		logger.Warn("Delivery failed, retrying", "provider", provider, "attempt", attempt, "retry_in", wait.String(), "error", err)
+		clock.Sleep(s.clock, wait)
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
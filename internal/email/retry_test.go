@@ -0,0 +1,50 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/smtptest"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Reject("421 4.3.2 Try again later")
+
+	// The waits the sender is expected to draw
+	jitter := clock.SeededJitter(7)
+	want := time.Second + jitter.N(500*time.Millisecond) + 2*time.Second + jitter.N(500*time.Millisecond)
+
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := clock.NewManual(start)
+	s := NewSender(config.Config{
+		SMTPHost:              server.Host(),
+		SMTPPort:              server.Port(),
+		SMTPAttemptTimeout:    5 * time.Second,
+		MailProvider:          config.MailProviderSMTP,
+		DeliveryMode:          config.DeliverySMTP,
+		DeliveryRetryAttempts: 3,
+		DeliveryRetryDelay:    time.Second,
+		DeliveryRetryJitter:   500 * time.Millisecond,
+		FromEmail:             "form2mail@example.com",
+	}, nil)
+	s.UseClock(c, nil)
+	s.UseJitter(clock.SeededJitter(7))
+
+	began := time.Now()
+	if err := s.Send("owner@example.com", "Hi", "<p>Hi</p>"); err == nil {
+		t.Fatal("Send succeeded against a rejecting server")
+	}
+	if waited := c.Now().Sub(start); waited != want {
+		t.Errorf("waited %v between attempts, want %v", waited, want)
+	}
+	if took := time.Since(began); took > 2*time.Second {
+		t.Errorf("Send took %v of real time", took)
+	}
+}
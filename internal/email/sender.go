@@ -1,16 +1,55 @@
 package email
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"net"
+	"net/http"
 	"net/smtp"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"form2mail/internal/clock"
 	"form2mail/internal/config"
+	"form2mail/internal/deadletter"
+	"form2mail/internal/message"
+	"form2mail/internal/outbox"
 )
 
 type Sender struct {
-	config config.Config
+	config     config.Config
+	outbox     *outbox.Outbox
+	onDelivery []func(err error)
+	onLatency  []func(Latency)
+	creds      atomic.Pointer[Credentials]
+	// smtpSlots limits concurrent SMTP sessions; nil means unlimited.
+	smtpSlots chan struct{}
+	images    map[string]InlineImage
+	health    healthState
+	// sizeLimit is the SMTP server's latest SIZE limit in bytes; 0 if it
+	// has none or no session was opened yet.
+	sizeLimit atomic.Int64
+	clock     clock.Clock
+	ids       *clock.IDs
+	jitter    *clock.Jitter
+	// noDSN is set once the SMTP server was found not to offer DSN.
+	noDSN atomic.Bool
+	// provider sends the messages that do not go to the Maildir, reporting
+	// as providerName. The API providers call out with apiClient.
+	provider     Provider
+	providerName string
+	apiClient    *http.Client
+	templates    *Templates
+	logger       *slog.Logger
+	deadLetters  *deadletter.Store
+	deferrals    deferrals
 }
 
 // loginAuth implements AUTH LOGIN authentication for Office365/Outlook
@@ -40,74 +79,487 @@ func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
 	return nil, nil
 }
 
-func NewSender(cfg config.Config) *Sender {
-	return &Sender{config: cfg}
+// NewSender creates a Sender. box may be nil to deliver without crash recovery.
+func NewSender(cfg config.Config, box *outbox.Outbox) *Sender {
+	s := &Sender{config: cfg, outbox: box, clock: clock.System, jitter: clock.SystemJitter, logger: slog.Default()}
+	if cfg.SMTPMaxConnections > 0 {
+		s.smtpSlots = make(chan struct{}, cfg.SMTPMaxConnections)
+	}
+	s.creds.Store(&Credentials{User: cfg.SMTPUser, Password: cfg.SMTPPassword})
+	s.provider, s.providerName = newProvider(s, cfg)
+	return s
 }
 
 func (s *Sender) Send(to, subject, body string) error {
+	return s.send(Submission{}, to, subject, body, "", nil, nil)
+}
+
+// send builds and delivers a message with files attached on behalf of sub,
+// which may be zero. text, if set, is sent as the plain-text alternative of
+// body. The submission's From address replaces the default
+// one, and its trace ID links the delivery's latency metrics to the trace
+// of the request.
+func (s *Sender) send(sub Submission, to, subject, body, text string, headers map[string]string, files []File) error {
+	id := message.NewID(s.ids)
+	from, fromAddr := s.from(sub.FromName, sub.FromEmail)
+	m := s.buildMessage(id, from, fromAddr, to, subject, body, headers)
+	m.Text = text
+	for _, f := range files {
+		m.Attachments = append(m.Attachments, message.Part{ContentType: f.ContentType, Filename: f.Name, Data: f.Data})
+	}
+	msg := m.Bytes()
+	traceID, envID := sub.TraceID, s.envelopeID(sub, to)
+	logger := s.loggerFor(sub).With("to", to, "message_id", id)
+
+	// Inline images are nice to have; drop them rather than the message
+	// if the SMTP server would refuse it
+	if s.config.DeliversSMTP() && s.checkSize(msg) != nil && len(m.Inline) > 0 {
+		logger.Warn("Message exceeds the SMTP server's size limit, sending it without inline images")
+		m.Inline = nil
+		msg = m.Bytes()
+	}
+	if s.config.DeliversSMTP() {
+		if err := s.checkSize(msg); err != nil {
+			return err
+		}
+	}
+
+	// In dry-run mode nothing leaves the process
+	if s.config.DryRun {
+		if journal := s.journal(to); journal != "" {
+			logger = logger.With("journal", journal)
+		}
+		logger.Info("[dry run] Would send email", "message", string(msg))
+		return nil
+	}
+
+	if s.outbox != nil {
+		if err := s.outbox.Begin(outbox.Entry{ID: id, To: to, Message: msg}); err != nil {
+			return err
+		}
+	}
+	undelivered, err := s.deliverOnce(logger, to, msg, traceID, envID)
+
+	// Greylisting only delays the message; its outbox entry stays in the
+	// sending state, so a restart delivers it if the retries are cut short
+	if s.defers(err) {
+		return s.deferDelivery(logger, id, to, msg, traceID, envID, err)
+	}
+	s.report(err)
+	s.settle(id, err)
+	if err != nil {
+		logger.Error("Failed to send email", "provider", s.providerName, "error", err)
+		s.bury(logger, id, to, undelivered, msg, envID, err)
+	} else {
+		logger.Info("Email sent", "provider", s.providerName)
+	}
+	return err
+}
+
+// settle removes the outbox entry of a message once its delivery succeeded
+// or failed for good.
+func (s *Sender) settle(id string, err error) {
+	if s.outbox == nil {
+		return
+	}
+	if err != nil {
+		if discardErr := s.outbox.Discard(id); discardErr != nil {
+			s.logger.Error("Failed to discard outbox entry", "entry", id, "error", discardErr)
+		}
+		return
+	}
+	if err := s.outbox.Finish(id); err != nil {
+		s.logger.Error("Failed to mark outbox entry as sent", "entry", id, "error", err)
+	}
+}
+
+// UseClock makes messages take their Date and Message-ID from c and ids
+// instead of the system clock and crypto/rand, so tests can compare whole
+// messages. ids may be nil. Waits between delivery retries pass on c.
+func (s *Sender) UseClock(c clock.Clock, ids *clock.IDs) {
+	s.clock = c
+	s.ids = ids
+}
+
+// UseJitter makes the Sender spread delivery retries by j instead of a
+// randomly seeded source.
+func (s *Sender) UseJitter(j *clock.Jitter) {
+	s.jitter = j
+}
+
+// UseLogger makes the Sender log to logger instead of the default logger.
+// Submissions with their own Logger still log their emails to it.
+func (s *Sender) UseLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// loggerFor returns the logger for the emails of sub.
+func (s *Sender) loggerFor(sub Submission) *slog.Logger {
+	if sub.Logger != nil {
+		return sub.Logger
+	}
+	return s.logger
+}
+
+// OnDelivery registers fn to be called with the outcome of every delivery
+// attempt; err is nil on success.
+func (s *Sender) OnDelivery(fn func(err error)) {
+	s.onDelivery = append(s.onDelivery, fn)
+}
+
+// ReplayOutbox re-delivers messages left in the sending state by a previous
+// run. Entries that were already delivered before the crash are dropped,
+// and those that used up OUTBOX_MAX_ATTEMPTS are kept as dead letters.
+// An entry whose re-delivery fails stays for the next start.
+func (s *Sender) ReplayOutbox() error {
+	if s.outbox == nil {
+		return nil
+	}
+
+	entries, err := s.outbox.Stuck()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if s.outbox.Delivered(e.ID) {
+			if err := s.outbox.Discard(e.ID); err != nil {
+				s.logger.Error("Failed to discard delivered outbox entry", "entry", e.ID, "error", err)
+			}
+			continue
+		}
+
+		if s.config.DryRun {
+			s.logger.Info("[dry run] Would re-deliver outbox entry", "entry", e.ID, "to", e.To)
+			continue
+		}
+
+		// Give up on messages that keep failing, or crashing the process
+		if e.Attempts >= s.config.OutboxMaxAttempts {
+			err := fmt.Errorf("gave up after %d delivery attempts", e.Attempts)
+			s.logger.Error("Giving up on outbox entry", "entry", e.ID, "to", e.To, "error", err)
+			s.bury(s.logger, e.ID, e.To, nil, e.Message, "", err)
+			if err := s.outbox.Discard(e.ID); err != nil {
+				s.logger.Error("Failed to discard outbox entry", "entry", e.ID, "error", err)
+			}
+			continue
+		}
+		e, err := s.outbox.Attempt(e)
+		if err != nil {
+			s.logger.Error("Failed to record outbox attempt", "entry", e.ID, "error", err)
+			continue
+		}
+
+		s.logger.Info("Re-delivering outbox entry", "entry", e.ID, "to", e.To, "attempt", e.Attempts)
+		if err := s.deliver(e.To, e.Message, ""); err != nil {
+			// Leave the entry in place so the next start tries again
+			s.logger.Error("Failed to re-deliver outbox entry", "entry", e.ID, "error", err)
+			continue
+		}
+		if err := s.outbox.Finish(e.ID); err != nil {
+			s.logger.Error("Failed to mark outbox entry as sent", "entry", e.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *Sender) deliver(to string, msg []byte, traceID string) error {
+	_, err := s.deliverOnce(s.logger, to, msg, traceID, "")
+	s.report(err)
+	return err
+}
+
+// report passes the outcome of a delivery to the OnDelivery callbacks.
+func (s *Sender) report(err error) {
+	for _, fn := range s.onDelivery {
+		fn(err)
+	}
+}
+
+// deliverOnce delivers msg through each provider, retrying transient
+// failures of each. envID, if set, is the envelope ID that delivery status
+// notifications are requested for. On failure, it also returns the
+// providers msg was not delivered through.
+func (s *Sender) deliverOnce(logger *slog.Logger, to string, msg []byte, traceID, envID string) ([]string, error) {
+	// Try healthy providers first, so an outage of one does not hold up
+	// the copy another can take
+	providers := s.providers()
+	sort.SliceStable(providers, func(i, j int) bool {
+		return s.healthy(providers[i]) && !s.healthy(providers[j])
+	})
+
+	var targets []string
+	for _, provider := range providers {
+		// The Maildir belongs to the site owner, so only their copies go there
+		if provider == ProviderMaildir && to != s.config.RecipientEmail {
+			continue
+		}
+		targets = append(targets, provider)
+	}
+	return s.deliverThrough(logger, targets, to, msg, traceID, envID)
+}
+
+// deliverThrough delivers msg through providers in order. On failure, it
+// returns the providers msg was not delivered through, starting with the
+// one that failed.
+func (s *Sender) deliverThrough(logger *slog.Logger, providers []string, to string, msg []byte, traceID, envID string) ([]string, error) {
+	for i, provider := range providers {
+		if err := s.deliverRetrying(logger, provider, to, msg, traceID, envID); err != nil {
+			return providers[i:], err
+		}
+	}
+	return nil, nil
+}
+
+func (s *Sender) deliverVia(provider, to string, msg []byte, traceID, envID string) error {
+	timer := s.timer(provider, traceID)
+	start := time.Now()
+	defer timer.since("", start)
+
+	if provider == ProviderMaildir {
+		defer timer.since(PhaseWrite, start)
+		return writeMaildir(s.config.MaildirPath, msg, s.clock.Now())
+	}
+	m := Message{To: to, Data: msg, EnvelopeID: envID, TraceID: traceID}
+	// The journal copy is a blind copy: it is only in the envelope, so
+	// recipients never see the archive address
+	if journal := s.journal(to); journal != "" {
+		m.Bcc = []string{journal}
+	}
+	ctx, cancel := s.attemptContext()
+	defer cancel()
+	return s.provider.Send(ctx, m)
+}
+
+// attemptContext returns the context for one delivery attempt or probe.
+// SMTP_SESSION_TIMEOUT bounds SMTP sessions, including the wait for a free
+// one, so a server that never answers cannot hold a slot for good. The API
+// providers are bounded by MAIL_API_TIMEOUT instead.
+func (s *Sender) attemptContext() (context.Context, context.CancelFunc) {
+	if _, ok := s.provider.(*smtpProvider); ok && s.config.SMTPSessionTimeout > 0 {
+		return context.WithTimeout(context.Background(), s.config.SMTPSessionTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// buildMessage assembles the message. from is the From header; fromAddr is
+// its bare address, whose domain is used for the Message-ID.
+func (s *Sender) buildMessage(id, from, fromAddr, to, subject, body string, headers map[string]string) message.Message {
+	return message.Message{
+		ID:          id,
+		From:        from,
+		FromAddress: fromAddr,
+		To:          to,
+		Subject:     subject,
+		Date:        s.clock.Now().In(s.location()),
+		Headers:     headers,
+		HTML:        body,
+		Inline:      s.inlineParts(body),
+	}
+}
+
+// location returns the time zone for human-facing timestamps.
+func (s *Sender) location() *time.Location {
+	if s.config.Location == nil {
+		return time.Local
+	}
+	return s.config.Location
+}
+
+// dial connects to the SMTP server, through SMTP_PROXY if set.
+func (s *Sender) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if s.config.SMTPProxy == "" {
+		return s.dialDirect(ctx, addr)
+	}
+	proxy, err := url.Parse(s.config.SMTPProxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_PROXY: %w", err)
+	}
+	return dialSOCKS5(ctx, proxy, addr)
+}
+
+// ctxConn is a connection bound to a context: it gives up at the context's
+// deadline, and reads and writes in progress fail once it is canceled.
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func bindConn(ctx context.Context, conn net.Conn) *ctxConn {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// A deadline in the past interrupts blocked reads and writes
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return &ctxConn{Conn: conn, stop: stop}
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// dialSMTP opens an authenticated SMTP session using creds, reporting the
+// time spent in each phase to timer and recording the dialogue in t if it
+// is not nil. The session is abandoned when ctx is done.
+func (s *Sender) dialSMTP(ctx context.Context, creds Credentials, timer *phaseTimer, t *transcript) (*smtp.Client, error) {
 	// Connect to the SMTP server
 	addr := fmt.Sprintf("%s:%s", s.config.SMTPHost, s.config.SMTPPort)
 
 	// Connect to server
-	client, err := smtp.Dial(addr)
+	start := time.Now()
+	raw, err := s.dial(ctx, addr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	var conn net.Conn = bindConn(ctx, raw)
+	session := conn
+	if t != nil {
+		session = t.wrap(conn)
+	}
+	client, err := smtp.NewClient(session, s.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
 	}
-	defer client.Close()
 
 	// Send EHLO/HELO
 	if err = client.Hello(s.config.SMTPHost); err != nil {
-		return fmt.Errorf("failed to send HELLO: %w", err)
+		client.Close()
+		return nil, fmt.Errorf("failed to send HELLO: %w", err)
 	}
+	timer.since(PhaseDial, start)
 
 	// Check if STARTTLS is supported and use it
+	encrypted := false
 	if ok, _ := client.Extension("STARTTLS"); ok {
+		start = time.Now()
 		tlsConfig := &tls.Config{
 			ServerName: s.config.SMTPHost,
 		}
-		if err = client.StartTLS(tlsConfig); err != nil {
-			return fmt.Errorf("failed to start TLS: %w", err)
+		tlsClient, err := startTLS(client, conn, tlsConfig, t)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
 		}
+		client = tlsClient
 		// Re-send EHLO after STARTTLS
 		if err = client.Hello(s.config.SMTPHost); err != nil {
-			return fmt.Errorf("failed to send HELLO after STARTTLS: %w", err)
+			client.Close()
+			return nil, fmt.Errorf("failed to send HELLO after STARTTLS: %w", err)
 		}
+		encrypted = true
+		timer.since(PhaseTLS, start)
 	}
+	s.learnSizeLimit(client)
 
 	// Authenticate - Try LOGIN auth first (works better with Outlook)
-	auth := LoginAuth(s.config.SMTPUser, s.config.SMTPPassword)
+	start = time.Now()
+	auth := LoginAuth(creds.User, creds.Password)
 	if err = client.Auth(auth); err != nil {
 		// If LOGIN fails, try PLAIN auth as fallback
-		auth = smtp.PlainAuth("", s.config.SMTPUser, s.config.SMTPPassword, s.config.SMTPHost)
+		auth = smtp.PlainAuth("", creds.User, creds.Password, s.config.SMTPHost)
+		if encrypted {
+			auth = tlsAuth{auth}
+		}
 		if err = client.Auth(auth); err != nil {
-			return fmt.Errorf("authentication failed: %w", err)
+			client.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
 		}
 	}
+	timer.since(PhaseAuth, start)
+
+	return client, nil
+}
+
+// sendSMTP delivers msg in one SMTP session. With SMTP_TRANSCRIPTS, a
+// failure comes as a *TranscriptError with the session's dialogue.
+func (s *Sender) sendSMTP(ctx context.Context, msg Message, timer *phaseTimer) error {
+	if !s.config.SMTPTranscripts {
+		return s.smtpSession(ctx, msg, timer, nil)
+	}
+	t := &transcript{}
+	if err := s.smtpSession(ctx, msg, timer, t); err != nil {
+		lines := t.Lines()
+		s.logger.Warn("SMTP transcript of failed delivery", "to", msg.To, "transcript", lines)
+		return &TranscriptError{Err: err, Transcript: lines}
+	}
+	return nil
+}
+
+func (s *Sender) smtpSession(ctx context.Context, m Message, timer *phaseTimer, t *transcript) error {
+	// Wait for a free session so spikes don't trip the provider's limits
+	if s.smtpSlots != nil {
+		select {
+		case s.smtpSlots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-s.smtpSlots }()
+	}
+
+	client, err := s.dialSMTP(ctx, s.credentials(), timer, t)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	start := time.Now()
+	defer timer.since(PhaseData, start)
+	msg := m.Data
+
+	// net/smtp declares BODY=8BITMIME itself when the server offers it
+	if ok, _ := client.Extension("8BITMIME"); !ok {
+		msg = message.Downgrade8bit(msg)
+	}
+
+	// Fail with a clear error instead of a 552 once the data is sent
+	if err = s.checkSize(msg); err != nil {
+		return err
+	}
+
+	dsn := m.EnvelopeID != "" && s.supportsDSN(client)
 
 	// Set sender
-	if err = client.Mail(s.config.FromEmail); err != nil {
+	if dsn {
+		err = mailDSN(client, s.config.FromEmail, s.config.DSNReturn, m.EnvelopeID)
+	} else {
+		err = client.Mail(s.config.FromEmail)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to set sender: %w", err)
 	}
 
 	// Set recipient
-	if err = client.Rcpt(to); err != nil {
+	if dsn {
+		err = rcptDSN(client, m.To, s.config.DSNNotify)
+	} else {
+		err = client.Rcpt(m.To)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to set recipient: %w", err)
 	}
 
+	// Blind copies get no notifications; DSN_NOTIFY is about the recipient
+	for _, bcc := range m.Bcc {
+		if dsn {
+			err = rcptDSN(client, bcc, "NEVER")
+		} else {
+			err = client.Rcpt(bcc)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set journal recipient: %w", err)
+		}
+	}
+
 	// Send message body
 	w, err := client.Data()
 	if err != nil {
 		return fmt.Errorf("failed to open data writer: %w", err)
 	}
 
-	msg := []byte(fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/html; charset=UTF-8\r\n"+
-		"\r\n"+
-		"%s\r\n", s.config.FromEmail, to, subject, body))
-
 	if _, err = w.Write(msg); err != nil {
 		return fmt.Errorf("failed to write message: %w", err)
 	}
@@ -120,39 +572,118 @@ func (s *Sender) Send(to, subject, body string) error {
 	return client.Quit()
 }
 
-func (s *Sender) SendContactNotification(name, email, subject, message string) error {
-	recipientSubject := fmt.Sprintf("New Contact Form Submission: %s", subject)
-	recipientBody := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>New Contact Form Submission</h2>
-			<p><strong>Name:</strong> %s</p>
-			<p><strong>Email:</strong> %s</p>
-			<p><strong>Subject:</strong> %s</p>
-			<p><strong>Message:</strong></p>
-			<p>%s</p>
-		</body>
-		</html>
-	`, name, email, subject, strings.ReplaceAll(message, "\n", "<br>"))
-
-	return s.Send(s.config.RecipientEmail, recipientSubject, recipientBody)
-}
-
-func (s *Sender) SendConfirmation(name, email, message string) error {
-	confirmationSubject := "Thank you for contacting us"
-	confirmationBody := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>Thank you for your message, %s!</h2>
-			<p>We have received your contact form submission and will get back to you as soon as possible.</p>
-			<hr>
-			<p><strong>Your message:</strong></p>
-			<p>%s</p>
-			<hr>
-			<p>Best regards</p>
-		</body>
-		</html>
-	`, name, strings.ReplaceAll(message, "\n", "<br>"))
-
-	return s.Send(email, confirmationSubject, confirmationBody)
+// journal returns the address that receives a copy of messages to to, or
+// "" if there is none.
+func (s *Sender) journal(to string) string {
+	if strings.EqualFold(s.config.JournalEmail, to) {
+		return ""
+	}
+	return s.config.JournalEmail
+}
+
+func (s *Sender) SendContactNotification(sub Submission) error {
+	recipientSubject := fmt.Sprintf("New Contact Form Submission: %s", sub.Subject)
+	if sub.Duplicate {
+		recipientSubject = "[Duplicate] " + recipientSubject
+	}
+	if sub.Spam {
+		recipientSubject = "[Spam] " + recipientSubject
+	}
+	if len(sub.Threats) > 0 {
+		recipientSubject = "[Malicious links] " + recipientSubject
+	}
+	if sub.SubjectPrefix != "" {
+		recipientSubject = sub.SubjectPrefix + " " + recipientSubject
+	}
+	data := NotificationData{
+		Submission: sub,
+		Received:   s.formatTime(sub.ReceivedAt),
+		Preheader:  htmltemplate.HTML(s.preheaderHTML(sub)),
+		Details:    htmltemplate.HTML(fieldsHTML(sub.Fields) + s.attachmentsHTML(sub.Attachments) + threatsHTML(sub.Threats) + spamHTML(sub) + sub.Source.html() + reputationHTML(sub.Reputation) + s.historyHTML(sub.History)),
+	}
+	recipientBody, recipientText := s.render(sub.Templates, TemplateNotification, data)
+
+	return s.send(sub, s.recipient(sub), recipientSubject, recipientBody, recipientText, s.notificationHeaders(sub), sub.Files)
+}
+
+// recipient returns the address of sub's notification.
+func (s *Sender) recipient(sub Submission) string {
+	if sub.Recipient != "" {
+		return sub.Recipient
+	}
+	return s.config.RecipientEmail
+}
+
+// notificationHeaders returns the X-Form2Mail-* headers enabled through
+// NOTIFICATION_HEADERS plus any headers configured for the form.
+func (s *Sender) notificationHeaders(sub Submission) map[string]string {
+	headers := make(map[string]string, len(sub.Headers)+len(s.config.NotificationHeaders))
+	for name, value := range sub.Headers {
+		headers[name] = value
+	}
+	if sub.Duplicate {
+		headers["X-Form2Mail-Duplicate"] = "true"
+	}
+	if sub.Spam {
+		headers["X-Form2Mail-Spam"] = "true"
+	}
+	if len(sub.Threats) > 0 {
+		headers["X-Form2Mail-Threats"] = strconv.Itoa(len(sub.Threats))
+	}
+	if sub.Signature != "" {
+		headers["X-Form2Mail-Signature"] = "ed25519=" + sub.Signature
+	}
+	for _, field := range s.config.NotificationHeaders {
+		switch field {
+		case config.HeaderForm:
+			if sub.FormID != "" {
+				headers["X-Form2Mail-Form"] = sub.FormID
+			}
+		case config.HeaderIP:
+			if sub.ClientIP != "" {
+				headers["X-Form2Mail-IP"] = sub.ClientIP
+			}
+		case config.HeaderScore:
+			headers["X-Form2Mail-Score"] = strconv.Itoa(sub.SpamScore)
+		}
+	}
+	return headers
+}
+
+// formatTime renders t for people reading the email, in the configured zone.
+func (s *Sender) formatTime(t time.Time) string {
+	if t.IsZero() {
+		t = s.clock.Now()
+	}
+	return t.In(s.location()).Format("Mon, 02 Jan 2006 15:04 MST")
+}
+
+func (s *Sender) SendConfirmation(sub Submission) error {
+	// Confirmations can only reach the customer through SMTP
+	if !s.config.DeliversSMTP() {
+		return nil
+	}
+
+	c := sub.Confirmation
+	if c == (Confirmation{}) {
+		c = Confirmation{
+			Subject:     "Thank you for contacting us",
+			Greeting:    fmt.Sprintf("Thank you for your message, %s!", sub.Name),
+			Intro:       "We have received your contact form submission and will get back to you as soon as possible.",
+			YourMessage: "Your message:",
+			Closing:     "Best regards",
+		}
+	}
+
+	confirmationSubject := c.Subject
+	sub.Confirmation = c
+	confirmationBody, confirmationText := s.render(sub.Templates, TemplateConfirmation, ConfirmationData{Submission: sub})
+
+	// Tag replies so they can be matched to the submission
+	var headers map[string]string
+	if s.config.ReplyAddress != "" {
+		headers = map[string]string{"Reply-To": ReplyAddress(s.config.ReplyAddress, sub.ID)}
+	}
+
+	return s.send(sub, sub.Email, confirmationSubject, confirmationBody, confirmationText, headers, nil)
 }
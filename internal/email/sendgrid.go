@@ -0,0 +1,242 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// sendGridReserved are the header fields SendGrid's API sets itself and
+// refuses in headers; the message's own values go into the request's
+// fields instead.
+var sendGridReserved = map[string]bool{
+	"From": true, "To": true, "Cc": true, "Bcc": true, "Reply-To": true, "Subject": true,
+	"Content-Type": true, "Content-Transfer-Encoding": true, "Mime-Version": true,
+	"Received": true, "Dkim-Signature": true, "X-Sg-Id": true, "X-Sg-Eid": true,
+}
+
+// SendGrid sends messages through SendGrid's v3 HTTP API. The API does not
+// take MIME messages, so each is taken apart into the request's fields:
+// its addresses, subject, and other header fields, its text and HTML
+// bodies, and its inline images and attachments.
+type SendGrid struct {
+	client *http.Client
+	url    string
+	key    *apiKey
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridMail struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+
+	// plain and html collect the bodies, which Content lists plain first
+	plain, html string
+}
+
+func (g *SendGrid) Send(ctx context.Context, msg Message) error {
+	mailReq, err := newSendGridMail(msg)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(mailReq)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint("/v3/mail/send"), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid SENDGRID_API_URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+g.key.get())
+	return doAPI(g.client, ProviderSendGrid, req)
+}
+
+// Probe lists the API key's scopes, which fails if the key is wrong.
+func (g *SendGrid) Probe(ctx context.Context) error {
+	return g.probeKey(ctx, g.key.get())
+}
+
+func (g *SendGrid) probeKey(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint("/v3/scopes"), nil)
+	if err != nil {
+		return fmt.Errorf("invalid SENDGRID_API_URL: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	return doAPI(g.client, ProviderSendGrid, req)
+}
+
+func (g *SendGrid) apiKey() *apiKey {
+	return g.key
+}
+
+func (g *SendGrid) endpoint(path string) string {
+	return strings.TrimSuffix(g.url, "/") + path
+}
+
+// newSendGridMail takes msg apart into a mail/send request.
+func newSendGridMail(msg Message) (*sendGridMail, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg.Data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	m := &sendGridMail{Headers: map[string]string{}}
+	dec := new(mime.WordDecoder)
+
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return nil, fmt.Errorf("invalid From header: %v", err)
+	}
+	m.From = sendGridAddress{Email: from[0].Address, Name: from[0].Name}
+	if replyTo, err := parsed.Header.AddressList("Reply-To"); err == nil && len(replyTo) > 0 {
+		m.ReplyTo = &sendGridAddress{Email: replyTo[0].Address, Name: replyTo[0].Name}
+	}
+	if m.Subject, err = dec.DecodeHeader(parsed.Header.Get("Subject")); err != nil {
+		m.Subject = parsed.Header.Get("Subject")
+	}
+
+	// The message's To header names the recipient; Bcc stay unnamed
+	to := sendGridAddress{Email: msg.To}
+	if list, err := parsed.Header.AddressList("To"); err == nil {
+		for _, addr := range list {
+			if strings.EqualFold(addr.Address, msg.To) {
+				to.Name = addr.Name
+			}
+		}
+	}
+	p := sendGridPersonalization{To: []sendGridAddress{to}}
+	for _, rcpt := range msg.Bcc {
+		p.Bcc = append(p.Bcc, sendGridAddress{Email: rcpt})
+	}
+	m.Personalizations = []sendGridPersonalization{p}
+
+	for name, values := range parsed.Header {
+		if sendGridReserved[name] || len(values) == 0 {
+			continue
+		}
+		value, err := dec.DecodeHeader(values[0])
+		if err != nil {
+			value = values[0]
+		}
+		m.Headers[name] = value
+	}
+
+	if err := m.addPart(textproto.MIMEHeader(parsed.Header), parsed.Body); err != nil {
+		return nil, err
+	}
+	if m.plain != "" {
+		m.Content = append(m.Content, sendGridContent{Type: "text/plain", Value: m.plain})
+	}
+	if m.html != "" {
+		m.Content = append(m.Content, sendGridContent{Type: "text/html", Value: m.html})
+	}
+	if len(m.Content) == 0 {
+		// The API refuses messages without a body
+		m.Content = []sendGridContent{{Type: "text/plain", Value: " "}}
+	}
+	return m, nil
+}
+
+// addPart adds a MIME part of the message: the parts of a multipart, the
+// first text/plain and text/html bodies, and everything else as
+// attachments, inline if they have a Content-ID.
+func (m *sendGridMail) addPart(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s part: %w", mediaType, err)
+			}
+			if err := m.addPart(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("invalid %s body: %w", mediaType, err)
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	contentID := strings.Trim(header.Get("Content-ID"), "<>")
+	if disposition != "attachment" && contentID == "" {
+		switch {
+		case mediaType == "text/plain" && m.plain == "":
+			m.plain = string(data)
+			return nil
+		case mediaType == "text/html" && m.html == "":
+			m.html = string(data)
+			return nil
+		}
+	}
+
+	a := sendGridAttachment{
+		Content:     base64.StdEncoding.EncodeToString(data),
+		Type:        mediaType,
+		Filename:    dispParams["filename"],
+		Disposition: "attachment",
+	}
+	if a.Filename == "" {
+		a.Filename = params["name"]
+	}
+	if a.Filename == "" {
+		a.Filename = "attachment"
+	}
+	if contentID != "" && disposition != "attachment" {
+		a.Disposition, a.ContentID = "inline", contentID
+	}
+	m.Attachments = append(m.Attachments, a)
+	return nil
+}
@@ -0,0 +1,39 @@
+package email
+
+import (
+	"fmt"
+	"net/smtp"
+	"strconv"
+)
+
+// TooLargeError reports a message bigger than the SMTP server accepts, as
+// advertised by its SIZE extension (RFC 1870).
+type TooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("message of %d bytes exceeds the SMTP server's limit of %d bytes", e.Size, e.Limit)
+}
+
+// learnSizeLimit remembers the SIZE limit client's server advertises, so
+// later messages can be checked before a session is opened for them.
+func (s *Sender) learnSizeLimit(client *smtp.Client) {
+	limit := 0
+	if ok, param := client.Extension("SIZE"); ok {
+		// SIZE without a value, or 0, means there is no fixed limit
+		limit, _ = strconv.Atoi(param)
+	}
+	s.sizeLimit.Store(int64(max(limit, 0)))
+}
+
+// checkSize returns a *TooLargeError if msg exceeds the last SIZE limit the
+// SMTP server advertised. Before the first session the limit is unknown and
+// every message passes.
+func (s *Sender) checkSize(msg []byte) error {
+	if limit := int(s.sizeLimit.Load()); limit > 0 && len(msg) > limit {
+		return &TooLargeError{Size: len(msg), Limit: limit}
+	}
+	return nil
+}
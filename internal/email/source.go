@@ -0,0 +1,66 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Source describes the page and campaign a submission came from.
+type Source struct {
+	PageURL     string `json:"page_url,omitempty"`
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+}
+
+// IsZero reports whether no source information was captured.
+func (s Source) IsZero() bool {
+	return s == Source{}
+}
+
+// Map returns the captured values keyed by their form field names.
+func (s Source) Map() map[string]string {
+	if s.IsZero() {
+		return nil
+	}
+	m := make(map[string]string)
+	for key, value := range map[string]string{
+		"page_url":     s.PageURL,
+		"utm_source":   s.UTMSource,
+		"utm_medium":   s.UTMMedium,
+		"utm_campaign": s.UTMCampaign,
+		"utm_term":     s.UTMTerm,
+		"utm_content":  s.UTMContent,
+	} {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+// html renders the "Source" section of the notification email.
+func (s Source) html() string {
+	if s.IsZero() {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<h3>Source</h3>\n")
+	for _, row := range []struct{ label, value string }{
+		{"Page", s.PageURL},
+		{"utm_source", s.UTMSource},
+		{"utm_medium", s.UTMMedium},
+		{"utm_campaign", s.UTMCampaign},
+		{"utm_term", s.UTMTerm},
+		{"utm_content", s.UTMContent},
+	} {
+		if row.value != "" {
+			fmt.Fprintf(&b, "\t\t\t<p><strong>%s:</strong> %s</p>\n", row.label, html.EscapeString(row.value))
+		}
+	}
+	return b.String()
+}
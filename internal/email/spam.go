@@ -0,0 +1,31 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"form2mail/internal/scan"
+)
+
+// spamHTML explains why a submission was flagged as spam.
+func spamHTML(sub Submission) string {
+	if !sub.Spam {
+		return ""
+	}
+	return fmt.Sprintf("<h3>Spam check</h3>\n\t\t\t<p><strong>Score %d:</strong> %s</p>\n", sub.SpamScore, html.EscapeString(strings.Join(sub.SpamReasons, ", ")))
+}
+
+// threatsHTML lists the malicious links found in a submission, defanged.
+func threatsHTML(threats []scan.Threat) string {
+	if len(threats) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<h3>Malicious links</h3>\n\t\t\t<p>Do not open these links; they are known to be unsafe.</p>\n\t\t\t<ul>\n")
+	for _, t := range threats {
+		fmt.Fprintf(&b, "\t\t\t\t<li>%s (%s)</li>\n", html.EscapeString(scan.Defang(t.URL, []scan.Threat{t})), html.EscapeString(t.Type))
+	}
+	b.WriteString("\t\t\t</ul>\n")
+	return b.String()
+}
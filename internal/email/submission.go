@@ -0,0 +1,82 @@
+package email
+
+import (
+	"log/slog"
+	"time"
+
+	"form2mail/internal/enrich"
+	"form2mail/internal/scan"
+)
+
+// Submission is a contact form submission to be delivered.
+type Submission struct {
+	ID       string
+	FormID   string
+	Name     string
+	Email    string
+	Subject  string
+	Message  string
+	ClientIP string
+	// FromName and FromEmail override FROM_NAME and FROM_EMAIL for the
+	// emails sent about this submission.
+	FromName  string
+	FromEmail string
+	// Recipient overrides RECIPIENT_EMAIL for the notification, and
+	// SubjectPrefix starts its subject.
+	Recipient     string
+	SubjectPrefix string
+	// Templates are the form's own templates, which take precedence over
+	// the Sender's.
+	Templates *Templates
+	// Signature is the submission's receipt signature, if receipts are
+	// enabled.
+	Signature string
+	// TraceID is the W3C trace ID the submission arrived with, if any.
+	TraceID string
+	// ReceivedAt is when the submission arrived.
+	ReceivedAt time.Time
+	Source     Source
+	// Duplicate marks a repeat of a recent identical submission.
+	Duplicate bool
+	// SpamScore is the submission's spam score; Spam marks it as reaching
+	// the threshold.
+	SpamScore   int
+	Spam        bool
+	SpamReasons []string
+	// Threats are the malicious links found in the submission; they are
+	// defanged in its content.
+	Threats []scan.Threat
+	// Reputation is optional context about the submitter's address.
+	Reputation *enrich.Info
+	// Fields are extra submitted fields, in display order.
+	Fields []Field
+	// Attachments link to files uploaded with the submission.
+	Attachments []Attachment
+	// Files are uploaded files attached to the notification.
+	Files []File
+	// History lists earlier submissions from the same submitter, if known.
+	History *History
+	// Confirmation holds the localized texts of the confirmation email;
+	// English defaults are used when it is zero.
+	Confirmation Confirmation
+	// Headers are extra headers added to the notification email.
+	Headers map[string]string
+	// Preheader is the form's template for the notification's preview
+	// text, replacing PREHEADER.
+	Preheader string
+	// Logger, if set, logs the delivery of the submission's emails with the
+	// attributes of the request it came with, instead of the Sender's
+	// logger.
+	Logger *slog.Logger
+}
+
+// Confirmation holds the texts of the confirmation email.
+type Confirmation struct {
+	// Image is an inline image shown above the greeting.
+	Image       string
+	Subject     string
+	Greeting    string
+	Intro       string
+	YourMessage string
+	Closing     string
+}
@@ -0,0 +1,191 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Names of the emails whose bodies TEMPLATE_DIR can replace. Each is read
+// from <name>.html and <name>.txt.
+const (
+	TemplateNotification = "notification"
+	TemplateConfirmation = "confirmation"
+)
+
+// templateFuncs are available in every template.
+var templateFuncs = map[string]any{
+	// br escapes text and keeps its line breaks, for messages in HTML
+	"br": func(text string) htmltemplate.HTML {
+		return htmltemplate.HTML(strings.ReplaceAll(htmltemplate.HTMLEscapeString(text), "\n", "<br>"))
+	},
+}
+
+// builtinTemplates are the bodies of emails without an HTML template of the
+// operator's. Like those, they escape everything the submitter entered.
+var builtinTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs).Parse(`
+{{- define "notification.html"}}
+		<html>
+		<body>
+			{{.Preheader}}
+			<h2>New Contact Form Submission</h2>
+			<p><strong>Name:</strong> {{.Name}}</p>
+			<p><strong>Email:</strong> {{.Email}}</p>
+			<p><strong>Subject:</strong> {{.Subject}}</p>
+			<p><strong>Received:</strong> {{.Received}}</p>
+			<p><strong>Message:</strong></p>
+			<p>{{br .Message}}</p>
+			{{.Details}}
+		</body>
+		</html>
+{{end}}
+{{- define "confirmation.html"}}
+		<html>
+		<body>
+			{{with .Confirmation.Image}}<img src="cid:{{.}}" alt="">{{end}}
+			<h2>{{.Confirmation.Greeting}}</h2>
+			<p>{{.Confirmation.Intro}}</p>
+			<hr>
+			<p><strong>{{.Confirmation.YourMessage}}</strong></p>
+			<p>{{br .Message}}</p>
+			<hr>
+			<p>{{.Confirmation.Closing}}</p>
+		</body>
+		</html>
+{{end}}`))
+
+// Templates are the operator's bodies of the notification and confirmation
+// emails. A .html template replaces the built-in HTML body; a .txt
+// template adds a plain-text alternative. Emails without templates keep the
+// built-in body.
+type Templates struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// NotificationData is what notification templates are executed with.
+type NotificationData struct {
+	Submission
+	// Received is ReceivedAt formatted for TIMEZONE.
+	Received string
+	// Preheader is the hidden preview text, for the start of the body.
+	Preheader htmltemplate.HTML
+	// Details are the built-in blocks that follow the message: extra
+	// fields, uploaded files, threats, spam reasons, source, reputation,
+	// and history.
+	Details htmltemplate.HTML
+}
+
+// ConfirmationData is what confirmation templates are executed with. Its
+// Confirmation holds the texts for the submitter's language.
+type ConfirmationData struct {
+	Submission
+}
+
+// LoadTemplates parses the templates in dir. Names without a file keep the
+// built-in body, but dir must hold at least one template, so a mistyped
+// TEMPLATE_DIR does not go unnoticed.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{html: map[string]*htmltemplate.Template{}, text: map[string]*texttemplate.Template{}}
+	for _, name := range []string{TemplateNotification, TemplateConfirmation} {
+		if src, ok, err := readTemplate(dir, name+".html"); err != nil {
+			return nil, err
+		} else if ok {
+			tmpl, err := htmltemplate.New(name + ".html").Funcs(templateFuncs).Parse(src)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template: %w", err)
+			}
+			t.html[name] = tmpl
+		}
+		if src, ok, err := readTemplate(dir, name+".txt"); err != nil {
+			return nil, err
+		} else if ok {
+			tmpl, err := texttemplate.New(name + ".txt").Funcs(templateFuncs).Parse(src)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template: %w", err)
+			}
+			t.text[name] = tmpl
+		}
+	}
+	if len(t.html) == 0 && len(t.text) == 0 {
+		return nil, fmt.Errorf("no templates in %s: expected %s.html, %s.txt, %s.html, or %s.txt",
+			dir, TemplateNotification, TemplateNotification, TemplateConfirmation, TemplateConfirmation)
+	}
+	return t, nil
+}
+
+func readTemplate(dir, file string) (string, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read template: %w", err)
+	}
+	return string(data), true, nil
+}
+
+// UseTemplates renders the notification and confirmation bodies from t.
+func (s *Sender) UseTemplates(t *Templates) {
+	s.templates = t
+}
+
+// render returns the bodies of the email name for data: the HTML from its
+// template or else the built-in one, and the text from its template or "".
+// The form's own templates are looked in first, then the Sender's. A
+// template that fails is logged and left out, so the email still goes out
+// with the built-in body.
+func (s *Sender) render(form *Templates, name string, data any) (html, text string) {
+	var b strings.Builder
+	rendered := false
+	if tmpl := htmlTemplate(name, form, s.templates); tmpl != nil {
+		if err := tmpl.Execute(&b, data); err != nil {
+			s.logger.Warn("Failed to render template, using the built-in body", "template", name+".html", "error", err)
+		} else {
+			html, rendered = b.String(), true
+		}
+	}
+	if !rendered {
+		b.Reset()
+		if err := builtinTemplates.ExecuteTemplate(&b, name+".html", data); err != nil {
+			s.logger.Error("Failed to render built-in body", "template", name+".html", "error", err)
+		}
+		html = b.String()
+	}
+	if tmpl := textTemplate(name, form, s.templates); tmpl != nil {
+		b.Reset()
+		if err := tmpl.Execute(&b, data); err != nil {
+			s.logger.Warn("Failed to render template, sending no text part", "template", name+".txt", "error", err)
+		} else {
+			text = b.String()
+		}
+	}
+	return html, text
+}
+
+// htmlTemplate returns the HTML template name from the first of sets that
+// has one, or nil.
+func htmlTemplate(name string, sets ...*Templates) *htmltemplate.Template {
+	for _, t := range sets {
+		if t != nil && t.html[name] != nil {
+			return t.html[name]
+		}
+	}
+	return nil
+}
+
+// textTemplate returns the text template name from the first of sets that
+// has one, or nil.
+func textTemplate(name string, sets ...*Templates) *texttemplate.Template {
+	for _, t := range sets {
+		if t != nil && t.text[name] != nil {
+			return t.text[name]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"form2mail/internal/config"
+)
+
+func TestBuiltinBodiesEscapeSubmission(t *testing.T) {
+	s := NewSender(config.Config{}, nil)
+	sub := Submission{
+		Name:    `Eve <a href="https://phish.example">`,
+		Email:   `eve@example.com"><script>`,
+		Subject: "<b>Urgent</b>",
+		Message: "Line one\n<img src=x onerror=alert(1)>",
+		Confirmation: Confirmation{
+			Image:       "logo.png",
+			Greeting:    "Thank you, <i>Eve</i>!",
+			Intro:       "We got it.",
+			YourMessage: "Your message:",
+			Closing:     "Bye",
+		},
+	}
+
+	tests := []struct {
+		name string
+		data any
+		want []string
+	}{
+		{
+			name: TemplateNotification,
+			data: NotificationData{Submission: sub, Received: "Sat, 01 Mar 2025 12:00 UTC"},
+			want: []string{
+				"<strong>Name:</strong> Eve &lt;a href=&#34;https://phish.example&#34;&gt;</p>",
+				"<strong>Email:</strong> eve@example.com&#34;&gt;&lt;script&gt;</p>",
+				"<strong>Subject:</strong> &lt;b&gt;Urgent&lt;/b&gt;</p>",
+				"<p>Line one<br>&lt;img src=x onerror=alert(1)&gt;</p>",
+			},
+		},
+		{
+			name: TemplateConfirmation,
+			data: ConfirmationData{Submission: sub},
+			want: []string{
+				`<img src="cid:logo.png" alt="">`,
+				"<h2>Thank you, &lt;i&gt;Eve&lt;/i&gt;!</h2>",
+				"<p>Line one<br>&lt;img src=x onerror=alert(1)&gt;</p>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := s.render(nil, tt.name, tt.data)
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+			for _, raw := range []string{"<script>", "<a href", "<img src=x", "<b>", "<i>"} {
+				if strings.Contains(body, raw) {
+					t.Errorf("body contains unescaped %q:\n%s", raw, body)
+				}
+			}
+		})
+	}
+}
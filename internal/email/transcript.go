@@ -0,0 +1,195 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+// TranscriptError is a failed SMTP delivery together with the dialogue
+// that led to it, recorded when SMTP_TRANSCRIPTS is enabled.
+type TranscriptError struct {
+	Err error
+	// Transcript holds one line per command and reply line, prefixed with
+	// "C: " or "S: ". Credentials and message contents are left out.
+	Transcript []string
+}
+
+func (e *TranscriptError) Error() string { return e.Err.Error() }
+
+func (e *TranscriptError) Unwrap() error { return e.Err }
+
+// transcript records an SMTP session line by line. It is safe for
+// concurrent use, since reads and writes may happen on different
+// goroutines.
+type transcript struct {
+	mu     sync.Mutex
+	lines  []string
+	client bytes.Buffer
+	server bytes.Buffer
+	// secret is set after a 334 challenge: the client's next line is an
+	// AUTH response
+	secret bool
+	// data counts the message bytes while the client sends DATA
+	data    bool
+	dataLen int
+}
+
+// wrap records everything read from and written to conn.
+func (t *transcript) wrap(conn net.Conn) net.Conn {
+	return &recordingConn{Conn: conn, t: t}
+}
+
+// Lines returns the recorded lines, including a line still in progress.
+func (t *transcript) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := append([]string(nil), t.lines...)
+	if t.client.Len() > 0 && !t.data {
+		lines = append(lines, "C: "+t.redact(t.client.String()))
+	}
+	if t.server.Len() > 0 {
+		lines = append(lines, "S: "+t.server.String())
+	}
+	return lines
+}
+
+func (t *transcript) written(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.client.Write(p)
+	for {
+		line, ok := nextLine(&t.client)
+		if !ok {
+			return
+		}
+		if t.data {
+			if line != "." {
+				t.dataLen += len(line) + 2
+				continue
+			}
+			t.lines = append(t.lines, fmt.Sprintf("C: [message, %d bytes]", t.dataLen), "C: .")
+			t.data, t.dataLen = false, 0
+			continue
+		}
+		t.lines = append(t.lines, "C: "+t.redact(line))
+		t.secret = false
+	}
+}
+
+func (t *transcript) read(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.server.Write(p)
+	for {
+		line, ok := nextLine(&t.server)
+		if !ok {
+			return
+		}
+		t.lines = append(t.lines, "S: "+line)
+		switch {
+		case strings.HasPrefix(line, "334"):
+			t.secret = true
+		case strings.HasPrefix(line, "354"):
+			t.data = true
+		}
+	}
+}
+
+// redact hides AUTH arguments and responses to AUTH challenges.
+func (t *transcript) redact(line string) string {
+	if t.secret {
+		return "[redacted]"
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 2 && strings.EqualFold(fields[0], "AUTH") {
+		return fields[0] + " " + fields[1] + " [redacted]"
+	}
+	return line
+}
+
+// nextLine removes and returns the first complete line of buf.
+func nextLine(buf *bytes.Buffer) (string, bool) {
+	i := bytes.IndexByte(buf.Bytes(), '\n')
+	if i < 0 {
+		return "", false
+	}
+	line := string(bytes.TrimRight(buf.Next(i+1), "\r\n"))
+	return line, true
+}
+
+type recordingConn struct {
+	net.Conn
+	t *transcript
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.t.read(p[:n])
+	return n, err
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.t.written(p)
+	return c.Conn.Write(p)
+}
+
+// startTLS upgrades client's session on conn and returns a client for the
+// encrypted session. net/smtp's own StartTLS would put the TLS layer above
+// a recording connection, leaving only ciphertext to record, so the
+// upgrade happens here below it.
+func startTLS(client *smtp.Client, conn net.Conn, config *tls.Config, t *transcript) (*smtp.Client, error) {
+	id, err := client.Text.Cmd("STARTTLS")
+	if err != nil {
+		return nil, err
+	}
+	client.Text.StartResponse(id)
+	_, _, err = client.Text.ReadResponse(220)
+	client.Text.EndResponse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	var encrypted net.Conn = tlsConn
+	if t != nil {
+		encrypted = t.wrap(encrypted)
+	}
+	// The server does not greet again after the upgrade; stand in for it,
+	// so the new client starts with EHLO
+	return smtp.NewClient(&greetedConn{Conn: encrypted, greeting: []byte("220 " + config.ServerName + "\r\n")}, config.ServerName)
+}
+
+// greetedConn reads a greeting before what is read from the connection.
+type greetedConn struct {
+	net.Conn
+	greeting []byte
+}
+
+func (c *greetedConn) Read(p []byte) (int, error) {
+	if len(c.greeting) > 0 {
+		n := copy(p, c.greeting)
+		c.greeting = c.greeting[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
+
+// tlsAuth tells auth that the session is encrypted, which net/smtp only
+// knows after its own StartTLS; PLAIN refuses to send the password
+// otherwise.
+type tlsAuth struct {
+	smtp.Auth
+}
+
+func (a tlsAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	server.TLS = true
+	return a.Auth.Start(server)
+}
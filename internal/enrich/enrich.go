@@ -0,0 +1,183 @@
+// Package enrich looks up context about a submitter's email address to help
+// owners prioritize inquiries.
+package enrich
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gravatarURL = "https://www.gravatar.com/avatar/%s?d=404"
+	rdapURL     = "https://rdap.org/domain/%s"
+)
+
+// Info is what could be learned about an address. Lookups that failed or
+// timed out leave their fields unset.
+type Info struct {
+	Domain string
+	// FreeMail is true for consumer mailbox providers such as gmail.com.
+	FreeMail bool
+	// HasGravatar is nil when the Gravatar lookup failed.
+	HasGravatar *bool
+	// DomainCreated is zero when the registration date is unknown.
+	DomainCreated time.Time
+}
+
+// Enricher performs the lookups with a shared timeout.
+type Enricher struct {
+	client  *http.Client
+	timeout time.Duration
+	// gravatarEndpoint and rdapEndpoint are formats taking the address
+	// hash and the domain.
+	gravatarEndpoint string
+	rdapEndpoint     string
+}
+
+// New returns an Enricher whose lookups give up after timeout.
+func New(timeout time.Duration) *Enricher {
+	return &Enricher{
+		client:           &http.Client{Timeout: timeout},
+		timeout:          timeout,
+		gravatarEndpoint: gravatarURL,
+		rdapEndpoint:     rdapURL,
+	}
+}
+
+// UseTransport makes the Gravatar and RDAP lookups of e go through rt
+// instead of http.DefaultTransport. It must be called before e is used.
+func (e *Enricher) UseTransport(rt http.RoundTripper) {
+	e.client.Transport = rt
+}
+
+// Lookup gathers Info for address, running the network lookups concurrently.
+func (e *Enricher) Lookup(ctx context.Context, address string) Info {
+	address = strings.ToLower(strings.TrimSpace(address))
+	info := Info{}
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		info.Domain = address[at+1:]
+	}
+	info.FreeMail = freeMailDomains[info.Domain]
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if has, err := e.gravatar(ctx, address); err == nil {
+			info.HasGravatar = &has
+		}
+	}()
+
+	// Domain age says little about free-mail providers
+	if info.Domain != "" && !info.FreeMail {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if created, err := e.domainCreated(ctx, info.Domain); err == nil {
+				info.DomainCreated = created
+			}
+		}()
+	}
+
+	wg.Wait()
+	return info
+}
+
+func (e *Enricher) gravatar(ctx context.Context, address string) (bool, error) {
+	sum := md5.Sum([]byte(address))
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, fmt.Sprintf(e.gravatarEndpoint, hex.EncodeToString(sum[:])), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected gravatar status %d", resp.StatusCode)
+	}
+}
+
+// domainCreated asks RDAP (the structured successor of WHOIS) for the
+// registration date of domain.
+func (e *Enricher) domainCreated(ctx context.Context, domain string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(e.rdapEndpoint, domain), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("unexpected RDAP status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Events []struct {
+			Action string    `json:"eventAction"`
+			Date   time.Time `json:"eventDate"`
+		} `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode RDAP response: %w", err)
+	}
+	for _, event := range body.Events {
+		if event.Action == "registration" {
+			return event.Date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no registration date for %s", domain)
+}
+
+var freeMailDomains = map[string]bool{
+	"163.com":        true,
+	"aol.com":        true,
+	"freenet.de":     true,
+	"gmail.com":      true,
+	"gmx.at":         true,
+	"gmx.ch":         true,
+	"gmx.com":        true,
+	"gmx.de":         true,
+	"gmx.net":        true,
+	"googlemail.com": true,
+	"hotmail.com":    true,
+	"icloud.com":     true,
+	"live.com":       true,
+	"mac.com":        true,
+	"mail.com":       true,
+	"mail.ru":        true,
+	"me.com":         true,
+	"msn.com":        true,
+	"outlook.com":    true,
+	"proton.me":      true,
+	"protonmail.com": true,
+	"qq.com":         true,
+	"t-online.de":    true,
+	"web.de":         true,
+	"yahoo.com":      true,
+	"yahoo.de":       true,
+	"yandex.com":     true,
+	"yandex.ru":      true,
+	"zoho.com":       true,
+}
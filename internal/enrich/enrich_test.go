@@ -0,0 +1,109 @@
+package enrich
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newServer answers Gravatar and RDAP lookups: ada@acme.example has a
+// Gravatar, acme.example was registered in 2009, and anything at
+// slow.example only answers once the test ends.
+func newServer(t *testing.T, e *Enricher) (rdapLookups func() []string) {
+	t.Helper()
+	ada := md5.Sum([]byte("ada@acme.example"))
+	slow := md5.Sum([]byte("slow@slow.example"))
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var domains []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/avatar/"+hex.EncodeToString(slow[:]) || r.URL.Path == "/domain/slow.example":
+			<-release
+		case r.URL.Path == "/avatar/"+hex.EncodeToString(ada[:]):
+			if r.Method != http.MethodHead || r.URL.Query().Get("d") != "404" {
+				t.Errorf("%s %s", r.Method, r.URL)
+			}
+		case strings.HasPrefix(r.URL.Path, "/avatar/"):
+			http.NotFound(w, r)
+		case strings.HasPrefix(r.URL.Path, "/domain/"):
+			mu.Lock()
+			domains = append(domains, strings.TrimPrefix(r.URL.Path, "/domain/"))
+			mu.Unlock()
+			if r.URL.Path != "/domain/acme.example" {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/rdap+json")
+			w.Write([]byte(`{"events": [
+				{"eventAction": "last changed", "eventDate": "2024-06-01T08:00:00Z"},
+				{"eventAction": "registration", "eventDate": "2009-04-17T10:30:00Z"}
+			]}`))
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		srv.Close()
+	})
+	e.gravatarEndpoint = srv.URL + "/avatar/%s?d=404"
+	e.rdapEndpoint = srv.URL + "/domain/%s"
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, domains...)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	e := New(time.Second)
+	rdapLookups := newServer(t, e)
+
+	info := e.Lookup(context.Background(), " Ada@Acme.Example ")
+	if info.Domain != "acme.example" || info.FreeMail {
+		t.Errorf("info %+v", info)
+	}
+	if info.HasGravatar == nil || !*info.HasGravatar {
+		t.Error("Gravatar not found")
+	}
+	if want := time.Date(2009, 4, 17, 10, 30, 0, 0, time.UTC); !info.DomainCreated.Equal(want) {
+		t.Errorf("domain created %v, want %v", info.DomainCreated, want)
+	}
+
+	info = e.Lookup(context.Background(), "bob@unknown.example")
+	if info.HasGravatar == nil || *info.HasGravatar {
+		t.Error("want a Gravatar lookup finding none")
+	}
+	if !info.DomainCreated.IsZero() {
+		t.Errorf("domain created %v for a domain RDAP does not know", info.DomainCreated)
+	}
+
+	info = e.Lookup(context.Background(), "someone@gmail.com")
+	if !info.FreeMail || info.HasGravatar == nil {
+		t.Errorf("info %+v", info)
+	}
+	for _, domain := range rdapLookups() {
+		if domain == "gmail.com" {
+			t.Error("looked up the age of a free-mail domain")
+		}
+	}
+}
+
+func TestLookupTimesOut(t *testing.T) {
+	e := New(50 * time.Millisecond)
+	newServer(t, e)
+
+	start := time.Now()
+	info := e.Lookup(context.Background(), "slow@slow.example")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Lookup took %v", elapsed)
+	}
+	if info.Domain != "slow.example" || info.HasGravatar != nil || !info.DomainCreated.IsZero() {
+		t.Errorf("info %+v, want only the domain", info)
+	}
+}
@@ -0,0 +1,150 @@
+package form
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Greeting styles selectable via Confirmation.Greeting.
+const (
+	// GreetingThanks thanks the submitter by name; the default.
+	GreetingThanks = "thanks"
+	// GreetingFormal addresses the submitter by the salutation field.
+	GreetingFormal = "formal"
+	// GreetingTime greets by the time of day.
+	GreetingTime = "time"
+)
+
+// defaultSalutationField is the field read for GreetingFormal.
+const defaultSalutationField = "salutation"
+
+// Confirmation configures the confirmation email sent to submitters. Unset
+// texts come from the built-in translation for the form's language.
+type Confirmation struct {
+	Subject     string `json:"subject,omitempty"`
+	Intro       string `json:"intro,omitempty"`
+	YourMessage string `json:"your_message,omitempty"`
+	Closing     string `json:"closing,omitempty"`
+	Greeting    string `json:"greeting,omitempty"`
+	// SalutationField names the field holding the salutation or gender,
+	// "salutation" by default.
+	SalutationField string `json:"salutation_field,omitempty"`
+	// Image is the name of an inline image shown above the greeting.
+	Image string `json:"image,omitempty"`
+}
+
+// ConfirmationText is a confirmation rendered for one submitter.
+type ConfirmationText struct {
+	Image       string
+	Subject     string
+	Greeting    string
+	Intro       string
+	YourMessage string
+	Closing     string
+}
+
+// greetings holds the greeting templates of a language. Each takes the
+// submitter's name.
+type greetings struct {
+	thanks, formalMale, formalFemale, formalNeutral, morning, day, evening string
+}
+
+var builtinConfirmations = map[string]Confirmation{
+	"en": {
+		Subject:     "Thank you for contacting us",
+		Intro:       "We have received your contact form submission and will get back to you as soon as possible.",
+		YourMessage: "Your message:",
+		Closing:     "Best regards",
+	},
+	"de": {
+		Subject:     "Vielen Dank für Ihre Nachricht",
+		Intro:       "Wir haben Ihre Nachricht erhalten und melden uns so schnell wie möglich bei Ihnen.",
+		YourMessage: "Ihre Nachricht:",
+		Closing:     "Mit freundlichen Grüßen",
+	},
+}
+
+var builtinGreetings = map[string]greetings{
+	"en": {
+		thanks:        "Thank you for your message, %s!",
+		formalMale:    "Dear Mr %s,",
+		formalFemale:  "Dear Ms %s,",
+		formalNeutral: "Dear %s,",
+		morning:       "Good morning, %s!",
+		day:           "Good afternoon, %s!",
+		evening:       "Good evening, %s!",
+	},
+	"de": {
+		thanks:        "Vielen Dank für Ihre Nachricht, %s!",
+		formalMale:    "Sehr geehrter Herr %s,",
+		formalFemale:  "Sehr geehrte Frau %s,",
+		formalNeutral: "Guten Tag %s,",
+		morning:       "Guten Morgen, %s!",
+		day:           "Guten Tag, %s!",
+		evening:       "Guten Abend, %s!",
+	},
+}
+
+// Salutations recognized in the salutation field, by gender.
+var (
+	maleSalutations   = map[string]bool{"mr": true, "herr": true, "male": true, "m": true, "man": true, "mann": true, "männlich": true}
+	femaleSalutations = map[string]bool{"ms": true, "mrs": true, "miss": true, "frau": true, "female": true, "f": true, "w": true, "woman": true, "weiblich": true}
+)
+
+// SalutationField returns the name of the field read for formal greetings.
+func (d Definition) SalutationField() string {
+	if d.Confirmation.SalutationField == "" {
+		return defaultSalutationField
+	}
+	return d.Confirmation.SalutationField
+}
+
+// ConfirmationText renders the confirmation for a submitter called name.
+// salutation is the value of the salutation field, if any; now decides
+// between morning, day, and evening greetings.
+func (d Definition) ConfirmationText(name, salutation string, now time.Time) ConfirmationText {
+	c := d.Confirmation
+	for _, fallback := range []Confirmation{builtinConfirmations[d.Lang()], builtinConfirmations[DefaultLanguage]} {
+		c.Subject = firstNonEmpty(c.Subject, fallback.Subject)
+		c.Intro = firstNonEmpty(c.Intro, fallback.Intro)
+		c.YourMessage = firstNonEmpty(c.YourMessage, fallback.YourMessage)
+		c.Closing = firstNonEmpty(c.Closing, fallback.Closing)
+	}
+
+	g, ok := builtinGreetings[d.Lang()]
+	if !ok {
+		g = builtinGreetings[DefaultLanguage]
+	}
+	template := g.thanks
+	switch c.Greeting {
+	case GreetingFormal:
+		key := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(salutation)), ".")
+		switch {
+		case maleSalutations[key]:
+			template = g.formalMale
+		case femaleSalutations[key]:
+			template = g.formalFemale
+		default:
+			template = g.formalNeutral
+		}
+	case GreetingTime:
+		switch hour := now.Hour(); {
+		case hour >= 5 && hour < 11:
+			template = g.morning
+		case hour >= 11 && hour < 18:
+			template = g.day
+		default:
+			template = g.evening
+		}
+	}
+
+	return ConfirmationText{
+		Image:       c.Image,
+		Subject:     c.Subject,
+		Greeting:    fmt.Sprintf(template, name),
+		Intro:       c.Intro,
+		YourMessage: c.YourMessage,
+		Closing:     c.Closing,
+	}
+}
@@ -0,0 +1,84 @@
+package form
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Field describes how an extra form field is shown in notifications and
+// which values it accepts. The rules also apply to the standard name,
+// subject, and message fields when configured under their names.
+type Field struct {
+	Name string `json:"name"`
+	// Label replaces the field name in the notification.
+	Label string `json:"label,omitempty"`
+	// Group puts the field under a section heading of that name.
+	Group string `json:"group,omitempty"`
+	// Type is FieldDate or FieldNumber for values normalized from the
+	// form's locale.
+	Type string `json:"type,omitempty"`
+
+	// Required rejects submissions that leave the field empty.
+	Required bool `json:"required,omitempty"`
+	// MaxLength caps the value's length in characters.
+	MaxLength int `json:"max_length,omitempty"`
+	// Pattern is a regular expression the whole value has to match.
+	Pattern string `json:"pattern,omitempty"`
+	// AllowedValues are the only values accepted, e.g. a select's options.
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	// Message replaces the built-in text for a value breaking the rules.
+	Message string `json:"message,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Value is a submitted field value together with its display settings.
+type Value struct {
+	Field
+	Value string
+}
+
+// Arrange orders submitted extra fields for display. Ungrouped fields come
+// first, then each group in the order it is first mentioned, with fields in
+// their configured order. Fields the definition does not mention are shown
+// after the configured ungrouped ones, sorted by name.
+func (d Definition) Arrange(values map[string]string) []Value {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var groups []string
+	byGroup := make(map[string][]Value)
+	configured := make(map[string]bool, len(d.Fields))
+	for _, f := range d.Fields {
+		value, ok := values[f.Name]
+		if !ok || configured[f.Name] {
+			continue
+		}
+		configured[f.Name] = true
+		if f.Label == "" {
+			f.Label = f.Name
+		}
+		if _, seen := byGroup[f.Group]; !seen && f.Group != "" {
+			groups = append(groups, f.Group)
+		}
+		byGroup[f.Group] = append(byGroup[f.Group], Value{Field: f, Value: value})
+	}
+
+	var rest []string
+	for name := range values {
+		if !configured[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		byGroup[""] = append(byGroup[""], Value{Field: Field{Name: name, Label: name}, Value: values[name]})
+	}
+
+	arranged := byGroup[""]
+	for _, group := range groups {
+		arranged = append(arranged, byGroup[group]...)
+	}
+	return arranged
+}
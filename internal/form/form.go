@@ -0,0 +1,320 @@
+// Package form loads the definitions of named forms served by one instance.
+package form
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// CORS describes which cross-origin requests a form accepts. Empty fields
+// fall back to the global defaults.
+type CORS struct {
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+}
+
+// Definition configures a single form.
+type Definition struct {
+	ID       string   `json:"id"`
+	CORS     CORS     `json:"cors,omitzero"`
+	Language string   `json:"language,omitempty"`
+	Locale   string   `json:"locale,omitempty"`
+	Messages Messages `json:"messages,omitzero"`
+	// Labels are the strings of the HTML fallback form.
+	Labels Labels `json:"labels,omitzero"`
+	// FromName and FromEmail override FROM_NAME and FROM_EMAIL for the
+	// emails sent about this form's submissions.
+	FromName  string `json:"from_name,omitempty"`
+	FromEmail string `json:"from_email,omitempty"`
+	// Confirmation configures the email sent to the submitter.
+	Confirmation Confirmation `json:"confirmation,omitzero"`
+	// Recipient receives this form's notifications instead of
+	// RECIPIENT_EMAIL.
+	Recipient string `json:"recipient,omitempty"`
+	// SubjectPrefix starts the subject of this form's notifications, e.g.
+	// "[Acme]", so each site's mail is easy to filter.
+	SubjectPrefix string `json:"subject_prefix,omitempty"`
+	// TemplateDir holds templates for this form's emails, named like those
+	// in TEMPLATE_DIR. Emails without one there use TEMPLATE_DIR's.
+	TemplateDir string `json:"template_dir,omitempty"`
+	// Headers are added verbatim to this form's notification emails.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Preheader is the template of the notifications' preview text,
+	// replacing PREHEADER.
+	Preheader string `json:"preheader,omitempty"`
+	// AllowedEmailDomains overrides ALLOWED_EMAIL_DOMAINS for this form.
+	AllowedEmailDomains []string `json:"allowed_email_domains,omitempty"`
+	// FeedToken protects this form's Atom feed, overriding FEED_TOKEN.
+	FeedToken string `json:"feed_token,omitempty"`
+	// SpamTraps are decoy field names added to SPAM_TRAP_FIELDS.
+	SpamTraps []string `json:"spam_traps,omitempty"`
+	// Fields sets labels, order, and grouping of extra submitted fields.
+	Fields []Field `json:"fields,omitempty"`
+	// RateLimit overrides the global rate limits for this form.
+	RateLimit RateLimit `json:"rate_limit,omitzero"`
+	// Tenant is the ID of the tenant the form's usage counts toward.
+	Tenant string `json:"tenant,omitempty"`
+	// QuietHours holds back notifications, but not confirmations, during
+	// a daily window.
+	QuietHours QuietHours `json:"quiet_hours,omitzero"`
+	// Webhooks receive every accepted submission that is not spam.
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+	// Uploads route uploaded files by field; files are linked by default.
+	Uploads []UploadRule `json:"uploads,omitempty"`
+}
+
+// Registry holds form definitions and tenants by ID. It is safe for
+// concurrent use, including Replace.
+type Registry struct {
+	mu      sync.RWMutex
+	forms   map[string]Definition
+	tenants map[string]Tenant
+}
+
+// Document is the contents of a forms file. It is also the portable format
+// of the admin API's form export and import.
+type Document struct {
+	Tenants []Tenant     `json:"tenants,omitempty"`
+	Forms   []Definition `json:"forms"`
+}
+
+// NewRegistry returns a registry containing defs.
+func NewRegistry(defs ...Definition) *Registry {
+	r := &Registry{forms: make(map[string]Definition, len(defs)), tenants: make(map[string]Tenant)}
+	for _, def := range defs {
+		r.forms[def.ID] = def
+	}
+	return r
+}
+
+// Load reads form definitions from the JSON file at path.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read forms file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads form definitions from a JSON Document and validates them.
+func Parse(data []byte) (*Registry, error) {
+	var f Document
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse forms file: %w", err)
+	}
+	return f.registry()
+}
+
+// registry validates the document's tenants and forms and returns them in
+// a Registry.
+func (f Document) registry() (*Registry, error) {
+	tenants := make(map[string]Tenant, len(f.Tenants))
+	for i, t := range f.Tenants {
+		if t.ID == "" {
+			return nil, fmt.Errorf("tenant #%d has no id", i+1)
+		}
+		if _, ok := tenants[t.ID]; ok {
+			return nil, fmt.Errorf("duplicate tenant id %q", t.ID)
+		}
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", t.ID, err)
+		}
+		tenants[t.ID] = t
+	}
+
+	seen := make(map[string]bool, len(f.Forms))
+	for i, def := range f.Forms {
+		if def.ID == "" {
+			return nil, fmt.Errorf("form #%d has no id", i+1)
+		}
+		if seen[def.ID] {
+			return nil, fmt.Errorf("duplicate form id %q", def.ID)
+		}
+		seen[def.ID] = true
+		switch def.Confirmation.Greeting {
+		case "", GreetingThanks, GreetingFormal, GreetingTime:
+		default:
+			return nil, fmt.Errorf("form %q: unknown greeting %q", def.ID, def.Confirmation.Greeting)
+		}
+		if def.FromEmail != "" {
+			if addr, err := mail.ParseAddress(def.FromEmail); err != nil || addr.Address != def.FromEmail {
+				return nil, fmt.Errorf("form %q: invalid from_email %q", def.ID, def.FromEmail)
+			}
+		}
+		if def.Recipient != "" {
+			if addr, err := mail.ParseAddress(def.Recipient); err != nil || addr.Address != def.Recipient {
+				return nil, fmt.Errorf("form %q: invalid recipient %q", def.ID, def.Recipient)
+			}
+		}
+		if def.Preheader != "" {
+			if _, err := template.New("preheader").Parse(def.Preheader); err != nil {
+				return nil, fmt.Errorf("form %q: invalid preheader: %w", def.ID, err)
+			}
+		}
+		if err := validateFields(def); err != nil {
+			return nil, fmt.Errorf("form %q: %w", def.ID, err)
+		}
+		if err := def.RateLimit.validate(); err != nil {
+			return nil, fmt.Errorf("form %q: %w", def.ID, err)
+		}
+		if err := validateUploads(def.Uploads); err != nil {
+			return nil, fmt.Errorf("form %q: %w", def.ID, err)
+		}
+		if err := f.Forms[i].QuietHours.parse(); err != nil {
+			return nil, fmt.Errorf("form %q: %w", def.ID, err)
+		}
+		if _, ok := tenants[def.Tenant]; def.Tenant != "" && !ok {
+			return nil, fmt.Errorf("form %q: unknown tenant %q", def.ID, def.Tenant)
+		}
+		for j := range def.Fields {
+			if err := f.Forms[i].Fields[j].compile(); err != nil {
+				return nil, fmt.Errorf("form %q: %w", def.ID, err)
+			}
+		}
+		for j := range def.Webhooks {
+			if err := f.Forms[i].Webhooks[j].compile(); err != nil {
+				return nil, fmt.Errorf("form %q: %w", def.ID, err)
+			}
+		}
+	}
+
+	r := NewRegistry(f.Forms...)
+	r.tenants = tenants
+	return r, nil
+}
+
+// Document returns the forms with the given IDs and the tenants they
+// belong to, or all forms and tenants without IDs. It fails for unknown
+// IDs.
+func (r *Registry) Document(ids ...string) (Document, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	doc := Document{Forms: []Definition{}}
+	if len(ids) == 0 {
+		for _, id := range r.sortedIDs() {
+			doc.Forms = append(doc.Forms, r.forms[id])
+		}
+		doc.Tenants = r.sortedTenants()
+		return doc, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		def, ok := r.forms[id]
+		if !ok {
+			return Document{}, fmt.Errorf("unknown form %q", id)
+		}
+		doc.Forms = append(doc.Forms, def)
+		if def.Tenant != "" && !seen[def.Tenant] {
+			seen[def.Tenant] = true
+			doc.Tenants = append(doc.Tenants, r.tenants[def.Tenant])
+		}
+	}
+	return doc, nil
+}
+
+// Merge returns a registry with the forms and tenants of r, replaced or
+// extended by those of other with the same IDs. The result is validated as
+// a whole, so a form of r may not refer to a tenant other dropped.
+func (r *Registry) Merge(other Document) (*Registry, error) {
+	merged, err := r.Document()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range other.Tenants {
+		merged.Tenants = replaceByID(merged.Tenants, t, func(t Tenant) string { return t.ID })
+	}
+	for _, def := range other.Forms {
+		merged.Forms = replaceByID(merged.Forms, def, func(d Definition) string { return d.ID })
+	}
+	return merged.registry()
+}
+
+func replaceByID[T any](items []T, item T, id func(T) string) []T {
+	for i := range items {
+		if id(items[i]) == id(item) {
+			items[i] = item
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// Replace swaps in the forms and tenants of next, e.g. after the forms file
+// changed. Callers holding definitions from before keep using them.
+func (r *Registry) Replace(next *Registry) {
+	next.mu.RLock()
+	forms, tenants := next.forms, next.tenants
+	next.mu.RUnlock()
+
+	r.mu.Lock()
+	r.forms, r.tenants = forms, tenants
+	r.mu.Unlock()
+}
+
+// HasQuietHours reports whether any form has quiet hours.
+func (r *Registry) HasQuietHours() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, def := range r.forms {
+		if def.QuietHours.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// IDs returns the IDs of all forms, sorted.
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedIDs()
+}
+
+func (r *Registry) sortedIDs() []string {
+	ids := make([]string, 0, len(r.forms))
+	for id := range r.forms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Get returns the definition for id.
+func (r *Registry) Get(id string) (Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	def, ok := r.forms[id]
+	return def, ok
+}
+
+// Tenant returns the tenant with id.
+func (r *Registry) Tenant(id string) (Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	return t, ok
+}
+
+// Tenants returns all tenants, sorted by ID.
+func (r *Registry) Tenants() []Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedTenants()
+}
+
+func (r *Registry) sortedTenants() []Tenant {
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
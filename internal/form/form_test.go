@@ -0,0 +1,53 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRejectsInvalidForms(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"not JSON", `{"forms": [`, "failed to parse forms file"},
+		{"no id", `{"forms": [{"recipient": "a@example.com"}]}`, "form #1 has no id"},
+		{"duplicate id", `{"forms": [{"id": "a"}, {"id": "a"}]}`, `duplicate form id "a"`},
+		{"unknown greeting", `{"forms": [{"id": "a", "confirmation": {"greeting": "yo"}}]}`, `unknown greeting "yo"`},
+		{"invalid from", `{"forms": [{"id": "a", "from_email": "Acme <a@example.com>"}]}`, "invalid from_email"},
+		{"invalid recipient", `{"forms": [{"id": "a", "recipient": "nobody"}]}`, "invalid recipient"},
+		{"invalid preheader", `{"forms": [{"id": "a", "preheader": "{{.Name"}]}`, "invalid preheader"},
+		{"unknown tenant", `{"forms": [{"id": "a", "tenant": "acme"}]}`, `unknown tenant "acme"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestArrange(t *testing.T) {
+	def := Definition{Fields: []Field{
+		{Name: "budget", Label: "Budget", Group: "Order"},
+		{Name: "company", Label: "Company"},
+		{Name: "size", Group: "Order"},
+		{Name: "phone", Group: "Contact"},
+	}}
+	got := def.Arrange(map[string]string{"size": "M", "zeta": "z", "company": "Acme", "alpha": "a", "phone": "123", "budget": "500"})
+	var order []string
+	for _, v := range got {
+		order = append(order, v.Group+"/"+v.Label)
+	}
+	want := []string{"/Company", "/alpha", "/zeta", "Order/Budget", "Order/size", "Contact/phone"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Arrange = %v, want %v", order, want)
+	}
+	if def.Arrange(nil) != nil {
+		t.Error("Arrange of no values is not nil")
+	}
+}
@@ -0,0 +1,50 @@
+package form
+
+// Labels holds the strings of the HTML fallback form served at
+// /f/{formID}: the page title and the labels of the built-in fields.
+type Labels struct {
+	Title    string `json:"title,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Submit   string `json:"submit,omitempty"`
+	Required string `json:"required,omitempty"`
+}
+
+var builtinLabels = map[string]Labels{
+	"en": {
+		Title:    "Contact us",
+		Name:     "Name",
+		Email:    "Email",
+		Subject:  "Subject",
+		Message:  "Message",
+		Submit:   "Send message",
+		Required: "required",
+	},
+	"de": {
+		Title:    "Kontakt",
+		Name:     "Name",
+		Email:    "E-Mail",
+		Subject:  "Betreff",
+		Message:  "Nachricht",
+		Submit:   "Nachricht senden",
+		Required: "Pflichtfeld",
+	},
+}
+
+// FormLabels returns the form's labels. Unset labels come from the
+// built-in translation for the form's language, then from English.
+func (d Definition) FormLabels() Labels {
+	l := d.Labels
+	for _, fallback := range []Labels{builtinLabels[d.Lang()], builtinLabels[DefaultLanguage]} {
+		l.Title = firstNonEmpty(l.Title, fallback.Title)
+		l.Name = firstNonEmpty(l.Name, fallback.Name)
+		l.Email = firstNonEmpty(l.Email, fallback.Email)
+		l.Subject = firstNonEmpty(l.Subject, fallback.Subject)
+		l.Message = firstNonEmpty(l.Message, fallback.Message)
+		l.Submit = firstNonEmpty(l.Submit, fallback.Submit)
+		l.Required = firstNonEmpty(l.Required, fallback.Required)
+	}
+	return l
+}
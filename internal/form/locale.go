@@ -0,0 +1,183 @@
+package form
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field types whose values are normalized from the form's locale.
+const (
+	// FieldDate values are stored as YYYY-MM-DD.
+	FieldDate = "date"
+	// FieldNumber values are stored without digit grouping and with a
+	// point as decimal separator.
+	FieldNumber = "number"
+)
+
+// locale describes how dates and numbers are written.
+type locale struct {
+	// monthFirst is set where dates are written month/day/year.
+	monthFirst bool
+	decimal    rune
+	// groups are the separators allowed between groups of thousands.
+	groups string
+}
+
+var locales = map[string]locale{
+	"en":    {monthFirst: true, decimal: '.', groups: ","},
+	"en-GB": {decimal: '.', groups: ","},
+	"de":    {decimal: ',', groups: "."},
+	"de-CH": {decimal: '.', groups: "'’"},
+	"es":    {decimal: ',', groups: "."},
+	"fr":    {decimal: ',', groups: " \u00a0\u202f"},
+	"it":    {decimal: ',', groups: "."},
+	"nl":    {decimal: ',', groups: "."},
+	"pt":    {decimal: ',', groups: "."},
+}
+
+// lookupLocale finds the locale for a tag such as "de-AT", falling back to
+// its language.
+func lookupLocale(tag string) (locale, bool) {
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	l, ok := locales[lang]
+	return l, ok
+}
+
+// Loc returns the locale dates and numbers are read in, defaulting to the
+// form's language.
+func (d Definition) Loc() string {
+	if d.Locale == "" {
+		return d.Lang()
+	}
+	return d.Locale
+}
+
+// Normalize rewrites the values of date and number fields from the form's
+// locale into their canonical form. Values that cannot be read are left as
+// they are.
+func (d Definition) Normalize(values map[string]string) {
+	l, ok := lookupLocale(d.Loc())
+	if !ok {
+		return
+	}
+	for _, f := range d.Fields {
+		value, ok := values[f.Name]
+		if !ok {
+			continue
+		}
+		var normalized string
+		switch f.Type {
+		case FieldDate:
+			normalized, ok = l.date(strings.TrimSpace(value))
+		case FieldNumber:
+			normalized, ok = l.number(strings.TrimSpace(value))
+		default:
+			continue
+		}
+		if ok {
+			values[f.Name] = normalized
+		}
+	}
+}
+
+// date reads dates like 01.02.2025 or 2/1/2025, and ISO dates as sent by
+// date inputs.
+func (l locale) date(s string) (string, bool) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t.Format(time.DateOnly), true
+	}
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '.' || r == '/' || r == '-' })
+	if len(parts) != 3 || len(parts[2]) != 4 || len(parts[0]) > 2 || len(parts[1]) > 2 {
+		return "", false
+	}
+	day, month := parts[0], parts[1]
+	if l.monthFirst {
+		day, month = month, day
+	}
+	t, err := time.Parse("2006-1-2", parts[2]+"-"+month+"-"+day)
+	if err != nil {
+		return "", false
+	}
+	return t.Format(time.DateOnly), true
+}
+
+// number reads numbers like 1.234,56 in German. Without a decimal
+// separator of the locale, a plain number with a point, as sent by number
+// inputs, is accepted too; 1.234 is still a thousand in German.
+func (l locale) number(s string) (string, bool) {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = strings.TrimPrefix(s[:1], "+"), s[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(s, string(l.decimal))
+	digits, ok := l.ungroup(whole)
+	if !ok || hasFraction && !allDigits(fraction) {
+		whole, fraction, hasFraction = strings.Cut(s, ".")
+		if !allDigits(whole) || hasFraction && !allDigits(fraction) {
+			return "", false
+		}
+		digits = whole
+	}
+	if hasFraction {
+		return sign + digits + "." + fraction, true
+	}
+	return sign + digits, true
+}
+
+// ungroup removes the locale's digit grouping from whole, which must then
+// be groups of three digits after the first.
+func (l locale) ungroup(whole string) (string, bool) {
+	var digits strings.Builder
+	group, groups := 0, 0
+	for _, r := range whole {
+		switch {
+		case '0' <= r && r <= '9':
+			digits.WriteRune(r)
+			group++
+		case strings.ContainsRune(l.groups, r):
+			if group == 0 || groups == 0 && group > 3 || groups > 0 && group != 3 {
+				return "", false
+			}
+			group, groups = 0, groups+1
+		default:
+			return "", false
+		}
+	}
+	if group == 0 || groups > 0 && group != 3 {
+		return "", false
+	}
+	return digits.String(), true
+}
+
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func validateFields(d Definition) error {
+	typed := false
+	for _, f := range d.Fields {
+		switch f.Type {
+		case "":
+		case FieldDate, FieldNumber:
+			typed = true
+		default:
+			return fmt.Errorf("field %q: unknown type %q", f.Name, f.Type)
+		}
+	}
+	if _, ok := lookupLocale(d.Loc()); !ok && (typed || d.Locale != "") {
+		return fmt.Errorf("unsupported locale %q", d.Loc())
+	}
+	return nil
+}
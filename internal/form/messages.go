@@ -0,0 +1,153 @@
+package form
+
+// DefaultLanguage is used when a form does not configure a language.
+const DefaultLanguage = "en"
+
+// Messages holds the user-facing strings returned by the submission endpoint.
+type Messages struct {
+	Success          string `json:"success,omitempty"`
+	InvalidJSON      string `json:"invalid_json,omitempty"`
+	InvalidForm      string `json:"invalid_form,omitempty"`
+	RequiredFields   string `json:"required_fields,omitempty"`
+	InvalidEmail     string `json:"invalid_email,omitempty"`
+	Undeliverable    string `json:"undeliverable_email,omitempty"`
+	Validation       string `json:"validation,omitempty"`
+	FieldRequired    string `json:"field_required,omitempty"`
+	FieldTooLong     string `json:"field_too_long,omitempty"`
+	FieldNotAllowed  string `json:"field_not_allowed,omitempty"`
+	FieldInvalid     string `json:"field_invalid,omitempty"`
+	SendFailed       string `json:"send_failed,omitempty"`
+	StorageFailed    string `json:"storage_failed,omitempty"`
+	Duplicate        string `json:"duplicate,omitempty"`
+	KeyReused        string `json:"idempotency_key_reused,omitempty"`
+	DailyLimit       string `json:"daily_limit,omitempty"`
+	RateLimit        string `json:"rate_limit,omitempty"`
+	QuotaExceeded    string `json:"quota_exceeded,omitempty"`
+	DomainNotAllowed string `json:"domain_not_allowed,omitempty"`
+	Busy             string `json:"busy,omitempty"`
+	Maintenance      string `json:"maintenance,omitempty"`
+	Captcha          string `json:"captcha,omitempty"`
+	CaptchaRequired  string `json:"captcha_required,omitempty"`
+	TooDeep          string `json:"json_too_deep,omitempty"`
+	TooManyFields    string `json:"json_too_many_fields,omitempty"`
+	TooLarge         string `json:"too_large,omitempty"`
+	FileType         string `json:"file_type,omitempty"`
+	Malicious        string `json:"malicious,omitempty"`
+}
+
+var builtinMessages = map[string]Messages{
+	"en": {
+		Success:          "Your message has been sent successfully",
+		InvalidJSON:      "Invalid JSON format",
+		InvalidForm:      "Failed to parse form",
+		RequiredFields:   "Name, email, and message are required",
+		InvalidEmail:     "Please enter a valid email address",
+		Undeliverable:    "This email address cannot receive mail. Please check the part after the @.",
+		Validation:       "Please correct the marked fields",
+		FieldRequired:    "This field is required",
+		FieldTooLong:     "This entry is too long",
+		FieldNotAllowed:  "Please choose one of the offered options",
+		FieldInvalid:     "This entry is not in the expected format",
+		SendFailed:       "Failed to send email",
+		StorageFailed:    "Your message could not be saved. Please try again later.",
+		Duplicate:        "This message has already been sent",
+		KeyReused:        "This request was already used for a different message",
+		DailyLimit:       "You have reached the daily limit of messages. Please try again tomorrow.",
+		RateLimit:        "You are sending messages too quickly. Please wait a moment and try again.",
+		QuotaExceeded:    "This form cannot accept more messages this month.",
+		DomainNotAllowed: "Submissions are only accepted from approved email domains",
+		Busy:             "We are receiving too many messages right now. Please try again in a moment.",
+		Maintenance:      "We are performing maintenance. Please try again shortly.",
+		Captcha:          "Captcha verification failed. Please try again.",
+		CaptchaRequired:  "Please solve the captcha to send your message.",
+		TooDeep:          "JSON is nested too deeply",
+		TooManyFields:    "Too many fields",
+		TooLarge:         "Submission is too large",
+		FileType:         "This type of file is not accepted",
+		Malicious:        "Your message contains a link known to be unsafe",
+	},
+	"de": {
+		Success:          "Ihre Nachricht wurde erfolgreich versendet",
+		InvalidJSON:      "Ungültiges JSON-Format",
+		InvalidForm:      "Formular konnte nicht gelesen werden",
+		RequiredFields:   "Name, E-Mail und Nachricht sind erforderlich",
+		InvalidEmail:     "Bitte geben Sie eine gültige E-Mail-Adresse ein",
+		Undeliverable:    "An diese E-Mail-Adresse kann keine Post zugestellt werden. Bitte prüfen Sie den Teil nach dem @.",
+		Validation:       "Bitte korrigieren Sie die markierten Felder",
+		FieldRequired:    "Dieses Feld ist erforderlich",
+		FieldTooLong:     "Diese Eingabe ist zu lang",
+		FieldNotAllowed:  "Bitte wählen Sie eine der angebotenen Optionen",
+		FieldInvalid:     "Diese Eingabe hat nicht das erwartete Format",
+		SendFailed:       "E-Mail konnte nicht versendet werden",
+		StorageFailed:    "Ihre Nachricht konnte nicht gespeichert werden. Bitte versuchen Sie es später erneut.",
+		Duplicate:        "Diese Nachricht wurde bereits gesendet",
+		KeyReused:        "Diese Anfrage wurde bereits für eine andere Nachricht verwendet",
+		DailyLimit:       "Sie haben das Tageslimit für Nachrichten erreicht. Bitte versuchen Sie es morgen erneut.",
+		RateLimit:        "Sie senden zu viele Nachrichten in kurzer Zeit. Bitte warten Sie einen Moment.",
+		QuotaExceeded:    "Über dieses Formular können diesen Monat keine weiteren Nachrichten gesendet werden.",
+		DomainNotAllowed: "Es werden nur Nachrichten von zugelassenen E-Mail-Domains angenommen",
+		Busy:             "Wir erhalten gerade sehr viele Nachrichten. Bitte versuchen Sie es gleich noch einmal.",
+		Maintenance:      "Wir führen gerade Wartungsarbeiten durch. Bitte versuchen Sie es in Kürze erneut.",
+		Captcha:          "Die Captcha-Prüfung ist fehlgeschlagen. Bitte versuchen Sie es erneut.",
+		CaptchaRequired:  "Bitte lösen Sie das Captcha, um Ihre Nachricht zu senden.",
+		TooDeep:          "JSON ist zu tief verschachtelt",
+		TooManyFields:    "Zu viele Felder",
+		TooLarge:         "Die Nachricht ist zu groß",
+		FileType:         "Dieser Dateityp wird nicht angenommen",
+		Malicious:        "Ihre Nachricht enthält einen als unsicher bekannten Link",
+	},
+}
+
+// Lang returns the form's language, defaulting to DefaultLanguage.
+func (d Definition) Lang() string {
+	if d.Language == "" {
+		return DefaultLanguage
+	}
+	return d.Language
+}
+
+// Strings returns the form's messages. Unset strings come from the built-in
+// translation for the form's language, then from English.
+func (d Definition) Strings() Messages {
+	m := d.Messages
+	for _, fallback := range []Messages{builtinMessages[d.Lang()], builtinMessages[DefaultLanguage]} {
+		m.Success = firstNonEmpty(m.Success, fallback.Success)
+		m.InvalidJSON = firstNonEmpty(m.InvalidJSON, fallback.InvalidJSON)
+		m.InvalidForm = firstNonEmpty(m.InvalidForm, fallback.InvalidForm)
+		m.RequiredFields = firstNonEmpty(m.RequiredFields, fallback.RequiredFields)
+		m.InvalidEmail = firstNonEmpty(m.InvalidEmail, fallback.InvalidEmail)
+		m.Undeliverable = firstNonEmpty(m.Undeliverable, fallback.Undeliverable)
+		m.Validation = firstNonEmpty(m.Validation, fallback.Validation)
+		m.FieldRequired = firstNonEmpty(m.FieldRequired, fallback.FieldRequired)
+		m.FieldTooLong = firstNonEmpty(m.FieldTooLong, fallback.FieldTooLong)
+		m.FieldNotAllowed = firstNonEmpty(m.FieldNotAllowed, fallback.FieldNotAllowed)
+		m.FieldInvalid = firstNonEmpty(m.FieldInvalid, fallback.FieldInvalid)
+		m.SendFailed = firstNonEmpty(m.SendFailed, fallback.SendFailed)
+		m.StorageFailed = firstNonEmpty(m.StorageFailed, fallback.StorageFailed)
+		m.Duplicate = firstNonEmpty(m.Duplicate, fallback.Duplicate)
+		m.KeyReused = firstNonEmpty(m.KeyReused, fallback.KeyReused)
+		m.DailyLimit = firstNonEmpty(m.DailyLimit, fallback.DailyLimit)
+		m.RateLimit = firstNonEmpty(m.RateLimit, fallback.RateLimit)
+		m.QuotaExceeded = firstNonEmpty(m.QuotaExceeded, fallback.QuotaExceeded)
+		m.DomainNotAllowed = firstNonEmpty(m.DomainNotAllowed, fallback.DomainNotAllowed)
+		m.Busy = firstNonEmpty(m.Busy, fallback.Busy)
+		m.Maintenance = firstNonEmpty(m.Maintenance, fallback.Maintenance)
+		m.Captcha = firstNonEmpty(m.Captcha, fallback.Captcha)
+		m.CaptchaRequired = firstNonEmpty(m.CaptchaRequired, fallback.CaptchaRequired)
+		m.TooDeep = firstNonEmpty(m.TooDeep, fallback.TooDeep)
+		m.TooManyFields = firstNonEmpty(m.TooManyFields, fallback.TooManyFields)
+		m.TooLarge = firstNonEmpty(m.TooLarge, fallback.TooLarge)
+		m.FileType = firstNonEmpty(m.FileType, fallback.FileType)
+		m.Malicious = firstNonEmpty(m.Malicious, fallback.Malicious)
+	}
+	return m
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
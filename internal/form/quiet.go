@@ -0,0 +1,84 @@
+package form
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily window, e.g. 22:00 to 07:00, during which a form's
+// notifications are held back and delivered together when it ends.
+type QuietHours struct {
+	// Start and End are times of day as HH:MM. A window with End before
+	// Start spans midnight.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Timezone is the IANA zone the times are in, TIMEZONE if empty.
+	Timezone string `json:"timezone,omitempty"`
+
+	start, end int // minutes after midnight
+	loc        *time.Location
+}
+
+// Enabled reports whether the form has quiet hours.
+func (q QuietHours) Enabled() bool {
+	return q.Start != "" || q.End != ""
+}
+
+// Until returns when the quiet hours containing t end, or the zero time if t
+// is outside them. The times are read in the form's timezone, or in fallback
+// if it has none.
+func (q QuietHours) Until(t time.Time, fallback *time.Location) time.Time {
+	if !q.Enabled() {
+		return time.Time{}
+	}
+	loc := q.loc
+	if loc == nil {
+		loc = fallback
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endOn := func(days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, q.end/60, q.end%60, 0, 0, loc)
+	}
+
+	switch {
+	case q.start < q.end && minute >= q.start && minute < q.end:
+		return endOn(0)
+	case q.start > q.end && minute >= q.start:
+		return endOn(1)
+	case q.start > q.end && minute < q.end:
+		return endOn(0)
+	}
+	return time.Time{}
+}
+
+func (q *QuietHours) parse() error {
+	if !q.Enabled() {
+		return nil
+	}
+	var err error
+	if q.start, err = parseClock(q.Start); err != nil {
+		return fmt.Errorf("quiet_hours.start: %w", err)
+	}
+	if q.end, err = parseClock(q.End); err != nil {
+		return fmt.Errorf("quiet_hours.end: %w", err)
+	}
+	if q.start == q.end {
+		return fmt.Errorf("quiet_hours must not start and end at the same time")
+	}
+	if q.Timezone != "" {
+		if q.loc, err = time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("quiet_hours.timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minutes after midnight of an HH:MM time of day.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
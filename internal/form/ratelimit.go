@@ -0,0 +1,57 @@
+package form
+
+import "fmt"
+
+// RateLimit overrides the instance-wide rate limits for one form. Unset
+// fields keep the global value; 0 turns the limit off for the form.
+type RateLimit struct {
+	// IPRate overrides IP_RATE_LIMIT, in submissions per minute.
+	IPRate *int `json:"ip_rate,omitempty"`
+	// IPBurst overrides IP_RATE_BURST.
+	IPBurst *int `json:"ip_burst,omitempty"`
+	// EmailDailyLimit overrides EMAIL_DAILY_LIMIT.
+	EmailDailyLimit *int `json:"email_daily_limit,omitempty"`
+}
+
+// OverridesIP reports whether the form sets its own per-IP rate or burst.
+func (l RateLimit) OverridesIP() bool {
+	return l.IPRate != nil || l.IPBurst != nil
+}
+
+// OverridesEmail reports whether the form sets its own per-address cap.
+func (l RateLimit) OverridesEmail() bool {
+	return l.EmailDailyLimit != nil
+}
+
+// IP returns the form's per-IP rate and burst, falling back to rate and
+// burst where the form does not set them.
+func (l RateLimit) IP(rate, burst int) (int, int) {
+	if l.IPRate != nil {
+		rate = *l.IPRate
+	}
+	if l.IPBurst != nil {
+		burst = *l.IPBurst
+	}
+	return rate, burst
+}
+
+// Email returns the form's daily cap per address, falling back to limit.
+func (l RateLimit) Email(limit int) int {
+	if l.EmailDailyLimit != nil {
+		return *l.EmailDailyLimit
+	}
+	return limit
+}
+
+func (l RateLimit) validate() error {
+	fields := []struct {
+		name  string
+		value *int
+	}{{"ip_rate", l.IPRate}, {"ip_burst", l.IPBurst}, {"email_daily_limit", l.EmailDailyLimit}}
+	for _, f := range fields {
+		if f.value != nil && *f.value < 0 {
+			return fmt.Errorf("rate_limit.%s must not be negative", f.name)
+		}
+	}
+	return nil
+}
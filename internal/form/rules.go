@@ -0,0 +1,124 @@
+package form
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rules a field value can break, as reported in FieldError.Rule. They are
+// named like the Field settings declaring them.
+const (
+	RuleRequired      = "required"
+	RuleMaxLength     = "max_length"
+	RuleAllowedValues = "allowed_values"
+	RulePattern       = "pattern"
+)
+
+// ruleFields are the standard fields rules may be declared for besides the
+// extra ones.
+var ruleFields = []string{"name", "email", "subject", "message"}
+
+// FieldError is a field whose value breaks one of its rules.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// HasRules reports whether the field declares any rule.
+func (f Field) HasRules() bool {
+	return f.Required || f.MaxLength > 0 || f.Pattern != "" || len(f.AllowedValues) > 0
+}
+
+// IsStandard reports whether the field is one of the standard fields rules
+// may be declared for, rather than an extra one.
+func (f Field) IsStandard() bool {
+	return slices.Contains(ruleFields, f.Name)
+}
+
+func (f *Field) compile() error {
+	if f.MaxLength < 0 {
+		return fmt.Errorf("field %q: invalid max_length %d", f.Name, f.MaxLength)
+	}
+	if slices.Contains(f.AllowedValues, "") {
+		return fmt.Errorf("field %q: empty allowed value", f.Name)
+	}
+	if f.Pattern == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(f.Pattern); err != nil {
+		return fmt.Errorf("field %q: invalid pattern: %w", f.Name, err)
+	}
+	// The whole value has to match, not just a part of it
+	f.pattern = regexp.MustCompile(`^(?:` + f.Pattern + `)$`)
+	return nil
+}
+
+// Check applies the rules of the form's fields to values and returns the
+// fields breaking them, in the order the fields are configured. Each field
+// is reported once, for the first rule it breaks. Rules other than
+// required only apply to values that are not empty.
+func (d Definition) Check(values map[string]string) []FieldError {
+	msgs := d.Strings()
+	var failed []FieldError
+	for _, f := range d.Fields {
+		if !f.HasRules() {
+			continue
+		}
+		rule, message := f.check(values[f.Name], msgs)
+		if rule == "" {
+			continue
+		}
+		if f.Message != "" {
+			message = f.Message
+		}
+		failed = append(failed, FieldError{Field: f.Name, Rule: rule, Message: message})
+	}
+	return failed
+}
+
+// check returns the rule value breaks and the built-in message for it, or
+// an empty rule.
+func (f Field) check(value string, msgs Messages) (rule, message string) {
+	if strings.TrimSpace(value) == "" {
+		if f.Required {
+			return RuleRequired, msgs.FieldRequired
+		}
+		return "", ""
+	}
+	if f.MaxLength > 0 && utf8.RuneCountInString(value) > f.MaxLength {
+		return RuleMaxLength, msgs.FieldTooLong
+	}
+	if len(f.AllowedValues) > 0 && !f.allowed(value) {
+		return RuleAllowedValues, msgs.FieldNotAllowed
+	}
+	if f.Pattern != "" {
+		if f.pattern == nil {
+			// Definitions not read from a forms file are compiled here
+			if err := f.compile(); err != nil {
+				return RulePattern, msgs.FieldInvalid
+			}
+		}
+		if !f.pattern.MatchString(value) {
+			return RulePattern, msgs.FieldInvalid
+		}
+	}
+	return "", ""
+}
+
+// allowed reports whether value is one of the allowed values. Checkbox
+// groups arrive joined with commas and pass if every checked value does.
+func (f Field) allowed(value string) bool {
+	if slices.Contains(f.AllowedValues, value) {
+		return true
+	}
+	for part := range strings.SplitSeq(value, ", ") {
+		if !slices.Contains(f.AllowedValues, part) {
+			return false
+		}
+	}
+	return true
+}
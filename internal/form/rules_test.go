@@ -0,0 +1,81 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	forms, err := Parse([]byte(`{"forms": [{
+		"id": "order",
+		"fields": [
+			{"name": "name", "required": true, "max_length": 5},
+			{"name": "size", "allowed_values": ["S", "M", "L"]},
+			{"name": "toppings", "allowed_values": ["cheese", "ham"]},
+			{"name": "zip", "pattern": "[0-9]{5}", "message": "Five digits, please"},
+			{"name": "note", "label": "Note"}
+		]
+	}, {
+		"id": "bestellung",
+		"language": "de",
+		"fields": [{"name": "name", "required": true}]
+	}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ := forms.Get("order")
+	german, _ := forms.Get("bestellung")
+
+	tests := []struct {
+		name   string
+		def    Definition
+		values map[string]string
+		want   []FieldError
+	}{
+		{"all valid", order, map[string]string{"name": "Ada", "size": "M", "toppings": "cheese, ham", "zip": "10115"}, nil},
+		{"optional fields empty", order, map[string]string{"name": "Ada"}, nil},
+		{"required missing", order, map[string]string{}, []FieldError{{"name", RuleRequired, "This field is required"}}},
+		{"required blank", order, map[string]string{"name": "  "}, []FieldError{{"name", RuleRequired, "This field is required"}}},
+		{"too long counts runes", order, map[string]string{"name": "Ådäöü"}, nil},
+		{"too long", order, map[string]string{"name": "Adelaide"}, []FieldError{{"name", RuleMaxLength, "This entry is too long"}}},
+		{"not allowed", order, map[string]string{"name": "Ada", "size": "XL"}, []FieldError{{"size", RuleAllowedValues, "Please choose one of the offered options"}}},
+		{"checkbox group with one not allowed", order, map[string]string{"name": "Ada", "toppings": "cheese, pineapple"}, []FieldError{{"toppings", RuleAllowedValues, "Please choose one of the offered options"}}},
+		{"pattern matches the whole value", order, map[string]string{"name": "Ada", "zip": "101155"}, []FieldError{{"zip", RulePattern, "Five digits, please"}}},
+		{"fields in configured order", order, map[string]string{"size": "XL", "zip": "x"}, []FieldError{
+			{"name", RuleRequired, "This field is required"},
+			{"size", RuleAllowedValues, "Please choose one of the offered options"},
+			{"zip", RulePattern, "Five digits, please"},
+		}},
+		{"translated messages", german, map[string]string{}, []FieldError{{"name", RuleRequired, "Dieses Feld ist erforderlich"}}},
+		{"definition not from a file", Definition{Fields: []Field{{Name: "code", Pattern: "[A-Z]+"}}}, map[string]string{"code": "abc"}, []FieldError{{"code", RulePattern, "This entry is not in the expected format"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.def.Check(tt.values)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"negative max length", `[{"name": "note", "max_length": -1}]`, "invalid max_length"},
+		{"empty allowed value", `[{"name": "size", "allowed_values": ["S", ""]}]`, "empty allowed value"},
+		{"invalid pattern", `[{"name": "zip", "pattern": "[0-9"}]`, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(`{"forms": [{"id": "order", "fields": ` + tt.fields + `}]}`))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
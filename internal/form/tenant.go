@@ -0,0 +1,49 @@
+package form
+
+import "fmt"
+
+// Reactions to an exceeded quota, selectable per tenant with on_exceeded.
+const (
+	// QuotaReject turns further submissions away with 429.
+	QuotaReject = "reject"
+	// QuotaNotify accepts them and tells the tenant once per month.
+	QuotaNotify = "notify"
+)
+
+// Tenant groups the forms of one client of a hosted instance, whose usage
+// is counted together and may be capped per calendar month.
+type Tenant struct {
+	ID string `json:"id"`
+	// MonthlySubmissions and MonthlyEmails cap accepted submissions and sent
+	// emails (notifications and confirmations); 0 means unlimited.
+	MonthlySubmissions int `json:"monthly_submissions,omitempty"`
+	MonthlyEmails      int `json:"monthly_emails,omitempty"`
+	// OnExceeded is QuotaReject (the default) or QuotaNotify.
+	OnExceeded string `json:"on_exceeded,omitempty"`
+	// NotifyEmail is told when a quota is exceeded, RECIPIENT_EMAIL if empty.
+	NotifyEmail string `json:"notify_email,omitempty"`
+}
+
+// Exceeded reports whether usage of submissions and emails has reached a
+// quota.
+func (t Tenant) Exceeded(submissions, emails int) bool {
+	return (t.MonthlySubmissions > 0 && submissions >= t.MonthlySubmissions) ||
+		(t.MonthlyEmails > 0 && emails >= t.MonthlyEmails)
+}
+
+// Rejects reports whether submissions over quota are turned away.
+func (t Tenant) Rejects() bool {
+	return t.OnExceeded != QuotaNotify
+}
+
+func (t Tenant) validate() error {
+	switch t.OnExceeded {
+	case "", QuotaReject, QuotaNotify:
+	default:
+		return fmt.Errorf("unknown on_exceeded %q", t.OnExceeded)
+	}
+	if t.MonthlySubmissions < 0 || t.MonthlyEmails < 0 {
+		return fmt.Errorf("quotas must not be negative")
+	}
+	return nil
+}
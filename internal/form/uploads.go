@@ -0,0 +1,83 @@
+package form
+
+import "fmt"
+
+// Upload destinations selectable per field in a form's upload rules.
+const (
+	// UploadLink keeps files in UPLOAD_DIR and links to them from the
+	// notification. It is the default.
+	UploadLink = "link"
+	// UploadAttach attaches files to the notification.
+	UploadAttach = "attach"
+	// UploadS3 puts files into an S3-compatible bucket.
+	UploadS3 = "s3"
+	// UploadDrop discards files.
+	UploadDrop = "drop"
+)
+
+// UploadRule routes the files uploaded in one field.
+type UploadRule struct {
+	// Field is the name of the upload field, or "*" for fields without a
+	// rule of their own.
+	Field       string `json:"field"`
+	Destination string `json:"destination"`
+	// Bucket and Prefix locate files routed to S3; keys are
+	// <prefix><submission ID>/<file name>.
+	Bucket string `json:"bucket,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// RetentionDays overrides UPLOAD_RETENTION for linked files. S3 files
+	// are tagged with it for the bucket's lifecycle rules.
+	RetentionDays int `json:"retention_days,omitempty"`
+}
+
+// UploadRule returns the rule for files uploaded in field: the field's
+// own, the form's "*" rule, or else sending them to destination, the
+// default set with UPLOAD_DESTINATION.
+func (d Definition) UploadRule(field, destination string) UploadRule {
+	fallback := UploadRule{Field: field, Destination: destination}
+	for _, rule := range d.Uploads {
+		switch rule.Field {
+		case field:
+			return rule
+		case "*":
+			fallback = rule
+		}
+	}
+	return fallback
+}
+
+// UsesS3 reports whether any of the form's upload rules routes to S3.
+func (d Definition) UsesS3() bool {
+	for _, rule := range d.Uploads {
+		if rule.Destination == UploadS3 {
+			return true
+		}
+	}
+	return false
+}
+
+func validateUploads(rules []UploadRule) error {
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Field == "" {
+			return fmt.Errorf("upload rule #%d has no field", i+1)
+		}
+		if seen[rule.Field] {
+			return fmt.Errorf("duplicate upload rule for field %q", rule.Field)
+		}
+		seen[rule.Field] = true
+		switch rule.Destination {
+		case UploadLink, UploadAttach, UploadDrop:
+		case UploadS3:
+			if rule.Bucket == "" {
+				return fmt.Errorf("upload rule for field %q has no bucket", rule.Field)
+			}
+		default:
+			return fmt.Errorf("upload rule for field %q: unknown destination %q", rule.Field, rule.Destination)
+		}
+		if rule.RetentionDays < 0 {
+			return fmt.Errorf("upload rule for field %q: retention_days must not be negative", rule.Field)
+		}
+	}
+	return nil
+}
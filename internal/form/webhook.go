@@ -0,0 +1,81 @@
+package form
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// Webhook forwards a form's submissions as JSON to another service, such as
+// Zapier, n8n, or a CRM.
+type Webhook struct {
+	URL string `json:"url"`
+	// Headers are added to the request, e.g. an API key.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Template is a text/template producing the JSON body. Without it the
+	// submission is posted as stored.
+	Template string `json:"template,omitempty"`
+	// Secret, if set, signs each request with HMAC-SHA256.
+	Secret string `json:"secret,omitempty"`
+	// Timeout overrides WEBHOOK_TIMEOUT for this webhook, e.g. "30s".
+	Timeout string `json:"timeout,omitempty"`
+
+	tmpl    *template.Template
+	timeout time.Duration
+}
+
+// webhookFuncs are available in payload templates. json encodes a value,
+// so strings come out quoted and escaped: {"name": {{json .Name}}}.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (w *Webhook) compile() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %q", w.URL)
+	}
+	if w.Timeout != "" {
+		if w.timeout, err = time.ParseDuration(w.Timeout); err != nil || w.timeout <= 0 {
+			return fmt.Errorf("invalid webhook timeout %q", w.Timeout)
+		}
+	}
+	if w.Template == "" {
+		return nil
+	}
+	if w.tmpl, err = template.New("payload").Option("missingkey=zero").Funcs(webhookFuncs).Parse(w.Template); err != nil {
+		return fmt.Errorf("invalid webhook template: %w", err)
+	}
+	return nil
+}
+
+// RequestTimeout returns how long a request to the webhook may take: its
+// own timeout, or def if it has none.
+func (w Webhook) RequestTimeout(def time.Duration) time.Duration {
+	if w.timeout > 0 {
+		return w.timeout
+	}
+	return def
+}
+
+// Render returns the JSON body for data: the template's output, which must
+// be valid JSON, or data itself encoded as JSON.
+func (w Webhook) Render(data any) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(data)
+	}
+	var b bytes.Buffer
+	if err := w.tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not produce valid JSON")
+	}
+	return b.Bytes(), nil
+}
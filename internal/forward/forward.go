@@ -0,0 +1,178 @@
+// Package forward posts accepted submissions to outbound webhooks: those
+// of their form and those every submission goes to.
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// maxRetryDelay caps the time between two attempts at a webhook.
+const maxRetryDelay = time.Minute
+
+// Forwarder posts submissions to webhooks.
+type Forwarder struct {
+	client  *http.Client
+	timeout time.Duration
+	dryRun  bool
+	// webhooks receive the submissions of every form
+	webhooks []form.Webhook
+	attempts int
+	delay    time.Duration
+	clock    clock.Clock
+}
+
+// New returns a Forwarder whose requests give up after timeout, unless a
+// webhook sets its own. In dry-run mode payloads are logged instead of
+// posted.
+func New(timeout time.Duration, dryRun bool) *Forwarder {
+	return &Forwarder{
+		client:   &http.Client{},
+		timeout:  timeout,
+		dryRun:   dryRun,
+		attempts: 1,
+		clock:    clock.System,
+	}
+}
+
+// UseClock makes the Forwarder sign requests with the time of c and wait
+// for retries on it.
+func (f *Forwarder) UseClock(c clock.Clock) {
+	f.clock = c
+}
+
+// UseTransport makes the Forwarder post to webhooks through rt instead of
+// http.DefaultTransport.
+func (f *Forwarder) UseTransport(rt http.RoundTripper) {
+	f.client.Transport = rt
+}
+
+// UseWebhooks makes every form's submissions go to hooks too, in addition
+// to the form's own webhooks.
+func (f *Forwarder) UseWebhooks(hooks []form.Webhook) {
+	f.webhooks = hooks
+}
+
+// UseRetries makes up to attempts attempts at a webhook while it is
+// unreachable or answers 429 or 5xx, waiting delay before the first retry
+// and twice as long before each further one.
+func (f *Forwarder) UseRetries(attempts int, delay time.Duration) {
+	f.attempts = max(attempts, 1)
+	f.delay = delay
+}
+
+// Webhooks returns the webhooks a submission to def goes to: those of
+// every form, then the form's own.
+func (f *Forwarder) Webhooks(def form.Definition) []form.Webhook {
+	return append(f.webhooks[:len(f.webhooks):len(f.webhooks)], def.Webhooks...)
+}
+
+// Send posts sub to hook, rendered with the webhook's template. The
+// template sees the submission's fields by their Go names, e.g. .Name,
+// .Fields.phone, or .Source.utm_source. It logs to the logger ctx
+// carries.
+func (f *Forwarder) Send(ctx context.Context, hook form.Webhook, sub storage.Submission) error {
+	// The delivery state is only known later and would mislead receivers
+	sub.Status = ""
+	payload, err := hook.Render(sub)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.FromContext(ctx).With("submission", sub.ID, "webhook", hook.URL)
+	if f.dryRun {
+		logger.Info("[dry run] Would post submission to webhook", "payload", string(payload))
+		return nil
+	}
+
+	delay := f.delay
+	for attempt := 1; ; attempt++ {
+		err := f.post(ctx, hook, sub.ID, payload)
+		if err == nil || attempt >= f.attempts || !retryable(err) {
+			return err
+		}
+		logger.Warn("Webhook failed, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-f.clock.After(delay):
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// statusError is a webhook's answer other than 2xx.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.code)
+}
+
+// retryable reports whether a webhook that failed with err may accept the
+// submission if tried again: it could not be reached or was busy or down.
+// Other answers mean the request itself was refused.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	return true
+}
+
+func (f *Forwarder) post(ctx context.Context, hook form.Webhook, id string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, hook.RequestTimeout(f.timeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "form2mail")
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+	// Unchanged between retries, so receivers can drop repeats
+	req.Header.Set("X-Form2mail-Submission", id)
+	if hook.Secret != "" {
+		sign(req, hook.Secret, payload, f.clock.Now())
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// sign adds the HMAC-SHA256 of the timestamp, a dot, and the payload under
+// secret. The timestamp is signed along, so receivers can reject requests
+// replayed later.
+func sign(req *http.Request, secret string, payload []byte, at time.Time) {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	req.Header.Set("X-Form2mail-Timestamp", timestamp)
+	req.Header.Set("X-Form2mail-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,278 @@
+package forward
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/form"
+	"form2mail/internal/storage"
+)
+
+// receiver is a webhook answering with the statuses in turn, the last one
+// once they run out, and recording the requests.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	rc := &receiver{statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, r)
+		rc.bodies = append(rc.bodies, string(body))
+		status := rc.statuses[0]
+		if len(rc.statuses) > 1 {
+			rc.statuses = rc.statuses[1:]
+		}
+		rc.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+// webhook returns hook as compiled from a forms file.
+func webhook(t *testing.T, hook string) form.Webhook {
+	t.Helper()
+	forms, err := form.Parse([]byte(`{"forms": [{"id": "f", "webhooks": [` + hook + `]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, _ := forms.Get("f")
+	return def.Webhooks[0]
+}
+
+var submission = storage.Submission{ID: "a1b2", Name: "Ada", Email: "ada@example.com", Status: storage.StatusDelivered}
+
+func TestSendSigns(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	f := New(time.Second, false)
+	f.UseClock(clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)))
+	hook := webhook(t, `{"url": "`+rc.URL+`", "secret": "s3cret", "headers": {"X-Api-Key": "k"},
+		"template": "{\"name\": {{json .Name}}}"}`)
+
+	if err := f.Send(context.Background(), hook, submission); err != nil {
+		t.Fatal(err)
+	}
+	if rc.count() != 1 {
+		t.Fatalf("%d requests, want 1", rc.count())
+	}
+	req, body := rc.requests[0], rc.bodies[0]
+	if body != `{"name": "Ada"}` {
+		t.Errorf("body %s", body)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1740830400." + body))
+	headers := map[string]string{
+		"Content-Type":           "application/json",
+		"User-Agent":             "form2mail",
+		"X-Api-Key":              "k",
+		"X-Form2mail-Submission": "a1b2",
+		"X-Form2mail-Timestamp":  "1740830400",
+		"X-Form2mail-Signature":  "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+	}
+	for name, want := range headers {
+		if got := req.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSendWithoutSecret(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	f := New(time.Second, false)
+	if err := f.Send(context.Background(), webhook(t, `{"url": "`+rc.URL+`"}`), submission); err != nil {
+		t.Fatal(err)
+	}
+	req := rc.requests[0]
+	if req.Header.Get("X-Form2mail-Signature") != "" || req.Header.Get("X-Form2mail-Timestamp") != "" {
+		t.Error("unsigned webhook got a signature")
+	}
+	// Without a template the submission is posted as is, minus its
+	// delivery state
+	var posted map[string]any
+	if err := json.Unmarshal([]byte(rc.bodies[0]), &posted); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := posted["status"]; ok || posted["name"] != "Ada" || posted["email"] != "ada@example.com" {
+		t.Errorf("body %s", rc.bodies[0])
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		ok       bool
+	}{
+		{"accepted", []int{http.StatusNoContent}, 1, true},
+		{"unavailable", []int{http.StatusServiceUnavailable}, 3, false},
+		{"busy, then accepted", []int{http.StatusTooManyRequests, http.StatusOK}, 2, true},
+		{"refused", []int{http.StatusBadRequest}, 1, false},
+		{"not found", []int{http.StatusNotFound}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newReceiver(t, tt.statuses...)
+			f := New(time.Second, false)
+			f.UseRetries(3, time.Millisecond)
+			err := f.Send(context.Background(), webhook(t, `{"url": "`+rc.URL+`"}`), submission)
+			if (err == nil) != tt.ok {
+				t.Errorf("Send = %v, want ok %v", err, tt.ok)
+			}
+			if rc.count() != tt.requests {
+				t.Errorf("%d requests, want %d", rc.count(), tt.requests)
+			}
+		})
+	}
+}
+
+func TestSendWaitsForRetriesOnClock(t *testing.T) {
+	rc := newReceiver(t, http.StatusBadGateway, http.StatusOK)
+	now := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	f := New(time.Second, false)
+	f.UseClock(now)
+	f.UseRetries(2, time.Hour)
+
+	done := make(chan error, 1)
+	go func() { done <- f.Send(context.Background(), webhook(t, `{"url": "`+rc.URL+`"}`), submission) }()
+	for deadline := time.Now().Add(5 * time.Second); now.Timers() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no retry was scheduled")
+		}
+	}
+	if rc.count() != 1 {
+		t.Fatalf("%d requests before the retry delay passed, want 1", rc.count())
+	}
+	now.Advance(time.Hour)
+	select {
+	case err := <-done:
+		if err != nil || rc.count() != 2 {
+			t.Errorf("Send = %v after %d requests, want success after 2", err, rc.count())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry did not run once the clock moved on")
+	}
+}
+
+func TestSendCanceledDuringRetryWait(t *testing.T) {
+	rc := newReceiver(t, http.StatusServiceUnavailable)
+	f := New(time.Second, false)
+	f.UseRetries(3, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := f.Send(ctx, webhook(t, `{"url": "`+rc.URL+`"}`), submission); err == nil {
+		t.Fatal("Send succeeded")
+	}
+	if rc.count() != 1 {
+		t.Errorf("%d requests, want 1", rc.count())
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		hook    string
+	}{
+		{"WEBHOOK_TIMEOUT", 50 * time.Millisecond, `{"url": "` + slow.URL + `"}`},
+		{"per webhook", time.Minute, `{"url": "` + slow.URL + `", "timeout": "50ms"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(tt.timeout, false)
+			start := time.Now()
+			err := f.Send(context.Background(), webhook(t, tt.hook), submission)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Send = %v, want a timeout", err)
+			}
+			if took := time.Since(start); took > 2*time.Second {
+				t.Errorf("Send took %s", took)
+			}
+		})
+	}
+}
+
+func TestSendDryRun(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	f := New(time.Second, true)
+	if err := f.Send(context.Background(), webhook(t, `{"url": "`+rc.URL+`", "secret": "s"}`), submission); err != nil {
+		t.Fatal(err)
+	}
+	if rc.count() != 0 {
+		t.Errorf("dry run posted %d requests", rc.count())
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&statusError{http.StatusTooManyRequests}, true},
+		{&statusError{http.StatusInternalServerError}, true},
+		{&statusError{http.StatusServiceUnavailable}, true},
+		{&statusError{http.StatusBadRequest}, false},
+		{&statusError{http.StatusUnauthorized}, false},
+		{&statusError{http.StatusMultipleChoices}, false},
+		{errors.New("failed to post to webhook: connection refused"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	f := New(time.Second, false)
+	global := []form.Webhook{{URL: "https://all.example"}}
+	f.UseWebhooks(global)
+	def := form.Definition{Webhooks: []form.Webhook{{URL: "https://own.example"}}}
+	hooks := f.Webhooks(def)
+	if len(hooks) != 2 || hooks[0].URL != "https://all.example" || hooks[1].URL != "https://own.example" {
+		t.Errorf("Webhooks = %v", hooks)
+	}
+	// Appending must not write into the shared list
+	f.Webhooks(form.Definition{Webhooks: []form.Webhook{{URL: "https://other.example"}}})
+	if hooks[1].URL != "https://own.example" || len(global) != 1 {
+		t.Errorf("Webhooks changed an earlier result: %v", hooks)
+	}
+	if got := f.Webhooks(form.Definition{}); len(got) != 1 {
+		t.Errorf("form without webhooks gets %v", got)
+	}
+}
@@ -0,0 +1,334 @@
+// Package golden compares messages with .eml golden files by their
+// structure instead of byte for byte: headers are decoded and compared
+// regardless of order and folding, and parts by their decoded contents,
+// so boundaries, transfer encodings, and line wrapping may change without
+// failing a test. Golden files are written in a canonical form that reads
+// well in a diff, which makes changes to templates and the message
+// builder reviewable:
+//
+//	messages, err := h.SMTP.Wait(2, 5*time.Second)
+//	golden.Check(t, "testdata/notification.eml", messages[0].Data, "Date", "Message-Id")
+//
+// Run the tests with UPDATE_GOLDEN=1 to write the golden files from the
+// messages instead.
+package golden
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// field is a header field with its value decoded and unfolded.
+type field struct {
+	Name, Value string
+}
+
+// part is a message or one of its MIME parts.
+type part struct {
+	Header []field
+	// MediaType and Params are those of the Content-Type, without the
+	// boundary.
+	MediaType string
+	Params    map[string]string
+	// Body is the decoded content of a single part.
+	Body  []byte
+	Parts []*part
+}
+
+// parse reads the structure of the raw message data, which may have CRLF
+// or LF line endings.
+func parse(data []byte) (*part, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return parsePart(textproto.MIMEHeader(msg.Header), msg.Body)
+}
+
+func parsePart(header textproto.MIMEHeader, body io.Reader) (*part, error) {
+	p := &part{MediaType: "text/plain", Params: map[string]string{}}
+	if mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+		p.MediaType, p.Params = mediaType, params
+	}
+	boundary := p.Params["boundary"]
+	delete(p.Params, "boundary")
+
+	dec := new(mime.WordDecoder)
+	for name, values := range header {
+		for _, value := range values {
+			if decoded, err := dec.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+			p.Header = append(p.Header, field{Name: name, Value: strings.Join(strings.Fields(value), " ")})
+		}
+	}
+	// Header maps lose the order of names, which does not matter here
+	slices.SortStableFunc(p.Header, func(a, b field) int { return strings.Compare(a.Name, b.Name) })
+
+	if strings.HasPrefix(p.MediaType, "multipart/") {
+		parts := multipart.NewReader(body, boundary)
+		for {
+			raw, err := parts.NextRawPart()
+			if err == io.EOF {
+				return p, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s part: %w", p.MediaType, err)
+			}
+			child, err := parsePart(raw.Header, raw)
+			if err != nil {
+				return nil, err
+			}
+			p.Parts = append(p.Parts, child)
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s body: %w", p.MediaType, err)
+	}
+	if p.text() {
+		data = bytes.TrimRight(data, "\n")
+	}
+	p.Body = data
+	return p, nil
+}
+
+func (p *part) text() bool {
+	return strings.HasPrefix(p.MediaType, "text/")
+}
+
+// Canonical returns the message data in the form golden files are written
+// in: header fields decoded, unfolded, and sorted by name; boundaries
+// numbered; text parts decoded and sent as 8bit; other parts in base64
+// wrapped at 76 columns; LF line endings.
+func Canonical(data []byte) ([]byte, error) {
+	p, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	n := 0
+	p.write(&b, &n)
+	return b.Bytes(), nil
+}
+
+func (p *part) write(b *bytes.Buffer, n *int) {
+	params := p.Params
+	boundary := ""
+	if len(p.Parts) > 0 || strings.HasPrefix(p.MediaType, "multipart/") {
+		*n++
+		boundary = fmt.Sprintf("golden-%d", *n)
+		params = make(map[string]string, len(p.Params)+1)
+		for k, v := range p.Params {
+			params[k] = v
+		}
+		params["boundary"] = boundary
+	}
+	encoded := false
+	for _, f := range p.Header {
+		switch f.Name {
+		case "Content-Type":
+			f.Value = mime.FormatMediaType(p.MediaType, params)
+		case "Content-Transfer-Encoding":
+			if boundary != "" {
+				continue
+			}
+			f.Value = "base64"
+			if p.text() {
+				f.Value = "8bit"
+			}
+			encoded = true
+		}
+		fmt.Fprintf(b, "%s: %s\n", f.Name, f.Value)
+	}
+	if !encoded && boundary == "" && !p.text() {
+		b.WriteString("Content-Transfer-Encoding: base64\n")
+	}
+	b.WriteString("\n")
+
+	switch {
+	case boundary != "":
+		for _, child := range p.Parts {
+			fmt.Fprintf(b, "--%s\n", boundary)
+			child.write(b, n)
+		}
+		fmt.Fprintf(b, "--%s--\n", boundary)
+	case p.text():
+		b.Write(p.Body)
+		b.WriteString("\n")
+	default:
+		encoded := base64.StdEncoding.EncodeToString(p.Body)
+		for len(encoded) > 76 {
+			b.WriteString(encoded[:76] + "\n")
+			encoded = encoded[76:]
+		}
+		b.WriteString(encoded + "\n")
+	}
+}
+
+// Compare reports how the message got differs from want, one difference
+// per line, or nil if they match. Header fields named in ignore, such as
+// "Date", only need to be present in both.
+func Compare(got, want []byte, ignore ...string) ([]string, error) {
+	g, err := parse(got)
+	if err != nil {
+		return nil, fmt.Errorf("got: %w", err)
+	}
+	w, err := parse(want)
+	if err != nil {
+		return nil, fmt.Errorf("want: %w", err)
+	}
+	skip := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		skip[textproto.CanonicalMIMEHeaderKey(name)] = true
+	}
+	var diffs []string
+	compare(g, w, "message", skip, &diffs)
+	return diffs, nil
+}
+
+func compare(got, want *part, path string, ignore map[string]bool, diffs *[]string) {
+	report := func(format string, args ...any) {
+		*diffs = append(*diffs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	gotFields, wantFields := fields(got), fields(want)
+	for _, name := range names(gotFields, wantFields) {
+		g, w := gotFields[name], wantFields[name]
+		switch {
+		case len(w) == 0:
+			report("unexpected header %s: %q", name, g)
+		case len(g) == 0:
+			report("missing header %s: %q", name, w)
+		case ignore[name]:
+		case !slices.Equal(g, w):
+			report("header %s is %q, want %q", name, g, w)
+		}
+	}
+	if got.MediaType != want.MediaType {
+		report("media type is %s, want %s", got.MediaType, want.MediaType)
+		return
+	}
+
+	if len(got.Parts) > 0 || len(want.Parts) > 0 {
+		for i := range max(len(got.Parts), len(want.Parts)) {
+			child := fmt.Sprintf("%s part %d", path, i+1)
+			switch {
+			case i >= len(want.Parts):
+				*diffs = append(*diffs, fmt.Sprintf("%s: unexpected %s part", child, got.Parts[i].MediaType))
+			case i >= len(got.Parts):
+				*diffs = append(*diffs, fmt.Sprintf("%s: missing %s part", child, want.Parts[i].MediaType))
+			default:
+				compare(got.Parts[i], want.Parts[i], child, ignore, diffs)
+			}
+		}
+		return
+	}
+
+	if bytes.Equal(got.Body, want.Body) {
+		return
+	}
+	if !got.text() {
+		report("%s body differs (%d bytes, want %d)", got.MediaType, len(got.Body), len(want.Body))
+		return
+	}
+	gotLines := strings.Split(string(got.Body), "\n")
+	wantLines := strings.Split(string(want.Body), "\n")
+	for i := range max(len(gotLines), len(wantLines)) {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			report("%s body differs at line %d:\n\tgot:  %s\n\twant: %s", got.MediaType, i+1, g, w)
+			return
+		}
+	}
+}
+
+// fields maps the header field names of p to their values, leaving out
+// the Content-Type and Content-Transfer-Encoding, which are compared
+// through the structure and contents. Content-Type parameters other than
+// the boundary still count.
+func fields(p *part) map[string][]string {
+	m := make(map[string][]string)
+	for _, f := range p.Header {
+		switch f.Name {
+		case "Content-Transfer-Encoding":
+			continue
+		case "Content-Type":
+			f.Value = mime.FormatMediaType(p.MediaType, p.Params)
+		}
+		m[f.Name] = append(m[f.Name], f.Value)
+	}
+	return m
+}
+
+func names(a, b map[string][]string) []string {
+	var names []string
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Check compares the message data with the golden file at path and fails
+// t for each difference. With UPDATE_GOLDEN set, it writes the canonical
+// form of data to path instead, creating its directory.
+func Check(t testing.TB, path string, data []byte, ignore ...string) {
+	t.Helper()
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		canonical, err := Canonical(data)
+		if err != nil {
+			t.Fatalf("golden %s: %v", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("golden %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, canonical, 0o644); err != nil {
+			t.Fatalf("golden %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden %s: %v (run with UPDATE_GOLDEN=1 to create it)", path, err)
+	}
+	diffs, err := Compare(data, want, ignore...)
+	if err != nil {
+		t.Fatalf("golden %s: %v", path, err)
+	}
+	for _, diff := range diffs {
+		t.Errorf("golden %s: %s", path, diff)
+	}
+}
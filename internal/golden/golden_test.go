@@ -0,0 +1,123 @@
+package golden
+
+import (
+	"strings"
+	"testing"
+)
+
+const want = "From: form2mail@example.com\r\n" +
+	"To: owner@example.com\r\n" +
+	"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n" +
+	"Date: Sat, 01 Mar 2025 12:00:00 +0000\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"mixed-1\"\r\n" +
+	"\r\n" +
+	"--mixed-1\r\n" +
+	"Content-Type: text/html; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"<p>Gr=C3=BC=C3=9Fe</p>\r\n" +
+	"<p>Ada</p>\r\n" +
+	"--mixed-1\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQ=\r\n" +
+	"--mixed-1--\r\n"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		got    string
+		ignore []string
+		diffs  []string
+	}{
+		{"same", want, nil, nil},
+		{"other boundary and encodings", "To: owner@example.com\n" +
+			"From: form2mail@example.com\n" +
+			"Subject: Grüße\n" +
+			"Date: Sat, 01 Mar 2025 12:00:00 +0000\n" +
+			"MIME-Version: 1.0\n" +
+			"Content-Type: multipart/mixed;\n boundary=other\n" +
+			"\n" +
+			"--other\n" +
+			"Content-Type: text/html; charset=UTF-8\n" +
+			"Content-Transfer-Encoding: 8bit\n" +
+			"\n" +
+			"<p>Grüße</p>\n<p>Ada</p>\n" +
+			"--other\n" +
+			"Content-Type: application/pdf\n" +
+			"Content-Transfer-Encoding: base64\n" +
+			"\n" +
+			"JVBE\nRi0xLjQ=\n" +
+			"--other--\n", nil, nil},
+		{"changed header", strings.Replace(want, "owner@", "admin@", 1), nil, []string{
+			`message: header To is ["admin@example.com"], want ["owner@example.com"]`,
+		}},
+		{"ignored header", strings.Replace(want, "12:00:00", "13:00:00", 1), []string{"date"}, nil},
+		{"missing header", strings.Replace(want, "Date: Sat, 01 Mar 2025 12:00:00 +0000\r\n", "", 1), []string{"Date"}, []string{
+			`message: missing header Date: ["Sat, 01 Mar 2025 12:00:00 +0000"]`,
+		}},
+		{"extra header", strings.Replace(want, "MIME-Version", "X-Spam: true\r\nMIME-Version", 1), nil, []string{
+			`message: unexpected header X-Spam: ["true"]`,
+		}},
+		{"changed line", strings.Replace(want, "<p>Ada</p>", "<p>Eve</p>", 1), nil, []string{
+			"message part 1: text/html body differs at line 2:\n\tgot:  <p>Eve</p>\n\twant: <p>Ada</p>",
+		}},
+		{"changed attachment", strings.Replace(want, "JVBERi0xLjQ=", "JVBERi0xLjU=", 1), nil, []string{
+			"message part 2: application/pdf body differs (8 bytes, want 8)",
+		}},
+		{"missing part", strings.Replace(want, "--mixed-1\r\nContent-Type: application/pdf\r\nContent-Transfer-Encoding: base64\r\n\r\nJVBERi0xLjQ=\r\n", "", 1), nil, []string{
+			"message part 2: missing application/pdf part",
+		}},
+		{"other media type", strings.Replace(want, "application/pdf", "image/png", 1), nil, []string{
+			`message part 2: header Content-Type is ["image/png"], want ["application/pdf"]`,
+			"message part 2: media type is image/png, want application/pdf",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Compare([]byte(tt.got), []byte(want), tt.ignore...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(diffs, "\n") != strings.Join(tt.diffs, "\n") {
+				t.Errorf("Compare =\n%s\nwant\n%s", strings.Join(diffs, "\n"), strings.Join(tt.diffs, "\n"))
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	canonical, err := Canonical([]byte(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"Subject: Grüße\n",
+		"Content-Type: multipart/mixed; boundary=golden-1\n",
+		"Content-Transfer-Encoding: 8bit\nContent-Type: text/html; charset=UTF-8\n\n<p>Grüße</p>\n<p>Ada</p>\n",
+	} {
+		if !strings.Contains(string(canonical), line) {
+			t.Errorf("canonical form lacks %q:\n%s", line, canonical)
+		}
+	}
+	again, err := Canonical(canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(canonical) {
+		t.Errorf("canonical form changes when canonicalized again:\n%s", again)
+	}
+	if diffs, _ := Compare([]byte(want), canonical); len(diffs) > 0 {
+		t.Errorf("message differs from its canonical form: %v", diffs)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	path := t.TempDir() + "/sub/message.eml"
+	t.Setenv("UPDATE_GOLDEN", "1")
+	Check(t, path, []byte(want))
+	t.Setenv("UPDATE_GOLDEN", "")
+	Check(t, path, []byte(want))
+}
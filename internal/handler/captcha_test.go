@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/captcha"
+	"form2mail/internal/e2e"
+	"form2mail/internal/handler"
+)
+
+// widget accepts the solution "solved" from its field g-recaptcha-response.
+type widget struct{}
+
+func (widget) Field() string { return "g-recaptcha-response" }
+
+func (widget) Verify(ctx context.Context, solution, remoteIP string) error {
+	if solution != "solved" {
+		return captcha.ErrRejected
+	}
+	return nil
+}
+
+func TestContactCaptchaFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		field  string
+		value  string
+		status int
+	}{
+		{"captcha", "captcha", "solved", http.StatusOK},
+		{"widget field", "g-recaptcha-response", "solved", http.StatusOK},
+		{"token", "token", "solved", http.StatusOK},
+		{"wrong solution", "token", "guessed", http.StatusForbidden},
+		{"other field", "solution", "solved", http.StatusForbidden},
+	}
+	encodings := []struct {
+		name string
+		post func(h *e2e.Harness, field, value string) (*http.Response, error)
+	}{
+		{"form", func(h *e2e.Harness, field, value string) (*http.Response, error) {
+			return h.Post("/contact", url.Values{
+				"name": {"Ada"}, "email": {"ada@example.com"}, "message": {"Hi"}, field: {value},
+			})
+		}},
+		{"JSON", func(h *e2e.Harness, field, value string) (*http.Response, error) {
+			body, err := json.Marshal(map[string]string{
+				"name": "Ada", "email": "ada@example.com", "message": "Hi", field: value,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return h.HTTP.Client().Post(h.HTTP.URL+"/contact", "application/json", strings.NewReader(string(body)))
+		}},
+	}
+	for _, enc := range encodings {
+		for _, tt := range tests {
+			t.Run(enc.name+"/"+tt.name, func(t *testing.T) {
+				h := newHarness(t, nil, nil, handler.Options{Captcha: widget{}})
+				resp, err := enc.post(h, tt.field, tt.value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.status {
+					t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+				}
+				if tt.status != http.StatusOK {
+					return
+				}
+				messages, err := h.SMTP.Wait(2, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				notification := byRecipient(messages)[e2e.RecipientEmail]
+				text, err := notification.Text()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(text, "solved") {
+					t.Errorf("notification forwards the captcha solution:\n%s", text)
+				}
+			})
+		}
+	}
+}
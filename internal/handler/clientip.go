@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the IP address of the client that sent r. proxies is
+// the number of trusted reverse proxies in front of the server, as from
+// Config.TrustedProxies, or 0 to use the connection's address. Each proxy
+// appends the address it was connected from to X-Forwarded-For, so the
+// client is the entry that many from the right; entries further left were
+// sent by the client and could be anything. Without X-Forwarded-For, the
+// X-Real-IP a proxy sets is used.
+func ClientIP(r *http.Request, proxies int) string {
+	if proxies > 0 {
+		if ip := forwardedFor(r.Header.Values("X-Forwarded-For"), proxies); ip != "" {
+			return ip
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns the X-Forwarded-For entry proxies from the right,
+// or the left-most one if there are fewer. Repeated headers count as one
+// list. It returns "" if the entry is not an IP address.
+func forwardedFor(headers []string, proxies int) string {
+	var hops []string
+	for _, h := range headers {
+		for hop := range strings.SplitSeq(h, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		return ""
+	}
+	ip := hops[max(len(hops)-proxies, 0)]
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+	return ip
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxies   int
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"untrusted ignores headers", 0, []string{"203.0.113.7"}, "203.0.113.8", "192.0.2.1"},
+		{"single proxy", 1, []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"spoofed entry ignored", 1, []string{"198.51.100.66, 203.0.113.7"}, "", "203.0.113.7"},
+		{"two proxies", 2, []string{"198.51.100.66, 203.0.113.7, 10.0.0.2"}, "", "203.0.113.7"},
+		{"repeated headers", 2, []string{"198.51.100.66", "203.0.113.7, 10.0.0.2"}, "", "203.0.113.7"},
+		{"fewer entries than proxies", 3, []string{"203.0.113.7, 10.0.0.2"}, "", "203.0.113.7"},
+		{"IPv6", 1, []string{"2001:db8::1"}, "", "2001:db8::1"},
+		{"not an address", 1, []string{"unknown"}, "", "192.0.2.1"},
+		{"real IP without forwarded", 1, nil, "203.0.113.8", "203.0.113.8"},
+		{"invalid real IP", 1, nil, "<script>", "192.0.2.1"},
+		{"no headers", 1, nil, "", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/contact", nil)
+			r.RemoteAddr = "192.0.2.1:4711"
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r, tt.proxies); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,38 +1,191 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"form2mail/internal/address"
+	"form2mail/internal/captcha"
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/duplicate"
 	"form2mail/internal/email"
+	"form2mail/internal/enrich"
+	"form2mail/internal/form"
+	"form2mail/internal/forward"
+	"form2mail/internal/logging"
+	"form2mail/internal/metrics"
+	"form2mail/internal/notify"
+	"form2mail/internal/quiet"
+	"form2mail/internal/ratelimit"
+	"form2mail/internal/receipt"
+	"form2mail/internal/scan"
+	"form2mail/internal/shortlink"
+	"form2mail/internal/spam"
+	"form2mail/internal/storage"
+	"form2mail/internal/summary"
+	"form2mail/internal/upload"
+	"form2mail/internal/usage"
 )
 
 type ContactForm struct {
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Subject string `json:"subject"`
-	Message string `json:"message"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Subject     string `json:"subject"`
+	Message     string `json:"message"`
+	PageURL     string `json:"page_url"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMTerm     string `json:"utm_term"`
+	UTMContent  string `json:"utm_content"`
+	Captcha     string `json:"captcha"`
 }
 
 type ContactHandler struct {
 	emailSender *email.Sender
-	corsOrigin  string
+	config      config.Config
+	forms       *form.Registry
+	duplicates  *duplicate.Detector
+	responses   *duplicate.Responses
+	scanner     scan.Scanner
+	limits      limits
+	formLimits  atomic.Pointer[map[string]limits]
+	templates   atomic.Pointer[map[string]*email.Templates]
+	enricher    *enrich.Enricher
+	mx          *address.Checker
+	store       storage.Store
+	captcha     captcha.Verifier
+	summary     *summary.Tracker
+	quiet       *quiet.Queue
+	usage       *usage.Tracker
+	uploads     *upload.Store
+	s3          *upload.S3
+	links       *shortlink.Store
+	receipts    *receipt.Signer
+	forwarder   *forward.Forwarder
+	channels    []notify.Notifier
+	dispatcher  *notify.Dispatcher
+	blocklist   *spam.Blocklist
+	keywords    *spam.Keywords
+	metrics     *metrics.Metrics
+	clock       clock.Clock
+	ids         *clock.IDs
+	inflight    atomic.Int64
+	tarpitted   atomic.Int64
+	draining    atomic.Bool
 }
 
-func NewContactHandler(emailSender *email.Sender, corsOrigin string) *ContactHandler {
-	return &ContactHandler{
+// Options holds the optional collaborators of ContactHandler. A nil field
+// disables the corresponding feature.
+type Options struct {
+	Duplicates *duplicate.Detector
+	Responses  *duplicate.Responses
+	EmailCap   *ratelimit.DailyCap
+	IPRate     *ratelimit.Rate
+	Enricher   *enrich.Enricher
+	MX         *address.Checker
+	Store      storage.Store
+	Captcha    captcha.Verifier
+	Scanner    scan.Scanner
+	Summary    *summary.Tracker
+	Quiet      *quiet.Queue
+	Usage      *usage.Tracker
+	Uploads    *upload.Store
+	S3         *upload.S3
+	Links      *shortlink.Store
+	Receipts   *receipt.Signer
+	Forwarder  *forward.Forwarder
+	// Channels tell the owner about every submission besides email, e.g.
+	// in Slack or Telegram.
+	Channels  []notify.Notifier
+	Blocklist *spam.Blocklist
+	Keywords  *spam.Keywords
+	// Metrics defaults to an unexposed set of collectors.
+	Metrics *metrics.Metrics
+	// Clock and IDs default to the system clock and crypto/rand; tests set
+	// them to get the same submissions on every run. The handler's own
+	// per-form limiters follow Clock; those passed in here take theirs
+	// through UseClock.
+	Clock clock.Clock
+	IDs   *clock.IDs
+}
+
+func NewContactHandler(emailSender *email.Sender, cfg config.Config, forms *form.Registry, opts Options) *ContactHandler {
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.New()
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	global := limits{ipRate: opts.IPRate, emailCap: opts.EmailCap}
+	h := &ContactHandler{
 		emailSender: emailSender,
-		corsOrigin:  corsOrigin,
+		config:      cfg,
+		forms:       forms,
+		duplicates:  opts.Duplicates,
+		responses:   opts.Responses,
+		limits:      global,
+		enricher:    opts.Enricher,
+		mx:          opts.MX,
+		store:       opts.Store,
+		captcha:     opts.Captcha,
+		scanner:     opts.Scanner,
+		summary:     opts.Summary,
+		quiet:       opts.Quiet,
+		usage:       opts.Usage,
+		uploads:     opts.Uploads,
+		s3:          opts.S3,
+		links:       opts.Links,
+		receipts:    opts.Receipts,
+		forwarder:   opts.Forwarder,
+		channels:    opts.Channels,
+		dispatcher:  notify.NewDispatcher(notify.Policy(cfg.NotifyPolicy)),
+		blocklist:   opts.Blocklist,
+		keywords:    opts.Keywords,
+		metrics:     opts.Metrics,
+		clock:       opts.Clock,
+		ids:         opts.IDs,
 	}
+	perForm := formLimits(cfg, opts.Clock, forms, global, nil)
+	h.formLimits.Store(&perForm)
+	// CheckForms loaded the templates before, so they only fail if their
+	// files changed since
+	templates, err := loadFormTemplates(forms)
+	if err != nil {
+		slog.Error("Failed to load form templates", "error", err)
+	}
+	h.templates.Store(&templates)
+	return h
 }
 
 func (h *ContactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Look up the form when served from /forms/{formID}
+	def, ok := h.resolveForm(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrFormNotFound, "Form not found")
+		return
+	}
+	logger := logging.FromContext(r.Context())
+	if def.ID != "" {
+		logger = logger.With("form", def.ID)
+	}
+
 	// Set CORS headers first (before any method checks)
-	w.Header().Set("Access-Control-Allow-Origin", h.corsOrigin)
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	h.setCORSHeaders(w, r, def.CORS)
+
+	msgs := def.Strings()
+	w.Header().Set("Content-Language", def.Lang())
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -42,56 +195,849 @@ func (h *ContactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow POST requests for actual form submission
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Refuse new submissions during maintenance
+	if h.draining.Load() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.config.MaintenanceRetryAfter.Seconds())))
+		writeError(w, http.StatusServiceUnavailable, ErrMaintenance, msgs.Maintenance)
 		return
 	}
 
+	// Blocklisted clients get the success response so they learn nothing
+	if h.blocklist != nil && h.blocklist.Contains(ClientIP(r, h.config.TrustedProxies())) {
+		logger.Info("Dropping submission from blocklisted client")
+		h.tarpit(w, r, msgs, "blocklist")
+		return
+	}
+
+	// Throttle clients submitting faster than the form allows. In challenge
+	// mode they may go on once they solve a captcha.
+	limiters := h.limitsFor(def.ID)
+	var rateLimited bool
+	var retryAfter time.Duration
+	if limiters.ipRate != nil {
+		ip := ClientIP(r, h.config.TrustedProxies())
+		if ok, wait := limiters.ipRate.Allow(ip); !ok {
+			logger.Warn("Rate limit reached")
+			if !h.challenges() {
+				writeRateLimited(w, wait, msgs)
+				return
+			}
+			rateLimited, retryAfter = true, wait
+		}
+	}
+
 	// Parse form data
-	var form ContactForm
+	var contact ContactForm
+	var extra map[string]string
 	contentType := r.Header.Get("Content-Type")
 
 	if strings.Contains(contentType, "application/json") {
 		// Parse JSON
-		if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
-			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		var object map[string]json.RawMessage
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBody))
+		if err == nil {
+			err = h.jsonLimits().check(body)
+		}
+		if err == nil {
+			err = json.Unmarshal(body, &object)
+		}
+		if err == nil {
+			err = json.Unmarshal(body, &contact)
+		}
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge), errors.Is(err, errJSONValueTooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, ErrTooLarge, msgs.TooLarge)
+			return
+		case errors.Is(err, errJSONTooDeep):
+			writeError(w, http.StatusBadRequest, ErrJSONTooDeep, msgs.TooDeep)
+			return
+		case errors.Is(err, errJSONTooManyFields):
+			writeError(w, http.StatusBadRequest, ErrTooManyFields, msgs.TooManyFields)
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, ErrInvalidJSON, msgs.InvalidJSON)
 			return
 		}
+		contact.Captcha = h.captchaSolution(func(name string) string {
+			return jsonString(object, name)
+		})
+		extra = h.jsonExtraFields(object)
 	} else {
 		// Parse form data
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		if h.uploads != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, h.config.UploadMaxSize)
+		}
+		err := r.ParseForm()
+		if err == nil && strings.HasPrefix(contentType, "multipart/form-data") {
+			err = r.ParseMultipartForm(maxUploadMemory)
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrTooLarge, msgs.TooLarge)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrInvalidForm, msgs.InvalidForm)
 			return
 		}
-		form.Name = r.FormValue("name")
-		form.Email = r.FormValue("email")
-		form.Subject = r.FormValue("subject")
-		form.Message = r.FormValue("message")
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+		switch err := h.checkUploads(r.Context(), def, r.MultipartForm); {
+		case errors.Is(err, errFileTooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, ErrTooLarge, msgs.TooLarge)
+			return
+		case errors.Is(err, errFileType):
+			writeError(w, http.StatusUnsupportedMediaType, ErrFileType, msgs.FileType)
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, ErrInvalidForm, msgs.InvalidForm)
+			return
+		}
+		contact.Name = r.FormValue("name")
+		contact.Email = r.FormValue("email")
+		contact.Subject = r.FormValue("subject")
+		contact.Message = r.FormValue("message")
+		contact.PageURL = r.FormValue("page_url")
+		contact.UTMSource = r.FormValue("utm_source")
+		contact.UTMMedium = r.FormValue("utm_medium")
+		contact.UTMCampaign = r.FormValue("utm_campaign")
+		contact.UTMTerm = r.FormValue("utm_term")
+		contact.UTMContent = r.FormValue("utm_content")
+		contact.Captcha = h.captchaSolution(r.FormValue)
+		extra = h.formExtraFields(r.PostForm)
+	}
+
+	// The honeypot is hidden from people; whoever fills it in is a bot and
+	// gets the success response while the submission is dropped
+	if name := h.config.HoneypotField; name != "" {
+		if value := strings.TrimSpace(extra[name]); value != "" {
+			logger.Info("Dropping submission with honeypot filled in", "field", name)
+			h.metrics.Honeypot.Inc()
+			h.record(def.ID, summary.Spam)
+			h.tarpit(w, r, msgs, "honeypot")
+			return
+		}
+		delete(extra, name)
 	}
 
 	// Validate required fields
-	if form.Name == "" || form.Email == "" || form.Message == "" {
-		http.Error(w, "Name, email, and message are required", http.StatusBadRequest)
+	if contact.Name == "" || contact.Email == "" || contact.Message == "" {
+		writeError(w, http.StatusBadRequest, ErrRequiredFields, msgs.RequiredFields)
+		return
+	}
+	// An address that is not one could never get the confirmation or a reply
+	if !address.Valid(contact.Email) {
+		writeError(w, http.StatusBadRequest, ErrInvalidEmail, msgs.InvalidEmail)
 		return
 	}
+	logger = logger.With("email", contact.Email)
+
+	// Answer clients retrying a request, e.g. after a timeout on a flaky
+	// connection, with the original response instead of sending it again
+	var entry *duplicate.Entry
+	if h.responses != nil {
+		if keys := h.replayKeys(r, def, contact); len(keys) > 0 {
+			if entry = h.claim(w, r, msgs, requestFingerprint(def, contact, extra), keys); entry == nil {
+				return
+			}
+			defer h.responses.Abandon(entry)
+		}
+	}
 
-	// Send email to recipient (site owner)
-	if err := h.emailSender.SendContactNotification(form.Name, form.Email, form.Subject, form.Message); err != nil {
-		log.Printf("Failed to send email to recipient: %v", err)
-		http.Error(w, "Failed to send email", http.StatusInternalServerError)
+	// Decoy fields are never rendered by real frontends, so only bots fill them
+	var score spam.Score
+	traps := append(append([]string(nil), h.config.SpamTrapFields...), def.SpamTraps...)
+	trapped := spam.Trapped(extra, traps)
+	for _, name := range trapped {
+		score.Add(h.config.SpamTrapScore, "trap field "+name)
+		delete(extra, name)
+	}
+
+	// With a tarpit, such bots are not scored but kept waiting
+	if len(trapped) > 0 && h.config.TarpitDelay > 0 {
+		logger.Info("Tarpitting submission", "trap_fields", trapped)
+		h.metrics.Spam.Inc()
+		h.record(def.ID, summary.Spam)
+		h.tarpit(w, r, msgs, "trap")
 		return
 	}
 
-	// Send confirmation email to customer
-	if err := h.emailSender.SendConfirmation(form.Name, form.Email, form.Message); err != nil {
-		log.Printf("Failed to send confirmation email to customer: %v", err)
-		// Don't fail the request if confirmation email fails
+	// Store and forward dates and numbers the same way whatever the locale
+	def.Normalize(extra)
+
+	// Apply the form's field rules, patterns seeing the normalized values
+	if failed := def.Check(ruleValues(contact, extra)); len(failed) > 0 {
+		names := make([]string, len(failed))
+		for i, f := range failed {
+			names[i] = f.Field + " (" + f.Rule + ")"
+		}
+		logger.Info("Rejected submission breaking field rules", "fields", names)
+		writeInvalid(w, msgs.Validation, failed)
+		return
+	}
+
+	// Score keywords typical of spam in the language the message is
+	// written in, which may have its own threshold
+	spamThreshold := h.config.SpamThreshold
+	if h.keywords != nil {
+		if threshold := h.keywords.Check(&score, def.Lang(), contentTexts(contact, extra)...); threshold > 0 {
+			spamThreshold = threshold
+		}
+	}
+
+	// In challenge mode, only suspicious clients have to solve a captcha
+	suspicious := rateLimited || score.Points >= h.config.CaptchaChallengeScore
+	if h.challenges() && suspicious && contact.Captcha == "" {
+		logger.Info("Challenging suspicious submission with a captcha")
+		writeChallenge(w, msgs.CaptchaRequired, challenge{
+			Provider: h.config.CaptchaProvider,
+			SiteKey:  h.config.CaptchaSiteKey,
+			Field:    h.captcha.Field(),
+		})
+		return
+	}
+
+	// Check the captcha solution; if the provider is unreachable, let the
+	// submission through rather than lock everyone out
+	if h.captcha != nil && (!h.challenges() || contact.Captcha != "") {
+		if err := h.captcha.Verify(r.Context(), contact.Captcha, ClientIP(r, h.config.TrustedProxies())); err != nil {
+			if errors.Is(err, captcha.ErrRejected) {
+				logger.Warn("Rejected submission with failed captcha", "error", err)
+				writeError(w, http.StatusForbidden, ErrCaptchaFailed, msgs.Captcha)
+				return
+			}
+			// A client that went away cannot be verified, and nobody waits for the answer
+			if r.Context().Err() != nil {
+				logger.Info("Client disconnected during captcha verification")
+				return
+			}
+			// An unverified captcha cannot lift the rate limit
+			if rateLimited {
+				logger.Warn("Captcha verification unavailable, keeping rate limit", "error", err)
+				writeRateLimited(w, retryAfter, msgs)
+				return
+			}
+			logger.Warn("Captcha verification unavailable, accepting submission", "error", err)
+		}
+	}
+
+	// Internal forms only accept addresses from allowlisted domains
+	allowedDomains := h.config.AllowedEmailDomains
+	if len(def.AllowedEmailDomains) > 0 {
+		allowedDomains = def.AllowedEmailDomains
+	}
+	if !emailDomainAllowed(contact.Email, allowedDomains) {
+		logger.Warn("Rejected submission from non-allowlisted address")
+		writeError(w, http.StatusForbidden, ErrDomainNotAllowed, msgs.DomainNotAllowed)
+		return
+	}
+
+	// Turn away made-up domains; if DNS does not answer in time, let the
+	// submission through rather than lose it
+	if h.mx != nil {
+		accepts, err := h.mx.Accepts(r.Context(), contact.Email)
+		switch {
+		case err != nil:
+			logger.Warn("MX lookup failed, accepting submission", "error", err)
+		case !accepts:
+			logger.Info("Rejected submission from address whose domain takes no mail")
+			writeError(w, http.StatusBadRequest, ErrUndeliverable, msgs.Undeliverable)
+			return
+		}
+	}
+
+	isSpam := score.Points >= spamThreshold && score.Points > 0
+	if isSpam {
+		h.metrics.Spam.Inc()
+		logger.Info("Spam submission", "score", score.Points, "reasons", score.Reasons)
+		// Pretend success so bots learn nothing
+		if h.config.SpamAction == config.SpamDrop {
+			h.record(def.ID, summary.Spam)
+			writeSuccess(w, msgs, storage.NewID(h.ids))
+			return
+		}
+	}
+
+	// Look for known-malicious links before they reach the owner's inbox;
+	// if the scanner is unavailable, let the submission through
+	var threats []scan.Threat
+	if h.scanner != nil {
+		found, err := h.scanner.Scan(r.Context(), scan.URLs(contentTexts(contact, extra)...))
+		switch {
+		case err != nil && r.Context().Err() != nil:
+			logger.Info("Client disconnected during link scan")
+			return
+		case err != nil:
+			logger.Warn("Link scan unavailable, accepting submission", "error", err)
+		case len(found) > 0 && h.config.ScanAction == config.ScanReject:
+			logger.Warn("Rejected submission with malicious links", "links", len(found))
+			writeError(w, http.StatusUnprocessableEntity, ErrMalicious, msgs.Malicious)
+			return
+		case len(found) > 0:
+			logger.Info("Flagging submission with malicious links", "links", len(found))
+			threats = found
+			contact.Name = scan.Defang(contact.Name, found)
+			contact.Subject = scan.Defang(contact.Subject, found)
+			contact.Message = scan.Defang(contact.Message, found)
+			for name, value := range extra {
+				extra[name] = scan.Defang(value, found)
+			}
+		}
+	}
+
+	// Hold tenants of a hosted instance to their monthly quotas
+	if tenant, over := h.overQuota(def); over {
+		if tenant.Rejects() {
+			logger.Warn("Tenant is over its monthly quota, rejecting submission", "tenant", tenant.ID)
+			w.Header().Set("Retry-After", strconv.Itoa(int(h.untilNextMonth().Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, ErrQuotaExceeded, msgs.QuotaExceeded)
+			return
+		}
+		h.notifyQuota(tenant)
+	}
+
+	sub := email.Submission{
+		ID:            storage.NewID(h.ids),
+		FormID:        def.ID,
+		Name:          contact.Name,
+		Email:         contact.Email,
+		Subject:       contact.Subject,
+		Message:       contact.Message,
+		ClientIP:      ClientIP(r, h.config.TrustedProxies()),
+		FromName:      def.FromName,
+		FromEmail:     def.FromEmail,
+		Recipient:     def.Recipient,
+		SubjectPrefix: def.SubjectPrefix,
+		Templates:     h.formTemplates(def.ID),
+		TraceID:       traceID(r),
+		ReceivedAt:    h.clock.Now(),
+		Source:        contact.source(r),
+		SpamScore:     score.Points,
+		Spam:          isSpam,
+		SpamReasons:   score.Reasons,
+		Fields:        emailFields(def.Arrange(extra)),
+		Headers:       def.Headers,
+		Threats:       threats,
+		Preheader:     def.Preheader,
+	}
+	sub.Confirmation = email.Confirmation(def.ConfirmationText(contact.Name, extra[def.SalutationField()], sub.ReceivedAt.In(h.config.Location)))
+	if sub.Confirmation.Image == "" {
+		sub.Confirmation.Image = h.config.ConfirmationImage
+	}
+	logger = logger.With("submission_id", sub.ID)
+	sub.Logger = logger
+
+	// Turn submissions away while too many are still waiting to be delivered
+	if !h.enqueue() {
+		logger.Warn("Queue saturated, rejecting submission")
+		h.metrics.BackpressureRejections.Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.config.QueueRetryAfter.Seconds())))
+		writeError(w, http.StatusServiceUnavailable, ErrQueueFull, msgs.Busy)
+		return
+	}
+	// A submission delivered in the background leaves the queue once it is
+	// sent rather than when the response is
+	background := false
+	defer func() {
+		if !background {
+			h.dequeue()
+		}
+	}()
+
+	// Catch identical submissions, e.g. from users pressing submit repeatedly
+	var duplicateKeys []string
+	if h.duplicates != nil {
+		fingerprint := duplicate.Fingerprint(def.ID, contact.Email, contact.Name, contact.Subject, contact.Message)
+		duplicateKeys = []string{fingerprint + "|ip:" + sub.ClientIP, fingerprint + "|email:" + strings.ToLower(contact.Email)}
+		if !h.duplicates.Claim(duplicateKeys...) {
+			if h.config.DuplicateAction == config.DuplicateReject {
+				logger.Info("Rejected duplicate submission")
+				writeError(w, http.StatusConflict, ErrDuplicate, msgs.Duplicate)
+				return
+			}
+			sub.Duplicate = true
+			duplicateKeys = nil
+		}
+	}
+
+	// Limit how many messages a single address can send per day
+	if limiters.emailCap != nil && !limiters.emailCap.Allow(strings.ToLower(contact.Email)) {
+		logger.Warn("Daily submission cap reached")
+		if duplicateKeys != nil {
+			h.duplicates.Release(duplicateKeys...)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(limiters.emailCap.UntilReset().Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, ErrRateLimited, msgs.DailyLimit)
+		return
+	}
+
+	// Look up context about the submitter for the notification
+	if h.enricher != nil {
+		info := h.enricher.Lookup(r.Context(), contact.Email)
+		sub.Reputation = &info
+	}
+
+	// Show whether this is a repeat customer or a serial spammer
+	if h.store != nil {
+		history, err := h.history(r.Context(), sub)
+		if err != nil {
+			h.metrics.StorageErrors.WithLabelValues("history").Inc()
+			logger.Error("Failed to look up submission history", "error", err)
+		} else {
+			sub.History = history
+		}
+	}
+
+	// Route uploads by the form's rules; by default they are linked, or
+	// attached with UPLOAD_DESTINATION=attach
+	if r.MultipartForm != nil {
+		sub.Attachments, sub.Files = h.saveUploads(r.Context(), def, sub.ID, r.MultipartForm)
+	}
+
+	// From here on the submission is accepted: lookups above were canceled
+	// if the client went away, but storing and delivering it must complete
+	// regardless. Delivery does not take a context for the same reason.
+	deliveryCtx := context.WithoutCancel(r.Context())
+
+	until := time.Time{}
+	if h.quiet != nil {
+		until = def.QuietHours.Until(h.clock.Now(), h.config.Location)
+	}
+
+	// Sign the submission so the owner can prove it was not altered
+	record := storedSubmission(sub, extra, r)
+	record.Status = storage.StatusPending
+	if !until.IsZero() {
+		record.Status = storage.StatusHeld
+	}
+	if h.receipts != nil {
+		receipt := h.receipts.Sign(record)
+		record.Receipt = &receipt
+		sub.Signature = receipt.Signature
 	}
 
-	// Send success response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Your message has been sent successfully",
+	// Keep a copy of the submission
+	stored := false
+	if h.store != nil {
+		if err := h.store.Save(deliveryCtx, record); err != nil {
+			h.metrics.StorageErrors.WithLabelValues("save").Inc()
+			if h.config.StorageFailure == config.StorageFailureReject {
+				logger.Error("Failed to store submission, rejecting it", "error", err)
+				h.record(def.ID, summary.Failed)
+				if duplicateKeys != nil {
+					h.duplicates.Release(duplicateKeys...)
+				}
+				writeError(w, http.StatusInternalServerError, ErrStorageFailed, msgs.StorageFailed)
+				return
+			}
+			// Losing the record is better than losing the lead
+			logger.Error("Failed to store submission, delivering it anyway", "error", err)
+		} else {
+			stored = true
+		}
+	}
+
+	// Send email to recipient (site owner), or hold it until the form's
+	// quiet hours end, and tell them on the other channels at the same time
+	mail := notify.Func("email", func(context.Context, storage.Submission) error {
+		if until.IsZero() {
+			return h.notify(def, sub, stored)
+		}
+		logger.Info("Quiet hours, holding notification", "until", until.Format(time.RFC3339))
+		// A held record in the database is held again after a restart
+		durable := stored && h.config.DatabaseURL != ""
+		h.quiet.Hold(until, durable, func() {
+			if err := h.notify(def, sub, stored); err != nil {
+				logger.Error("Failed to send held email to recipient", "error", err)
+			}
+		})
+		return nil
 	})
+	channels := append([]notify.Notifier{mail}, h.notifiers(def, sub)...)
+	async := h.config.ResponseMode == config.ResponseAsync
+	var partial *notify.PartialError
+	switch {
+	case async && until.IsZero():
+		// The client polls the status endpoint rather than waiting for SMTP
+		background = true
+		go func() {
+			defer h.dequeue()
+			var partial *notify.PartialError
+			if err := h.dispatcher.Dispatch(deliveryCtx, logger, record, channels...); errors.As(err, &partial) {
+				logger.Error("Failed to notify recipient on some channels", "error", partial.Err)
+			} else if err != nil {
+				logger.Error("Failed to notify recipient", "error", err)
+				if duplicateKeys != nil {
+					h.duplicates.Release(duplicateKeys...)
+				}
+				return
+			}
+			h.afterNotify(def, sub)
+		}()
+	default:
+		// Once the email is out, retrying would only send it again
+		if err := h.dispatcher.Dispatch(deliveryCtx, logger, record, channels...); errors.As(err, &partial) {
+			logger.Error("Failed to notify recipient on some channels", "error", partial.Err)
+		} else if err != nil {
+			logger.Error("Failed to notify recipient", "error", err)
+			if duplicateKeys != nil {
+				h.duplicates.Release(duplicateKeys...)
+			}
+			var tooLarge *email.TooLargeError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, ErrTooLarge, msgs.TooLarge)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, ErrSendFailed, msgs.SendFailed)
+			return
+		}
+	}
+	if !background {
+		h.afterNotify(def, sub)
+	}
+
+	// Send success response, and remember it for retries
+	resp := successResponse(msgs, sub.ID)
+	if async {
+		location := ""
+		if stored {
+			location = strings.TrimSuffix(h.config.PublicURL, "/") + "/v1/submissions/" + sub.ID + "/status"
+		}
+		resp = queuedResponse(msgs, sub.ID, location)
+	} else if partial != nil {
+		resp = partialResponse(msgs, sub.ID)
+	}
+	if entry != nil {
+		h.responses.Finish(entry, resp)
+	}
+	writeResponse(w, resp)
+}
+
+// notifiers returns the channels besides email that the owner is told
+// about sub through: those of every submission, then the webhooks. Spam is
+// only sent by email, where it is flagged.
+func (h *ContactHandler) notifiers(def form.Definition, sub email.Submission) []notify.Notifier {
+	if sub.Spam {
+		return nil
+	}
+	channels := slices.Clone(h.channels)
+	if h.forwarder != nil {
+		for _, hook := range h.forwarder.Webhooks(def) {
+			channels = append(channels, notify.Webhook(h.forwarder, hook))
+		}
+	}
+	return channels
+}
+
+// afterNotify counts an accepted submission and sends the confirmation.
+func (h *ContactHandler) afterNotify(def form.Definition, sub email.Submission) {
+	h.countSubmission(def)
+	logger := submissionLogger(sub)
+
+	// Send confirmation email to customer, unless the address likely came from a bot
+	if sub.Spam {
+		logger.Info("Skipping confirmation email for spam submission")
+	} else if len(sub.Threats) > 0 {
+		// Echoing the message would send the malicious links from our domain
+		logger.Info("Skipping confirmation email for submission with malicious links")
+	} else if err := h.emailSender.SendConfirmation(sub); err != nil {
+		var deferred *email.DeferredError
+		if errors.As(err, &deferred) {
+			go func() {
+				if <-deferred.Done == nil {
+					h.countEmail(def)
+				}
+			}()
+		} else {
+			logger.Error("Failed to send confirmation email to customer", "error", err)
+			// Don't fail the request if confirmation email fails
+		}
+	} else {
+		h.countEmail(def)
+	}
+}
+
+// notify sends the notification to the site owner and records the outcome
+// for the daily summary, the tenant's usage, and, if the submission was
+// stored, in the store.
+func (h *ContactHandler) notify(def form.Definition, sub email.Submission, stored bool) error {
+	err := h.emailSender.SendContactNotification(sub)
+
+	// A greylisted notification is retried in the background and recorded
+	// once the retries are over
+	var deferred *email.DeferredError
+	if errors.As(err, &deferred) {
+		if stored {
+			h.setStatus(sub, storage.StatusDeferred)
+		}
+		go func() {
+			if err := h.delivered(def, sub, stored, <-deferred.Done); err != nil {
+				submissionLogger(sub).Error("Failed to send greylisted email to recipient", "error", err)
+			}
+		}()
+		return nil
+	}
+	return h.delivered(def, sub, stored, err)
+}
+
+// delivered records the outcome err of the notification for sub and
+// returns it.
+func (h *ContactHandler) delivered(def form.Definition, sub email.Submission, stored bool, err error) error {
+	switch {
+	case err != nil:
+		h.record(def.ID, summary.Failed)
+	case sub.Spam:
+		h.record(def.ID, summary.Spam)
+	default:
+		h.record(def.ID, summary.Delivered)
+	}
+	if err == nil {
+		h.countEmail(def)
+	}
+	switch {
+	case !stored:
+	case err != nil:
+		h.recordFailure(sub, err)
+	default:
+		h.setStatus(sub, storage.StatusDelivered)
+	}
+	return err
+}
+
+// recordFailure marks the stored submission as failed with err, including
+// the SMTP transcript if one was recorded.
+func (h *ContactHandler) recordFailure(sub email.Submission, err error) {
+	failure := storage.Failure{Error: err.Error(), FailedAt: h.clock.Now()}
+	var transcript *email.TranscriptError
+	if errors.As(err, &transcript) {
+		failure.Transcript = transcript.Transcript
+	}
+	if err := h.store.RecordFailure(context.Background(), sub.ID, failure); err != nil {
+		h.metrics.StorageErrors.WithLabelValues("status").Inc()
+		submissionLogger(sub).Error("Failed to record delivery failure", "error", err)
+	}
+}
+
+// setStatus updates the delivery state of the stored submission.
+func (h *ContactHandler) setStatus(sub email.Submission, status storage.Status) {
+	if err := h.store.UpdateStatus(context.Background(), sub.ID, status); err != nil {
+		h.metrics.StorageErrors.WithLabelValues("status").Inc()
+		submissionLogger(sub).Error("Failed to update submission status", "error", err)
+	}
+}
+
+// submissionLogger returns the logger of the request sub came with, which
+// carries its ID, or the default logger with the ID for submissions sent
+// again later.
+func submissionLogger(sub email.Submission) *slog.Logger {
+	if sub.Logger != nil {
+		return sub.Logger
+	}
+	return slog.Default().With("submission_id", sub.ID)
+}
+
+// record counts an outcome for the daily summary, if enabled.
+func (h *ContactHandler) record(formID string, outcome summary.Outcome) {
+	if h.summary != nil {
+		h.summary.Record(formID, outcome)
+	}
+}
+
+// tarpit answers a bot with a fake success, after TARPIT_DELAY if set, so
+// it spends its time waiting rather than probing further. Beyond
+// TARPIT_MAX_CONNECTIONS held requests, bots are answered right away so
+// they cannot exhaust the server instead.
+func (h *ContactHandler) tarpit(w http.ResponseWriter, r *http.Request, msgs form.Messages, reason string) {
+	if h.config.TarpitDelay > 0 {
+		held := h.tarpitted.Add(1)
+		defer h.tarpitted.Add(-1)
+		if h.config.TarpitMaxConnections <= 0 || held <= int64(h.config.TarpitMaxConnections) {
+			h.metrics.Tarpitted.WithLabelValues(reason).Inc()
+			timer := time.NewTimer(h.config.TarpitDelay)
+			defer timer.Stop()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-timer.C:
+			}
+		}
+	}
+	writeSuccess(w, msgs, storage.NewID(h.ids))
+}
+
+func writeSuccess(w http.ResponseWriter, msgs form.Messages, id string) {
+	writeResponse(w, successResponse(msgs, id))
+}
+
+// Drain stops accepting submissions and waits until every accepted one has
+// been delivered or ctx is done. Notifications held for quiet hours are
+// delivered first unless they are held again after a restart (see
+// RearmHeld), since they would be lost otherwise.
+func (h *ContactHandler) Drain(ctx context.Context) error {
+	h.draining.Store(true)
+	if h.quiet != nil {
+		if n := h.quiet.Flush(); n > 0 {
+			logging.FromContext(ctx).Info("Delivered held notifications early for the drain", "count", n)
+		}
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for h.QueueDepth() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Resume accepts submissions again after Drain.
+func (h *ContactHandler) Resume() {
+	h.draining.Store(false)
+}
+
+// Draining reports whether submissions are currently refused.
+func (h *ContactHandler) Draining() bool {
+	return h.draining.Load()
+}
+
+// QueueDepth returns the number of submissions awaiting delivery, and of
+// greylisted messages awaiting a retry.
+func (h *ContactHandler) QueueDepth() int {
+	return int(h.inflight.Load()) + h.emailSender.Deferred()
+}
+
+// enqueue accounts for a submission awaiting delivery and reports whether
+// the queue had room for it.
+func (h *ContactHandler) enqueue() bool {
+	depth := h.inflight.Add(1)
+	if h.config.QueueHighWater > 0 && depth > int64(h.config.QueueHighWater) {
+		h.inflight.Add(-1)
+		return false
+	}
+	h.metrics.QueueDepth.Set(float64(depth))
+	return true
+}
+
+func (h *ContactHandler) dequeue() {
+	h.metrics.QueueDepth.Set(float64(h.inflight.Add(-1)))
+}
+
+// maxUploadMemory is how much of a multipart body is kept in memory; larger
+// uploads are buffered in temporary files.
+const maxUploadMemory = 1 << 20
+
+// historyRecentLimit is the number of earlier submissions listed by name.
+const historyRecentLimit = 5
+
+// history summarizes earlier stored submissions from the same email address
+// and IP across all forms.
+func (h *ContactHandler) history(ctx context.Context, sub email.Submission) (*email.History, error) {
+	byEmail, err := h.store.List(ctx, storage.Filter{AnyForm: true, Email: sub.Email})
+	if err != nil {
+		return nil, err
+	}
+	var byIP []storage.Submission
+	if sub.ClientIP != "" {
+		byIP, err = h.store.List(ctx, storage.Filter{AnyForm: true, ClientIP: sub.ClientIP})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	history := &email.History{EmailCount: len(byEmail), IPCount: len(byIP)}
+	if len(byEmail) > 0 {
+		history.EmailLast = byEmail[0].ReceivedAt
+	}
+	if len(byIP) > 0 {
+		history.IPLast = byIP[0].ReceivedAt
+	}
+
+	// Merge both lists, newest first, without listing a submission twice
+	seen := make(map[string]bool)
+	recent := append(append([]storage.Submission(nil), byEmail...), byIP...)
+	sort.Slice(recent, func(i, j int) bool { return recent[i].ReceivedAt.After(recent[j].ReceivedAt) })
+	for _, s := range recent {
+		if seen[s.ID] || len(history.Recent) == historyRecentLimit {
+			continue
+		}
+		seen[s.ID] = true
+		history.Recent = append(history.Recent, email.HistoryEntry{ReceivedAt: s.ReceivedAt, FormID: s.FormID, Subject: s.Subject})
+	}
+	return history, nil
+}
+
+// source collects the page and campaign context of the submission. Values
+// missing from the body are taken from the query string of the action URL,
+// and the page falls back to the Referer header.
+func (c ContactForm) source(r *http.Request) email.Source {
+	query := r.URL.Query()
+	pick := func(value, key string) string {
+		if value != "" {
+			return value
+		}
+		return query.Get(key)
+	}
+
+	src := email.Source{
+		PageURL:     pick(c.PageURL, "page_url"),
+		UTMSource:   pick(c.UTMSource, "utm_source"),
+		UTMMedium:   pick(c.UTMMedium, "utm_medium"),
+		UTMCampaign: pick(c.UTMCampaign, "utm_campaign"),
+		UTMTerm:     pick(c.UTMTerm, "utm_term"),
+		UTMContent:  pick(c.UTMContent, "utm_content"),
+	}
+	if src.PageURL == "" {
+		src.PageURL = r.Referer()
+	}
+	return src
+}
+
+// resolveForm returns the form addressed by the request path. Requests to the
+// plain /contact endpoint use an empty default definition.
+func (h *ContactHandler) resolveForm(r *http.Request) (form.Definition, bool) {
+	formID := r.PathValue("formID")
+	if formID == "" {
+		return form.Definition{}, true
+	}
+	if h.forms == nil {
+		return form.Definition{}, false
+	}
+	return h.forms.Get(formID)
+}
+
+// storedSubmission converts sub into its storage representation.
+func storedSubmission(sub email.Submission, extra map[string]string, r *http.Request) storage.Submission {
+	return storage.Submission{
+		ID:         sub.ID,
+		FormID:     sub.FormID,
+		Name:       sub.Name,
+		Email:      sub.Email,
+		Subject:    sub.Subject,
+		Message:    sub.Message,
+		ClientIP:   sub.ClientIP,
+		UserAgent:  r.UserAgent(),
+		Source:     sub.Source.Map(),
+		Fields:     extra,
+		ReceivedAt: sub.ReceivedAt,
+	}
+}
+
+// challenges reports whether captchas are only required from suspicious
+// clients (CAPTCHA_MODE=challenge).
+func (h *ContactHandler) challenges() bool {
+	return h.captcha != nil && h.config.CaptchaMode == config.CaptchaChallenge
+}
+
+func writeRateLimited(w http.ResponseWriter, wait time.Duration, msgs form.Messages) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	writeError(w, http.StatusTooManyRequests, ErrRateLimited, msgs.RateLimit)
 }
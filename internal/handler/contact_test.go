@@ -0,0 +1,172 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/duplicate"
+	"form2mail/internal/e2e"
+	"form2mail/internal/form"
+	"form2mail/internal/golden"
+	"form2mail/internal/handler"
+	"form2mail/internal/smtptest"
+)
+
+func newHarness(t *testing.T, configure func(*config.Config), forms *form.Registry, opts handler.Options) *e2e.Harness {
+	t.Helper()
+	if opts.Responses == nil {
+		opts.Responses = duplicate.NewResponses(time.Minute)
+	}
+	if opts.Duplicates == nil {
+		opts.Duplicates = duplicate.New(time.Minute)
+	}
+	h, err := e2e.New(configure, forms, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+// byRecipient returns the messages keyed by their To header.
+func byRecipient(messages []smtptest.Message) map[string]smtptest.Message {
+	m := make(map[string]smtptest.Message, len(messages))
+	for _, msg := range messages {
+		m[msg.Header("To")] = msg
+	}
+	return m
+}
+
+func TestContactDelivers(t *testing.T) {
+	forms, err := form.Parse([]byte(`{"forms": [
+		{"id": "support", "language": "de", "subject_prefix": "[Support]"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		path   string
+		values url.Values
+	}{
+		{"contact", "/contact", url.Values{
+			"name": {"Ada Lovelace"}, "email": {"ada@example.com"}, "subject": {"Engines"}, "message": {"Hello,\nI need a quote."},
+		}},
+		{"contact_extra_fields", "/contact", url.Values{
+			"name": {"Ada Lovelace"}, "email": {"ada@example.com"}, "message": {"Hello"}, "company": {"Analytical & Co"}, "phone": {"+44 20 7946 0000"},
+		}},
+		{"support_form", "/forms/support", url.Values{
+			"name": {"Ada Lovelace"}, "email": {"ada@example.com"}, "subject": {"Größe"}, "message": {"Grüße"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, func(c *config.Config) {
+				c.Location = time.UTC
+				c.FromName = "Example Contact"
+			}, forms, handler.Options{
+				Clock: clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)),
+				IDs:   clock.Seeded(1),
+			})
+			resp, err := h.Post(tt.path, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d, want 200", resp.StatusCode)
+			}
+			messages, err := h.SMTP.Wait(2, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := byRecipient(messages)
+			notification, ok := sent[e2e.RecipientEmail]
+			if !ok {
+				t.Fatalf("no notification among %d messages", len(messages))
+			}
+			confirmation, ok := sent["ada@example.com"]
+			if !ok {
+				t.Fatalf("no confirmation among %d messages", len(messages))
+			}
+			golden.Check(t, "testdata/"+tt.name+"_notification.eml", notification.Data)
+			golden.Check(t, "testdata/"+tt.name+"_confirmation.eml", confirmation.Data)
+		})
+	}
+}
+
+func TestContactRejects(t *testing.T) {
+	h := newHarness(t, nil, nil, handler.Options{})
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"GET", "GET", "", "", http.StatusMethodNotAllowed, handler.ErrMethodNotAllowed},
+		{"missing name", "POST", "application/x-www-form-urlencoded", "email=ada%40example.com&message=Hi", http.StatusBadRequest, handler.ErrRequiredFields},
+		{"missing message", "POST", "application/x-www-form-urlencoded", "name=Ada&email=ada%40example.com", http.StatusBadRequest, handler.ErrRequiredFields},
+		{"invalid email", "POST", "application/x-www-form-urlencoded", "name=Ada&email=ada&message=Hi", http.StatusBadRequest, handler.ErrInvalidEmail},
+		{"invalid JSON", "POST", "application/json", `{"name": "Ada",`, http.StatusBadRequest, handler.ErrInvalidJSON},
+		{"unknown form", "POST", "application/x-www-form-urlencoded", "name=Ada&email=ada%40example.com&message=Hi", http.StatusNotFound, handler.ErrFormNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/contact"
+			if tt.code == handler.ErrFormNotFound {
+				path = "/forms/missing"
+			}
+			req, _ := http.NewRequest(tt.method, h.HTTP.URL+path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			resp, err := h.HTTP.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body map[string]any
+			json.NewDecoder(resp.Body).Decode(&body)
+			if resp.StatusCode != tt.status || body["code"] != tt.code {
+				t.Errorf("status %d, code %v; want %d, %s", resp.StatusCode, body["code"], tt.status, tt.code)
+			}
+		})
+	}
+	if n := len(h.SMTP.Messages()); n != 0 {
+		t.Errorf("rejected submissions sent %d messages", n)
+	}
+}
+
+func TestContactJSON(t *testing.T) {
+	h := newHarness(t, nil, nil, handler.Options{})
+	resp, err := h.HTTP.Client().Post(h.HTTP.URL+"/contact", "application/json",
+		strings.NewReader(`{"name": "Ada", "email": "ada@example.com", "message": "Hello from JSON"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	messages, err := h.SMTP.Wait(2, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for to, msg := range byRecipient(messages) {
+		text, err := msg.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(text, "Hello from JSON") {
+			t.Errorf("message to %s does not quote the submission:\n%s", to, text)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"form2mail/internal/form"
+)
+
+const (
+	defaultCORSMethods = "POST, OPTIONS"
+	defaultCORSHeaders = "Content-Type, Idempotency-Key"
+)
+
+// setCORSHeaders applies the form's CORS policy, falling back to the global
+// origin and the default methods and headers for anything it leaves unset.
+func (h *ContactHandler) setCORSHeaders(w http.ResponseWriter, r *http.Request, policy form.CORS) {
+	if len(policy.AllowedOrigins) == 0 {
+		w.Header().Set("Access-Control-Allow-Origin", h.config.CORSOrigin)
+	} else {
+		// Browsers accept a single origin, so echo the caller's origin if it is allowed
+		w.Header().Add("Vary", "Origin")
+		if origin := allowedOrigin(policy.AllowedOrigins, r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+	}
+
+	methods := defaultCORSMethods
+	if len(policy.AllowedMethods) > 0 {
+		methods = strings.Join(policy.AllowedMethods, ", ")
+	}
+	w.Header().Set("Access-Control-Allow-Methods", methods)
+
+	headers := defaultCORSHeaders
+	if len(policy.AllowedHeaders) > 0 {
+		headers = strings.Join(policy.AllowedHeaders, ", ")
+	}
+	w.Header().Set("Access-Control-Allow-Headers", headers)
+}
+
+func allowedOrigin(allowed []string, origin string) string {
+	for _, a := range allowed {
+		if a == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(a, origin) {
+			return origin
+		}
+	}
+	return ""
+}
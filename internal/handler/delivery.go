@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// DeliveryStatusHandler serves GET /v1/submissions/{referenceID}/status,
+// the delivery state of a stored submission's notification. It is public:
+// the reference is unguessable and only the state is exposed, so an SPA
+// that got the reference in the submission response can poll it.
+type DeliveryStatusHandler struct {
+	store storage.Store
+}
+
+func NewDeliveryStatusHandler(store storage.Store) *DeliveryStatusHandler {
+	return &DeliveryStatusHandler{store: store}
+}
+
+// Delivery states reported to clients.
+const (
+	deliveryQueued = "queued"
+	deliverySent   = "sent"
+	deliveryFailed = "failed"
+)
+
+type deliveryStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func (h *DeliveryStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("referenceID")
+	sub, err := h.store.Get(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load submission for its status", "submission_id", id, "error", err)
+		http.Error(w, "Failed to load status", http.StatusInternalServerError)
+		return
+	}
+
+	// Held and greylisted notifications are still on their way; how they
+	// are waiting is the owner's business
+	state := deliveryQueued
+	switch sub.Status {
+	case storage.StatusDelivered:
+		state = deliverySent
+	case storage.StatusFailed:
+		state = deliveryFailed
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveryStatus{ID: sub.ID, Status: state})
+}
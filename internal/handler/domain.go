@@ -0,0 +1,25 @@
+package handler
+
+import "strings"
+
+// emailDomainAllowed reports whether the domain of address is in allowed.
+// Entries may be written as "example.com" or "@example.com"; an empty list
+// allows every domain.
+func emailDomainAllowed(address string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.TrimSpace(address[at+1:])
+
+	for _, entry := range allowed {
+		if strings.EqualFold(strings.TrimPrefix(entry, "@"), domain) {
+			return true
+		}
+	}
+	return false
+}
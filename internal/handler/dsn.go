@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"form2mail/internal/email"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// DSNHandler records the delivery status notifications returned for
+// notifications sent with DSN_NOTIFY, posted as raw messages by an
+// inbound email service, with the stored submissions they are about.
+type DSNHandler struct {
+	store storage.Store
+	token string
+}
+
+func NewDSNHandler(store storage.Store, token string) *DSNHandler {
+	return &DSNHandler{store: store, token: token}
+}
+
+func (h *DSNHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(h.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboundBody)
+	var raw io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "multipart/") || mediaType == "application/x-www-form-urlencoded" {
+		if err := r.ParseMultipartForm(maxInboundBody); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		raw = strings.NewReader(firstNonEmpty(r.FormValue("body-mime"), r.FormValue("email")))
+	}
+
+	// Answer 200 for other mail too, so providers don't retry it
+	w.Header().Set("Content-Type", "application/json")
+	status, err := email.ParseDSN(raw)
+	if err != nil || status.SubmissionID == "" {
+		json.NewEncoder(w).Encode(map[string]string{"status": "unmatched"})
+		return
+	}
+
+	now := time.Now()
+	for _, rcpt := range status.Recipients {
+		report := storage.DeliveryReport{
+			Recipient:  rcpt.Recipient,
+			Action:     rcpt.Action,
+			Status:     rcpt.Status,
+			Diagnostic: rcpt.Diagnostic,
+			ReceivedAt: now,
+		}
+		logger := logging.FromContext(r.Context()).With("submission_id", status.SubmissionID)
+		err := h.store.AddDeliveryReport(r.Context(), status.SubmissionID, report)
+		if errors.Is(err, storage.ErrNotFound) {
+			logger.Warn("Delivery status for unknown submission")
+			json.NewEncoder(w).Encode(map[string]string{"status": "unmatched"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to store delivery status", "error", err)
+			http.Error(w, "Failed to store delivery status", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Delivery status of notification", "recipient", rcpt.Recipient, "action", rcpt.Action, "status", rcpt.Status)
+
+		// The server accepted the message but could not deliver it; mark
+		// it failed so it can be resent
+		if rcpt.Action == "failed" {
+			failure := storage.Failure{Error: dsnError(rcpt), FailedAt: now}
+			if err := h.store.RecordFailure(r.Context(), status.SubmissionID, failure); err != nil {
+				logger.Error("Failed to record failed delivery", "error", err)
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":        "matched",
+		"submission_id": status.SubmissionID,
+	})
+}
+
+// dsnError describes a failed delivery reported by a DSN.
+func dsnError(rcpt email.RecipientStatus) string {
+	msg := fmt.Sprintf("delivery to %s failed after it was sent", rcpt.Recipient)
+	if rcpt.Status != "" {
+		msg += " (" + rcpt.Status + ")"
+	}
+	if rcpt.Diagnostic != "" {
+		msg += ": " + rcpt.Diagnostic
+	}
+	return msg
+}
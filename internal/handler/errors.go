@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"form2mail/internal/form"
+)
+
+// Rejection codes returned in the "code" field of error responses. They are
+// stable so frontends and analytics can rely on them; messages may change.
+const (
+	ErrFormNotFound     = "ERR_FORM_NOT_FOUND"
+	ErrMethodNotAllowed = "ERR_METHOD_NOT_ALLOWED"
+	ErrMaintenance      = "ERR_MAINTENANCE"
+	ErrInvalidJSON      = "ERR_INVALID_JSON"
+	ErrInvalidForm      = "ERR_INVALID_FORM"
+	ErrTooLarge         = "ERR_TOO_LARGE"
+	ErrFileType         = "ERR_FILE_TYPE"
+	ErrJSONTooDeep      = "ERR_JSON_TOO_DEEP"
+	ErrTooManyFields    = "ERR_TOO_MANY_FIELDS"
+	ErrRequiredFields   = "ERR_REQUIRED_FIELDS"
+	ErrValidation       = "ERR_VALIDATION"
+	ErrInvalidEmail     = "ERR_INVALID_EMAIL"
+	ErrUndeliverable    = "ERR_UNDELIVERABLE_EMAIL"
+	ErrCaptchaFailed    = "ERR_CAPTCHA_FAILED"
+	ErrCaptchaRequired  = "ERR_CAPTCHA_REQUIRED"
+	ErrDomainNotAllowed = "ERR_DOMAIN_NOT_ALLOWED"
+	ErrMalicious        = "ERR_MALICIOUS"
+	ErrQueueFull        = "ERR_QUEUE_FULL"
+	ErrDuplicate        = "ERR_DUPLICATE"
+	ErrKeyReused        = "ERR_IDEMPOTENCY_KEY_REUSED"
+	ErrRateLimited      = "ERR_RATE_LIMITED"
+	ErrQuotaExceeded    = "ERR_QUOTA_EXCEEDED"
+	ErrSendFailed       = "ERR_SEND_FAILED"
+	ErrStorageFailed    = "ERR_STORAGE_FAILED"
+)
+
+// writeError answers a rejected submission with its code and message.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "error",
+		"code":    code,
+		"message": message,
+	})
+}
+
+// writeInvalid answers a submission breaking the form's field rules with
+// 400 and each field at fault, so frontends can mark them.
+func writeInvalid(w http.ResponseWriter, message string, fields []form.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  "error",
+		"code":    ErrValidation,
+		"message": message,
+		"fields":  fields,
+	})
+}
+
+// challenge tells the frontend which captcha to present.
+type challenge struct {
+	Provider string `json:"provider"`
+	SiteKey  string `json:"sitekey,omitempty"`
+	Field    string `json:"field"`
+}
+
+// writeChallenge answers a suspicious submission that came without a captcha
+// solution with 428 and the captcha to solve before resubmitting.
+func writeChallenge(w http.ResponseWriter, message string, c challenge) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusPreconditionRequired)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  "error",
+		"code":    ErrCaptchaRequired,
+		"message": message,
+		"captcha": c,
+	})
+}
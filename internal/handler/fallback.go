@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+)
+
+// FallbackFormHandler serves /f/{formID}, a server-rendered HTML version
+// of a named form for visitors without JavaScript or using assistive
+// technology, to link to next to an embedded widget. GET renders the form;
+// POST hands the submission to the contact handler and renders its
+// outcome, keeping the entered values if it failed.
+type FallbackFormHandler struct {
+	contact *ContactHandler
+}
+
+func NewFallbackFormHandler(contact *ContactHandler) *FallbackFormHandler {
+	return &FallbackFormHandler{contact: contact}
+}
+
+// fallbackField is an input of the fallback form.
+type fallbackField struct {
+	ID, Name, Label string
+	// Input is the input type, "textarea", or "select"
+	Input        string
+	InputMode    string
+	Autocomplete string
+	Required     bool
+	MaxLength    int
+	// Options are the choices of a select
+	Options []string
+	Value   string
+	// Error is why the failed submission's value was refused
+	Error string
+}
+
+// fallbackGroup is a fieldset of the fallback form; the first group of a
+// page has no legend and holds the ungrouped fields.
+type fallbackGroup struct {
+	Legend string
+	Fields []fallbackField
+}
+
+type fallbackPage struct {
+	Lang    string
+	Labels  form.Labels
+	Groups  []fallbackGroup
+	Uploads bool
+	Error   string
+	Success string
+
+	fieldErrors map[string]string
+}
+
+func (h *FallbackFormHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	def, ok := h.contact.resolveForm(r)
+	if !ok || def.ID == "" {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	}
+
+	status := http.StatusOK
+	page := fallbackPage{Lang: def.Lang(), Labels: def.FormLabels()}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		rec := &recordedResponse{header: make(http.Header), status: http.StatusOK}
+		h.contact.ServeHTTP(rec, r)
+		var result struct {
+			Status  string            `json:"status"`
+			Message string            `json:"message"`
+			Fields  []form.FieldError `json:"fields"`
+		}
+		if err := json.Unmarshal(rec.body.Bytes(), &result); err != nil || result.Message == "" {
+			result.Message = def.Strings().InvalidForm
+		}
+		if rec.status < 300 && result.Status == "success" {
+			page.Success = result.Message
+			break
+		}
+		status, page.Error = rec.status, result.Message
+		page.fieldErrors = make(map[string]string, len(result.Fields))
+		for _, f := range result.Fields {
+			page.fieldErrors[f.Field] = f.Message
+		}
+		if retry := rec.header.Get("Retry-After"); retry != "" {
+			w.Header().Set("Retry-After", retry)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if page.Success == "" {
+		page.Groups, page.Uploads = h.fallbackFields(def, r, page.fieldErrors)
+	}
+
+	var body bytes.Buffer
+	if err := fallbackTemplate.Execute(&body, page); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render fallback form", "form", def.ID, "error", err)
+		http.Error(w, "Failed to render form", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", def.Lang())
+	w.Header().Set("Cache-Control", "no-store")
+	// The page needs neither scripts nor anything from elsewhere
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// fallbackFields lays out the built-in fields followed by the form's
+// configured ones, ungrouped fields first and then each group in the order
+// it is first mentioned, like Arrange does for notifications. Field rules
+// become input attributes, allowed values a select. Values and errors are
+// those of a failed submission in r, if any. Upload fields are only
+// rendered when uploads are enabled; spam traps never are.
+func (h *FallbackFormHandler) fallbackFields(def form.Definition, r *http.Request, errs map[string]string) ([]fallbackGroup, bool) {
+	labels := def.FormLabels()
+	value := func(name string) string {
+		if r.Method != http.MethodPost {
+			return ""
+		}
+		return r.PostFormValue(name)
+	}
+
+	groups := []fallbackGroup{{Fields: []fallbackField{
+		{Name: "name", Label: labels.Name, Input: "text", Autocomplete: "name", Required: true},
+		{Name: "email", Label: labels.Email, Input: "email", Autocomplete: "email", Required: true},
+		{Name: "subject", Label: labels.Subject, Input: "text"},
+		{Name: "message", Label: labels.Message, Input: "textarea", Required: true},
+	}}}
+	index := map[string]int{"": 0}
+	add := func(group string, f fallbackField) {
+		i, ok := index[group]
+		if !ok {
+			i = len(groups)
+			index[group] = i
+			groups = append(groups, fallbackGroup{Legend: group})
+		}
+		groups[i].Fields = append(groups[i].Fields, f)
+	}
+	for _, f := range def.Fields {
+		// Rules for the built-in fields apply to their inputs
+		if f.IsStandard() {
+			for i := range groups[0].Fields {
+				if field := &groups[0].Fields[i]; field.Name == f.Name {
+					applyRules(field, f)
+				}
+			}
+			continue
+		}
+		field := fallbackField{Name: f.Name, Label: f.Label, Input: "text"}
+		if field.Label == "" {
+			field.Label = f.Name
+		}
+		switch f.Type {
+		case form.FieldDate:
+			field.Input = "date"
+		case form.FieldNumber:
+			// Numbers may be written like in the form's locale, which
+			// number inputs would refuse
+			field.InputMode = "decimal"
+		}
+		applyRules(&field, f)
+		add(f.Group, field)
+	}
+	uploads := false
+	if h.contact.uploads != nil {
+		for _, rule := range def.Uploads {
+			if rule.Field != "*" {
+				add("", fallbackField{Name: rule.Field, Label: rule.Field, Input: "file"})
+				uploads = true
+			}
+		}
+	}
+
+	for g := range groups {
+		for i := range groups[g].Fields {
+			f := &groups[g].Fields[i]
+			f.ID = "field-" + f.Name
+			if f.Input != "file" {
+				f.Value = value(f.Name)
+			}
+			f.Error = errs[f.Name]
+		}
+	}
+	return groups, uploads
+}
+
+// applyRules lets the browser check what it can of f's rules before the
+// submission is sent. Patterns are left to the server, as browsers read
+// them with a different syntax.
+func applyRules(field *fallbackField, f form.Field) {
+	field.Required = field.Required || f.Required
+	field.MaxLength = f.MaxLength
+	if len(f.AllowedValues) > 0 && field.Input != "textarea" {
+		field.Input, field.InputMode, field.Options = "select", "", f.AllowedValues
+	}
+}
+
+// recordedResponse keeps the contact handler's response to render it.
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recordedResponse) Header() http.Header { return r.header }
+
+func (r *recordedResponse) WriteHeader(status int) { r.status = status }
+
+func (r *recordedResponse) Write(p []byte) (int, error) { return r.body.Write(p) }
+
+var fallbackTemplate = template.Must(template.New("fallback").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Error}}{{.Error}} – {{end}}{{.Labels.Title}}</title>
+<style>
+body { font: 1.125rem/1.5 system-ui, sans-serif; color: #1a1a1a; background: #fff; margin: 0; }
+main { max-width: 40rem; margin: 0 auto; padding: 1.5rem; }
+fieldset { border: 1px solid #767676; margin: 0 0 1.5rem; padding: 1rem; }
+fieldset.plain { border: 0; padding: 0; }
+legend { font-weight: 600; padding: 0 .25rem; }
+label { display: block; font-weight: 600; margin-top: 1rem; }
+input, textarea, select { display: block; box-sizing: border-box; width: 100%; font: inherit; padding: .5rem; border: 2px solid #767676; border-radius: 4px; }
+textarea { min-height: 10rem; }
+input:focus, textarea:focus, select:focus, button:focus { outline: 3px solid #0b57d0; outline-offset: 2px; }
+button { font: inherit; font-weight: 600; margin-top: 1.5rem; padding: .75rem 1.5rem; color: #fff; background: #0b57d0; border: 0; border-radius: 4px; cursor: pointer; }
+.alert { border-left: 6px solid #b00020; background: #fdecef; padding: .75rem 1rem; }
+.invalid { color: #b00020; margin: .25rem 0 0; }
+.status { border-left: 6px solid #1e7b34; background: #e8f5eb; padding: .75rem 1rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Labels.Title}}</h1>
+{{- if .Success}}
+<p class="status" role="status">{{.Success}}</p>
+{{- else}}
+{{- with .Error}}
+<p class="alert" role="alert">{{.}}</p>
+{{- end}}
+<form method="post"{{if .Uploads}} enctype="multipart/form-data"{{end}}>
+{{- range .Groups}}
+<fieldset{{if not .Legend}} class="plain"{{end}}>
+{{- with .Legend}}
+<legend>{{.}}</legend>
+{{- end}}
+{{- range $field := .Fields}}
+<label for="{{.ID}}">{{.Label}}{{if .Required}} <span>({{$.Labels.Required}})</span>{{end}}</label>
+{{- if eq .Input "textarea"}}
+<textarea id="{{.ID}}" name="{{.Name}}"{{with .MaxLength}} maxlength="{{.}}"{{end}}{{if .Required}} required{{end}}{{template "invalid" .}}>{{.Value}}</textarea>
+{{- else if eq .Input "select"}}
+<select id="{{.ID}}" name="{{.Name}}"{{if .Required}} required{{end}}{{template "invalid" .}}>
+<option value=""></option>
+{{- range .Options}}
+<option{{if eq . $field.Value}} selected{{end}}>{{.}}</option>
+{{- end}}
+</select>
+{{- else}}
+<input id="{{.ID}}" name="{{.Name}}" type="{{.Input}}"{{with .InputMode}} inputmode="{{.}}"{{end}}{{with .Autocomplete}} autocomplete="{{.}}"{{end}}{{with .MaxLength}} maxlength="{{.}}"{{end}}{{with .Value}} value="{{.}}"{{end}}{{if .Required}} required{{end}}{{template "invalid" .}}>
+{{- end}}
+{{- with .Error}}
+<p class="invalid" id="{{$field.ID}}-error">{{.}}</p>
+{{- end}}
+{{- end}}
+</fieldset>
+{{- end}}
+<button type="submit">{{.Labels.Submit}}</button>
+</form>
+{{- end}}
+</main>
+</body>
+</html>
+{{- define "invalid"}}{{with .Error}} aria-invalid="true" aria-describedby="{{$.ID}}-error"{{end}}{{end}}
+`))
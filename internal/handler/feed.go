@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"form2mail/internal/config"
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// FeedHandler serves a token-protected Atom feed of recent submissions at
+// /feed (default form) and /feed/{formID}.
+type FeedHandler struct {
+	store  storage.Store
+	forms  *form.Registry
+	config config.Config
+}
+
+func NewFeedHandler(store storage.Store, forms *form.Registry, cfg config.Config) *FeedHandler {
+	return &FeedHandler{
+		store:  store,
+		forms:  forms,
+		config: cfg,
+	}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	formID := r.PathValue("formID")
+	token := h.config.FeedToken
+	if formID != "" {
+		def, ok := h.forms.Get(formID)
+		if !ok {
+			http.Error(w, "Form not found", http.StatusNotFound)
+			return
+		}
+		if def.FeedToken != "" {
+			token = def.FeedToken
+		}
+	}
+
+	// Without a token the feed stays disabled
+	if token == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	subs, err := h.store.List(r.Context(), storage.Filter{FormID: formID, Limit: h.config.FeedLimit})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list submissions for feed", "error", err)
+		http.Error(w, "Failed to load submissions", http.StatusInternalServerError)
+		return
+	}
+
+	title, feedID := "form2mail submissions", "default"
+	if formID != "" {
+		title, feedID = fmt.Sprintf("form2mail submissions: %s", formID), formID
+	}
+	feed := atomFeed{
+		ID:      "urn:form2mail:feed:" + feedID,
+		Title:   title,
+		Updated: h.timestamp(time.Now()),
+	}
+	if len(subs) > 0 {
+		feed.Updated = h.timestamp(subs[0].ReceivedAt)
+	}
+	for _, sub := range subs {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:form2mail:submission:" + sub.ID,
+			Title:   entryTitle(sub),
+			Updated: h.timestamp(sub.ReceivedAt),
+			Author:  atomAuthor{Name: sub.Name, Email: sub.Email},
+			Content: atomContent{Type: "html", Body: entryContent(sub)},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to write feed", "error", err)
+	}
+}
+
+// timestamp formats t as RFC 3339 in the configured time zone.
+func (h *FeedHandler) timestamp(t time.Time) string {
+	if h.config.Location != nil {
+		t = t.In(h.config.Location)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// requestToken returns the token from the Authorization header or, for feed
+// readers that only support URLs, the token query parameter.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+func entryTitle(sub storage.Submission) string {
+	if sub.Subject == "" {
+		return sub.Name
+	}
+	return fmt.Sprintf("%s: %s", sub.Name, sub.Subject)
+}
+
+func entryContent(sub storage.Submission) string {
+	return fmt.Sprintf("<p><strong>From:</strong> %s &lt;%s&gt;</p><p>%s</p>",
+		html.EscapeString(sub.Name), html.EscapeString(sub.Email),
+		strings.ReplaceAll(html.EscapeString(sub.Message), "\n", "<br>"))
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"form2mail/internal/email"
+	"form2mail/internal/form"
+)
+
+// standardFields are the fields with a dedicated meaning; everything else
+// is forwarded as an extra field.
+var standardFields = map[string]bool{
+	"name":         true,
+	"email":        true,
+	"subject":      true,
+	"message":      true,
+	"page_url":     true,
+	"utm_source":   true,
+	"utm_medium":   true,
+	"utm_campaign": true,
+	"utm_term":     true,
+	"utm_content":  true,
+	"captcha":      true,
+}
+
+// isExtraField reports whether name should be forwarded as an extra field.
+func (h *ContactHandler) isExtraField(name string) bool {
+	if standardFields[name] {
+		return false
+	}
+	return !slices.Contains(h.captchaFields(), name)
+}
+
+// captchaFields are the fields the captcha solution is read from, in
+// order: captcha, the widget's own field, and token. Without a captcha,
+// only captcha is.
+func (h *ContactHandler) captchaFields() []string {
+	if h.captcha == nil {
+		return []string{"captcha"}
+	}
+	return []string{"captcha", h.captcha.Field(), "token"}
+}
+
+// captchaSolution returns the first captcha field value returns a
+// solution for.
+func (h *ContactHandler) captchaSolution(value func(name string) string) string {
+	for _, name := range h.captchaFields() {
+		if solution := value(name); solution != "" {
+			return solution
+		}
+	}
+	return ""
+}
+
+// jsonString returns the string in object under name, or "" if there is
+// none.
+func jsonString(object map[string]json.RawMessage, name string) string {
+	var s string
+	if err := json.Unmarshal(object[name], &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+// jsonExtraFields collects the extra fields of a JSON object. Strings are
+// used as they are, other values as their JSON text.
+func (h *ContactHandler) jsonExtraFields(object map[string]json.RawMessage) map[string]string {
+	fields := make(map[string]string)
+	for name, raw := range object {
+		if !h.isExtraField(name) {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			fields[name] = s
+		} else {
+			fields[name] = string(raw)
+		}
+	}
+	return fields
+}
+
+// formExtraFields collects the extra fields of a urlencoded or multipart
+// body. Repeated fields, such as checkbox groups, are joined with commas.
+func (h *ContactHandler) formExtraFields(values url.Values) map[string]string {
+	fields := make(map[string]string)
+	for name, v := range values {
+		if h.isExtraField(name) {
+			fields[name] = strings.Join(v, ", ")
+		}
+	}
+	return fields
+}
+
+// emailFields converts arranged field values for the notification.
+func emailFields(values []form.Value) []email.Field {
+	if len(values) == 0 {
+		return nil
+	}
+	fields := make([]email.Field, len(values))
+	for i, v := range values {
+		fields[i] = email.Field{Label: v.Label, Group: v.Group, Value: v.Value}
+	}
+	return fields
+}
+
+// ruleValues returns the values field rules apply to: the extra fields and
+// the standard ones rules may be declared for.
+func ruleValues(contact ContactForm, extra map[string]string) map[string]string {
+	values := maps.Clone(extra)
+	if values == nil {
+		values = make(map[string]string)
+	}
+	values["name"] = contact.Name
+	values["email"] = contact.Email
+	values["subject"] = contact.Subject
+	values["message"] = contact.Message
+	return values
+}
+
+// contentTexts returns the texts of a submission that may carry links,
+// extra fields sorted by name.
+func contentTexts(contact ContactForm, extra map[string]string) []string {
+	texts := []string{contact.Name, contact.Subject, contact.Message}
+	for _, name := range slices.Sorted(maps.Keys(extra)) {
+		texts = append(texts, extra[name])
+	}
+	return texts
+}
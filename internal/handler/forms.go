@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+
+	"form2mail/internal/config"
+	"form2mail/internal/email"
+	"form2mail/internal/form"
+)
+
+// ReloadForms checks the definitions of next against the configuration,
+// swaps them in, and rebuilds the forms' templates and the limiters of forms
+// whose rate limits changed. Limiters of forms with the same limits keep
+// their counts, so a reload cannot be used to reset them. Requests in
+// flight finish with the definitions they started with. If the check
+// fails, nothing changes.
+func (h *ContactHandler) ReloadForms(next *form.Registry) error {
+	if err := CheckForms(h.config, next); err != nil {
+		return err
+	}
+	templates, err := loadFormTemplates(next)
+	if err != nil {
+		return err
+	}
+	h.forms.Replace(next)
+	perForm := formLimits(h.config, h.clock, h.forms, h.limits, *h.formLimits.Load())
+	h.formLimits.Store(&perForm)
+	h.templates.Store(&templates)
+	return nil
+}
+
+// ValidateForms checks the definitions of next against the configuration
+// without applying them.
+func (h *ContactHandler) ValidateForms(next *form.Registry) error {
+	return CheckForms(h.config, next)
+}
+
+// CheckForms validates form definitions against the configuration, beyond
+// what form.Load checks on its own.
+func CheckForms(cfg config.Config, forms *form.Registry) error {
+	for _, id := range forms.IDs() {
+		def, _ := forms.Get(id)
+		if def.FromEmail != "" && !cfg.SendingDomainAllowed(def.FromEmail) {
+			return fmt.Errorf("form %q: from_email %s is not in an allowed sending domain (SENDING_DOMAINS)", id, def.FromEmail)
+		}
+		if len(def.Uploads) > 0 && cfg.UploadDir == "" {
+			return fmt.Errorf("form %q: upload rules need UPLOAD_DIR", id)
+		}
+		if def.UsesS3() && cfg.S3AccessKeyID == "" {
+			return fmt.Errorf("form %q: uploads routed to S3 need S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY", id)
+		}
+		// The Maildir only takes RECIPIENT_EMAIL's copies
+		if def.Recipient != "" && cfg.DeliveryMode == config.DeliveryMaildir {
+			return fmt.Errorf("form %q: recipient needs DELIVERY_MODE smtp or both", id)
+		}
+	}
+	if _, err := loadFormTemplates(forms); err != nil {
+		return err
+	}
+	return nil
+}
+
+// formTemplates returns the templates of the form id, or nil if it has
+// none.
+func (h *ContactHandler) formTemplates(id string) *email.Templates {
+	return (*h.templates.Load())[id]
+}
+
+// loadFormTemplates loads the templates of the forms that have a template_dir.
+func loadFormTemplates(forms *form.Registry) (map[string]*email.Templates, error) {
+	templates := make(map[string]*email.Templates)
+	for _, id := range forms.IDs() {
+		def, _ := forms.Get(id)
+		if def.TemplateDir == "" {
+			continue
+		}
+		t, err := email.LoadTemplates(def.TemplateDir)
+		if err != nil {
+			return nil, fmt.Errorf("form %q: %w", id, err)
+		}
+		templates[id] = t
+	}
+	return templates, nil
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"form2mail/internal/email"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// maxInboundBody limits the size of inbound reply payloads.
+const maxInboundBody = 10 << 20
+
+// inboundReply is the reply posted by an inbound email service. Field names
+// cover the common providers; JSON keys match case-insensitively.
+type inboundReply struct {
+	To       string `json:"to"`
+	From     string `json:"from"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text"`
+	TextBody string `json:"textbody"`
+}
+
+// InboundHandler matches replies to confirmation emails, posted by an
+// inbound email service, to the stored submissions they answer.
+type InboundHandler struct {
+	store storage.Store
+	token string
+}
+
+func NewInboundHandler(store storage.Store, token string) *InboundHandler {
+	return &InboundHandler{store: store, token: token}
+}
+
+func (h *InboundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(h.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboundBody)
+	var in inboundReply
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+	} else {
+		if err := r.ParseMultipartForm(maxInboundBody); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		in.To = firstNonEmpty(r.FormValue("recipient"), r.FormValue("to"))
+		in.From = firstNonEmpty(r.FormValue("sender"), r.FormValue("from"))
+		in.Subject = r.FormValue("subject")
+		in.Text = firstNonEmpty(r.FormValue("body-plain"), r.FormValue("text"))
+	}
+
+	// Answer 200 for unmatched mail too, so providers don't retry it
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := email.ReplyTag(in.To)
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]string{"status": "unmatched"})
+		return
+	}
+
+	reply := storage.Reply{
+		From:       in.From,
+		Subject:    in.Subject,
+		Text:       firstNonEmpty(in.Text, in.TextBody),
+		ReceivedAt: time.Now(),
+	}
+	err := h.store.AddReply(r.Context(), id, reply)
+	if errors.Is(err, storage.ErrNotFound) {
+		logging.FromContext(r.Context()).Warn("Reply for unknown submission", "submission_id", id, "from", in.From)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unmatched"})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to store reply", "submission_id", id, "error", err)
+		http.Error(w, "Failed to store reply", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":        "matched",
+		"submission_id": id,
+	})
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
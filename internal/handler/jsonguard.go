@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// maxJSONBody bounds the size of JSON submissions.
+const maxJSONBody = 1 << 20
+
+var (
+	errJSONTooDeep       = errors.New("json nested too deeply")
+	errJSONTooManyFields = errors.New("json has too many fields")
+	errJSONValueTooLarge = errors.New("json value too large")
+)
+
+// jsonLimits bounds the shape of JSON submissions. Zero disables a limit.
+type jsonLimits struct {
+	MaxDepth int
+	// MaxFields counts object members and array elements at any depth.
+	MaxFields    int
+	MaxValueSize int
+}
+
+// check walks data token by token, failing as soon as a limit is exceeded
+// so that hostile payloads are never decoded into memory as a whole.
+func (l jsonLimits) check(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// inObject tracks, per open container, whether it is an object
+	var inObject []bool
+	expectKey := false
+	fields := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				// Object members were counted at their key
+				if len(inObject) > 0 && !inObject[len(inObject)-1] {
+					if err := l.countField(&fields); err != nil {
+						return err
+					}
+				}
+				inObject = append(inObject, delim == '{')
+				if l.MaxDepth > 0 && len(inObject) > l.MaxDepth {
+					return errJSONTooDeep
+				}
+				expectKey = delim == '{'
+			case '}', ']':
+				inObject = inObject[:len(inObject)-1]
+				expectKey = len(inObject) > 0 && inObject[len(inObject)-1]
+			}
+			continue
+		}
+
+		size := 0
+		switch v := tok.(type) {
+		case string:
+			size = len(v)
+		case json.Number:
+			size = len(v)
+		}
+		if l.MaxValueSize > 0 && size > l.MaxValueSize {
+			return errJSONValueTooLarge
+		}
+
+		if len(inObject) == 0 {
+			continue
+		}
+		if inObject[len(inObject)-1] {
+			// Keys and values alternate within objects
+			if expectKey {
+				if err := l.countField(&fields); err != nil {
+					return err
+				}
+			}
+			expectKey = !expectKey
+		} else if err := l.countField(&fields); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonLimits returns the configured limits for JSON submissions.
+func (h *ContactHandler) jsonLimits() jsonLimits {
+	return jsonLimits{
+		MaxDepth:     h.config.JSONMaxDepth,
+		MaxFields:    h.config.JSONMaxFields,
+		MaxValueSize: h.config.JSONMaxValueSize,
+	}
+}
+
+func (l jsonLimits) countField(fields *int) error {
+	*fields++
+	if l.MaxFields > 0 && *fields > l.MaxFields {
+		return errJSONTooManyFields
+	}
+	return nil
+}
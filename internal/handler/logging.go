@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"form2mail/internal/logging"
+)
+
+// requestIDHeader carries the request ID. One set by a proxy in front is
+// kept, so its logs and ours can be matched; the response always has it.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestID is the length beyond which a proxy's request ID is replaced.
+const maxRequestID = 64
+
+// LogRequests gives every request a logger carrying its request ID, client
+// address, and trace ID, which handlers get with logging.FromContext, and
+// logs each request once it is answered, with its status and latency.
+// Successful GET and HEAD requests, such as health checks and static files,
+// are only logged at debug level.
+func LogRequests(next http.Handler, logger *slog.Logger, proxies int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r)
+		w.Header().Set(requestIDHeader, id)
+		l := logger.With("request_id", id, "client_ip", ClientIP(r, proxies))
+		if trace := traceID(r); trace != "" {
+			l = l.With("trace_id", trace)
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(logging.WithLogger(r.Context(), l)))
+
+		level := slog.LevelInfo
+		switch {
+		case sw.status >= 500:
+			level = slog.LevelError
+		case sw.status < 400 && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+			level = slog.LevelDebug
+		}
+		l.Log(r.Context(), level, "Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000)
+	})
+}
+
+// requestID returns the request's X-Request-ID if it is usable, or else a
+// new random ID.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= maxRequestID && printableASCII(id) {
+		return id
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func printableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusWriter records the status of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status, sw.wroteHeader = status, true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
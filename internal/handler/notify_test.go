@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"form2mail/internal/config"
+	"form2mail/internal/duplicate"
+	"form2mail/internal/e2e"
+	"form2mail/internal/handler"
+	"form2mail/internal/notify"
+	"form2mail/internal/storage"
+)
+
+func TestNotifyAllPartialFailure(t *testing.T) {
+	slack := notify.Func("slack", func(context.Context, storage.Submission) error {
+		return errors.New("slack returned status 500")
+	})
+	tests := []struct {
+		name        string
+		responses   *duplicate.Responses
+		retryStatus int
+	}{
+		{"retry replayed", duplicate.NewResponses(time.Minute), http.StatusAccepted},
+		{"retry is a duplicate", nil, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := e2e.New(func(c *config.Config) {
+				c.NotifyPolicy = config.NotifyPolicyAll
+			}, nil, handler.Options{
+				Channels:   []notify.Notifier{slack},
+				Duplicates: duplicate.New(time.Minute),
+				Responses:  tt.responses,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			values := url.Values{"name": {"Ada"}, "email": {"ada@example.com"}, "message": {"Hello"}}
+			post := func() (int, map[string]string) {
+				resp, err := h.Post("/contact", values)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				var body map[string]string
+				json.NewDecoder(resp.Body).Decode(&body)
+				return resp.StatusCode, body
+			}
+			if status, body := post(); status != http.StatusAccepted || body["delivery"] != "partial" {
+				t.Fatalf("status %d, body %v; want 202 with partial delivery", status, body)
+			}
+			if status, body := post(); status != tt.retryStatus {
+				t.Errorf("retry: status %d, body %v; want %d", status, body, tt.retryStatus)
+			}
+
+			// The notification and the confirmation, once
+			if _, err := h.SMTP.Wait(2, 5*time.Second); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if n := len(h.SMTP.Messages()); n != 2 {
+				t.Errorf("%d messages delivered, want 2", n)
+			}
+		})
+	}
+}
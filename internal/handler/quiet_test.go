@@ -0,0 +1,125 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/e2e"
+	"form2mail/internal/form"
+	"form2mail/internal/handler"
+	"form2mail/internal/quiet"
+	"form2mail/internal/storage"
+)
+
+// night is during the quiet hours of the "night" form.
+var night = time.Date(2025, 3, 1, 23, 0, 0, 0, time.UTC)
+
+func quietHarness(t *testing.T, store storage.Store) (*e2e.Harness, *quiet.Queue) {
+	t.Helper()
+	forms, err := form.Parse([]byte(`{"forms": [
+		{"id": "night", "quiet_hours": {"start": "22:00", "end": "07:00", "timezone": "UTC"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := quiet.NewQueue()
+	h, err := e2e.New(func(c *config.Config) {
+		c.Location = time.UTC
+	}, forms, handler.Options{Clock: clock.NewManual(night), Quiet: queue, Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h, queue
+}
+
+func TestDrainDeliversHeldNotifications(t *testing.T) {
+	h, queue := quietHarness(t, nil)
+	resp, err := h.Post("/forms/night", url.Values{"name": {"Ada"}, "email": {"ada@example.com"}, "message": {"Hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	// The confirmation only
+	if _, err := h.SMTP.Wait(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if queue.Len() != 1 {
+		t.Fatalf("%d notifications held, want 1", queue.Len())
+	}
+
+	if err := h.Contact.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if queue.Len() != 0 {
+		t.Errorf("%d notifications still held after the drain", queue.Len())
+	}
+	if _, err := h.SMTP.Wait(2, 5*time.Second); err != nil {
+		t.Errorf("held notification was not delivered: %v", err)
+	}
+}
+
+func TestRearmHeld(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory(10)
+	records := []storage.Submission{
+		{ID: "held-night", FormID: "night", Name: "Ada", Email: "ada@example.com", Message: "Hello", Status: storage.StatusHeld, ReceivedAt: night},
+		{ID: "held-default", FormID: "", Name: "Bob", Email: "bob@example.com", Message: "Hi", Status: storage.StatusHeld, ReceivedAt: night},
+		{ID: "delivered", FormID: "night", Name: "Cy", Email: "cy@example.com", Message: "Hey", Status: storage.StatusDelivered, ReceivedAt: night},
+	}
+	for _, record := range records {
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, queue := quietHarness(t, store)
+
+	n, err := h.Contact.RearmHeld(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("RearmHeld found %d, want 2", n)
+	}
+	if queue.Len() != 1 {
+		t.Fatalf("%d notifications held, want 1", queue.Len())
+	}
+	// The default form has no quiet hours, so its notification went out
+	if _, err := h.SMTP.Wait(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if ran := queue.Release(night.Add(8 * time.Hour)); ran != 1 {
+		t.Fatalf("released %d, want 1", ran)
+	}
+	tests := []struct {
+		id   string
+		want storage.Status
+	}{
+		{"held-night", storage.StatusDelivered},
+		{"held-default", storage.StatusDelivered},
+		{"delivered", storage.StatusDelivered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			record, err := store.Get(ctx, tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Status != tt.want {
+				t.Errorf("status %s, want %s", record.Status, tt.want)
+			}
+		})
+	}
+	if n := len(h.SMTP.Messages()); n != 2 {
+		t.Errorf("%d messages delivered, want 2", n)
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"time"
+
+	"form2mail/internal/form"
+)
+
+// overQuota returns the form's tenant and whether it has used up a monthly
+// quota. Forms without a tenant have no quota.
+func (h *ContactHandler) overQuota(def form.Definition) (form.Tenant, bool) {
+	tenant, ok := h.forms.Tenant(def.Tenant)
+	if h.usage == nil || !ok {
+		return tenant, false
+	}
+	used := h.usage.Usage(tenant.ID)
+	return tenant, tenant.Exceeded(used.Submissions, used.Emails)
+}
+
+// notifyQuota tells the tenant, once per month, that submissions keep
+// coming in over its quota.
+func (h *ContactHandler) notifyQuota(tenant form.Tenant) {
+	if !h.usage.Notify(tenant.ID) {
+		return
+	}
+	to := tenant.NotifyEmail
+	if to == "" {
+		to = h.config.RecipientEmail
+	}
+	used := h.usage.Usage(tenant.ID)
+	subject := fmt.Sprintf("form2mail: monthly quota exceeded for %s", tenant.ID)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Monthly quota exceeded</h2>
+			<p>%s has used up its quota for %s. Submissions are still accepted and delivered.</p>
+			<p><strong>Submissions:</strong> %s</p>
+			<p><strong>Emails:</strong> %s</p>
+		</body>
+		</html>
+	`, html.EscapeString(tenant.ID), h.usage.Month(h.clock.Now()), quotaLine(used.Submissions, tenant.MonthlySubmissions), quotaLine(used.Emails, tenant.MonthlyEmails))
+	if err := h.emailSender.Send(to, subject, body); err != nil {
+		slog.Error("Failed to send quota notice", "tenant", tenant.ID, "error", err)
+	}
+}
+
+// countSubmission counts an accepted submission toward the form's tenant.
+func (h *ContactHandler) countSubmission(def form.Definition) {
+	if h.usage != nil && def.Tenant != "" {
+		h.usage.AddSubmission(def.Tenant)
+	}
+}
+
+// countEmail counts an email sent for the form toward its tenant.
+func (h *ContactHandler) countEmail(def form.Definition) {
+	if h.usage != nil && def.Tenant != "" {
+		h.usage.AddEmail(def.Tenant)
+	}
+}
+
+// untilNextMonth returns how long the current month lasts in the
+// configured time zone.
+func (h *ContactHandler) untilNextMonth() time.Duration {
+	loc := h.config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	now := h.clock.Now().In(loc)
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, loc).Sub(now)
+}
+
+func quotaLine(used, quota int) string {
+	if quota == 0 {
+		return fmt.Sprintf("%d (unlimited)", used)
+	}
+	return fmt.Sprintf("%d of %d", used, quota)
+}
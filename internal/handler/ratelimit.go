@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/form"
+	"form2mail/internal/ratelimit"
+)
+
+// limits are the rate limiters applied to one form. A nil limiter means the
+// limit is off.
+type limits struct {
+	ipRate   *ratelimit.Rate
+	emailCap *ratelimit.DailyCap
+}
+
+// formLimits builds limiters for the forms that override the global rate
+// limits. Forms that only override one kind of limit share the global
+// limiter for the other, so their submissions still count towards it. The
+// limiters of previous, those before a reload, are kept where a form's
+// limits did not change, so reloading does not reset their counts. New
+// limiters tell the time by c.
+func formLimits(cfg config.Config, c clock.Clock, forms *form.Registry, global limits, previous map[string]limits) map[string]limits {
+	perForm := make(map[string]limits)
+	if forms == nil {
+		return perForm
+	}
+	for _, id := range forms.IDs() {
+		def, _ := forms.Get(id)
+		override := def.RateLimit
+		if !override.OverridesIP() && !override.OverridesEmail() {
+			continue
+		}
+
+		l, old := global, previous[id]
+		if override.OverridesIP() {
+			l.ipRate = nil
+			if rate, burst := override.IP(cfg.IPRateLimit, cfg.IPRateBurst); rate > 0 {
+				if old.ipRate != nil && old.ipRate != global.ipRate && sameRate(old.ipRate, rate, burst) {
+					l.ipRate = old.ipRate
+				} else {
+					l.ipRate = ratelimit.NewRate(rate, burst)
+					l.ipRate.UseClock(c)
+				}
+			}
+		}
+		if override.OverridesEmail() {
+			l.emailCap = nil
+			if limit := override.Email(cfg.EmailDailyLimit); limit > 0 {
+				if old.emailCap != nil && old.emailCap != global.emailCap && old.emailCap.Limit() == limit {
+					l.emailCap = old.emailCap
+				} else {
+					l.emailCap = ratelimit.NewDailyCap(limit, cfg.Location)
+					l.emailCap.UseClock(c)
+				}
+			}
+		}
+		perForm[id] = l
+	}
+	return perForm
+}
+
+// sameRate reports whether r allows rate uses per minute with burst, as
+// NewRate(rate, burst) would.
+func sameRate(r *ratelimit.Rate, rate, burst int) bool {
+	perMinute, b := r.Limits()
+	return perMinute == rate && b == max(burst, 1)
+}
+
+// limitsFor returns the rate limiters that apply to the form id.
+func (h *ContactHandler) limitsFor(id string) limits {
+	if l, ok := (*h.formLimits.Load())[id]; ok {
+		return l
+	}
+	return h.limits
+}
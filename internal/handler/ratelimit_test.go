@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+	"form2mail/internal/config"
+	"form2mail/internal/form"
+	"form2mail/internal/ratelimit"
+)
+
+func mustForms(t *testing.T, doc string) *form.Registry {
+	t.Helper()
+	forms, err := form.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return forms
+}
+
+func TestFormLimitsSurviveReload(t *testing.T) {
+	cfg := config.Config{IPRateLimit: 10, IPRateBurst: 5, EmailDailyLimit: 3, Location: time.UTC}
+	global := limits{ipRate: ratelimit.NewRate(10, 5), emailCap: ratelimit.NewDailyCap(3, time.UTC)}
+	before := formLimits(cfg, clock.System, mustForms(t, `{"forms": [
+		{"id": "strict", "rate_limit": {"ip_rate": 1, "ip_burst": 1}},
+		{"id": "capped", "rate_limit": {"email_daily_limit": 1}},
+		{"id": "changed", "rate_limit": {"ip_rate": 2}}
+	]}`), global, nil)
+
+	// Use up the limits before the reload
+	if ok, _ := before["strict"].ipRate.Allow("192.0.2.1"); !ok {
+		t.Fatal("first submission refused")
+	}
+	if !before["capped"].emailCap.Allow("ada@example.com") {
+		t.Fatal("first submission refused")
+	}
+
+	after := formLimits(cfg, clock.System, mustForms(t, `{"forms": [
+		{"id": "strict", "rate_limit": {"ip_rate": 1, "ip_burst": 1}},
+		{"id": "capped", "rate_limit": {"email_daily_limit": 1}},
+		{"id": "changed", "rate_limit": {"ip_rate": 3}},
+		{"id": "new", "rate_limit": {"ip_rate": 1}}
+	]}`), global, before)
+
+	tests := []struct {
+		name string
+		same bool
+		got  any
+		want any
+	}{
+		{"unchanged IP rate is kept", true, after["strict"].ipRate, before["strict"].ipRate},
+		{"email cap is kept", true, after["capped"].emailCap, before["capped"].emailCap},
+		{"global rate stays shared", true, after["capped"].ipRate, global.ipRate},
+		{"changed rate is replaced", false, after["changed"].ipRate, before["changed"].ipRate},
+		{"shared global cap stays shared", true, after["changed"].emailCap, global.emailCap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.got == tt.want) != tt.same {
+				t.Errorf("limiter kept = %v, want %v", tt.got == tt.want, tt.same)
+			}
+		})
+	}
+
+	if ok, _ := after["strict"].ipRate.Allow("192.0.2.1"); ok {
+		t.Error("reload reset the per-IP rate")
+	}
+	if after["capped"].emailCap.Allow("ada@example.com") {
+		t.Error("reload reset the daily cap")
+	}
+	if perMinute, _ := after["changed"].ipRate.Limits(); perMinute != 3 {
+		t.Errorf("changed rate allows %d per minute, want 3", perMinute)
+	}
+	if after["new"].ipRate == nil {
+		t.Error("new form has no rate limiter")
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"form2mail/internal/config"
+	"form2mail/internal/duplicate"
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+)
+
+// idempotencyHeader carries a key with which clients mark retries of the
+// same request.
+const idempotencyHeader = "Idempotency-Key"
+
+// keyPrefix starts the replay keys taken from idempotencyHeader.
+const keyPrefix = "key:"
+
+// replayKeys returns the keys under which the response to a submission is
+// remembered: the client's idempotency key, if it sent one, and, when
+// duplicates are rejected anyway, the fingerprint of its content.
+func (h *ContactHandler) replayKeys(r *http.Request, def form.Definition, contact ContactForm) []string {
+	var keys []string
+	if key := r.Header.Get(idempotencyHeader); key != "" {
+		keys = append(keys, keyPrefix+def.ID+"|"+key)
+	}
+	if h.config.DuplicateAction == config.DuplicateReject {
+		keys = append(keys, "content:"+duplicate.Fingerprint(def.ID, contact.Email, contact.Name, contact.Subject, contact.Message))
+	}
+	return keys
+}
+
+// requestFingerprint hashes the submitted fields of a request, so a retry
+// can be told from another request sent with the same idempotency key. It
+// does not depend on the encoding or the order of the fields, or on the
+// captcha token.
+func requestFingerprint(def form.Definition, contact ContactForm, extra map[string]string) string {
+	contact.Captcha = ""
+	data, _ := json.Marshal(struct {
+		Form    string            `json:"form"`
+		Contact ContactForm       `json:"contact"`
+		Extra   map[string]string `json:"extra"`
+	}{def.ID, contact, extra})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// claim starts processing a submission under keys and returns its entry,
+// or answers it with the response to an earlier request with one of the
+// keys and returns nil. A request still in progress is waited for; if it
+// fails, this one is processed instead. An idempotency key reused for a
+// request with other content is refused.
+func (h *ContactHandler) claim(w http.ResponseWriter, r *http.Request, msgs form.Messages, fingerprint string, keys []string) *duplicate.Entry {
+	for {
+		entry, found, started := h.responses.Start(fingerprint, keys...)
+		if started {
+			return entry
+		}
+		if strings.HasPrefix(found, keyPrefix) && !entry.Matches(fingerprint) {
+			logging.FromContext(r.Context()).Warn("Idempotency key reused for a different submission")
+			writeError(w, http.StatusUnprocessableEntity, ErrKeyReused, msgs.KeyReused)
+			return nil
+		}
+		resp, ok := entry.Wait(r.Context())
+		if ok {
+			logging.FromContext(r.Context()).Info("Replaying response to retried submission")
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeResponse(w, resp)
+			return nil
+		}
+		if r.Context().Err() != nil {
+			return nil
+		}
+	}
+}
+
+// successResponse is the response to an accepted submission with the
+// given ID.
+func successResponse(msgs form.Messages, id string) duplicate.Response {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(map[string]string{
+		"status":  "success",
+		"message": msgs.Success,
+		"id":      id,
+	})
+	return duplicate.Response{Status: http.StatusOK, ContentType: "application/json", Body: body.Bytes()}
+}
+
+// queuedResponse is the response to a submission accepted for delivery in
+// the background, pointing to location for its delivery state if set.
+func queuedResponse(msgs form.Messages, id, location string) duplicate.Response {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(map[string]string{
+		"status":   "success",
+		"message":  msgs.Success,
+		"id":       id,
+		"delivery": "queued",
+	})
+	return duplicate.Response{Status: http.StatusAccepted, ContentType: "application/json", Location: location, Body: body.Bytes()}
+}
+
+// partialResponse is the response to a submission whose email went out
+// while other channels NOTIFY_POLICY=all requires failed.
+func partialResponse(msgs form.Messages, id string) duplicate.Response {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(map[string]string{
+		"status":   "success",
+		"message":  msgs.Success,
+		"id":       id,
+		"delivery": "partial",
+	})
+	return duplicate.Response{Status: http.StatusAccepted, ContentType: "application/json", Body: body.Bytes()}
+}
+
+func writeResponse(w http.ResponseWriter, resp duplicate.Response) {
+	w.Header().Set("Content-Type", resp.ContentType)
+	if resp.Location != "" {
+		w.Header().Set("Location", resp.Location)
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
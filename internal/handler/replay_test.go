@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/config"
+	"form2mail/internal/duplicate"
+	"form2mail/internal/e2e"
+	"form2mail/internal/handler"
+)
+
+func TestIdempotencyKeyReplay(t *testing.T) {
+	h, err := e2e.New(func(c *config.Config) {
+		c.DuplicateAction = config.DuplicateFlag
+	}, nil, handler.Options{Responses: duplicate.NewResponses(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	post := func(key, body string) (*http.Response, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", h.HTTP.URL+"/contact", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Idempotency-Key", key)
+		resp, err := h.HTTP.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]any
+		json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp, decoded
+	}
+	original := url.Values{"name": {"Ada"}, "email": {"ada@example.com"}, "message": {"Hello"}, "company": {"Analytical"}}
+	first, firstBody := post("k1", original.Encode())
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first submission: status %d, body %v", first.StatusCode, firstBody)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		body     string
+		status   int
+		replayed bool
+		code     string
+	}{
+		{"same request", "k1", original.Encode(), http.StatusOK, true, ""},
+		{"fields reordered", "k1", "company=Analytical&message=Hello&email=ada%40example.com&name=Ada", http.StatusOK, true, ""},
+		{"other captcha token", "k1", original.Encode() + "&captcha=fresh", http.StatusOK, true, ""},
+		{"other message", "k1", "name=Ada&email=ada%40example.com&message=Changed&company=Analytical", http.StatusUnprocessableEntity, false, handler.ErrKeyReused},
+		{"other extra field", "k1", "name=Ada&email=ada%40example.com&message=Hello&company=Babbage", http.StatusUnprocessableEntity, false, handler.ErrKeyReused},
+		{"new key", "k2", "name=Ada&email=ada%40example.com&message=Changed", http.StatusOK, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := post(tt.key, tt.body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d; body %v", resp.StatusCode, tt.status, body)
+			}
+			if replayed := resp.Header.Get("Idempotent-Replayed") == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+			if tt.replayed && body["id"] != firstBody["id"] {
+				t.Errorf("replayed id %v, want %v", body["id"], firstBody["id"])
+			}
+			if tt.code != "" && body["code"] != tt.code {
+				t.Errorf("code %v, want %s", body["code"], tt.code)
+			}
+		})
+	}
+
+	// Only the first submission and the one with the new key were sent,
+	// each as a notification and a confirmation
+	if _, err := h.SMTP.Wait(4, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(h.SMTP.Messages()); n != 4 {
+		t.Errorf("%d messages delivered, want 4", n)
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"form2mail/internal/email"
+	"form2mail/internal/form"
+	"form2mail/internal/storage"
+)
+
+// Resend delivers the notification of a stored submission again, e.g. one
+// that failed during a provider outage, and records the outcome in the
+// store. The notification is rebuilt from the stored record with the
+// form's current definition; sender reputation and history are left out,
+// and no confirmation is sent.
+func (h *ContactHandler) Resend(record storage.Submission) error {
+	def, sub := h.rebuild(record)
+	return h.notify(def, sub, true)
+}
+
+// RearmHeld holds the notifications of stored submissions again that were
+// held for quiet hours when the process stopped, until their form's quiet
+// hours end. Those whose quiet hours are over, or whose form has none any
+// more, are delivered right away. Like Resend, the notifications are
+// rebuilt from the records. It returns how many were found.
+func (h *ContactHandler) RearmHeld(ctx context.Context) (int, error) {
+	if h.store == nil {
+		return 0, nil
+	}
+	records, err := h.store.List(ctx, storage.Filter{AnyForm: true, Status: storage.StatusHeld})
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		def, sub := h.rebuild(record)
+		deliver := func() {
+			if err := h.notify(def, sub, true); err != nil {
+				submissionLogger(sub).Error("Failed to send held email to recipient", "error", err)
+			}
+		}
+		var until time.Time
+		if h.quiet != nil {
+			until = def.QuietHours.Until(h.clock.Now(), h.config.Location)
+		}
+		if until.IsZero() {
+			deliver()
+			continue
+		}
+		h.quiet.Hold(until, true, deliver)
+	}
+	return len(records), nil
+}
+
+// rebuild returns the notification of a stored submission with the
+// definition of its form.
+func (h *ContactHandler) rebuild(record storage.Submission) (form.Definition, email.Submission) {
+	def := form.Definition{ID: record.FormID}
+	if record.FormID != "" && h.forms != nil {
+		if current, ok := h.forms.Get(record.FormID); ok {
+			def = current
+		}
+	}
+	m := record.Source
+	sub := email.Submission{
+		ID:            record.ID,
+		FormID:        record.FormID,
+		Name:          record.Name,
+		Email:         record.Email,
+		Subject:       record.Subject,
+		Message:       record.Message,
+		ClientIP:      record.ClientIP,
+		FromName:      def.FromName,
+		FromEmail:     def.FromEmail,
+		Recipient:     def.Recipient,
+		SubjectPrefix: def.SubjectPrefix,
+		Templates:     h.formTemplates(def.ID),
+		Source: email.Source{
+			PageURL:     m["page_url"],
+			UTMSource:   m["utm_source"],
+			UTMMedium:   m["utm_medium"],
+			UTMCampaign: m["utm_campaign"],
+			UTMTerm:     m["utm_term"],
+			UTMContent:  m["utm_content"],
+		},
+		ReceivedAt: record.ReceivedAt,
+		Fields:     emailFields(def.Arrange(record.Fields)),
+		Headers:    def.Headers,
+		Preheader:  def.Preheader,
+	}
+	if record.Receipt != nil {
+		sub.Signature = record.Receipt.Signature
+	}
+	return def, sub
+}
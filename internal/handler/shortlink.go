@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"form2mail/internal/shortlink"
+)
+
+// ShortLinkHandler redirects short links served as /s/{code} to their
+// targets.
+type ShortLinkHandler struct {
+	links *shortlink.Store
+}
+
+func NewShortLinkHandler(links *shortlink.Store) *ShortLinkHandler {
+	return &ShortLinkHandler{links: links}
+}
+
+func (h *ShortLinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target, err := h.links.Resolve(r.PathValue("code"), time.Now())
+	if err != nil {
+		http.Error(w, "Link invalid or expired", http.StatusNotFound)
+		return
+	}
+	// The target is a capability, so keep it out of caches and referrers
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// shorten returns a short link to target valid until expires, or target
+// itself if short links are disabled or the link cannot be stored.
+func (h *ContactHandler) shorten(target string, expires time.Time) string {
+	if h.links == nil {
+		return target
+	}
+	code, err := h.links.Shorten(target, expires)
+	if err != nil {
+		slog.Warn("Failed to shorten link, using it as is", "error", err)
+		return target
+	}
+	return strings.TrimSuffix(h.config.PublicURL, "/") + "/s/" + code
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path"
+)
+
+// NewStaticHandler serves the files in dir, such as the page containing the
+// form. Directories are only served through their index.html; listings are
+// never shown.
+func NewStaticHandler(dir string) http.Handler {
+	files := http.FileServer(indexOnlyFS{http.Dir(dir)})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// indexOnlyFS hides directories that have no index.html.
+type indexOnlyFS struct {
+	fs http.FileSystem
+}
+
+func (f indexOnlyFS) Open(name string) (http.File, error) {
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := f.fs.Open(path.Join(name, "index.html"))
+		if err != nil {
+			file.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return file, nil
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+	"form2mail/internal/storage"
+)
+
+// StatsHandler serves unauthenticated aggregate counts at /stats (default
+// form) and /stats/{formID}, for a "we answered N messages" widget. It never
+// exposes anything about individual submissions.
+type StatsHandler struct {
+	store storage.Store
+	forms *form.Registry
+	loc   *time.Location
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedStats
+}
+
+// Stats is the public summary of one form's stored submissions.
+type Stats struct {
+	Year              int `json:"year"`
+	ReceivedThisYear  int `json:"received_this_year"`
+	AnsweredThisYear  int `json:"answered_this_year"`
+	ReceivedThisMonth int `json:"received_this_month"`
+}
+
+type cachedStats struct {
+	body    []byte
+	expires time.Time
+}
+
+// NewStatsHandler computes counts in loc and reuses them for ttl.
+func NewStatsHandler(store storage.Store, forms *form.Registry, loc *time.Location, ttl time.Duration) *StatsHandler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &StatsHandler{
+		store: store,
+		forms: forms,
+		loc:   loc,
+		ttl:   ttl,
+		cache: make(map[string]cachedStats),
+	}
+}
+
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	formID := r.PathValue("formID")
+	if formID != "" {
+		if _, ok := h.forms.Get(formID); !ok {
+			http.Error(w, "Form not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	body, err := h.stats(r, formID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to compute public stats", "error", err)
+		http.Error(w, "Failed to load stats", http.StatusInternalServerError)
+		return
+	}
+
+	// Widgets are embedded on other origins and only ever read
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.ttl.Seconds())))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// stats returns the encoded counts for formID, from the cache while fresh.
+// Computing them lists every stored submission of the year, so the cache
+// keeps a busy page from doing that on every view.
+func (h *StatsHandler) stats(r *http.Request, formID string) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now().In(h.loc)
+	if cached, ok := h.cache[formID]; ok && now.Before(cached.expires) {
+		return cached.body, nil
+	}
+
+	yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, h.loc)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, h.loc)
+	subs, err := h.store.List(r.Context(), storage.Filter{FormID: formID, Since: yearStart})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := Stats{Year: now.Year(), ReceivedThisYear: len(subs)}
+	for _, sub := range subs {
+		if sub.Handled() {
+			stats.AnsweredThisYear++
+		}
+		if !sub.ReceivedAt.Before(monthStart) {
+			stats.ReceivedThisMonth++
+		}
+	}
+
+	body, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	h.cache[formID] = cachedStats{body: body, expires: now.Add(h.ttl)}
+	return body, nil
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// traceID returns the trace ID from the request's W3C traceparent header,
+// or "" if there is none or it is malformed.
+func traceID(r *http.Request) string {
+	// version-traceid-parentid-flags, e.g. 00-4bf9...4736-00f0...02b7-01
+	parts := strings.Split(strings.TrimSpace(r.Header.Get("traceparent")), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	// An all-zero trace ID is invalid
+	if _, err := hex.DecodeString(parts[1]); err != nil || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	return strings.ToLower(parts[1])
+}
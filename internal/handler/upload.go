@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"form2mail/internal/email"
+	"form2mail/internal/form"
+	"form2mail/internal/logging"
+	"form2mail/internal/upload"
+)
+
+// UploadHandler serves uploaded files through signed, expiring links.
+type UploadHandler struct {
+	uploads *upload.Store
+}
+
+func NewUploadHandler(uploads *upload.Store) *UploadHandler {
+	return &UploadHandler{uploads: uploads}
+}
+
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.uploads.Authorized(id, r.URL.Query()) {
+		http.Error(w, "Link invalid or expired", http.StatusForbidden)
+		return
+	}
+
+	f, name, err := h.uploads.Open(id)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	// Never let the browser render uploads from our origin
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// Reasons checkUploads refuses a submission for.
+var (
+	errFileTooLarge = errors.New("file too large")
+	errFileType     = errors.New("file type not allowed")
+)
+
+// checkUploads refuses the files of a multipart submission that exceed
+// UPLOAD_MAX_FILE_SIZE or are not of UPLOAD_ALLOWED_TYPES, before anything
+// is stored. Files the form drops are not checked.
+func (h *ContactHandler) checkUploads(ctx context.Context, def form.Definition, mf *multipart.Form) error {
+	if h.uploads == nil || mf == nil {
+		return nil
+	}
+	for field, files := range mf.File {
+		if def.UploadRule(field, h.config.UploadDestination).Destination == form.UploadDrop {
+			continue
+		}
+		for _, fh := range files {
+			if h.config.UploadMaxFileSize > 0 && fh.Size > h.config.UploadMaxFileSize {
+				logging.FromContext(ctx).Warn("Refusing upload over UPLOAD_MAX_FILE_SIZE", "file", fh.Filename, "size", fh.Size)
+				return errFileTooLarge
+			}
+			if len(h.config.UploadAllowedTypes) == 0 {
+				continue
+			}
+			src, err := fh.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read upload %q: %w", fh.Filename, err)
+			}
+			// Sniffing looks at no more than the first 512 bytes
+			head := make([]byte, 512)
+			n, _ := io.ReadFull(src, head)
+			src.Close()
+			if !upload.TypeAllowed(h.config.UploadAllowedTypes, fh.Filename, head[:n]) {
+				logging.FromContext(ctx).Warn("Refusing upload not of UPLOAD_ALLOWED_TYPES", "file", fh.Filename)
+				return errFileType
+			}
+		}
+	}
+	return nil
+}
+
+// saveUploads routes the files of a multipart submission by the form's
+// upload rules. It returns the files kept for the notification: linked or
+// stored elsewhere, and attached.
+func (h *ContactHandler) saveUploads(ctx context.Context, def form.Definition, subID string, mf *multipart.Form) ([]email.Attachment, []email.File) {
+	if h.uploads == nil || mf == nil {
+		return nil, nil
+	}
+
+	var attachments []email.Attachment
+	var files []email.File
+	saved := 0
+	for _, field := range slices.Sorted(maps.Keys(mf.File)) {
+		rule := def.UploadRule(field, h.config.UploadDestination)
+		if rule.Destination == form.UploadDrop {
+			continue
+		}
+		for _, fh := range mf.File[field] {
+			if saved == h.config.UploadMaxFiles {
+				logging.FromContext(ctx).Warn("Ignoring uploads beyond UPLOAD_MAX_FILES", "max_files", h.config.UploadMaxFiles)
+				return attachments, files
+			}
+			src, err := fh.Open()
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to read upload", "file", fh.Filename, "error", err)
+				continue
+			}
+			switch rule.Destination {
+			case form.UploadAttach, form.UploadS3:
+				var data []byte
+				if data, err = io.ReadAll(src); err != nil {
+					break
+				}
+				data = h.uploads.Process(fh.Filename, data)
+				if rule.Destination == form.UploadAttach {
+					files = append(files, email.File{Name: upload.CleanName(fh.Filename), ContentType: http.DetectContentType(data), Data: data})
+					break
+				}
+				var key string
+				if key, err = h.s3.Put(ctx, rule.Bucket, rule.Prefix, subID, fh.Filename, data, rule.RetentionDays); err == nil {
+					attachments = append(attachments, email.Attachment{Name: path.Base(key), Size: int64(len(data)), Location: "s3://" + rule.Bucket + "/" + key})
+				}
+			default:
+				var a email.Attachment
+				if a, err = h.linkUpload(fh.Filename, src, rule.RetentionDays); err == nil {
+					attachments = append(attachments, a)
+				}
+			}
+			src.Close()
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to store upload", "file", fh.Filename, "error", err)
+				continue
+			}
+			saved++
+		}
+	}
+	return attachments, files
+}
+
+// linkUpload stores an upload in UPLOAD_DIR, for retentionDays if positive,
+// and returns a link to it for the notification.
+func (h *ContactHandler) linkUpload(name string, src io.Reader, retentionDays int) (email.Attachment, error) {
+	retention := time.Duration(retentionDays) * 24 * time.Hour
+	f, err := h.uploads.Save(name, src, retention)
+	if err != nil {
+		return email.Attachment{}, err
+	}
+
+	// A link must not outlive the file
+	ttl := h.config.UploadLinkTTL
+	if retention > 0 {
+		ttl = min(ttl, retention)
+	}
+	expires := h.clock.Now().Add(ttl)
+	base := strings.TrimSuffix(h.config.PublicURL, "/")
+	return email.Attachment{
+		Name:    f.Name,
+		Size:    f.Size,
+		URL:     h.shorten(base+"/uploads/"+f.ID+"?"+h.uploads.Link(f.ID, expires), expires),
+		Expires: expires,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"form2mail/internal/bridge"
+	"form2mail/internal/email"
+	"form2mail/internal/logging"
+)
+
+// maxWebhookBody limits the size of inbound webhook payloads.
+const maxWebhookBody = 1 << 20
+
+// WebhookHandler emails JSON posted by third-party services to /webhook/{id}.
+type WebhookHandler struct {
+	emailSender *email.Sender
+	endpoints   *bridge.Registry
+	recipient   string
+}
+
+func NewWebhookHandler(emailSender *email.Sender, endpoints *bridge.Registry, recipient string) *WebhookHandler {
+	return &WebhookHandler{
+		emailSender: emailSender,
+		endpoints:   endpoints,
+		recipient:   recipient,
+	}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	endpoint, ok := h.endpoints.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	// Many services can only configure a URL, so accept the token as a query parameter too
+	if !endpoint.Authorized(requestToken(r)) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&payload); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	subject, body, err := endpoint.Render(payload)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render webhook", "webhook", endpoint.ID, "error", err)
+		http.Error(w, "Failed to render webhook", http.StatusInternalServerError)
+		return
+	}
+
+	recipient := endpoint.Recipient
+	if recipient == "" {
+		recipient = h.recipient
+	}
+	// A greylisted email is retried in the background; failing the call
+	// would only make the caller send it again
+	var deferred *email.DeferredError
+	if err := h.emailSender.Send(recipient, subject, body); err != nil && !errors.As(err, &deferred) {
+		logging.FromContext(r.Context()).Error("Failed to send webhook email", "webhook", endpoint.ID, "error", err)
+		http.Error(w, "Failed to send email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	})
+}
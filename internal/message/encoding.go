@@ -0,0 +1,101 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime"
+	"mime/quotedprintable"
+	"regexp"
+	"strings"
+)
+
+// maxLineLength is the longest line SMTP allows, excluding CRLF.
+const maxLineLength = 998
+
+// Content-Transfer-Encodings used for text parts.
+const (
+	encoding7bit            = "7bit"
+	encoding8bit            = "8bit"
+	encodingQuotedPrintable = "quoted-printable"
+)
+
+var (
+	contentTypeLine = regexp.MustCompile(`(?im)^Content-Type: ([^\r\n]*)`)
+	encodingLine    = regexp.MustCompile(`(?im)^(Content-Transfer-Encoding: )8bit(\r?)$`)
+)
+
+// transferEncoding picks how text is sent: as is when it is ASCII, as 8bit
+// when only non-ASCII characters need care, and quoted-printable when lines
+// are too long for SMTP.
+func transferEncoding(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if len(strings.TrimSuffix(line, "\r")) > maxLineLength {
+			return encodingQuotedPrintable
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			return encoding8bit
+		}
+	}
+	return encoding7bit
+}
+
+// encodeText applies encoding to text.
+func encodeText(text, encoding string) string {
+	if encoding != encodingQuotedPrintable {
+		return text
+	}
+	var b strings.Builder
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(text))
+	w.Close()
+	return b.String()
+}
+
+// Downgrade8bit re-encodes the 8bit parts of msg as quoted-printable, for
+// servers that do not offer 8BITMIME. Messages are built already encoded
+// because the same bytes also go to the outbox and the Maildir.
+func Downgrade8bit(msg []byte) []byte {
+	header, body, ok := bytes.Cut(msg, []byte("\r\n\r\n"))
+	if !ok || !encodingLine.Match(header) {
+		return msg
+	}
+
+	var boundary string
+	if m := contentTypeLine.FindSubmatch(header); m != nil {
+		if mediaType, params, err := mime.ParseMediaType(string(m[1])); err == nil && strings.HasPrefix(mediaType, "multipart/") {
+			boundary = params["boundary"]
+		}
+	}
+
+	if boundary == "" {
+		header = encodingLine.ReplaceAll(header, []byte("${1}"+encodingQuotedPrintable+"${2}"))
+		body = []byte(encodeText(string(body), encodingQuotedPrintable))
+	} else {
+		// The parts are separated by delimiter lines; only the ones in
+		// between carry content
+		delimiter := []byte("--" + boundary)
+		parts := bytes.Split(body, delimiter)
+		for i := 1; i < len(parts)-1; i++ {
+			part := bytes.TrimSuffix(bytes.TrimPrefix(parts[i], []byte("\r\n")), []byte("\r\n"))
+			parts[i] = append(append([]byte("\r\n"), Downgrade8bit(part)...), "\r\n"...)
+		}
+		body = bytes.Join(parts, delimiter)
+		header = encodingLine.ReplaceAll(header, []byte("${1}"+encoding7bit+"${2}"))
+	}
+	return append(append(header, "\r\n\r\n"...), body...)
+}
+
+// encodeBase64 encodes data as base64 in lines of 76 characters, each
+// ending in CRLF.
+func encodeBase64(data []byte) string {
+	var b strings.Builder
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.String()
+}
@@ -0,0 +1,182 @@
+// Package message builds RFC 5322 messages: headers, the HTML body with
+// its text alternative, inline images, and attachments as MIME parts, and
+// transfer encodings that survive SMTP. Every delivery provider sends the
+// same bytes.
+package message
+
+import (
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Message is an HTML email. The zero value of optional fields leaves them
+// out of the message.
+type Message struct {
+	// ID is the local part of the Message-ID, e.g. from NewID. It also
+	// makes the MIME boundaries unique.
+	ID string
+	// From is the From header, e.g. "Acme <hello@acme.example>"; FromAddress
+	// is its bare address, whose domain completes the Message-ID.
+	From        string
+	FromAddress string
+	To          string
+	Subject     string
+	Date        time.Time
+	// Headers are added after the standard ones, sorted by name.
+	Headers map[string]string
+	HTML    string
+	// Text is a plain-text version of the HTML, sent before it as
+	// multipart/alternative for clients that do not show HTML.
+	Text string
+	// Inline parts are referenced from the HTML as cid:<ContentID> and sent
+	// with it as multipart/related.
+	Inline []Part
+	// Attachments follow the body as multipart/mixed.
+	Attachments []Part
+}
+
+// Part is a binary MIME part, sent base64-encoded.
+type Part struct {
+	ContentType string
+	Filename    string
+	// ContentID identifies inline parts; it is ignored for attachments.
+	ContentID string
+	Data      []byte
+}
+
+// Bytes renders the message with CRLF line endings.
+func (m Message) Bytes() []byte {
+	var b strings.Builder
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+
+	header("From", SanitizeHeader(m.From))
+	header("To", SanitizeHeader(m.To))
+	header("Subject", mime.QEncoding.Encode("utf-8", SanitizeHeader(m.Subject)))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", m.ID, idDomain(m.FromAddress)))
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header(SanitizeHeader(name), SanitizeHeader(m.Headers[name]))
+	}
+
+	contentType, encoding, body := m.entity()
+	header("MIME-Version", "1.0")
+	header("Content-Type", contentType)
+	header("Content-Transfer-Encoding", encoding)
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// disposition returns a Content-Disposition of kind for filename. Names
+// that are not plain ASCII are encoded as RFC 2231 allows, and quotes and
+// backslashes are escaped as MIME expects rather than as Go does.
+func disposition(kind, filename string) string {
+	return mime.FormatMediaType(kind, map[string]string{"filename": filename})
+}
+
+// entity returns the Content-Type, Content-Transfer-Encoding, and content
+// of the message body.
+func (m Message) entity() (contentType, encoding, body string) {
+	contentType = "text/html; charset=UTF-8"
+	encoding = transferEncoding(m.HTML)
+	body = encodeText(m.HTML, encoding)
+
+	if len(m.Inline) > 0 {
+		boundary := "related-" + m.ID
+		var b strings.Builder
+		writePart(&b, boundary, contentType, encoding, nil, body)
+		for _, part := range m.Inline {
+			writePart(&b, boundary, part.ContentType, "base64", map[string]string{
+				"Content-ID":          "<" + part.ContentID + ">",
+				"Content-Disposition": disposition("inline", part.Filename),
+			}, strings.TrimSuffix(encodeBase64(part.Data), "\r\n"))
+		}
+		fmt.Fprintf(&b, "--%s--", boundary)
+		contentType = fmt.Sprintf("multipart/related; boundary=%q; type=\"text/html\"", boundary)
+		encoding, body = container(encoding), b.String()
+	}
+
+	if m.Text != "" {
+		boundary := "alternative-" + m.ID
+		textEncoding := transferEncoding(m.Text)
+		var b strings.Builder
+		writePart(&b, boundary, "text/plain; charset=UTF-8", textEncoding, nil, encodeText(m.Text, textEncoding))
+		writePart(&b, boundary, contentType, encoding, nil, body)
+		fmt.Fprintf(&b, "--%s--", boundary)
+		contentType = fmt.Sprintf("multipart/alternative; boundary=%q", boundary)
+		encoding, body = container(encoding, textEncoding), b.String()
+	}
+
+	if len(m.Attachments) > 0 {
+		boundary := "mixed-" + m.ID
+		var b strings.Builder
+		writePart(&b, boundary, contentType, encoding, nil, body)
+		for _, part := range m.Attachments {
+			writePart(&b, boundary, part.ContentType, "base64", map[string]string{
+				"Content-Disposition": disposition("attachment", part.Filename),
+			}, strings.TrimSuffix(encodeBase64(part.Data), "\r\n"))
+		}
+		fmt.Fprintf(&b, "--%s--", boundary)
+		contentType = fmt.Sprintf("multipart/mixed; boundary=%q", boundary)
+		encoding, body = container(encoding), b.String()
+	}
+	return contentType, encoding, body
+}
+
+// container returns the Content-Transfer-Encoding of a multipart entity
+// whose text parts have encodings: 8bit if one of them is.
+func container(encodings ...string) string {
+	for _, encoding := range encodings {
+		if encoding == encoding8bit {
+			return encoding8bit
+		}
+	}
+	return encoding7bit
+}
+
+// writePart writes a delimiter and one part of a multipart entity.
+func writePart(b *strings.Builder, boundary, contentType, encoding string, headers map[string]string, content string) {
+	fmt.Fprintf(b, "--%s\r\nContent-Type: %s\r\nContent-Transfer-Encoding: %s\r\n", boundary, contentType, encoding)
+	for _, name := range []string{"Content-ID", "Content-Disposition"} {
+		if value, ok := headers[name]; ok {
+			fmt.Fprintf(b, "%s: %s\r\n", name, value)
+		}
+	}
+	fmt.Fprintf(b, "\r\n%s\r\n", content)
+}
+
+// SanitizeHeader strips line breaks so a value cannot inject extra headers.
+func SanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// NewID returns a random identifier for the Message-ID header. Callers keep
+// it with the message, e.g. in the outbox, so a replayed message keeps the
+// same Message-ID. The ID is drawn from ids, or from crypto/rand if ids is
+// nil.
+func NewID(ids *clock.IDs) string {
+	if ids == nil {
+		ids = clock.Random
+	}
+	return ids.New(16)
+}
+
+func idDomain(from string) string {
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		return from[i+1:]
+	}
+	return "form2mail.local"
+}
@@ -0,0 +1,139 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/golden"
+)
+
+func TestMessageGolden(t *testing.T) {
+	base := Message{
+		ID:          "test",
+		From:        "Example Contact <form2mail@example.com>",
+		FromAddress: "form2mail@example.com",
+		To:          "owner@example.com",
+		Subject:     "Hello",
+		Date:        time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		HTML:        "<p>Hello</p>",
+	}
+	logo := Part{ContentType: "image/png", Filename: "logo.png", ContentID: "logo.png", Data: []byte("\x89PNG\r\n\x1a\n")}
+	pdf := Part{ContentType: "application/pdf", Filename: "Übersicht.pdf", Data: []byte("%PDF-1.4")}
+	tests := []struct {
+		name   string
+		change func(m *Message)
+	}{
+		{"html", func(m *Message) {}},
+		{"headers", func(m *Message) {
+			m.Subject = "Größe\r\nBcc: eve@example.com"
+			m.Headers = map[string]string{"X-Form2Mail-Spam": "true", "Reply-To": "ada@example.com"}
+		}},
+		{"non_ascii_8bit", func(m *Message) {
+			m.HTML = "<p>Grüße aus München</p>"
+		}},
+		{"long_lines", func(m *Message) {
+			m.HTML = "<p>" + strings.Repeat("word ", 300) + "</p>"
+		}},
+		{"alternative", func(m *Message) {
+			m.Text = "Hello"
+		}},
+		{"related", func(m *Message) {
+			m.HTML = `<img src="cid:logo.png"><p>Hello</p>`
+			m.Inline = []Part{logo}
+		}},
+		{"mixed", func(m *Message) {
+			m.Attachments = []Part{pdf, {ContentType: "text/csv", Filename: "rows.csv", Data: []byte("a,b\n1,2\n")}}
+		}},
+		{"everything", func(m *Message) {
+			m.HTML = `<img src="cid:logo.png"><p>Grüße</p>`
+			m.Text = "Grüße"
+			m.Inline = []Part{logo}
+			m.Attachments = []Part{pdf}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := base
+			tt.change(&m)
+			data := m.Bytes()
+			for i, line := range strings.Split(string(data), "\r\n") {
+				if len(line) > 998 {
+					t.Errorf("line %d is %d bytes long", i+1, len(line))
+				}
+			}
+			golden.Check(t, "testdata/"+tt.name+".eml", data)
+		})
+	}
+}
+
+func TestAttachmentFilenames(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+	}{
+		{"plain", "report.pdf"},
+		{"space", "annual report.pdf"},
+		{"quotes", `say "hi".txt`},
+		{"backslash", `back\slash.txt`},
+		{"non-ASCII", "Übersicht März.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Message{
+				ID:          "test",
+				From:        "form2mail@example.com",
+				FromAddress: "form2mail@example.com",
+				To:          "owner@example.com",
+				Subject:     "Files",
+				Date:        time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+				HTML:        "<p>See attached</p>",
+				Attachments: []Part{{ContentType: "application/pdf", Filename: tt.filename, Data: []byte("%PDF")}},
+			}
+			data := m.Bytes()
+
+			msg, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := multipart.NewReader(msg.Body, params["boundary"])
+			if _, err := r.NextPart(); err != nil {
+				t.Fatal(err)
+			}
+			part, err := r.NextPart()
+			if err != nil {
+				t.Fatal(err)
+			}
+			disposition := part.Header.Get("Content-Disposition")
+			for _, c := range []byte(disposition) {
+				if c >= 0x80 {
+					t.Fatalf("Content-Disposition %q is not ASCII", disposition)
+				}
+			}
+			kind, dispParams, err := mime.ParseMediaType(disposition)
+			if err != nil {
+				t.Fatalf("Content-Disposition %q: %v", disposition, err)
+			}
+			if kind != "attachment" || dispParams["filename"] != tt.filename {
+				t.Errorf("Content-Disposition %q = %s, filename %q; want attachment, %q", disposition, kind, dispParams["filename"], tt.filename)
+			}
+			body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != "%PDF" {
+				t.Errorf("attachment = %q, want %%PDF", body)
+			}
+		})
+	}
+}
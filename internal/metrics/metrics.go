@@ -0,0 +1,116 @@
+// Package metrics defines the Prometheus metrics exposed at /metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the application's collectors and the registry they are
+// registered with.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// QueueDepth is the number of submissions accepted but not yet delivered.
+	QueueDepth prometheus.Gauge
+	// BackpressureRejections counts submissions turned away with 503.
+	BackpressureRejections prometheus.Counter
+	// Spam counts submissions that reached SPAM_THRESHOLD.
+	Spam prometheus.Counter
+	// Honeypot counts submissions dropped for filling in HONEYPOT_FIELD.
+	Honeypot prometheus.Counter
+	// Tarpitted counts bots answered slowly, by what gave them away.
+	Tarpitted *prometheus.CounterVec
+	// StorageErrors counts failed storage operations, per operation.
+	StorageErrors *prometheus.CounterVec
+	// DeliveryDuration is the time a whole delivery took, per provider.
+	DeliveryDuration *prometheus.HistogramVec
+	// DeliveryPhase is the time each phase of a delivery took, per provider.
+	DeliveryPhase *prometheus.HistogramVec
+}
+
+// New creates the collectors on a fresh registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		QueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "form2mail_queue_depth",
+			Help: "Submissions accepted but not yet delivered.",
+		}),
+		BackpressureRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "form2mail_backpressure_rejections_total",
+			Help: "Submissions rejected with 503 because the queue was saturated.",
+		}),
+		Spam: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "form2mail_spam_total",
+			Help: "Submissions scored as spam, whether flagged or dropped.",
+		}),
+		Honeypot: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "form2mail_honeypot_dropped_total",
+			Help: "Submissions dropped with a fake success because the honeypot field was filled in.",
+		}),
+		Tarpitted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "form2mail_tarpitted_total",
+			Help: "Requests from bots held for TARPIT_DELAY before a fake success, by reason (trap, blocklist, honeypot).",
+		}, []string{"reason"}),
+		StorageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "form2mail_storage_errors_total",
+			Help: "Failed storage operations (save, status, history), whether or not the submission was delivered.",
+		}, []string{"operation"}),
+		DeliveryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "form2mail_delivery_duration_seconds",
+			Help:    "Time taken to deliver a message, by provider.",
+			Buckets: deliveryBuckets,
+		}, []string{"provider"}),
+		DeliveryPhase: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "form2mail_delivery_phase_duration_seconds",
+			Help:    "Time taken by each phase of a delivery (dial, tls, auth, data, write), by provider.",
+			Buckets: deliveryBuckets,
+		}, []string{"provider", "phase"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.QueueDepth,
+		m.BackpressureRejections,
+		m.Spam,
+		m.Honeypot,
+		m.Tarpitted,
+		m.StorageErrors,
+		m.DeliveryDuration,
+		m.DeliveryPhase,
+	)
+	return m
+}
+
+// deliveryBuckets span fast local relays to slow providers near the SMTP
+// timeouts.
+var deliveryBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// ObserveDelivery records how long a delivery phase took; an empty phase
+// means the delivery as a whole. A non-empty traceID is attached as an
+// exemplar so slow observations can be followed to their trace.
+func (m *Metrics) ObserveDelivery(provider, phase string, d time.Duration, traceID string) {
+	var observer prometheus.Observer
+	if phase == "" {
+		observer = m.DeliveryDuration.WithLabelValues(provider)
+	} else {
+		observer = m.DeliveryPhase.WithLabelValues(provider, phase)
+	}
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && traceID != "" {
+		eo.ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	observer.Observe(d.Seconds())
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	// Exemplars are only part of the OpenMetrics format, which is served
+	// to scrapers that ask for it
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"form2mail/internal/form"
+	"form2mail/internal/forward"
+	"form2mail/internal/storage"
+	"form2mail/internal/telegram"
+)
+
+// Webhook returns a Notifier posting submissions to hook through fw.
+func Webhook(fw *forward.Forwarder, hook form.Webhook) Notifier {
+	return Func("webhook "+hook.URL, func(ctx context.Context, sub storage.Submission) error {
+		return fw.Send(ctx, hook, sub)
+	})
+}
+
+// Telegram returns a Notifier posting submissions to the chat of bot.
+func Telegram(bot *telegram.Bot) Notifier {
+	return Func("telegram", bot.SendSubmission)
+}
+
+// Slack posts submissions to a Slack incoming webhook.
+type Slack struct {
+	url    string
+	client *http.Client
+	dryRun bool
+}
+
+// NewSlack returns a Slack channel posting to url. In dry-run mode messages
+// are logged instead of posted.
+func NewSlack(url string, dryRun bool) *Slack {
+	return &Slack{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		dryRun: dryRun,
+	}
+}
+
+// UseTransport makes s post to Slack through rt instead of
+// http.DefaultTransport. It must be called before s is used.
+func (s *Slack) UseTransport(rt http.RoundTripper) {
+	s.client.Transport = rt
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+func (s *Slack) Notify(ctx context.Context, sub storage.Submission) error {
+	payload, err := json.Marshal(map[string]any{
+		"text":         slackText(sub),
+		"unfurl_links": false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+
+	if s.dryRun {
+		log.Printf("[dry run] Would post submission %s to Slack: %s", sub.ID, payload)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// slackEscaper escapes the characters Slack reads as markup, so submitters
+// cannot add links or mentions.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackText formats sub in Slack's mrkdwn, like telegram.Format.
+func slackText(sub storage.Submission) string {
+	var text strings.Builder
+	text.WriteString("*New submission*")
+	if sub.FormID != "" {
+		fmt.Fprintf(&text, " to `%s`", slackEscaper.Replace(sub.FormID))
+	}
+	fmt.Fprintf(&text, "\n*From:* %s &lt;%s&gt;", slackEscaper.Replace(sub.Name), slackEscaper.Replace(sub.Email))
+	if sub.Subject != "" {
+		fmt.Fprintf(&text, "\n*Subject:* %s", slackEscaper.Replace(sub.Subject))
+	}
+	fmt.Fprintf(&text, "\n\n%s", slackEscaper.Replace(sub.Message))
+
+	if len(sub.Fields) > 0 {
+		text.WriteString("\n")
+		names := make([]string, 0, len(sub.Fields))
+		for name := range sub.Fields {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(&text, "\n*%s:* %s", slackEscaper.Replace(name), slackEscaper.Replace(sub.Fields[name]))
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,164 @@
+// Package notify fans a submission out to every channel the owner is told
+// about it through, such as email, Slack, Telegram, and webhooks.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"form2mail/internal/storage"
+)
+
+// Notifier tells the owner about a submission over one channel.
+type Notifier interface {
+	// Name identifies the channel in logs and errors, e.g. "telegram".
+	Name() string
+	Notify(ctx context.Context, sub storage.Submission) error
+}
+
+// Func adapts a function to a Notifier named name.
+func Func(name string, fn func(context.Context, storage.Submission) error) Notifier {
+	return funcNotifier{name: name, fn: fn}
+}
+
+type funcNotifier struct {
+	name string
+	fn   func(context.Context, storage.Submission) error
+}
+
+func (n funcNotifier) Name() string { return n.name }
+
+func (n funcNotifier) Notify(ctx context.Context, sub storage.Submission) error {
+	return n.fn(ctx, sub)
+}
+
+// Policy decides from the outcome on each channel whether a submission
+// reached the owner.
+type Policy string
+
+const (
+	// PolicyEmail succeeds when the first channel, the email notification,
+	// does; the others are best effort.
+	PolicyEmail Policy = "email"
+	// PolicyAny succeeds when at least one channel does.
+	PolicyAny Policy = "any"
+	// PolicyAll succeeds only when every channel does.
+	PolicyAll Policy = "all"
+)
+
+// ChannelError is the failure of one channel.
+type ChannelError struct {
+	Channel string
+	Err     error
+}
+
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Channel, e.Err)
+}
+
+func (e *ChannelError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError is the failure of PolicyAll when the first channel, the
+// email notification, succeeded and only others failed. The owner was told,
+// so the submission must not be sent again; Err joins the failures.
+type PartialError struct {
+	Err error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("notified by email only: %v", e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Dispatcher sends a submission through all its channels at once.
+type Dispatcher struct {
+	policy Policy
+}
+
+// NewDispatcher returns a Dispatcher judging the outcome by policy.
+func NewDispatcher(policy Policy) *Dispatcher {
+	return &Dispatcher{policy: policy}
+}
+
+type outcome struct {
+	primary bool
+	err     error
+}
+
+// Dispatch notifies the owner of sub through channels, concurrently, and
+// returns as soon as the policy can tell whether that succeeded: nil if it
+// did, otherwise the failures it went by, joined, or a *PartialError if the
+// email went out nonetheless. Channels still running
+// then finish in the background. Failures the returned error leaves out
+// are logged to logger, so none goes unnoticed.
+func (d *Dispatcher) Dispatch(ctx context.Context, logger *slog.Logger, sub storage.Submission, channels ...Notifier) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	outcomes := make(chan outcome, len(channels))
+	for i, n := range channels {
+		go func() {
+			err := n.Notify(ctx, sub)
+			if err != nil {
+				err = &ChannelError{Channel: n.Name(), Err: err}
+			}
+			outcomes <- outcome{primary: i == 0, err: err}
+		}()
+	}
+
+	// failures is left with those the returned error does not report
+	var failures []error
+	var result error
+	succeeded, received := 0, 0
+	primaryOK := false
+	for decided := false; !decided; {
+		o := <-outcomes
+		received++
+		if o.err != nil {
+			failures = append(failures, o.err)
+		} else {
+			succeeded++
+			primaryOK = primaryOK || o.primary
+		}
+		all := received == len(channels)
+		switch d.policy {
+		case PolicyAny:
+			if decided = succeeded > 0 || all; decided && succeeded == 0 {
+				result, failures = errors.Join(failures...), nil
+			}
+		case PolicyAll:
+			if decided = all; decided && len(failures) > 0 {
+				result, failures = errors.Join(failures...), nil
+				if primaryOK {
+					result = &PartialError{Err: result}
+				}
+			}
+		default:
+			if decided = o.primary; decided && o.err != nil {
+				// The email's own error, so callers can tell its kind
+				result, failures = o.err.(*ChannelError).Err, failures[:len(failures)-1]
+			}
+		}
+	}
+
+	for _, err := range failures {
+		logger.Error("Failed to notify through channel", "error", err)
+	}
+	if received < len(channels) {
+		go func() {
+			for ; received < len(channels); received++ {
+				if o := <-outcomes; o.err != nil {
+					logger.Error("Failed to notify through channel", "error", o.err)
+				}
+			}
+		}()
+	}
+	return result
+}
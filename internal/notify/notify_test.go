@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"form2mail/internal/storage"
+)
+
+func channel(name string, err error) Notifier {
+	return Func(name, func(context.Context, storage.Submission) error { return err })
+}
+
+func TestDispatch(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name     string
+		policy   Policy
+		channels []Notifier
+		ok       bool
+		partial  bool
+	}{
+		{"email sent", PolicyEmail, []Notifier{channel("email", nil), channel("slack", failed)}, true, false},
+		{"email failed", PolicyEmail, []Notifier{channel("email", failed), channel("slack", nil)}, false, false},
+		{"any one channel", PolicyAny, []Notifier{channel("email", failed), channel("slack", nil)}, true, false},
+		{"any without success", PolicyAny, []Notifier{channel("email", failed), channel("slack", failed)}, false, false},
+		{"all succeeded", PolicyAll, []Notifier{channel("email", nil), channel("slack", nil)}, true, false},
+		{"all with email sent", PolicyAll, []Notifier{channel("email", nil), channel("slack", failed)}, false, true},
+		{"all with email failed", PolicyAll, []Notifier{channel("email", failed), channel("slack", nil)}, false, false},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDispatcher(tt.policy).Dispatch(context.Background(), logger, storage.Submission{ID: "s1"}, tt.channels...)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Dispatch = %v, want success %v", err, tt.ok)
+			}
+			var partial *PartialError
+			if errors.As(err, &partial) != tt.partial {
+				t.Errorf("Dispatch = %v, want partial %v", err, tt.partial)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Package outbound builds the HTTP transport shared by the clients of
+// third-party APIs, so that OUTBOUND_PROXY applies to all of them.
+package outbound
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Transport returns a transport sending requests through proxy, except to
+// hosts listed in NO_PROXY. Without a proxy it follows HTTPS_PROXY and
+// HTTP_PROXY like http.DefaultTransport.
+func Transport(proxy string) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy == "" {
+		return t
+	}
+	env := httpproxy.FromEnvironment()
+	proxyFunc := (&httpproxy.Config{HTTPProxy: proxy, HTTPSProxy: proxy, NoProxy: env.NoProxy}).ProxyFunc()
+	t.Proxy = func(r *http.Request) (*url.URL, error) {
+		return proxyFunc(r.URL)
+	}
+	return t
+}
@@ -0,0 +1,62 @@
+package outbound
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	t.Setenv("NO_PROXY", "intranet.example,.corp.example")
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.example:8080")
+	proxy := Transport("http://proxy.example:3128")
+
+	tests := map[string]string{
+		"https://api.hcaptcha.com/siteverify": "http://proxy.example:3128",
+		"http://hooks.example/form":           "http://proxy.example:3128",
+		"https://intranet.example/hook":       "",
+		"https://crm.corp.example/hook":       "",
+	}
+	for target, want := range tests {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := proxy.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != want {
+			t.Errorf("proxy for %s = %q, want %q", target, got, want)
+		}
+	}
+
+	if Transport("").Proxy == nil {
+		t.Error("transport without OUTBOUND_PROXY ignores the proxy variables")
+	}
+}
+
+func TestTransportReachesProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	client := &http.Client{Transport: Transport(proxy.URL)}
+	resp, err := client.Get("http://hooks.example/form?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if requested != "http://hooks.example/form?id=1" || string(body) != "via proxy" {
+		t.Errorf("proxy saw %q and answered %q", requested, body)
+	}
+}
@@ -0,0 +1,252 @@
+// Package outbox persists outgoing messages while they are being delivered,
+// so a crash mid-delivery does not lose them.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateSending marks an entry whose delivery has started but not finished.
+const StateSending = "sending"
+
+// sentRetention is how long delivered IDs are remembered for deduplication.
+const sentRetention = 7 * 24 * time.Hour
+
+// Entry is a single outgoing message recorded in the outbox.
+type Entry struct {
+	ID        string    `json:"id"`
+	To        string    `json:"to"`
+	Message   []byte    `json:"message"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	// Attempts counts the deliveries started, the first one included.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Outbox stores entries as JSON files in a directory. Delivered IDs are kept
+// in a "sent" subdirectory so replays never deliver a message twice, and
+// entries that cannot be read are moved to a "corrupt" one.
+type Outbox struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open creates the outbox directory if needed, removes entries left half
+// written by a crash, and prunes old sent markers.
+func Open(dir string) (*Outbox, error) {
+	for _, sub := range []string{"sent", "corrupt"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create outbox: %w", err)
+		}
+	}
+	o := &Outbox{dir: dir}
+	// A temporary file was never renamed, so its delivery never started
+	tmps, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+	if err := o.pruneSent(time.Now().Add(-sentRetention)); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Begin records e in the sending state before delivery starts, as its
+// first attempt.
+func (o *Outbox) Begin(e Entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e.State = StateSending
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.Attempts = 1
+	return o.write(e)
+}
+
+// Attempt counts another delivery of e before it starts and returns e with
+// the new count. It is recorded first, so an entry whose delivery crashes
+// the process runs out of attempts too.
+func (o *Outbox) Attempt(e Entry) (Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e.Attempts++
+	return e, o.write(e)
+}
+
+// write stores e durably: it is synced to disk under a temporary name and
+// then renamed, so a crash leaves either the old entry or the new one.
+func (o *Outbox) write(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox entry: %w", err)
+	}
+
+	tmp := o.entryPath(e.ID) + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, o.entryPath(e.ID))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+	return syncDir(o.dir)
+}
+
+// Finish records id as delivered and removes its entry.
+func (o *Outbox) Finish(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, err := os.Create(o.sentPath(id))
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	// The marker must outlive a crash, or the entry is delivered again
+	if err := syncDir(filepath.Join(o.dir, "sent")); err != nil {
+		return err
+	}
+	return o.remove(id)
+}
+
+// Discard removes the entry for id without marking it delivered.
+func (o *Outbox) Discard(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.remove(id)
+}
+
+// Delivered reports whether id has already been delivered.
+func (o *Outbox) Delivered(id string) bool {
+	_, err := os.Stat(o.sentPath(id))
+	return err == nil
+}
+
+// Stuck returns entries left in the sending state, oldest first. These are
+// messages whose delivery was interrupted, typically by a crash. Entries
+// that cannot be read or decoded are logged and moved to the corrupt
+// directory, so they neither block the others nor come up again.
+func (o *Outbox) Stuck() ([]Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			o.quarantine(file, fmt.Errorf("failed to read outbox entry: %w", err))
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			o.quarantine(file, fmt.Errorf("failed to decode outbox entry: %w", err))
+			continue
+		}
+		if e.State == StateSending {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// quarantine moves the entry file aside after err, keeping it for
+// inspection.
+func (o *Outbox) quarantine(file string, err error) {
+	dest := filepath.Join(o.dir, "corrupt", filepath.Base(file))
+	log.Printf("Moving outbox entry %s to %s: %v", filepath.Base(file), dest, err)
+	if err := os.Rename(file, dest); err != nil {
+		log.Printf("Failed to move corrupt outbox entry %s: %v", filepath.Base(file), err)
+	}
+}
+
+// syncDir flushes the entries of dir, so files created or renamed in it
+// survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync outbox: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox: %w", err)
+	}
+	return nil
+}
+
+func (o *Outbox) remove(id string) error {
+	if err := os.Remove(o.entryPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove outbox entry: %w", err)
+	}
+	return nil
+}
+
+func (o *Outbox) pruneSent(before time.Time) error {
+	markers, err := os.ReadDir(filepath.Join(o.dir, "sent"))
+	if err != nil {
+		return fmt.Errorf("failed to list sent markers: %w", err)
+	}
+	for _, m := range markers {
+		info, err := m.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(before) {
+			os.Remove(filepath.Join(o.dir, "sent", m.Name()))
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) entryPath(id string) string {
+	return filepath.Join(o.dir, safeName(id)+".json")
+}
+
+func (o *Outbox) sentPath(id string) string {
+	return filepath.Join(o.dir, "sent", safeName(id))
+}
+
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, id)
+}
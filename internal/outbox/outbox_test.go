@@ -0,0 +1,105 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenRemovesPartialWrites(t *testing.T) {
+	dir := t.TempDir()
+	tmp := filepath.Join(dir, "m1.json.tmp")
+	if err := os.WriteFile(tmp, []byte(`{"id":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("temporary file still there: %v", err)
+	}
+}
+
+func TestStuck(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Begin(Entry{ID: "m1", To: "owner@example.com", Message: []byte("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Begin(Entry{ID: "m2", To: "owner@example.com", Message: []byte("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Finish("m2"); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"truncated.json": `{"id": "truncated", "state": "sen`,
+		"garbage.json":   "\x00\x01",
+		"sent.json":      `{"id": "sent", "state": "sent"}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := o.Stuck()
+	if err != nil {
+		t.Fatalf("Stuck: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != "m1" || entries[0].Attempts != 1 {
+		t.Fatalf("Stuck = %+v, want m1 at its first attempt", entries)
+	}
+	if !o.Delivered("m2") || o.Delivered("m1") {
+		t.Error("delivery markers are wrong")
+	}
+
+	tests := []struct {
+		file        string
+		quarantined bool
+	}{
+		{"truncated.json", true},
+		{"garbage.json", true},
+		{"sent.json", false},
+		{"m1.json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := os.Stat(filepath.Join(dir, "corrupt", tt.file))
+			if quarantined := err == nil; quarantined != tt.quarantined {
+				t.Errorf("quarantined = %v, want %v", quarantined, tt.quarantined)
+			}
+			_, err = os.Stat(filepath.Join(dir, tt.file))
+			if kept := err == nil; kept == tt.quarantined {
+				t.Errorf("left in the outbox = %v, want %v", kept, !tt.quarantined)
+			}
+		})
+	}
+}
+
+func TestAttemptIsPersisted(t *testing.T) {
+	o, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Entry{ID: "m1", To: "owner@example.com", Message: []byte("hi")}
+	if err := o.Begin(e); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := o.Stuck()
+	for range 2 {
+		if entries[0], err = o.Attempt(entries[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err = o.Stuck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Attempts != 3 {
+		t.Errorf("Stuck = %+v, want m1 with 3 attempts", entries)
+	}
+}
@@ -0,0 +1,167 @@
+// Package pdf writes simple text documents as PDF, using the standard
+// Helvetica fonts so no fonts need to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page geometry in points.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 50
+)
+
+type style struct {
+	font    string // resource name, /F1 regular or /F2 bold
+	size    float64
+	leading float64
+	bold    bool
+}
+
+var (
+	titleStyle   = style{font: "F2", size: 16, leading: 22, bold: true}
+	headingStyle = style{font: "F2", size: 12, leading: 18, bold: true}
+	labelStyle   = style{font: "F2", size: 10, leading: 14, bold: true}
+	textStyle    = style{font: "F1", size: 10, leading: 14}
+	smallStyle   = style{font: "F1", size: 8, leading: 11}
+)
+
+// labelWidth is the column reserved for field labels.
+const labelWidth = 130
+
+// Document is a PDF document built up from top to bottom. Text that does
+// not fit on the current page continues on a new one.
+type Document struct {
+	pages  []*bytes.Buffer
+	y      float64
+	footer string
+}
+
+// New returns an empty document. footer is printed at the bottom of every
+// page, e.g. to note when it was generated.
+func New(footer string) *Document {
+	d := &Document{footer: footer}
+	d.newPage()
+	return d
+}
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin
+}
+
+// space moves down by h points, starting a new page if that leaves no room.
+func (d *Document) space(h float64) {
+	if d.y-h < margin {
+		d.newPage()
+	}
+	d.y -= h
+}
+
+func (d *Document) text(s style, x float64, text string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %g %g Td (%s) Tj ET\n", s.font, s.size, x, d.y, escape(text))
+}
+
+// Title adds the document title.
+func (d *Document) Title(text string) {
+	d.lines(titleStyle, margin, pageWidth-2*margin, text)
+	d.y -= 6
+}
+
+// Heading starts a new section.
+func (d *Document) Heading(text string) {
+	d.y -= 8
+	d.lines(headingStyle, margin, pageWidth-2*margin, text)
+	d.y -= 2
+}
+
+// Field adds a labelled value. Long values wrap within the value column.
+func (d *Document) Field(label, value string) {
+	width := float64(pageWidth - 2*margin - labelWidth)
+	wrapped := wrap(value, textStyle, width)
+	for i, line := range wrapped {
+		d.space(textStyle.leading)
+		if i == 0 {
+			d.text(labelStyle, margin, label)
+		}
+		d.text(textStyle, margin+labelWidth, line)
+	}
+}
+
+// Paragraph adds text across the full width, keeping its line breaks.
+func (d *Document) Paragraph(text string) {
+	d.lines(textStyle, margin, pageWidth-2*margin, text)
+	d.y -= 4
+}
+
+func (d *Document) lines(s style, x, width float64, text string) {
+	for _, line := range wrap(text, s, width) {
+		d.space(s.leading)
+		d.text(s, x, line)
+	}
+}
+
+// Bytes returns the finished PDF.
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are fixed; each page then takes a page and a content object
+	const firstPage = 5
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		content := page.String()
+		footer := fmt.Sprintf("%s    Page %d of %d", d.footer, i+1, len(d.pages))
+		content += fmt.Sprintf("BT /%s %g Tf %d %d Td (%s) Tj ET\n", smallStyle.font, smallStyle.size, margin, margin/2, escape(strings.TrimSpace(footer)))
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// escape encodes text as a PDF string literal body in WinAnsiEncoding.
+// Characters outside Latin-1 are replaced with "?".
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString("    ")
+		case r < 0x20 || r > 0xff || (r >= 0x7f && r < 0xa0):
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package pdf
+
+import "strings"
+
+// helveticaWidths are the advance widths of printable ASCII in Helvetica,
+// in thousandths of the font size, from the standard font metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// textWidth estimates the width of text in points. Bold text and
+// characters outside ASCII are approximated, erring on the wide side.
+func textWidth(text string, s style) float64 {
+	total := 0
+	for _, r := range text {
+		w := 600
+		if r >= 32 && r < 127 {
+			w = helveticaWidths[r-32]
+		}
+		total += w
+	}
+	width := float64(total) * s.size / 1000
+	if s.bold {
+		width *= 1.1
+	}
+	return width
+}
+
+// wrap breaks text into lines no wider than width, at spaces where
+// possible. Existing line breaks are kept; an empty text yields one empty
+// line.
+func wrap(text string, s style, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if textWidth(candidate, s) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Split words that are too long on their own, e.g. URLs
+			for textWidth(word, s) > width {
+				cut := fit(word, s, width)
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fit returns the byte length of the longest prefix of word that fits in
+// width, at least one character.
+func fit(word string, s style, width float64) int {
+	cut := 0
+	for i, r := range word {
+		end := i + len(string(r))
+		if cut > 0 && textWidth(word[:end], s) > width {
+			break
+		}
+		cut = end
+	}
+	return cut
+}
@@ -0,0 +1,89 @@
+// Package quiet holds back notifications during a form's quiet hours and
+// delivers them in one batch when the quiet hours end.
+package quiet
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Queue holds deliveries until they are due. Held deliveries are kept in
+// memory; those marked durable are on record elsewhere and held again after
+// a restart, the others must be flushed before the process stops.
+type Queue struct {
+	mu      sync.Mutex
+	pending []held
+}
+
+type held struct {
+	due     time.Time
+	durable bool
+	deliver func()
+}
+
+// NewQueue returns an empty queue.
+func NewQueue() *Queue {
+	return &Queue{}
+}
+
+// Hold schedules deliver to run once due has passed. durable tells that the
+// delivery outlives the process without the queue, so Flush leaves it.
+func (q *Queue) Hold(due time.Time, durable bool, deliver func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, held{due: due, durable: durable, deliver: deliver})
+}
+
+// Len returns the number of deliveries being held.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Release runs every delivery due at now, oldest first, and returns how
+// many ran.
+func (q *Queue) Release(now time.Time) int {
+	return q.run(func(h held) bool { return !now.Before(h.due) })
+}
+
+// Flush runs every delivery that is not durable right away, due or not,
+// and returns how many ran, so none is lost when the process stops.
+func (q *Queue) Flush() int {
+	return q.run(func(h held) bool { return !h.durable })
+}
+
+// run takes the deliveries selected by take from the queue and runs them,
+// oldest first.
+func (q *Queue) run(take func(held) bool) int {
+	q.mu.Lock()
+	var due, rest []held
+	for _, h := range q.pending {
+		if take(h) {
+			due = append(due, h)
+		} else {
+			rest = append(rest, h)
+		}
+	}
+	q.pending = rest
+	q.mu.Unlock()
+
+	// Deliver outside the lock, so submissions can still be held meanwhile
+	sort.SliceStable(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+	for _, h := range due {
+		h.deliver()
+	}
+	return len(due)
+}
+
+// ReleaseDue delivers the notifications whose quiet hours are over, as a
+// scheduled job.
+func (q *Queue) ReleaseDue(ctx context.Context) error {
+	if n := q.Release(time.Now()); n > 0 {
+		log.Printf("Quiet hours over, delivered %d held notification(s)", n)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package quiet
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	base := time.Date(2025, 3, 1, 22, 0, 0, 0, time.UTC)
+	morning := base.Add(9 * time.Hour)
+	tests := []struct {
+		name string
+		run  func(q *Queue) int
+		want []string
+	}{
+		{"before the end", func(q *Queue) int { return q.Release(base) }, nil},
+		{"at the end", func(q *Queue) int { return q.Release(morning) }, []string{"memory", "durable"}},
+		{"much later", func(q *Queue) int { return q.Release(morning.Add(2 * time.Hour)) }, []string{"memory", "durable", "later"}},
+		{"flush", func(q *Queue) int { return q.Flush() }, []string{"memory", "later"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue()
+			var ran []string
+			hold := func(name string, due time.Time, durable bool) {
+				q.Hold(due, durable, func() { ran = append(ran, name) })
+			}
+			hold("later", morning.Add(time.Hour), false)
+			hold("memory", morning, false)
+			hold("durable", morning, true)
+
+			if n := tt.run(q); n != len(tt.want) || !slices.Equal(ran, tt.want) {
+				t.Errorf("ran %d: %v, want %v", n, ran, tt.want)
+			}
+			if q.Len() != 3-len(tt.want) {
+				t.Errorf("%d still held, want %d", q.Len(), 3-len(tt.want))
+			}
+		})
+	}
+}
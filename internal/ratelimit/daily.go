@@ -0,0 +1,70 @@
+// Package ratelimit limits how often submissions are accepted.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// DailyCap allows each key a fixed number of uses per calendar day in loc.
+type DailyCap struct {
+	limit  int
+	loc    *time.Location
+	mu     sync.Mutex
+	day    string
+	counts map[string]int
+	clock  clock.Clock
+}
+
+// NewDailyCap returns a DailyCap allowing limit uses per key and day. Days
+// start at midnight in loc, or in the local time zone if loc is nil.
+func NewDailyCap(limit int, loc *time.Location) *DailyCap {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &DailyCap{
+		limit:  limit,
+		loc:    loc,
+		counts: make(map[string]int),
+		clock:  clock.System,
+	}
+}
+
+// UseClock makes c tell the time by clk instead of the system clock. It
+// must be called before c is used.
+func (c *DailyCap) UseClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Limit returns the uses per key and day c allows.
+func (c *DailyCap) Limit() int {
+	return c.limit
+}
+
+// Allow counts one use of key and reports whether it is within the cap.
+// Uses beyond the cap are not counted.
+func (c *DailyCap) Allow(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Start over when the day changes
+	if today := c.clock.Now().In(c.loc).Format(time.DateOnly); today != c.day {
+		c.day = today
+		c.counts = make(map[string]int)
+	}
+
+	if c.counts[key] >= c.limit {
+		return false
+	}
+	c.counts[key]++
+	return true
+}
+
+// UntilReset returns the time left until the caps reset at midnight.
+func (c *DailyCap) UntilReset() time.Duration {
+	now := c.clock.Now().In(c.loc)
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, c.loc).Sub(now)
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Lockout locks a key out after repeated failures. Once a key reaches the
+// threshold, every further failure doubles its lockout, up to a maximum. A
+// key's failures are forgotten after it stays quiet for that maximum.
+type Lockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*lockoutEntry
+	swept     time.Time
+	clock     clock.Clock
+}
+
+type lockoutEntry struct {
+	failures int
+	until    time.Time
+	last     time.Time
+}
+
+// NewLockout locks keys out for base after threshold failures, then twice
+// as long after each further failure, but never longer than max.
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		entries:   make(map[string]*lockoutEntry),
+		swept:     time.Now(),
+		clock:     clock.System,
+	}
+}
+
+// UseClock makes l tell the time by c instead of the system clock. It must
+// be called before l is used.
+func (l *Lockout) UseClock(c clock.Clock) {
+	l.clock = c
+	l.swept = c.Now()
+}
+
+// Locked reports whether key is locked out and, if so, for how much longer.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return false, 0
+	}
+	if wait := e.until.Sub(l.clock.Now()); wait > 0 {
+		return true, wait
+	}
+	return false, 0
+}
+
+// Fail counts a failure for key. It returns the number of failures so far
+// and the lockout the failure started, 0 if below the threshold.
+func (l *Lockout) Fail(key string) (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || l.expired(e, now) {
+		e = &lockoutEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+
+	if e.failures < l.threshold {
+		return e.failures, 0
+	}
+	lockout := l.max
+	// Beyond 30 doublings any sane base exceeds max anyway
+	if doublings := e.failures - l.threshold; doublings < 30 {
+		lockout = min(l.base<<doublings, l.max)
+	}
+	e.until = now.Add(lockout)
+	return e.failures, lockout
+}
+
+// Reset forgets the failures of key, e.g. after it succeeded, and returns
+// how many there were.
+func (l *Lockout) Reset(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	delete(l.entries, key)
+	return e.failures
+}
+
+// expired reports whether e has been quiet long enough to start over.
+func (l *Lockout) expired(e *lockoutEntry, now time.Time) bool {
+	return now.After(e.until) && now.Sub(e.last) > l.max
+}
+
+// sweep drops expired entries so keys from a spread-out attack do not pile
+// up. It runs at most once a minute.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for key, e := range l.entries {
+		if l.expired(e, now) {
+			delete(l.entries, key)
+		}
+	}
+}
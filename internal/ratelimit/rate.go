@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Rate allows each key a steady number of uses per minute, with bursts of up
+// to a fixed size. It is a token bucket per key.
+type Rate struct {
+	perMinute float64
+	burst     float64
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	swept     time.Time
+	clock     clock.Clock
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRate returns a Rate allowing perMinute uses per key and minute, of
+// which up to burst may happen at once. A burst below 1 is raised to 1.
+func NewRate(perMinute, burst int) *Rate {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Rate{
+		perMinute: float64(perMinute),
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		swept:     time.Now(),
+		clock:     clock.System,
+	}
+}
+
+// UseClock makes r tell the time by c instead of the system clock. It must
+// be called before r is used.
+func (r *Rate) UseClock(c clock.Clock) {
+	r.clock = c
+	r.swept = c.Now()
+}
+
+// Limits returns the uses per minute and the burst r allows.
+func (r *Rate) Limits() (perMinute, burst int) {
+	return int(r.perMinute), int(r.burst)
+}
+
+// Allow counts one use of key and reports whether it is within the rate.
+// If not, it also returns how long until the next use would be allowed.
+func (r *Rate) Allow(key string) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	r.sweep(now)
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	b.tokens = r.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / r.perMinute * float64(time.Minute))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (r *Rate) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Minutes()*r.perMinute
+	if tokens > r.burst {
+		return r.burst
+	}
+	return tokens
+}
+
+// sweep drops the buckets of keys that have been idle long enough to be full
+// again, so they do not pile up. It runs at most once a minute.
+func (r *Rate) sweep(now time.Time) {
+	if now.Sub(r.swept) < time.Minute {
+		return
+	}
+	r.swept = now
+	for key, b := range r.buckets {
+		if r.refill(b, now) >= r.burst {
+			delete(r.buckets, key)
+		}
+	}
+}
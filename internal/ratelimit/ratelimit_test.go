@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+var start = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRate(t *testing.T) {
+	c := clock.NewManual(start)
+	r := NewRate(6, 2) // one use every 10s
+	r.UseClock(c)
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{"first of burst", 0, true, 0},
+		{"second of burst", 0, true, 0},
+		{"burst used up", 0, false, 10 * time.Second},
+		{"partly refilled", 4 * time.Second, false, 6 * time.Second},
+		{"refilled", 6 * time.Second, true, 0},
+		{"used up again", 0, false, 10 * time.Second},
+		{"idle refills up to burst", time.Hour, true, 0},
+		{"second after idling", 0, true, 0},
+		{"not beyond burst", 0, false, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Advance(tt.advance)
+			allowed, wait := r.Allow("192.0.2.1")
+			// Tokens are fractional, so waits may be off by a rounding error
+			wait = wait.Round(time.Millisecond)
+			if allowed != tt.allowed || wait != tt.wait {
+				t.Errorf("Allow = %v, %v; want %v, %v", allowed, wait, tt.allowed, tt.wait)
+			}
+		})
+	}
+	if allowed, _ := r.Allow("192.0.2.2"); !allowed {
+		t.Error("other key shares the bucket")
+	}
+}
+
+func TestDailyCap(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 22:30 in Berlin
+	c := clock.NewManual(time.Date(2025, 3, 1, 21, 30, 0, 0, time.UTC))
+	cap := NewDailyCap(2, berlin)
+	cap.UseClock(c)
+
+	if got := cap.UntilReset(); got != 90*time.Minute {
+		t.Errorf("UntilReset = %v, want 1h30m", got)
+	}
+	tests := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		allowed bool
+	}{
+		{"first", 0, "ada@example.com", true},
+		{"second", 0, "ada@example.com", true},
+		{"over the cap", 0, "ada@example.com", false},
+		{"other key", 0, "bob@example.com", true},
+		{"before local midnight", 89 * time.Minute, "ada@example.com", false},
+		{"after local midnight", 2 * time.Minute, "ada@example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Advance(tt.advance)
+			if allowed := cap.Allow(tt.key); allowed != tt.allowed {
+				t.Errorf("Allow = %v, want %v", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	c := clock.NewManual(start)
+	l := NewLockout(3, time.Minute, 4*time.Minute)
+	l.UseClock(c)
+
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		failures int
+		lockout  time.Duration
+	}{
+		{"first failure", 0, 1, 0},
+		{"second failure", 0, 2, 0},
+		{"threshold", 0, 3, time.Minute},
+		{"doubled", time.Minute, 4, 2 * time.Minute},
+		{"doubled again", 2 * time.Minute, 5, 4 * time.Minute},
+		{"capped", 4 * time.Minute, 6, 4 * time.Minute},
+		{"forgotten after staying quiet", 9 * time.Minute, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Advance(tt.advance)
+			failures, lockout := l.Fail("192.0.2.1")
+			if failures != tt.failures || lockout != tt.lockout {
+				t.Errorf("Fail = %d, %v; want %d, %v", failures, lockout, tt.failures, tt.lockout)
+			}
+			if locked, wait := l.Locked("192.0.2.1"); locked != (tt.lockout > 0) || wait != tt.lockout {
+				t.Errorf("Locked = %v, %v; want locked for %v", locked, wait, tt.lockout)
+			}
+		})
+	}
+
+	if n := l.Reset("192.0.2.1"); n != 1 {
+		t.Errorf("Reset = %d, want 1", n)
+	}
+	if locked, _ := l.Locked("192.0.2.1"); locked {
+		t.Error("still locked after Reset")
+	}
+}
@@ -0,0 +1,84 @@
+// Package receipt signs submissions so their content and timestamp can later
+// be proven unaltered.
+package receipt
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"form2mail/internal/storage"
+)
+
+// Signer creates Ed25519 signatures over the canonical JSON of submissions.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner returns a Signer for the base64-encoded key, either a 32-byte
+// seed (e.g. from openssl rand -base64 32) or a 64-byte private key.
+func NewSigner(encoded string) (*Signer, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("receipt key is not valid base64: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return &Signer{key: ed25519.NewKeyFromSeed(raw)}, nil
+	case ed25519.PrivateKeySize:
+		return &Signer{key: ed25519.PrivateKey(raw)}, nil
+	default:
+		return nil, fmt.Errorf("receipt key must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+// PublicKey returns the base64-encoded public key that verifies receipts.
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// content is what a receipt covers. Field order is fixed and map keys are
+// sorted by encoding/json, so the same submission always encodes the same.
+type content struct {
+	ID         string            `json:"id"`
+	FormID     string            `json:"form_id"`
+	Name       string            `json:"name"`
+	Email      string            `json:"email"`
+	Subject    string            `json:"subject"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields"`
+	ClientIP   string            `json:"client_ip"`
+	ReceivedAt string            `json:"received_at"`
+}
+
+// Canonical returns the canonical JSON of sub that receipts sign.
+func Canonical(sub storage.Submission) []byte {
+	fields := sub.Fields
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	data, _ := json.Marshal(content{
+		ID:         sub.ID,
+		FormID:     sub.FormID,
+		Name:       sub.Name,
+		Email:      sub.Email,
+		Subject:    sub.Subject,
+		Message:    sub.Message,
+		Fields:     fields,
+		ClientIP:   sub.ClientIP,
+		ReceivedAt: sub.ReceivedAt.UTC().Format(time.RFC3339Nano),
+	})
+	return data
+}
+
+// Sign returns the receipt for sub: its canonical JSON and a detached
+// signature over it.
+func (s *Signer) Sign(sub storage.Submission) storage.Receipt {
+	payload := Canonical(sub)
+	return storage.Receipt{
+		Payload:   string(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, payload)),
+	}
+}
@@ -0,0 +1,94 @@
+package receipt
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"form2mail/internal/storage"
+)
+
+// seed is a fixed 32-byte key, base64-encoded.
+var seed = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestNewSigner(t *testing.T) {
+	full := base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef")))
+	tests := []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{"seed", seed, ""},
+		{"private key", full, ""},
+		{"not base64", "not base64!", "not valid base64"},
+		{"too short", base64.StdEncoding.EncodeToString([]byte("short")), "must be 32 or 64 bytes, got 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSigner(tt.key)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("NewSigner = %v, want an error mentioning %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, _ := NewSigner(seed); s.PublicKey() != want.PublicKey() {
+				t.Errorf("public key %s differs from the seed's", s.PublicKey())
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	sub := storage.Submission{
+		ID: "a1", Name: "Ada", Email: "ada@example.com", Message: "Hi <3",
+		Fields:     map[string]string{"zip": "10115", "company": "Acme"},
+		ClientIP:   "192.0.2.1",
+		ReceivedAt: time.Date(2025, 3, 1, 13, 0, 0, 500, berlin),
+		Tracking:   storage.Tracking{Tags: []string{"ignored"}},
+	}
+	want := `{"id":"a1","form_id":"","name":"Ada","email":"ada@example.com","subject":"","message":"Hi \u003c3",` +
+		`"fields":{"company":"Acme","zip":"10115"},"client_ip":"192.0.2.1","received_at":"2025-03-01T12:00:00.0000005Z"}`
+	if got := string(Canonical(sub)); got != want {
+		t.Errorf("Canonical =\n%s\nwant\n%s", got, want)
+	}
+	sub.Fields = nil
+	if got := string(Canonical(sub)); !strings.Contains(got, `"fields":{}`) {
+		t.Errorf("Canonical without fields = %s", got)
+	}
+}
+
+func TestSign(t *testing.T) {
+	s, err := NewSigner(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := storage.Submission{ID: "a1", Message: "Hello", ReceivedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	r := s.Sign(sub)
+	public, _ := base64.StdEncoding.DecodeString(s.PublicKey())
+	signature, _ := base64.StdEncoding.DecodeString(r.Signature)
+
+	tests := []struct {
+		name    string
+		payload string
+		valid   bool
+	}{
+		{"as signed", r.Payload, true},
+		{"recomputed", string(Canonical(sub)), true},
+		{"altered message", strings.Replace(r.Payload, "Hello", "Hallo", 1), false},
+		{"altered time", strings.Replace(r.Payload, "12:00", "13:00", 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := ed25519.Verify(public, []byte(tt.payload), signature); ok != tt.valid {
+				t.Errorf("signature valid = %v, want %v", ok, tt.valid)
+			}
+		})
+	}
+}
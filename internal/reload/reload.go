@@ -0,0 +1,81 @@
+// Package reload watches configuration files and reloads the parts of the
+// server that read them when their contents change.
+//
+// Files are polled by content rather than watched for events. Kubernetes
+// updates ConfigMap and Secret volumes by swapping a symlink to a new
+// directory, which changes neither the file's own inode nor, reliably, its
+// modification time; reading through the symlink sees the swap as soon as
+// it happens.
+package reload
+
+import (
+	"crypto/sha256"
+	"log"
+	"os"
+)
+
+// Watcher polls files and calls their reload functions on changes. Add all
+// files before scheduling Check.
+type Watcher struct {
+	watches []*watch
+}
+
+type watch struct {
+	name   string
+	path   string
+	reload func() error
+	sum    [sha256.Size]byte
+	// failed is the contents that last failed to load, so a broken file
+	// is reported once rather than on every check
+	failed [sha256.Size]byte
+}
+
+// NewWatcher returns a watcher without files.
+func NewWatcher() *Watcher {
+	return &Watcher{}
+}
+
+// Add watches the file at path, calling reload whenever its contents
+// change. name describes the file in log messages, e.g. "forms file". The
+// current contents count as loaded.
+func (w *Watcher) Add(name, path string, reload func() error) {
+	wt := &watch{name: name, path: path, reload: reload}
+	wt.sum, _ = checksum(path)
+	w.watches = append(w.watches, wt)
+}
+
+// Len returns the number of watched files.
+func (w *Watcher) Len() int {
+	return len(w.watches)
+}
+
+// Check reloads every file whose contents changed since the last check.
+// If a reload fails, the previous configuration stays active until the
+// file changes again.
+func (w *Watcher) Check() {
+	for _, wt := range w.watches {
+		sum, err := checksum(wt.path)
+		if err != nil {
+			// Volumes briefly lack the file while they are updated
+			continue
+		}
+		if sum == wt.sum || sum == wt.failed {
+			continue
+		}
+		if err := wt.reload(); err != nil {
+			log.Printf("Failed to reload %s, keeping the previous one: %v", wt.name, err)
+			wt.failed = sum
+			continue
+		}
+		wt.sum = sum
+		log.Printf("Reloaded %s", wt.name)
+	}
+}
+
+func checksum(path string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeBrowsing(t *testing.T) {
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "api key" {
+			http.Error(w, "API key not valid", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			ThreatInfo struct {
+				ThreatEntries []safeBrowsingEntry `json:"threatEntries"`
+			} `json:"threatInfo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requested = nil
+		for _, e := range req.ThreatInfo.ThreatEntries {
+			requested = append(requested, e.URL)
+		}
+		w.Write([]byte(`{"matches": [
+			{"threatType": "MALWARE", "threat": {"url": "https://evil.example/x"}},
+			{"threatType": "UNWANTED_SOFTWARE", "threat": {"url": "https://evil.example/x"}},
+			{"threatType": "SOCIAL_ENGINEERING", "threat": {"url": "https://phish.example"}}
+		]}`))
+	}))
+	defer srv.Close()
+
+	s := NewSafeBrowsing("api key", time.Second)
+	s.endpoint = srv.URL
+	urls := []string{"https://evil.example/x", "https://safe.example", "https://phish.example"}
+	threats, err := s.Scan(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(requested, urls) {
+		t.Errorf("looked up %q", requested)
+	}
+	want := []Threat{{"https://evil.example/x", "MALWARE"}, {"https://phish.example", "SOCIAL_ENGINEERING"}}
+	if !slices.Equal(threats, want) {
+		t.Errorf("threats %+v, want %+v", threats, want)
+	}
+
+	requested = nil
+	if threats, err := s.Scan(context.Background(), nil); err != nil || threats != nil || requested != nil {
+		t.Errorf("Scan without links = %v, %v", threats, err)
+	}
+
+	s = NewSafeBrowsing("wrong key", time.Second)
+	s.endpoint = srv.URL
+	if _, err := s.Scan(context.Background(), urls); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Scan with a wrong key = %v", err)
+	}
+}
+
+func TestSafeBrowsingKeepsKeyOutOfErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	s := NewSafeBrowsing("api key", time.Second)
+	s.endpoint = srv.URL
+	_, err := s.Scan(context.Background(), []string{"https://evil.example"})
+	if err == nil {
+		t.Fatal("Scan of an unreachable service succeeded")
+	}
+	if strings.Contains(err.Error(), "key") {
+		t.Errorf("error %q carries the API key", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package scan checks submission content for known-malicious links before
+// it reaches the owner's inbox.
+package scan
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxURLs limits how many links of a submission are checked.
+const maxURLs = 500
+
+// Threat is a link a scanner knows to be malicious.
+type Threat struct {
+	URL string
+	// Type is the kind of threat as named by the scanner, e.g. MALWARE.
+	Type string
+}
+
+// Scanner checks links with a reputation service. Implementations other
+// than SafeBrowsing plug in through handler.Options.
+type Scanner interface {
+	// Scan returns the threats among urls. An error means the service
+	// could not be asked.
+	Scan(ctx context.Context, urls []string) ([]Threat, error)
+}
+
+// urlPattern finds links in plain text; trailing punctuation is trimmed
+// separately.
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'` + "`" + `]+`)
+
+// URLs returns the distinct links in texts, in order of appearance and at
+// most maxURLs of them.
+func URLs(texts ...string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, u := range urlPattern.FindAllString(text, -1) {
+			u = strings.TrimRight(u, ".,;:!?)]}")
+			if seen[u] || len(urls) == maxURLs {
+				continue
+			}
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Defang rewrites the links of threats in text so mail clients do not
+// turn them into clickable links, e.g. https://evil.example/x becomes
+// hxxps://evil[.]example/x.
+func Defang(text string, threats []Threat) string {
+	// Longer links first, so a link is not broken up by defanging a prefix
+	urls := make([]string, len(threats))
+	for i, t := range threats {
+		urls[i] = t.URL
+	}
+	sort.Slice(urls, func(i, j int) bool { return len(urls[i]) > len(urls[j]) })
+	for _, u := range urls {
+		text = strings.ReplaceAll(text, u, defang(u))
+	}
+	return text
+}
+
+func defang(u string) string {
+	scheme, rest, _ := strings.Cut(u, "://")
+	host, path, hasPath := strings.Cut(rest, "/")
+	defanged := strings.Replace(strings.ToLower(scheme), "t", "x", 2) + "://" + strings.ReplaceAll(host, ".", "[.]")
+	if hasPath {
+		defanged += "/" + path
+	}
+	return defanged
+}
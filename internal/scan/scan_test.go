@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestURLs(t *testing.T) {
+	got := URLs(
+		"Download at https://evil.example/setup.exe, or (see http://mirror.example/a?b=c).",
+		`<a href="HTTPS://Files.example/x">again</a> https://evil.example/setup.exe`,
+		"ftp://not.example and www.not.example are not links",
+	)
+	want := []string{"https://evil.example/setup.exe", "http://mirror.example/a?b=c", "HTTPS://Files.example/x"}
+	if !slices.Equal(got, want) {
+		t.Errorf("URLs = %q, want %q", got, want)
+	}
+
+	var many strings.Builder
+	for i := range maxURLs + 10 {
+		fmt.Fprintf(&many, "https://%d.example/ ", i)
+	}
+	if n := len(URLs(many.String())); n != maxURLs {
+		t.Errorf("found %d links, want at most %d", n, maxURLs)
+	}
+}
+
+func TestDefang(t *testing.T) {
+	threats := []Threat{
+		{URL: "https://evil.example", Type: "MALWARE"},
+		{URL: "https://evil.example/login.php", Type: "SOCIAL_ENGINEERING"},
+		{URL: "HTTP://Phish.example", Type: "SOCIAL_ENGINEERING"},
+	}
+	text := "Sign in at https://evil.example/login.php or https://evil.example, or HTTP://Phish.example. https://safe.example stays."
+	want := "Sign in at hxxps://evil[.]example/login.php or hxxps://evil[.]example, or hxxp://Phish[.]example. https://safe.example stays."
+	if got := Defang(text, threats); got != want {
+		t.Errorf("Defang =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+// Package schedule runs the background jobs of one instance, such as
+// digests, retention purges, and health probes, on interval or cron
+// schedules and keeps the outcome of their latest runs for /admin/status.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a job runs next.
+type Schedule interface {
+	// Next returns the first run after t, or the zero time if there is
+	// none.
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// Every runs a job every d, starting when the scheduler does.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
+
+func (e every) String() string { return "@every " + time.Duration(e).String() }
+
+// Daily runs a job every day at hour (0-23) in loc.
+func Daily(hour int, loc *time.Location) Schedule {
+	c, err := parseCron(fmt.Sprintf("0 %d * * *", hour), loc)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Parse reads "@every <duration>", "@hourly", "@daily", "@weekly", or a
+// five-field cron expression (minute, hour, day of month, month, day of
+// week) evaluated in loc.
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	}
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval in schedule %q", spec)
+		}
+		return Every(interval), nil
+	}
+	return parseCron(spec, loc)
+}
+
+// cron matches times by minute, hour, day of month, month, and weekday,
+// each a bit set.
+type cron struct {
+	spec                          string
+	loc                           *time.Location
+	minute, hour, dom, month, dow uint64
+	anyDOM, anyDOW                bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(spec string, loc *time.Location) (*cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("schedule %q must have five fields or start with @", spec)
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %s: %w", spec, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	c := &cron{
+		spec:   spec,
+		loc:    loc,
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		anyDOM: fields[2] == "*",
+		anyDOW: fields[4] == "*",
+	}
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule %q never matches", spec)
+	}
+	return c, nil
+}
+
+// parseCronField reads lists of *, n, n-m, each optionally with /step.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("%q is outside %d-%d", rng, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// maxCronSteps bounds the search for the next run; a matching minute is
+// found within a few hundred steps unless the expression never matches,
+// like February 30.
+const maxCronSteps = 10000
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	for range maxCronSteps {
+		y, mo, d := t.Date()
+		h, mi := t.Hour(), t.Minute()
+		switch {
+		case c.month&(1<<mo) == 0:
+			t = time.Date(y, mo+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.matchesDay(t):
+			t = time.Date(y, mo, d+1, 0, 0, 0, 0, c.loc)
+		case c.hour&(1<<h) == 0:
+			t = time.Date(y, mo, d, h+1, 0, 0, 0, c.loc)
+		case c.minute&(1<<mi) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay follows cron: when both the day of month and the weekday are
+// restricted, either may match.
+func (c *cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	switch {
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (c *cron) String() string { return c.spec }
@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+func TestParseNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// A Saturday
+	from := time.Date(2025, 3, 1, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		spec string
+		loc  *time.Location
+		want []time.Time
+	}{
+		{"@every 90m", time.UTC, []time.Time{from.Add(90 * time.Minute), from.Add(180 * time.Minute)}},
+		{"@hourly", time.UTC, []time.Time{time.Date(2025, 3, 1, 13, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC)}},
+		{"@daily", time.UTC, []time.Time{time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)}},
+		{"@weekly", time.UTC, []time.Time{time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)}},
+		{"*/20 * * * *", time.UTC, []time.Time{time.Date(2025, 3, 1, 12, 40, 0, 0, time.UTC), time.Date(2025, 3, 1, 13, 0, 0, 0, time.UTC)}},
+		{"0 8 * * 1-5", time.UTC, []time.Time{time.Date(2025, 3, 3, 8, 0, 0, 0, time.UTC), time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC)}},
+		{"0 9 * * 7", time.UTC, []time.Time{time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC), time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC)}},
+		{"15 10 1,15 * *", time.UTC, []time.Time{time.Date(2025, 3, 15, 10, 15, 0, 0, time.UTC), time.Date(2025, 4, 1, 10, 15, 0, 0, time.UTC)}},
+		{"0 0 13 * 5", time.UTC, []time.Time{time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC)}},
+		{"0 0 29 2 *", time.UTC, []time.Time{time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2032, 2, 29, 0, 0, 0, 0, time.UTC)}},
+		{"30 2 * * *", berlin, []time.Time{time.Date(2025, 3, 2, 1, 30, 0, 0, time.UTC), time.Date(2025, 3, 3, 1, 30, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at := from
+			for i, want := range tt.want {
+				at = s.Next(at)
+				if !at.Equal(want) {
+					t.Fatalf("run %d at %v, want %v", i+1, at.UTC(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"@every", "five fields"},
+		{"@every soon", "invalid interval"},
+		{"@every -5m", "invalid interval"},
+		{"@monthly", "five fields"},
+		{"0 8 * *", "five fields"},
+		{"60 * * * *", "minute"},
+		{"0 24 * * *", "hour"},
+		{"0 0 0 * *", "day of month"},
+		{"0 0 * 13 *", "month"},
+		{"0 0 * * 8", "day of week"},
+		{"*/0 * * * *", "invalid step"},
+		{"5-1 * * * *", "outside"},
+		{"a * * * *", "invalid value"},
+		{"0 0 30 2 *", "never matches"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec, time.UTC)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaily(t *testing.T) {
+	s := Daily(6, time.UTC)
+	if got := s.Next(time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2025, 3, 2, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next = %v", got)
+	}
+	if s.String() != "0 6 * * *" {
+		t.Errorf("String = %q", s.String())
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s := New(time.Millisecond)
+	s.UseJitter(clock.SeededJitter(1))
+	var ok, failing atomic.Int32
+	s.Add("ok", Every(10*time.Millisecond), func(ctx context.Context) error {
+		ok.Add(1)
+		return nil
+	})
+	s.Add("failing", Every(10*time.Millisecond), func(ctx context.Context) error {
+		failing.Add(1)
+		return errors.New("boom")
+	})
+	s.Add("later", Every(time.Hour), func(ctx context.Context) error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for ok.Load() < 2 || failing.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("jobs did not run twice")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	jobs := s.Jobs()
+	if len(jobs) != 3 || jobs[0].Name != "failing" || jobs[1].Name != "later" || jobs[2].Name != "ok" {
+		t.Fatalf("Jobs = %+v", jobs)
+	}
+	if jobs[0].LastError != "boom" || jobs[2].LastError != "" || jobs[2].Runs < 2 || jobs[2].Schedule != "@every 10ms" {
+		t.Errorf("Jobs = %+v", jobs)
+	}
+	if jobs[1].Runs != 1 || jobs[1].NextRun.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("hourly job: %+v, want one run right away and the next in an hour", jobs[1])
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("adding a job twice did not panic")
+		}
+	}()
+	s.Add("ok", Every(time.Hour), nil)
+}
@@ -0,0 +1,140 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Func is the work of a job. Returned errors are logged and shown in the
+// job's status.
+type Func func(ctx context.Context) error
+
+// Scheduler runs jobs on their schedules, each in its own goroutine, so a
+// slow job delays only its own next run. It is safe for concurrent use.
+type Scheduler struct {
+	jitter time.Duration
+	random *clock.Jitter
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started context.Context
+}
+
+type job struct {
+	name     string
+	schedule Schedule
+	run      Func
+	status   Status
+}
+
+// Status is the state of a job as shown in /admin/status.
+type Status struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Running  bool   `json:"running"`
+	Runs     int    `json:"runs"`
+	// LastError is the error of the latest run, if it failed.
+	LastError string        `json:"last_error,omitempty"`
+	LastRun   time.Time     `json:"last_run,omitzero"`
+	LastTook  time.Duration `json:"last_duration_ns,omitempty"`
+	NextRun   time.Time     `json:"next_run,omitzero"`
+}
+
+// New returns a scheduler that delays each run by a random duration of up
+// to jitter, so instances sharing a schedule do not all run at once.
+func New(jitter time.Duration) *Scheduler {
+	return &Scheduler{jitter: jitter, random: clock.SystemJitter, jobs: make(map[string]*job)}
+}
+
+// UseJitter makes the scheduler draw the delays of runs from j instead of
+// a randomly seeded source. It must be called before Start.
+func (s *Scheduler) UseJitter(j *clock.Jitter) {
+	s.random = j
+}
+
+// Add registers run under name. Jobs added after Start begin right away.
+// Names must be unique.
+func (s *Scheduler) Add(name string, schedule Schedule, run Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		panic("schedule: duplicate job " + name)
+	}
+	j := &job{name: name, schedule: schedule, run: run, status: Status{Name: name, Schedule: schedule.String()}}
+	s.jobs[name] = j
+	if s.started != nil {
+		go s.loop(s.started, j)
+	}
+}
+
+// Start runs the jobs until ctx is done. Interval jobs run once right
+// away, cron jobs at their first matching time.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = ctx
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+// Jobs returns the status of all jobs sorted by name.
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j.status)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	return jobs
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	next := time.Now()
+	if _, ok := j.schedule.(every); !ok {
+		next = j.schedule.Next(next)
+	}
+	for !next.IsZero() {
+		at := next.Add(s.random.N(s.jitter))
+		s.update(j, func(st *Status) { st.NextRun = at })
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.update(j, func(st *Status) { st.Running = true })
+		started := time.Now()
+		err := j.run(ctx)
+		took := time.Since(started)
+		if err != nil {
+			log.Printf("Scheduled job %s failed: %v", j.name, err)
+		}
+		s.update(j, func(st *Status) {
+			st.Running = false
+			st.Runs++
+			st.LastRun = started
+			st.LastTook = took
+			st.LastError = ""
+			if err != nil {
+				st.LastError = err.Error()
+			}
+		})
+		next = j.schedule.Next(time.Now())
+	}
+}
+
+func (s *Scheduler) update(j *job, change func(*Status)) {
+	s.mu.Lock()
+	change(&j.status)
+	s.mu.Unlock()
+}
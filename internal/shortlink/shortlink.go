@@ -0,0 +1,179 @@
+// Package shortlink keeps short codes for long links, such as the signed
+// download links in notifications, so the instance can serve them as
+// /s/{code} redirects until they expire or are revoked.
+package shortlink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// codeLength is the length of codes; 10 base62 characters are about 59
+// random bits, too many to guess.
+const codeLength = 10
+
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrNotFound is returned for unknown, expired, and revoked codes.
+var ErrNotFound = errors.New("short link not found")
+
+// Store keeps links in a directory, one file per code, so they survive
+// restarts.
+type Store struct {
+	dir string
+	ids *clock.IDs
+}
+
+type link struct {
+	Target  string    `json:"target"`
+	Expires time.Time `json:"expires"`
+}
+
+// Open creates dir if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create short link directory: %w", err)
+	}
+	return &Store{dir: dir, ids: clock.Random}, nil
+}
+
+// UseIDs draws codes from ids instead of crypto/rand, so tests get the same
+// links on every run.
+func (s *Store) UseIDs(ids *clock.IDs) {
+	s.ids = ids
+}
+
+// Shorten returns a new code for target, valid until expires.
+func (s *Store) Shorten(target string, expires time.Time) (string, error) {
+	data, err := json.Marshal(link{Target: target, Expires: expires})
+	if err != nil {
+		return "", err
+	}
+	code, err := s.newCode()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, code), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to store short link: %w", err)
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to store short link: %w", err)
+	}
+	return code, nil
+}
+
+// Resolve returns the target of code if it has not expired at now.
+func (s *Store) Resolve(code string, now time.Time) (string, error) {
+	l, err := s.read(code)
+	if err != nil {
+		return "", err
+	}
+	if !now.Before(l.Expires) {
+		return "", ErrNotFound
+	}
+	return l.Target, nil
+}
+
+// Revoke deletes code, so it no longer resolves.
+func (s *Store) Revoke(code string) error {
+	if !validCode(code) {
+		return ErrNotFound
+	}
+	err := os.Remove(filepath.Join(s.dir, code))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Prune deletes links expired at now and returns how many.
+func (s *Store) Prune(now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		l, err := s.read(e.Name())
+		if err != nil || now.Before(l.Expires) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// PruneExpired deletes expired links and logs how many.
+func (s *Store) PruneExpired(ctx context.Context) error {
+	n, err := s.Prune(time.Now())
+	if n > 0 {
+		log.Printf("Deleted %d expired short link(s)", n)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune short links: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) read(code string) (link, error) {
+	if !validCode(code) {
+		return link{}, ErrNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, code))
+	if err != nil {
+		return link{}, ErrNotFound
+	}
+	var l link
+	if err := json.Unmarshal(data, &l); err != nil {
+		return link{}, ErrNotFound
+	}
+	return l, nil
+}
+
+// newCode draws a random code. Rejection sampling keeps the characters
+// uniformly distributed.
+func (s *Store) newCode() (string, error) {
+	code := make([]byte, 0, codeLength)
+	buf := make([]byte, codeLength)
+	for len(code) < codeLength {
+		if _, err := s.ids.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to draw short link code: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) < 256-256%len(alphabet) && len(code) < codeLength {
+				code = append(code, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+func validCode(code string) bool {
+	if len(code) != codeLength {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package shortlink
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UseIDs(clock.Seeded(1))
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	soon, err := s.Shorten("https://example.com/files/a?sig=1", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := s.Shorten("https://example.com/files/b?sig=2", now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validCode(soon) || !validCode(later) || soon == later {
+		t.Fatalf("codes %q and %q", soon, later)
+	}
+
+	tests := []struct {
+		name   string
+		code   string
+		at     time.Time
+		target string
+	}{
+		{"valid", soon, now, "https://example.com/files/a?sig=1"},
+		{"until it expires", soon, now.Add(time.Hour - time.Second), "https://example.com/files/a?sig=1"},
+		{"expired", soon, now.Add(time.Hour), ""},
+		{"other link", later, now.Add(2 * time.Hour), "https://example.com/files/b?sig=2"},
+		{"unknown", "AAAAAAAAAA", now, ""},
+		{"too short", soon[:9], now, ""},
+		{"path", "../../etc/", now, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := s.Resolve(tt.code, tt.at)
+			if tt.target == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Resolve = %q, %v; want ErrNotFound", target, err)
+				}
+				return
+			}
+			if err != nil || target != tt.target {
+				t.Errorf("Resolve = %q, %v; want %q", target, err, tt.target)
+			}
+		})
+	}
+
+	n, err := s.Prune(now.Add(2 * time.Hour))
+	if err != nil || n != 1 {
+		t.Errorf("Prune = %d, %v; want 1", n, err)
+	}
+	if _, err := s.Resolve(later, now); err != nil {
+		t.Errorf("Prune removed an unexpired link: %v", err)
+	}
+	if err := s.Revoke(later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Resolve(later, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoked link resolves: %v", err)
+	}
+	for _, code := range []string{later, "../x"} {
+		if err := s.Revoke(code); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Revoke(%q) = %v, want ErrNotFound", code, err)
+		}
+	}
+}
+
+func TestCodesRepeatWithSeededIDs(t *testing.T) {
+	codes := make([]string, 2)
+	for i := range codes {
+		s, err := Open(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.UseIDs(clock.Seeded(7))
+		if codes[i], err = s.Shorten("https://example.com", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if codes[0] != codes[1] {
+		t.Errorf("seeded codes %q and %q differ", codes[0], codes[1])
+	}
+}
+
+func TestPruneSkipsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "BROKEN0000"), []byte("{"), 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0o600)
+	if n, err := s.Prune(time.Now()); n != 0 || err != nil {
+		t.Errorf("Prune = %d, %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("Prune removed a file that is not a link")
+	}
+}
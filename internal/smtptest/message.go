@@ -0,0 +1,90 @@
+package smtptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// Message is a message received by the server.
+type Message struct {
+	// User is the name the client authenticated as, if any.
+	User string
+	// From and To are the envelope addresses.
+	From string
+	To   []string
+	// FromParams and ToParams are the parameters after the envelope
+	// addresses, e.g. "RET=HDRS ENVID=...".
+	FromParams string
+	ToParams   []string
+	// Data is the raw message as sent, with CRLF line endings.
+	Data []byte
+}
+
+// Parse parses the raw message.
+func (m Message) Parse() (*mail.Message, error) {
+	return mail.ReadMessage(bytes.NewReader(m.Data))
+}
+
+// Header returns the decoded value of the header key, e.g. an encoded
+// Subject in plain text. It returns "" if the message cannot be parsed.
+func (m Message) Header(key string) string {
+	msg, err := m.Parse()
+	if err != nil {
+		return ""
+	}
+	value := msg.Header.Get(key)
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// Text returns the first text part of the message, e.g. the HTML body,
+// with its transfer encoding undone.
+func (m Message) Text() (string, error) {
+	msg, err := m.Parse()
+	if err != nil {
+		return "", err
+	}
+	return textPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+}
+
+func textPart(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextRawPart()
+			if err != nil {
+				return "", errors.New("message has no text part")
+			}
+			text, err := textPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err == nil {
+				return text, nil
+			}
+		}
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return "", errors.New("message has no text part")
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
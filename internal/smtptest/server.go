@@ -0,0 +1,324 @@
+// Package smtptest runs an in-process SMTP server that records the messages
+// it receives, so the whole delivery path can be exercised end to end
+// without an external mail server.
+package smtptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a minimal SMTP server on a loopback port. It supports EHLO, AUTH
+// LOGIN and PLAIN, SIZE, DSN, MAIL, RCPT, DATA, RSET, NOOP, and QUIT,
+// without TLS.
+type Server struct {
+	// User and Password, if set, are the only credentials accepted.
+	User     string
+	Password string
+	// MaxSize, if positive, is advertised with SIZE; larger messages are
+	// refused with 552 after DATA.
+	MaxSize int
+	// DSN, if set, advertises the DSN extension. Its parameters are only
+	// recorded; no notifications are sent.
+	DSN bool
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+	received chan struct{}
+	reject   string
+	rejected int
+}
+
+// NewServer starts a server on a free loopback port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SMTP server: %w", err)
+	}
+	s := &Server{listener: l, received: make(chan struct{}, 1)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host returns the server's IP address.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Port returns the server's port.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Reject makes the server answer every DATA command with reply, e.g.
+// "554 5.7.1 Message rejected", to simulate a failing provider. An empty
+// reply accepts messages again.
+func (s *Server) Reject(reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reply
+}
+
+// Rejected returns the number of messages refused because of Reject.
+func (s *Server) Rejected() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejected
+}
+
+// Messages returns the messages received so far, oldest first.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Reset forgets the messages received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// Wait returns the received messages once there are at least n, or an error
+// after timeout.
+func (s *Server) Wait(n int, timeout time.Duration) ([]Message, error) {
+	deadline := time.After(timeout)
+	for {
+		if messages := s.Messages(); len(messages) >= n {
+			return messages, nil
+		}
+		select {
+		case <-s.received:
+		case <-deadline:
+			return nil, fmt.Errorf("received %d of %d messages within %s", len(s.Messages()), n, timeout)
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *Server) record(m Message) {
+	s.mu.Lock()
+	s.messages = append(s.messages, m)
+	s.mu.Unlock()
+	select {
+	case s.received <- struct{}{}:
+	default:
+	}
+}
+
+// rejection returns the reply set by Reject, counting the message as
+// refused if there is one.
+func (s *Server) rejection() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reject != "" {
+		s.rejected++
+	}
+	return s.reject
+}
+
+// session speaks SMTP on conn until the client quits or disconnects.
+func (s *Server) session(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...any) bool {
+		return tp.PrintfLine(format, args...) == nil
+	}
+
+	var (
+		user string
+		msg  Message
+	)
+	if !reply("220 smtptest ESMTP ready") {
+		return
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			msg = Message{}
+			lines := []string{"smtptest", "8BITMIME"}
+			if s.MaxSize > 0 {
+				lines = append(lines, fmt.Sprintf("SIZE %d", s.MaxSize))
+			}
+			if s.DSN {
+				lines = append(lines, "DSN")
+			}
+			lines = append(lines, "AUTH LOGIN PLAIN")
+			reply("250-%s\r\n250 %s", strings.Join(lines[:len(lines)-1], "\r\n250-"), lines[len(lines)-1])
+		case "AUTH":
+			name, err := s.auth(tp, arg)
+			if err != nil {
+				reply("535 5.7.8 %v", err)
+				continue
+			}
+			user = name
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			if s.User != "" && user == "" {
+				reply("530 5.7.0 Authentication required")
+				continue
+			}
+			from, params := address(arg, "FROM:")
+			msg = Message{User: user, From: from, FromParams: params}
+			reply("250 2.1.0 OK")
+		case "RCPT":
+			to, params := address(arg, "TO:")
+			msg.To = append(msg.To, to)
+			msg.ToParams = append(msg.ToParams, params)
+			reply("250 2.1.5 OK")
+		case "DATA":
+			if len(msg.To) == 0 {
+				reply("503 5.5.1 No recipients")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			if rejection := s.rejection(); rejection != "" {
+				reply("%s", rejection)
+				continue
+			}
+			if s.MaxSize > 0 && len(data) > s.MaxSize {
+				reply("552 5.3.4 Message size exceeds fixed limit")
+				continue
+			}
+			msg.Data = toCRLF(data)
+			s.record(msg)
+			msg = Message{User: user}
+			reply("250 2.0.0 OK queued")
+		case "RSET":
+			msg = Message{User: user}
+			reply("250 2.0.0 OK")
+		case "NOOP":
+			reply("250 2.0.0 OK")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("502 5.5.2 Command not implemented")
+		}
+	}
+}
+
+// auth runs an AUTH exchange and returns the authenticated user.
+func (s *Server) auth(tp *textproto.Conn, arg string) (string, error) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var user, password string
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		if initial == "" {
+			var err error
+			if initial, err = challenge(tp, ""); err != nil {
+				return "", err
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		if err != nil {
+			return "", errors.New("invalid encoding")
+		}
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) != 3 {
+			return "", errors.New("invalid PLAIN response")
+		}
+		user, password = parts[1], parts[2]
+	case "LOGIN":
+		encoded := initial
+		if encoded == "" {
+			var err error
+			if encoded, err = challenge(tp, "Username:"); err != nil {
+				return "", err
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", errors.New("invalid encoding")
+		}
+		user = string(decoded)
+		if encoded, err = challenge(tp, "Password:"); err != nil {
+			return "", err
+		}
+		if decoded, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return "", errors.New("invalid encoding")
+		}
+		password = string(decoded)
+	default:
+		return "", fmt.Errorf("unsupported mechanism %q", mechanism)
+	}
+
+	if s.User != "" && (user != s.User || password != s.Password) {
+		return "", errors.New("invalid credentials")
+	}
+	return user, nil
+}
+
+// challenge sends a 334 prompt and returns the client's response.
+func challenge(tp *textproto.Conn, prompt string) (string, error) {
+	if err := tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt))); err != nil {
+		return "", err
+	}
+	line, err := tp.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if line == "*" {
+		return "", errors.New("authentication canceled")
+	}
+	return line, nil
+}
+
+// address splits a MAIL FROM:<...> or RCPT TO:<...> argument into the
+// mailbox and its parameters, such as BODY=8BITMIME.
+func address(arg, prefix string) (string, string) {
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = arg[len(prefix):]
+	}
+	arg, params, _ := strings.Cut(strings.TrimSpace(arg), " ")
+	return strings.Trim(arg, "<>"), params
+}
+
+// toCRLF restores the CRLF line endings textproto turns into LF.
+func toCRLF(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
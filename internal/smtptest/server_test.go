@@ -0,0 +1,159 @@
+package smtptest
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// send delivers data from ada@example.com to to as user, returning the
+// first error.
+func send(s *Server, user, password string, to []string, data string) error {
+	c, err := smtp.Dial(net.JoinHostPort(s.Host(), s.Port()))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if user != "" {
+		if err := c.Auth(smtp.PlainAuth("", user, password, s.Host())); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail("ada@example.com"); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func TestServer(t *testing.T) {
+	const data = "Subject: Hi\r\n\r\nHello\r\n"
+	tests := []struct {
+		name     string
+		setup    func(s *Server)
+		user     string
+		password string
+		to       []string
+		data     string
+		err      string
+	}{
+		{"accepted", nil, "", "", []string{"owner@example.com"}, data, ""},
+		{"several recipients", nil, "", "", []string{"owner@example.com", "copy@example.com"}, data, ""},
+		{"authenticated", func(s *Server) { s.User, s.Password = "form2mail", "secret" }, "form2mail", "secret", []string{"owner@example.com"}, data, ""},
+		{"wrong password", func(s *Server) { s.User, s.Password = "form2mail", "secret" }, "form2mail", "guess", []string{"owner@example.com"}, data, "535"},
+		{"authentication required", func(s *Server) { s.User, s.Password = "form2mail", "secret" }, "", "", []string{"owner@example.com"}, data, "530"},
+		{"rejected", func(s *Server) { s.Reject("554 5.7.1 Message rejected") }, "", "", []string{"owner@example.com"}, data, "554"},
+		{"too large", func(s *Server) { s.MaxSize = 10 }, "", "", []string{"owner@example.com"}, data, "552"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t)
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+			err := send(s, tt.user, tt.password, tt.to, tt.data)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("send = %v, want %s", err, tt.err)
+				}
+				if n := len(s.Messages()); n != 0 {
+					t.Errorf("refused message was recorded %d times", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages, err := s.Wait(1, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := messages[0]
+			if m.From != "ada@example.com" || strings.Join(m.To, ",") != strings.Join(tt.to, ",") || m.User != tt.user {
+				t.Errorf("envelope %q -> %q as %q", m.From, m.To, m.User)
+			}
+			if string(m.Data) != tt.data {
+				t.Errorf("data = %q, want %q", m.Data, tt.data)
+			}
+		})
+	}
+}
+
+func TestServerWaitAndReset(t *testing.T) {
+	s := newServer(t)
+	if _, err := s.Wait(1, 50*time.Millisecond); err == nil {
+		t.Error("Wait returned before any message arrived")
+	}
+	for range 2 {
+		if err := send(s, "", "", []string{"owner@example.com"}, "Subject: Hi\r\n\r\nHi\r\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if messages, err := s.Wait(2, time.Second); err != nil || len(messages) != 2 {
+		t.Fatalf("Wait = %d messages, %v", len(messages), err)
+	}
+	s.Reset()
+	if n := len(s.Messages()); n != 0 {
+		t.Errorf("Reset left %d messages", n)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		subject string
+		text    string
+	}{
+		{"plain", "Subject: Hi\r\n\r\nHello\r\n", "Hi", "Hello\r\n"},
+		{"encoded subject", "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n\r\nHi\r\n", "Grüße", "Hi\r\n"},
+		{"quoted-printable", "Subject: Hi\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n<p>Gr=C3=BC=C3=9Fe</p>", "Hi", "<p>Grüße</p>"},
+		{"multipart", "Subject: Hi\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"--b\r\nContent-Type: application/pdf\r\nContent-Transfer-Encoding: base64\r\n\r\nJVBERg==\r\n" +
+			"--b\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: base64\r\n\r\nSGVsbG8=\r\n--b--\r\n", "Hi", "Hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Message{Data: []byte(tt.data)}
+			if got := m.Header("Subject"); got != tt.subject {
+				t.Errorf("Subject = %q, want %q", got, tt.subject)
+			}
+			text, err := m.Text()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.text {
+				t.Errorf("Text = %q, want %q", text, tt.text)
+			}
+		})
+	}
+	if _, err := (Message{Data: []byte("Content-Type: image/png\r\n\r\nx")}).Text(); err == nil {
+		t.Error("Text of an image succeeded")
+	}
+}
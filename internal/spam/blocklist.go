@@ -0,0 +1,51 @@
+package spam
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Blocklist matches client IPs against addresses and networks known to
+// send only spam.
+type Blocklist struct {
+	prefixes []netip.Prefix
+}
+
+// ParseBlocklist reads single addresses like 203.0.113.7 and networks in
+// CIDR notation like 198.51.100.0/24 or 2001:db8::/32.
+func ParseBlocklist(entries []string) (*Blocklist, error) {
+	b := &Blocklist{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blocked IP %q", entry)
+			}
+			entry = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocked network %q", entry)
+		}
+		b.prefixes = append(b.prefixes, prefix.Masked())
+	}
+	return b, nil
+}
+
+// Contains reports whether ip is blocked. Addresses that cannot be parsed
+// are not.
+func (b *Blocklist) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range b.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
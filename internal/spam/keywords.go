@@ -0,0 +1,201 @@
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AnyLanguage tags the keyword list applied to submissions in every
+// language.
+const AnyLanguage = "*"
+
+// KeywordList holds the keywords typical of spam in one language.
+type KeywordList struct {
+	// Score is added for each keyword found.
+	Score int `json:"score"`
+	// Threshold replaces SPAM_THRESHOLD for submissions in the language,
+	// if set.
+	Threshold int      `json:"threshold,omitempty"`
+	Keywords  []string `json:"keywords"`
+}
+
+// Keywords holds keyword lists by language tag, e.g. "en", "ru", or "zh".
+type Keywords struct {
+	lists map[string]KeywordList
+}
+
+// LoadKeywords reads a JSON object mapping language tags, or AnyLanguage,
+// to keyword lists.
+func LoadKeywords(path string) (*Keywords, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spam keywords file: %w", err)
+	}
+	var raw map[string]KeywordList
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse spam keywords file: %w", err)
+	}
+	lists := make(map[string]KeywordList, len(raw))
+	for lang, list := range raw {
+		if list.Score <= 0 {
+			return nil, fmt.Errorf("spam keywords %q: score must be positive", lang)
+		}
+		if list.Threshold < 0 {
+			return nil, fmt.Errorf("spam keywords %q: threshold must not be negative", lang)
+		}
+		keywords := make([]string, 0, len(list.Keywords))
+		for _, keyword := range list.Keywords {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword == "" {
+				return nil, fmt.Errorf("spam keywords %q: empty keyword", lang)
+			}
+			keywords = append(keywords, keyword)
+		}
+		list.Keywords = keywords
+		lists[strings.ToLower(lang)] = list
+	}
+	return &Keywords{lists: lists}, nil
+}
+
+// Check adds the score of each keyword found in texts to score, from the
+// list for their language and the AnyLanguage list, and returns the
+// threshold of that language, or 0 if it has none. The language is told
+// by the script the texts are written in, falling back to lang (the
+// form's language) for Latin script and languages not recognized.
+func (k *Keywords) Check(score *Score, lang string, texts ...string) int {
+	text := strings.ToLower(strings.Join(texts, "\n"))
+	lang = Language(text, lang)
+
+	threshold := 0
+	for i, tag := range []string{lang, AnyLanguage} {
+		tag, list, ok := k.lookup(tag)
+		if !ok {
+			continue
+		}
+		if i == 0 {
+			threshold = list.Threshold
+		}
+		var found []string
+		for _, keyword := range list.Keywords {
+			if containsKeyword(text, keyword) {
+				found = append(found, keyword)
+			}
+		}
+		sort.Strings(found)
+		for _, keyword := range found {
+			score.Add(list.Score, fmt.Sprintf("keyword %q (%s)", keyword, tag))
+		}
+	}
+	return threshold
+}
+
+// lookup finds the list for a tag such as "pt-BR", falling back to its
+// language, and returns the tag it was found under.
+func (k *Keywords) lookup(tag string) (string, KeywordList, bool) {
+	tag = strings.ToLower(tag)
+	if list, ok := k.lists[tag]; ok {
+		return tag, list, true
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	list, ok := k.lists[lang]
+	return lang, list, ok
+}
+
+// scripts maps writing systems to the language they most likely indicate
+// in a contact form.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Hangul, "ko"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+}
+
+// Language guesses the language of text by the script most of its letters
+// are written in. Latin script, which most languages share, and texts
+// without letters give fallback. Japanese mixes kana with Han characters,
+// so any kana makes it Japanese.
+func Language(text, fallback string) string {
+	counts := make(map[string]int)
+	latin := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	lang, most := fallback, latin
+	for _, s := range scripts {
+		if n := counts[s.lang]; n > most {
+			lang, most = s.lang, n
+		}
+	}
+	return lang
+}
+
+// containsKeyword reports whether keyword occurs in text as a whole word
+// or phrase, so "sex" does not match "Sussex". Scripts written without
+// spaces, like Chinese, match anywhere.
+func containsKeyword(text, keyword string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], keyword)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(keyword)
+		if boundary(text[:start], keyword, false) && boundary(text[end:], keyword, true) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// boundary reports whether the keyword ends at a word boundary next to
+// rest, which follows the keyword if after is set and precedes it
+// otherwise.
+func boundary(rest, keyword string, after bool) bool {
+	var edge, next rune
+	var size int
+	if after {
+		edge, _ = utf8.DecodeLastRuneInString(keyword)
+		next, size = utf8.DecodeRuneInString(rest)
+	} else {
+		edge, _ = utf8.DecodeRuneInString(keyword)
+		next, size = utf8.DecodeLastRuneInString(rest)
+	}
+	if size == 0 || !wordRune(edge) || unspaced(edge) {
+		return true
+	}
+	return !wordRune(next)
+}
+
+func wordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+func unspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+}
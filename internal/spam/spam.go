@@ -0,0 +1,33 @@
+// Package spam scores submissions for signs of automated traffic.
+package spam
+
+import "sort"
+
+// Score collects the points a submission earned for looking like spam and
+// why.
+type Score struct {
+	Points  int
+	Reasons []string
+}
+
+// Add records points for reason.
+func (s *Score) Add(points int, reason string) {
+	s.Points += points
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// Trapped returns the names of the decoy fields present in fields, sorted.
+// Legitimate frontends never render decoys, so any submission carrying one
+// was filled in by a bot.
+func Trapped(fields map[string]string, traps []string) []string {
+	var found []string
+	seen := make(map[string]bool, len(traps))
+	for _, name := range traps {
+		if _, ok := fields[name]; ok && !seen[name] {
+			seen[name] = true
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
@@ -0,0 +1,148 @@
+package spam
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	b, err := ParseBlocklist([]string{"203.0.113.7", " 198.51.100.0/24 ", "2001:db8::/32", "::ffff:192.0.2.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"203.0.113.7", true},
+		{"203.0.113.8", false},
+		{"198.51.100.200", true},
+		{"198.51.101.1", false},
+		{"2001:db8:1::5", true},
+		{"2001:db9::1", false},
+		{"::ffff:203.0.113.7", true},
+		{"192.0.2.9", true},
+		{"fe80::1%eth0", false},
+		{"not an address", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := b.Contains(tt.ip); got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	for _, entry := range []string{"203.0.113", "198.51.100.0/33", "example.com"} {
+		if _, err := ParseBlocklist([]string{entry}); err == nil {
+			t.Errorf("ParseBlocklist accepted %q", entry)
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		text, fallback, want string
+	}{
+		{"Hello there", "en", "en"},
+		{"Guten Tag", "de", "de"},
+		{"Привет, как дела? Buy now", "en", "ru"},
+		{"你好，我想买东西", "en", "zh"},
+		{"こんにちは、東京です", "en", "ja"},
+		{"안녕하세요", "en", "ko"},
+		{"مرحبا", "en", "ar"},
+		{"12345 !!!", "fr", "fr"},
+		{"Hello there Привет", "en", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Language(tt.text, tt.fallback); got != tt.want {
+				t.Errorf("Language = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeKeywords(t *testing.T, data string) (*Keywords, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keywords.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadKeywords(path)
+}
+
+func TestKeywords(t *testing.T) {
+	k, err := writeKeywords(t, `{
+		"en": {"score": 3, "threshold": 6, "keywords": ["viagra", " Casino ", "sex", "seo services"]},
+		"ru": {"score": 5, "keywords": ["казино"]},
+		"zh": {"score": 4, "keywords": ["发票"]},
+		"*": {"score": 1, "keywords": ["crypto"]}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		lang      string
+		texts     []string
+		points    int
+		reasons   []string
+		threshold int
+	}{
+		{"clean", "en", []string{"Hello", "I need a quote"}, 0, nil, 6},
+		{"keywords case-insensitive", "en", []string{"Best CASINO and Viagra"}, 6, []string{`keyword "casino" (en)`, `keyword "viagra" (en)`}, 6},
+		{"whole words only", "en", []string{"Greetings from Sussex"}, 0, nil, 6},
+		{"phrase", "en", []string{"We offer SEO services."}, 3, []string{`keyword "seo services" (en)`}, 6},
+		{"across texts", "en", []string{"sex", "crypto"}, 4, []string{`keyword "sex" (en)`, `keyword "crypto" (*)`}, 6},
+		{"region falls back to language", "en-GB", []string{"casino"}, 3, []string{`keyword "casino" (en)`}, 6},
+		{"script decides the language", "en", []string{"Лучшее казино"}, 5, []string{`keyword "казино" (ru)`}, 0},
+		{"unspaced script matches anywhere", "en", []string{"我们提供发票服务"}, 4, []string{`keyword "发票" (zh)`}, 0},
+		{"unknown language", "fr", []string{"casino crypto"}, 1, []string{`keyword "crypto" (*)`}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var score Score
+			threshold := k.Check(&score, tt.lang, tt.texts...)
+			if score.Points != tt.points || !reflect.DeepEqual(score.Reasons, tt.reasons) || threshold != tt.threshold {
+				t.Errorf("Check = %d %q, threshold %d; want %d %q, threshold %d", score.Points, score.Reasons, threshold, tt.points, tt.reasons, tt.threshold)
+			}
+		})
+	}
+}
+
+func TestLoadKeywordsErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"not JSON", `{"en": `, "failed to parse"},
+		{"no score", `{"en": {"keywords": ["a"]}}`, "score must be positive"},
+		{"negative threshold", `{"en": {"score": 1, "threshold": -1, "keywords": ["a"]}}`, "threshold must not be negative"},
+		{"empty keyword", `{"en": {"score": 1, "keywords": [" "]}}`, "empty keyword"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := writeKeywords(t, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadKeywords = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := LoadKeywords(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadKeywords of a missing file succeeded")
+	}
+}
+
+func TestTrapped(t *testing.T) {
+	fields := map[string]string{"website": "", "fax": "123", "company": "Acme"}
+	if got := Trapped(fields, []string{"fax", "website", "url", "fax"}); !reflect.DeepEqual(got, []string{"fax", "website"}) {
+		t.Errorf("Trapped = %v", got)
+	}
+	if got := Trapped(fields, nil); got != nil {
+		t.Errorf("Trapped without traps = %v", got)
+	}
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Memory is a Store that keeps the most recent submissions in memory. It is
+// meant for tests and small deployments; everything is lost on restart.
+type Memory struct {
+	maxEntries  int
+	mu          sync.RWMutex
+	submissions []Submission
+}
+
+// NewMemory returns a Memory store holding at most maxEntries submissions.
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{maxEntries: maxEntries}
+}
+
+func (m *Memory) Save(ctx context.Context, sub Submission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.submissions = append(m.submissions, sub)
+	if m.maxEntries > 0 && len(m.submissions) > m.maxEntries {
+		m.submissions = m.submissions[len(m.submissions)-m.maxEntries:]
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, id string) (Submission, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, sub := range m.submissions {
+		if sub.ID == id {
+			return sub, nil
+		}
+	}
+	return Submission{}, ErrNotFound
+}
+
+func (m *Memory) List(ctx context.Context, filter Filter) ([]Submission, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Submission
+	skipped := 0
+	for i := len(m.submissions) - 1; i >= 0; i-- {
+		sub := m.submissions[i]
+		if !filter.Match(sub) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		result = append(result, sub)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *Memory) Count(ctx context.Context, filter Filter) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, sub := range m.submissions {
+		if filter.Match(sub) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) AddReply(ctx context.Context, id string, reply Reply) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.submissions {
+		if m.submissions[i].ID == id {
+			m.submissions[i].Replies = append(m.submissions[i].Replies, reply)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) AddDeliveryReport(ctx context.Context, id string, report DeliveryReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.submissions {
+		if m.submissions[i].ID == id {
+			m.submissions[i].DeliveryReports = append(m.submissions[i].DeliveryReports, report)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) UpdateTracking(ctx context.Context, id string, update func(*Tracking)) (Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.submissions {
+		if m.submissions[i].ID == id {
+			// Copy the tags so submissions handed out earlier don't change
+			tracking := m.submissions[i].Tracking
+			tracking.Tags = append([]string(nil), tracking.Tags...)
+			update(&tracking)
+			m.submissions[i].Tracking = tracking
+			return m.submissions[i], nil
+		}
+	}
+	return Submission{}, ErrNotFound
+}
+
+func (m *Memory) UpdateStatus(ctx context.Context, id string, status Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.submissions {
+		if m.submissions[i].ID == id {
+			m.submissions[i].Status = status
+			m.submissions[i].Failure = nil
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) RecordFailure(ctx context.Context, id string, failure Failure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.submissions {
+		if m.submissions[i].ID == id {
+			m.submissions[i].Status = StatusFailed
+			m.submissions[i].Failure = &failure
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) Trash(ctx context.Context, filter Filter, at time.Time) (int, error) {
+	return m.setDeleted(filter, at), nil
+}
+
+func (m *Memory) Untrash(ctx context.Context, filter Filter) (int, error) {
+	return m.setDeleted(filter, time.Time{}), nil
+}
+
+func (m *Memory) setDeleted(filter Filter, at time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for i := range m.submissions {
+		if filter.Match(m.submissions[i]) {
+			m.submissions[i].DeletedAt = at
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Memory) Delete(ctx context.Context, filter Filter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.submissions)
+	m.submissions = slices.DeleteFunc(m.submissions, filter.Match)
+	return n - len(m.submissions), nil
+}
+
+func (m *Memory) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.submissions)
+	m.submissions = slices.DeleteFunc(m.submissions, func(sub Submission) bool {
+		return sub.ReceivedAt.Before(before)
+	})
+	return n - len(m.submissions), nil
+}
@@ -0,0 +1,31 @@
+//go:build postgres
+
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// TestPostgres needs a database it may empty, named by
+// FORM2MAIL_TEST_POSTGRES_URL.
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("FORM2MAIL_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("FORM2MAIL_TEST_POSTGRES_URL is not set")
+	}
+	testStore(t, func(t *testing.T) Store {
+		store, err := OpenSQL(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		if _, err := store.db.Exec(`DELETE FROM submissions`); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,22 @@
+//go:build sqlite
+
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLite(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		store, err := OpenSQL(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "submissions.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
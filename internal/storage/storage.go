@@ -0,0 +1,271 @@
+// Package storage keeps accepted submissions so they can be browsed later.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Submission is a stored form submission.
+type Submission struct {
+	ID         string            `json:"id"`
+	FormID     string            `json:"form_id"`
+	Name       string            `json:"name"`
+	Email      string            `json:"email"`
+	Subject    string            `json:"subject"`
+	Message    string            `json:"message"`
+	ClientIP   string            `json:"client_ip"`
+	UserAgent  string            `json:"user_agent"`
+	Source     map[string]string `json:"source,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	ReceivedAt time.Time         `json:"received_at"`
+	Replies    []Reply           `json:"replies,omitempty"`
+	// Status is how far the notification to the site owner got.
+	Status Status `json:"status,omitempty"`
+	// Failure tells why the notification failed, if it did.
+	Failure *Failure `json:"failure,omitempty"`
+	// DeliveryReports are the delivery status notifications returned for
+	// the notification, if DSN_NOTIFY requests them.
+	DeliveryReports []DeliveryReport `json:"delivery_reports,omitempty"`
+	// Receipt proves the content and timestamp, if receipts are enabled.
+	Receipt *Receipt `json:"receipt,omitempty"`
+	// DeletedAt is when the submission was moved to the trash, zero while
+	// it is not.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+	Tracking
+}
+
+// Trashed reports whether the submission is in the trash.
+func (s Submission) Trashed() bool {
+	return !s.DeletedAt.IsZero()
+}
+
+// Status is the delivery state of a submission's notification.
+type Status string
+
+// Delivery states, in the order a submission normally passes through them.
+const (
+	StatusPending   Status = "pending"
+	StatusHeld      Status = "held"     // during the form's quiet hours
+	StatusDeferred  Status = "deferred" // greylisted, awaiting a retry
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+)
+
+// Failure is a failed delivery of a submission's notification.
+type Failure struct {
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+	// Transcript is the SMTP dialogue of the failed attempt, with
+	// credentials redacted, if SMTP_TRANSCRIPTS is enabled.
+	Transcript []string `json:"transcript,omitempty"`
+}
+
+// Receipt is a detached Ed25519 signature over the canonical JSON of a
+// submission, both base64 and as signed.
+type Receipt struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// Tracking is how the site owner follows up on a submission. It is set
+// through the admin API, never by the submitter.
+type Tracking struct {
+	Tags []string `json:"tags,omitempty"`
+	// AssignedTo names whoever takes care of the submission; free text.
+	AssignedTo string `json:"assigned_to,omitempty"`
+	// HandledAt is when the submission was marked handled, zero while open.
+	HandledAt time.Time `json:"handled_at,omitzero"`
+}
+
+// Handled reports whether the submission was marked handled.
+func (t Tracking) Handled() bool {
+	return !t.HandledAt.IsZero()
+}
+
+// HasTag reports whether the submission carries tag, ignoring case.
+func (t Tracking) HasTag(tag string) bool {
+	for _, have := range t.Tags {
+		if strings.EqualFold(have, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reply is a message the submitter sent in response to the confirmation.
+type Reply struct {
+	From       string    `json:"from"`
+	Subject    string    `json:"subject"`
+	Text       string    `json:"text"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// DeliveryReport is the outcome a delivery status notification reported
+// for one recipient of the notification.
+type DeliveryReport struct {
+	Recipient string `json:"recipient"`
+	// Action is one of failed, delayed, delivered, relayed, or expanded.
+	Action     string    `json:"action"`
+	Status     string    `json:"status,omitempty"`
+	Diagnostic string    `json:"diagnostic,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ErrNotFound is returned when a submission does not exist.
+var ErrNotFound = errors.New("submission not found")
+
+// Filter selects submissions for List. Zero values match everything.
+type Filter struct {
+	// ID matches the one submission with that ID.
+	ID     string
+	FormID string
+	// AnyForm disables FormID filtering, since "" is the default form's ID.
+	AnyForm bool
+	// Email matches the submitter's address case-insensitively.
+	Email string
+	// ClientIP matches the submitter's IP address exactly.
+	ClientIP string
+	// Tag matches submissions carrying the tag, ignoring case.
+	Tag string
+	// AssignedTo matches the assignee case-insensitively.
+	AssignedTo string
+	// Handled, if set, matches only handled or only open submissions.
+	Handled *bool
+	// Status matches the delivery state exactly.
+	Status Status
+	// Since and Until bound ReceivedAt (inclusive and exclusive).
+	Since time.Time
+	Until time.Time
+	// Trash selects submissions in the trash instead of the others.
+	Trash bool
+	// DeletedBefore matches submissions moved to the trash before it.
+	DeletedBefore time.Time
+	Limit         int
+	Offset        int
+}
+
+// Match reports whether sub passes the filter's conditions, ignoring
+// Limit and Offset.
+func (f Filter) Match(sub Submission) bool {
+	if f.ID != "" && sub.ID != f.ID {
+		return false
+	}
+	if !f.AnyForm && sub.FormID != f.FormID {
+		return false
+	}
+	if f.Email != "" && !strings.EqualFold(sub.Email, f.Email) {
+		return false
+	}
+	if f.ClientIP != "" && sub.ClientIP != f.ClientIP {
+		return false
+	}
+	if f.Tag != "" && !sub.HasTag(f.Tag) {
+		return false
+	}
+	if f.AssignedTo != "" && !strings.EqualFold(sub.AssignedTo, f.AssignedTo) {
+		return false
+	}
+	if f.Handled != nil && sub.Handled() != *f.Handled {
+		return false
+	}
+	if f.Status != "" && sub.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && sub.ReceivedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !sub.ReceivedAt.Before(f.Until) {
+		return false
+	}
+	if sub.Trashed() != f.Trash {
+		return false
+	}
+	if !f.DeletedBefore.IsZero() && !sub.DeletedAt.Before(f.DeletedBefore) {
+		return false
+	}
+	return true
+}
+
+// Store persists submissions. It is the contract every backend implements
+// and the admin features build on; implementations must be safe for
+// concurrent use.
+type Store interface {
+	Save(ctx context.Context, sub Submission) error
+	// Get returns the submission with id or ErrNotFound.
+	Get(ctx context.Context, id string) (Submission, error)
+	// List returns matching submissions, newest first.
+	List(ctx context.Context, filter Filter) ([]Submission, error)
+	// Count returns how many submissions match filter, ignoring its Limit
+	// and Offset.
+	Count(ctx context.Context, filter Filter) (int, error)
+	// AddReply records a reply to the submission with id or returns
+	// ErrNotFound.
+	AddReply(ctx context.Context, id string, reply Reply) error
+	// AddDeliveryReport records a delivery status notification for the
+	// submission with id or returns ErrNotFound.
+	AddDeliveryReport(ctx context.Context, id string, report DeliveryReport) error
+	// UpdateTracking applies update to the tracking state of the submission
+	// with id and returns the updated submission, or ErrNotFound.
+	UpdateTracking(ctx context.Context, id string, update func(*Tracking)) (Submission, error)
+	// UpdateStatus sets the delivery state of the submission with id,
+	// clearing a recorded failure, or returns ErrNotFound.
+	UpdateStatus(ctx context.Context, id string, status Status) error
+	// RecordFailure marks the submission with id as failed for the given
+	// reason or returns ErrNotFound.
+	RecordFailure(ctx context.Context, id string, failure Failure) error
+	// Trash moves the submissions matching filter to the trash at the
+	// given time, ignoring the filter's Limit and Offset, and returns how
+	// many were moved.
+	Trash(ctx context.Context, filter Filter, at time.Time) (int, error)
+	// Untrash takes the submissions matching filter, which selects from
+	// the trash, out of it again and returns how many.
+	Untrash(ctx context.Context, filter Filter) (int, error)
+	// Delete permanently deletes the submissions matching filter, ignoring
+	// its Limit and Offset, and returns how many were deleted.
+	Delete(ctx context.Context, filter Filter) (int, error)
+	// Purge deletes submissions received before the given time and returns
+	// how many were deleted.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// PurgeExpired deletes submissions older than retention from store and
+// logs how many.
+func PurgeExpired(ctx context.Context, store Store, retention time.Duration) error {
+	n, err := store.Purge(ctx, time.Now().Add(-retention))
+	if n > 0 {
+		log.Printf("Deleted %d expired submission(s)", n)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to purge submissions: %w", err)
+	}
+	return nil
+}
+
+// EmptyTrash permanently deletes submissions that have been in the trash
+// of store for longer than retention and logs how many.
+func EmptyTrash(ctx context.Context, store Store, retention time.Duration) error {
+	n, err := store.Delete(ctx, Filter{AnyForm: true, Trash: true, DeletedBefore: time.Now().Add(-retention)})
+	if n > 0 {
+		log.Printf("Deleted %d submission(s) from the trash", n)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to empty trash: %w", err)
+	}
+	return nil
+}
+
+// NewID returns a random, unguessable submission ID drawn from ids, or
+// from crypto/rand if ids is nil.
+func NewID(ids *clock.IDs) string {
+	if ids == nil {
+		ids = clock.Random
+	}
+	return ids.New(12)
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fixtures are saved oldest first, so every store lists them newest first
+// in the reverse order.
+func fixtures() []Submission {
+	return []Submission{
+		{ID: "a1", FormID: "", Email: "Ada@Example.com", ClientIP: "192.0.2.1", ReceivedAt: epoch, Status: StatusDelivered,
+			Tracking: Tracking{Tags: []string{"Sales", "urgent"}, AssignedTo: "Bob"}},
+		{ID: "b2", FormID: "support", Email: "bea@example.com", ClientIP: "192.0.2.2", ReceivedAt: epoch.Add(time.Hour), Status: StatusFailed,
+			Tracking: Tracking{Tags: []string{"bug"}, HandledAt: epoch.Add(2 * time.Hour)}},
+		{ID: "c3", FormID: "support", Email: "cid@example.com", ClientIP: "192.0.2.1", ReceivedAt: epoch.Add(2 * time.Hour), Status: StatusHeld},
+		{ID: "d4", FormID: "", Email: "ada@example.com", ClientIP: "192.0.2.3", ReceivedAt: epoch.Add(3 * time.Hour), Status: StatusDelivered,
+			Tracking: Tracking{AssignedTo: "bob"}},
+		{ID: "e5", FormID: "support", Email: "eve@example.com", ClientIP: "192.0.2.4", ReceivedAt: epoch.Add(4 * time.Hour), Status: StatusPending,
+			DeletedAt: epoch.Add(5 * time.Hour)},
+	}
+}
+
+func ids(subs []Submission) []string {
+	var result []string
+	for _, sub := range subs {
+		result = append(result, sub.ID)
+	}
+	return result
+}
+
+// testStore runs the Store contract against the stores newStore returns,
+// each empty.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	ctx := context.Background()
+	fill := func(t *testing.T) Store {
+		t.Helper()
+		store := newStore(t)
+		for _, sub := range fixtures() {
+			if err := store.Save(ctx, sub); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return store
+	}
+	yes, no := true, false
+
+	t.Run("List", func(t *testing.T) {
+		store := fill(t)
+		tests := []struct {
+			name   string
+			filter Filter
+			want   []string
+		}{
+			{"default form", Filter{}, []string{"d4", "a1"}},
+			{"any form", Filter{AnyForm: true}, []string{"d4", "c3", "b2", "a1"}},
+			{"form", Filter{FormID: "support"}, []string{"c3", "b2"}},
+			{"ID", Filter{AnyForm: true, ID: "b2"}, []string{"b2"}},
+			{"email ignores case", Filter{AnyForm: true, Email: "ADA@example.COM"}, []string{"d4", "a1"}},
+			{"client IP", Filter{AnyForm: true, ClientIP: "192.0.2.1"}, []string{"c3", "a1"}},
+			{"tag ignores case", Filter{AnyForm: true, Tag: "sales"}, []string{"a1"}},
+			{"second tag", Filter{AnyForm: true, Tag: "URGENT"}, []string{"a1"}},
+			{"unknown tag", Filter{AnyForm: true, Tag: "sale"}, nil},
+			{"assignee ignores case", Filter{AnyForm: true, AssignedTo: "BOB"}, []string{"d4", "a1"}},
+			{"handled", Filter{AnyForm: true, Handled: &yes}, []string{"b2"}},
+			{"open", Filter{AnyForm: true, Handled: &no}, []string{"d4", "c3", "a1"}},
+			{"status", Filter{AnyForm: true, Status: StatusDelivered}, []string{"d4", "a1"}},
+			{"since is inclusive", Filter{AnyForm: true, Since: epoch.Add(2 * time.Hour)}, []string{"d4", "c3"}},
+			{"until is exclusive", Filter{AnyForm: true, Until: epoch.Add(2 * time.Hour)}, []string{"b2", "a1"}},
+			{"trash", Filter{AnyForm: true, Trash: true}, []string{"e5"}},
+			{"deleted before", Filter{AnyForm: true, Trash: true, DeletedBefore: epoch.Add(5 * time.Hour)}, nil},
+			{"limit", Filter{AnyForm: true, Limit: 2}, []string{"d4", "c3"}},
+			{"offset", Filter{AnyForm: true, Offset: 3}, []string{"a1"}},
+			{"limit and offset", Filter{AnyForm: true, Limit: 2, Offset: 1}, []string{"c3", "b2"}},
+			{"offset past the end", Filter{AnyForm: true, Offset: 10}, nil},
+			{"limit after filter", Filter{AnyForm: true, Tag: "bug", Limit: 1}, []string{"b2"}},
+			{"combined", Filter{FormID: "support", ClientIP: "192.0.2.1", Handled: &no}, []string{"c3"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := store.List(ctx, tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(ids(got), tt.want) {
+					t.Errorf("List = %v, want %v", ids(got), tt.want)
+				}
+
+				unpaged := tt.filter
+				unpaged.Limit, unpaged.Offset = 0, 0
+				all, err := store.List(ctx, unpaged)
+				if err != nil {
+					t.Fatal(err)
+				}
+				n, err := store.Count(ctx, tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(all) {
+					t.Errorf("Count = %d, want %d", n, len(all))
+				}
+			})
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		store := fill(t)
+		got, err := store.Get(ctx, "a1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != "Ada@Example.com" || !got.ReceivedAt.Equal(epoch) || !slices.Equal(got.Tags, []string{"Sales", "urgent"}) {
+			t.Errorf("Get = %+v", got)
+		}
+		if _, err := store.Get(ctx, "zz"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get of a missing ID: %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("updates", func(t *testing.T) {
+		store := fill(t)
+		if err := store.RecordFailure(ctx, "c3", Failure{Error: "550 no such user", FailedAt: epoch}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AddReply(ctx, "c3", Reply{From: "cid@example.com", Text: "thanks"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AddDeliveryReport(ctx, "c3", DeliveryReport{Recipient: "owner@example.com", Action: "delayed"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.UpdateTracking(ctx, "c3", func(tr *Tracking) {
+			tr.Tags = append(tr.Tags, "Spam")
+			tr.HandledAt = epoch
+		}); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := store.Get(ctx, "c3")
+		if got.Status != StatusFailed || got.Failure == nil || len(got.Replies) != 1 || len(got.DeliveryReports) != 1 {
+			t.Errorf("updated submission = %+v", got)
+		}
+		if list, _ := store.List(ctx, Filter{AnyForm: true, Tag: "spam", Handled: &yes, Status: StatusFailed}); !slices.Equal(ids(list), []string{"c3"}) {
+			t.Errorf("updated tracking lists %v, want [c3]", ids(list))
+		}
+
+		if err := store.UpdateStatus(ctx, "c3", StatusDelivered); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := store.Get(ctx, "c3"); got.Status != StatusDelivered || got.Failure != nil {
+			t.Errorf("UpdateStatus left status %q, failure %v", got.Status, got.Failure)
+		}
+		for name, err := range map[string]error{
+			"AddReply":     store.AddReply(ctx, "zz", Reply{}),
+			"UpdateStatus": store.UpdateStatus(ctx, "zz", StatusDelivered),
+		} {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s of a missing ID: %v, want ErrNotFound", name, err)
+			}
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		store := fill(t)
+		at := epoch.Add(6 * time.Hour)
+		n, err := store.Trash(ctx, Filter{AnyForm: true, Email: "ada@example.com", Limit: 1}, at)
+		if err != nil || n != 2 {
+			t.Fatalf("Trash = %d, %v; want both of Ada's, ignoring the limit", n, err)
+		}
+		got, _ := store.Get(ctx, "a1")
+		if !got.DeletedAt.Equal(at) {
+			t.Errorf("trashed DeletedAt = %v, want %v", got.DeletedAt, at)
+		}
+		if got.AssignedTo != "Bob" || !slices.Equal(got.Tags, []string{"Sales", "urgent"}) {
+			t.Errorf("trashing lost the rest of the submission: %+v", got)
+		}
+		if list, _ := store.List(ctx, Filter{AnyForm: true, Trash: true}); !slices.Equal(ids(list), []string{"e5", "d4", "a1"}) {
+			t.Errorf("trash holds %v", ids(list))
+		}
+
+		n, err = store.Untrash(ctx, Filter{AnyForm: true, Trash: true, ID: "a1"})
+		if err != nil || n != 1 {
+			t.Fatalf("Untrash = %d, %v; want 1", n, err)
+		}
+		if got, _ := store.Get(ctx, "a1"); got.Trashed() {
+			t.Error("untrashed submission is still in the trash")
+		}
+		if list, _ := store.List(ctx, Filter{Tag: "sales"}); !slices.Equal(ids(list), []string{"a1"}) {
+			t.Errorf("untrashed submission lists as %v", ids(list))
+		}
+
+		n, err = store.Delete(ctx, Filter{AnyForm: true, Trash: true, DeletedBefore: epoch.Add(6 * time.Hour)})
+		if err != nil || n != 1 {
+			t.Fatalf("Delete = %d, %v; want only e5", n, err)
+		}
+		if _, err := store.Get(ctx, "e5"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleted submission: %v, want ErrNotFound", err)
+		}
+		if _, err := store.Get(ctx, "d4"); err != nil {
+			t.Errorf("submission trashed later was deleted: %v", err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		store := fill(t)
+		n, err := store.Purge(ctx, epoch.Add(2*time.Hour))
+		if err != nil || n != 2 {
+			t.Fatalf("Purge = %d, %v; want 2", n, err)
+		}
+		if list, _ := store.List(ctx, Filter{AnyForm: true}); !slices.Equal(ids(list), []string{"d4", "c3"}) {
+			t.Errorf("purge left %v", ids(list))
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewMemory(0) })
+}
+
+func TestMemoryMaxEntries(t *testing.T) {
+	store := NewMemory(2)
+	for _, sub := range fixtures() {
+		store.Save(context.Background(), sub)
+	}
+	if list, _ := store.List(context.Background(), Filter{AnyForm: true, Trash: true}); !slices.Equal(ids(list), []string{"e5"}) {
+		t.Errorf("trash holds %v", ids(list))
+	}
+	if list, _ := store.List(context.Background(), Filter{AnyForm: true}); !slices.Equal(ids(list), []string{"d4"}) {
+		t.Errorf("store keeps %v, want the two newest", ids(list))
+	}
+}
+
+func TestParseDatabaseURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		dialect string
+		dsn     string
+		ok      bool
+	}{
+		{"sqlite:///var/lib/form2mail/submissions.db", DialectSQLite, "/var/lib/form2mail/submissions.db", true},
+		{"sqlite:submissions.db", DialectSQLite, "submissions.db", true},
+		{"sqlite://", "", "", false},
+		{"postgres://u:p@db/form2mail", DialectPostgres, "postgres://u:p@db/form2mail", true},
+		{"postgresql://db/form2mail", DialectPostgres, "postgresql://db/form2mail", true},
+		{"mysql://db/form2mail", "", "", false},
+		{"submissions.db", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			dialect, dsn, err := parseDatabaseURL(tt.url)
+			if (err == nil) != tt.ok || dialect != tt.dialect || dsn != tt.dsn {
+				t.Errorf("parseDatabaseURL = %q, %q, %v", dialect, dsn, err)
+			}
+		})
+	}
+}
+
+func TestSQLQuery(t *testing.T) {
+	q := `UPDATE submissions SET status = ? WHERE id = ? AND data <> '$.x'`
+	if got := (&SQL{dialect: DialectSQLite}).query(q); got != q {
+		t.Errorf("SQLite query = %q", got)
+	}
+	want := `UPDATE submissions SET status = $1 WHERE id = $2 AND data <> '$.x'`
+	if got := (&SQL{dialect: DialectPostgres}).query(q); got != want {
+		t.Errorf("PostgreSQL query = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+// Package summary counts what happened to each form's submissions and mails
+// the owner a daily digest, so silent failures and spam storms get noticed.
+package summary
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome is what happened to a submission.
+type Outcome int
+
+// Outcomes recorded by the tracker.
+const (
+	Delivered Outcome = iota
+	Spam
+	Failed
+)
+
+// Counts are one form's outcomes on one day. Spam counts both flagged and
+// dropped submissions.
+type Counts struct {
+	Delivered int
+	Spam      int
+	Failed    int
+}
+
+// Mailer sends the summary email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Tracker counts outcomes per form and day, and sends daily summaries.
+type Tracker struct {
+	loc   *time.Location
+	forms []string
+
+	mu   sync.Mutex
+	days map[string]map[string]*Counts
+}
+
+// NewTracker returns a tracker whose days follow loc. Summaries always cover
+// forms, even on days without submissions; other forms appear only when
+// something happened.
+func NewTracker(loc *time.Location, forms ...string) *Tracker {
+	return &Tracker{loc: loc, forms: forms, days: make(map[string]map[string]*Counts)}
+}
+
+// Record counts an outcome for formID.
+func (t *Tracker) Record(formID string, outcome Outcome) {
+	day := time.Now().In(t.loc).Format(time.DateOnly)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	forms, ok := t.days[day]
+	if !ok {
+		forms = make(map[string]*Counts)
+		t.days[day] = forms
+	}
+	c, ok := forms[formID]
+	if !ok {
+		c = &Counts{}
+		forms[formID] = c
+	}
+	switch outcome {
+	case Delivered:
+		c.Delivered++
+	case Spam:
+		c.Spam++
+	case Failed:
+		c.Failed++
+	}
+}
+
+// take removes and returns the counts of day, including the always-covered
+// forms.
+func (t *Tracker) take(day string) map[string]Counts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string]Counts)
+	for _, formID := range t.forms {
+		result[formID] = Counts{}
+	}
+	for formID, c := range t.days[day] {
+		result[formID] = *c
+	}
+	// Also drop days that were missed, e.g. while the clock jumped
+	for d := range t.days {
+		if d <= day {
+			delete(t.days, d)
+		}
+	}
+	return result
+}
+
+// Send mails the previous day's summary to recipient, one email per form.
+// It runs as a scheduled job, daily at DAILY_SUMMARY_HOUR by default.
+func (t *Tracker) Send(mailer Mailer, recipient string) error {
+	day := time.Now().In(t.loc).AddDate(0, 0, -1).Format(time.DateOnly)
+	counts := t.take(day)
+	formIDs := make([]string, 0, len(counts))
+	for formID := range counts {
+		formIDs = append(formIDs, formID)
+	}
+	sort.Strings(formIDs)
+	var errs []error
+	for _, formID := range formIDs {
+		subject, body := render(formID, day, counts[formID])
+		if err := mailer.Send(recipient, subject, body); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send daily summary for %s: %w", formName(formID), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// formName is how formID is shown to the owner.
+func formName(formID string) string {
+	if formID == "" {
+		return "/contact"
+	}
+	return formID
+}
+
+func render(formID, day string, c Counts) (subject, body string) {
+	parts := []string{plural(c.Delivered, "submission", "submissions")}
+	if c.Spam > 0 {
+		parts = append(parts, fmt.Sprintf("%d marked as spam", c.Spam))
+	}
+	if c.Failed > 0 {
+		parts = append(parts, plural(c.Failed, "delivery failure", "delivery failures"))
+	}
+	line := strings.Join(parts, ", ")
+
+	subject = fmt.Sprintf("Daily summary for %s: %s", formName(formID), line)
+	body = fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Daily summary for %s</h2>
+			<p>%s</p>
+			<p><strong>Delivered:</strong> %d</p>
+			<p><strong>Spam:</strong> %d</p>
+			<p><strong>Delivery failures:</strong> %d</p>
+		</body>
+		</html>
+	`, html.EscapeString(formName(formID)), html.EscapeString(day+": "+line), c.Delivered, c.Spam, c.Failed)
+	return subject, body
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
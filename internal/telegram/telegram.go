@@ -0,0 +1,153 @@
+// Package telegram posts submissions and operator alerts to a Telegram chat
+// through a bot.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"form2mail/internal/storage"
+)
+
+// apiURL is the Bot API the messages are sent through.
+const apiURL = "https://api.telegram.org"
+
+// maxMessage caps the submission message quoted in the chat, keeping the
+// whole text below Telegram's limit of 4096 characters.
+const maxMessage = 3000
+
+// Bot sends messages to one chat.
+type Bot struct {
+	token  string
+	chatID string
+	// silent delivers messages without a notification sound
+	silent bool
+	client *http.Client
+	api    string
+	dryRun bool
+}
+
+// New returns a Bot sending to chatID with the token from @BotFather. In
+// dry-run mode messages are logged instead of sent.
+func New(token, chatID string, silent, dryRun bool) *Bot {
+	return &Bot{
+		token:  token,
+		chatID: chatID,
+		silent: silent,
+		client: &http.Client{Timeout: 10 * time.Second},
+		api:    apiURL,
+		dryRun: dryRun,
+	}
+}
+
+// UseTransport makes b call the Bot API through rt instead of
+// http.DefaultTransport. It must be called before b is used.
+func (b *Bot) UseTransport(rt http.RoundTripper) {
+	b.client.Transport = rt
+}
+
+// Notify sends an operator alert, so the bot can stand in for email and
+// Slack as an alert.Notifier.
+func (b *Bot) Notify(subject, text string) error {
+	return b.send(context.Background(), "<b>"+html.EscapeString(subject)+"</b>\n"+html.EscapeString(text))
+}
+
+// SendSubmission posts sub to the chat: who sent it, the subject, the
+// message, and the form's extra fields.
+func (b *Bot) SendSubmission(ctx context.Context, sub storage.Submission) error {
+	return b.send(ctx, Format(sub))
+}
+
+// Format returns the HTML text a submission is posted as. Everything the
+// submitter entered is escaped, so it cannot add markup or links.
+func Format(sub storage.Submission) string {
+	var text strings.Builder
+	text.WriteString("<b>New submission</b>")
+	if sub.FormID != "" {
+		fmt.Fprintf(&text, " to <code>%s</code>", html.EscapeString(sub.FormID))
+	}
+	fmt.Fprintf(&text, "\n<b>From:</b> %s &lt;%s&gt;", html.EscapeString(sub.Name), html.EscapeString(sub.Email))
+	if sub.Subject != "" {
+		fmt.Fprintf(&text, "\n<b>Subject:</b> %s", html.EscapeString(sub.Subject))
+	}
+
+	message := sub.Message
+	if utf8.RuneCountInString(message) > maxMessage {
+		message = string([]rune(message)[:maxMessage]) + "…"
+	}
+	fmt.Fprintf(&text, "\n\n%s", html.EscapeString(message))
+
+	if len(sub.Fields) > 0 {
+		text.WriteString("\n")
+		names := make([]string, 0, len(sub.Fields))
+		for name := range sub.Fields {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(&text, "\n<b>%s:</b> %s", html.EscapeString(name), html.EscapeString(sub.Fields[name]))
+		}
+	}
+	return text.String()
+}
+
+// apiResponse is the envelope of every Bot API answer.
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func (b *Bot) send(ctx context.Context, text string) error {
+	payload, err := json.Marshal(map[string]any{
+		"chat_id":                  b.chatID,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_notification":     b.silent,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Telegram message: %w", err)
+	}
+
+	if b.dryRun {
+		log.Printf("[dry run] Would send Telegram message to chat %s: %s", b.chatID, text)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.api+"/bot"+b.token+"/sendMessage", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The request URL holds the token, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send Telegram message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram returned status %d: %s", resp.StatusCode, result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"form2mail/internal/storage"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  storage.Submission
+		want string
+	}{
+		{
+			name: "default form",
+			sub:  storage.Submission{Name: "Ada", Email: "ada@example.com", Message: "Hi"},
+			want: "<b>New submission</b>\n<b>From:</b> Ada &lt;ada@example.com&gt;\n\nHi",
+		},
+		{
+			name: "form, subject, and fields",
+			sub: storage.Submission{FormID: "support", Name: "Ada", Email: "ada@example.com", Subject: "Help", Message: "Hi",
+				Fields: map[string]string{"phone": "555", "company": "ACME"}},
+			want: "<b>New submission</b> to <code>support</code>\n<b>From:</b> Ada &lt;ada@example.com&gt;\n<b>Subject:</b> Help\n\nHi\n\n<b>company:</b> ACME\n<b>phone:</b> 555",
+		},
+		{
+			name: "markup is escaped",
+			sub: storage.Submission{FormID: "<i>x</i>", Name: `<a href="https://evil.example">Ada</a>`, Email: "a&b@example.com",
+				Subject: "<b>urgent</b>", Message: "1 < 2 & 3 > 2", Fields: map[string]string{"<code>": "</b>"}},
+			want: "<b>New submission</b> to <code>&lt;i&gt;x&lt;/i&gt;</code>\n" +
+				"<b>From:</b> &lt;a href=&#34;https://evil.example&#34;&gt;Ada&lt;/a&gt; &lt;a&amp;b@example.com&gt;\n" +
+				"<b>Subject:</b> &lt;b&gt;urgent&lt;/b&gt;\n\n1 &lt; 2 &amp; 3 &gt; 2\n\n<b>&lt;code&gt;:</b> &lt;/b&gt;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Format(tt.sub); got != tt.want {
+				t.Errorf("Format =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTruncatesMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		truncated bool
+	}{
+		{"at the limit", strings.Repeat("ä", maxMessage), false},
+		{"past the limit", strings.Repeat("ä", maxMessage+1), true},
+		{"escaped past the limit", strings.Repeat("<", maxMessage+500), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := Format(storage.Submission{Name: "Ada", Email: "ada@example.com", Message: tt.message})
+			_, quoted, _ := strings.Cut(text, "\n\n")
+			if !utf8.ValidString(quoted) {
+				t.Fatal("truncation split a character")
+			}
+			if strings.HasSuffix(quoted, "…") != tt.truncated {
+				t.Errorf("truncated %v, want %v", strings.HasSuffix(quoted, "…"), tt.truncated)
+			}
+			// Cut before escaping, so no entity is split and the limit
+			// counts what the submitter wrote
+			unescaped := strings.NewReplacer("&lt;", "<", "&amp;", "&").Replace(strings.TrimSuffix(quoted, "…"))
+			if n := utf8.RuneCountInString(unescaped); n != min(utf8.RuneCountInString(tt.message), maxMessage) {
+				t.Errorf("quoted %d characters", n)
+			}
+			if tt.truncated && strings.HasSuffix(strings.TrimSuffix(quoted, "…"), "&lt") {
+				t.Error("truncation split an entity")
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got struct {
+		path string
+		body map[string]any
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got.body)
+		if r.URL.Path != "/botgood/sendMessage" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok": false, "description": "Unauthorized"}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	b := New("good", "-100123", true, false)
+	b.api = srv.URL
+	if err := b.Notify("Disk <full>", "at 99%"); err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/botgood/sendMessage" {
+		t.Errorf("posted to %s", got.path)
+	}
+	want := map[string]any{
+		"chat_id":                  "-100123",
+		"text":                     "<b>Disk &lt;full&gt;</b>\nat 99%",
+		"parse_mode":               "HTML",
+		"disable_notification":     true,
+		"disable_web_page_preview": true,
+	}
+	for key, value := range want {
+		if got.body[key] != value {
+			t.Errorf("%s = %v, want %v", key, got.body[key], value)
+		}
+	}
+
+	b = New("bad", "-100123", false, false)
+	b.api = srv.URL
+	if err := b.SendSubmission(context.Background(), storage.Submission{}); err == nil || !strings.Contains(err.Error(), "401: Unauthorized") {
+		t.Errorf("SendSubmission = %v, want the API's description", err)
+	}
+
+	// The token is part of the URL, which must not end up in errors
+	srv.Close()
+	b = New("secret-token", "-100123", false, false)
+	b.api = srv.URL
+	if err := b.Notify("x", "y"); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Notify = %v, want an error without the token", err)
+	}
+}
+
+func TestSendDryRun(t *testing.T) {
+	b := New("token", "-100123", false, true)
+	b.api = "http://127.0.0.1:0"
+	if err := b.Notify("x", "y"); err != nil {
+		t.Errorf("dry run = %v, want nothing sent", err)
+	}
+}
@@ -0,0 +1,202 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.NRGBA{255, 0, 0, 255}
+	blue = color.NRGBA{0, 0, 255, 255}
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, red)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// encodeJPEG encodes img with an EXIF segment setting orientation, in the
+// byte order of order.
+func encodeJPEG(t *testing.T, img image.Image, order binary.AppendByteOrder, orientation uint16) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte("MM\x00\x2a")
+	if order == binary.LittleEndian {
+		tiff = []byte("II\x2a\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xff, 0xd8, 0xff, 0xe1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, b.Bytes()[2:]...)
+}
+
+func decodedSize(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestProcessImageDownscales(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h, max    int
+		wantW, wantH int
+	}{
+		{"landscape", 400, 200, 100, 100, 50},
+		{"portrait", 200, 400, 100, 50, 100},
+		{"small enough", 80, 40, 100, 80, 40},
+		{"no limit", 400, 200, 0, 400, 200},
+		{"sliver", 1000, 2, 100, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := processImage(encodePNG(t, testImage(tt.w, tt.h)), ImageOptions{MaxDimension: tt.max, Quality: 85})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w, h := decodedSize(t, out); w != tt.wantW || h != tt.wantH {
+				t.Errorf("processed to %dx%d, want %dx%d", w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestProcessImageStripsMetadata(t *testing.T) {
+	// A tEXt chunk right after IHDR, as cameras and editors write them
+	data := encodePNG(t, testImage(10, 10))
+	chunk := append([]byte("tEXt"), "Comment\x00shot at 52.52N 13.40E"...)
+	text := binary.BigEndian.AppendUint32(nil, uint32(len(chunk)-4))
+	text = append(text, chunk...)
+	text = binary.BigEndian.AppendUint32(text, crc32.ChecksumIEEE(chunk))
+	ihdrEnd := 8 + 4 + 4 + 13 + 4
+	data = append(data[:ihdrEnd:ihdrEnd], append(text, data[ihdrEnd:]...)...)
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("test image does not decode: %v", err)
+	}
+
+	out, err := processImage(data, ImageOptions{Quality: 85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("52.52N")) {
+		t.Error("PNG text kept")
+	}
+
+	out, err = processImage(encodeJPEG(t, testImage(10, 10), binary.BigEndian, 1), ImageOptions{Quality: 85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("Exif")) {
+		t.Error("EXIF kept")
+	}
+}
+
+func TestProcessImageOrientsJPEG(t *testing.T) {
+	tests := []struct {
+		orientation uint16
+		order       binary.AppendByteOrder
+		w, h        int
+	}{
+		{1, binary.BigEndian, 40, 20},
+		{3, binary.LittleEndian, 40, 20},
+		{6, binary.BigEndian, 20, 40},
+		{8, binary.LittleEndian, 20, 40},
+		{9, binary.BigEndian, 40, 20},
+	}
+	for _, tt := range tests {
+		data := encodeJPEG(t, testImage(40, 20), tt.order, tt.orientation)
+		if got := exifOrientation(data); got != int(tt.orientation) {
+			t.Errorf("exifOrientation = %d, want %d", got, tt.orientation)
+		}
+		out, err := processImage(data, ImageOptions{Quality: 85})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, h := decodedSize(t, out); w != tt.w || h != tt.h {
+			t.Errorf("orientation %d: processed to %dx%d, want %dx%d", tt.orientation, w, h, tt.w, tt.h)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Red on the left, blue on the right
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		redAt       image.Point
+	}{
+		{1, 2, 1, image.Pt(0, 0)},
+		{2, 2, 1, image.Pt(1, 0)},
+		{3, 2, 1, image.Pt(1, 0)},
+		{4, 2, 1, image.Pt(0, 0)},
+		{5, 1, 2, image.Pt(0, 0)},
+		{6, 1, 2, image.Pt(0, 0)},
+		{7, 1, 2, image.Pt(0, 1)},
+		{8, 1, 2, image.Pt(0, 1)},
+	}
+	for _, tt := range tests {
+		got := orient(img, tt.orientation)
+		b := got.Bounds()
+		if b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if c := color.NRGBAModel.Convert(got.At(tt.redAt.X, tt.redAt.Y)); c != red {
+			t.Errorf("orientation %d: %v at %v, want red", tt.orientation, c, tt.redAt)
+		}
+	}
+}
+
+func TestProcessKeepsOtherFiles(t *testing.T) {
+	s := &Store{}
+	s.ProcessImages(ImageOptions{MaxDimension: 10, Quality: 85})
+	tests := map[string][]byte{
+		"notes.txt":  []byte("just text"),
+		"broken.png": append([]byte("\x89PNG\r\n\x1a\n"), "not really"...),
+		"broken.jpg": {0xff, 0xd8, 0xff, 0xe0, 0, 2},
+	}
+	for name, data := range tests {
+		if got := s.Process(name, data); !bytes.Equal(got, data) {
+			t.Errorf("%s changed", name)
+		}
+	}
+}
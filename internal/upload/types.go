@@ -0,0 +1,53 @@
+package upload
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// unnamed are the types sniffing gives content it cannot name more
+// precisely: office documents and other zip-based formats, and text such
+// as CSV. Only such content may be allowed by its file extension.
+var unnamed = map[string]bool{"application/zip": true, "text/plain": true}
+
+// sniffType returns the media type of content starting with head, without
+// parameters, as http.DetectContentType sees it.
+func sniffType(head []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// TypeAllowed reports whether a file called name whose content starts with
+// head matches one of allowed. Entries are media types matched against the
+// sniffed content, such as "application/pdf" or "image/*", or extensions
+// such as ".docx" matched against name. An extension only matches content
+// that sniffing cannot name, so an HTML page does not pass as a document
+// by being renamed. An empty allowed matches everything.
+func TypeAllowed(allowed []string, name string, head []byte) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	sniffed := sniffType(head)
+	ext := strings.ToLower(path.Ext(CleanName(name)))
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case strings.HasPrefix(entry, "."):
+			if entry == ext && unnamed[sniffed] {
+				return true
+			}
+		case strings.HasSuffix(entry, "/*"):
+			if strings.HasPrefix(sniffed, strings.TrimSuffix(entry, "*")) {
+				return true
+			}
+		case entry == sniffed:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package upload
+
+import "testing"
+
+func TestTypeAllowed(t *testing.T) {
+	var (
+		pdf  = []byte("%PDF-1.7\n")
+		png  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+		zip  = []byte("PK\x03\x04\x14\x00\x06\x00")
+		html = []byte("<!DOCTYPE html><html><body>")
+		csv  = []byte("name,email\nAda,ada@example.com\n")
+	)
+	tests := []struct {
+		name    string
+		allowed []string
+		file    string
+		head    []byte
+		want    bool
+	}{
+		{"anything without a list", nil, "page.html", html, true},
+		{"media type", []string{"application/pdf"}, "cv.pdf", pdf, true},
+		{"media type by content, not name", []string{"application/pdf"}, "cv.pdf", html, false},
+		{"wildcard", []string{"image/*"}, "photo.jpeg", png, true},
+		{"wildcard of another type", []string{"image/*"}, "cv.pdf", pdf, false},
+		{"extension of zip content", []string{".docx"}, "Lebenslauf.docx", zip, true},
+		{"extension of renamed HTML", []string{".docx"}, "page.docx", html, false},
+		{"extension of another file", []string{".docx"}, "archive.zip", zip, false},
+		{"extension of text", []string{".csv"}, "export.csv", csv, true},
+		{"extension from a path", []string{".csv"}, "C:\\exports\\export.CSV", csv, true},
+		{"case and spaces", []string{" Application/PDF "}, "cv.pdf", pdf, true},
+		{"any entry", []string{"image/*", ".docx", "application/pdf"}, "cv.pdf", pdf, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TypeAllowed(tt.allowed, tt.file, tt.head); got != tt.want {
+				t.Errorf("TypeAllowed(%q, %q) = %v, want %v", tt.allowed, tt.file, got, tt.want)
+			}
+		})
+	}
+}
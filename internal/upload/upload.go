@@ -0,0 +1,236 @@
+// Package upload keeps files uploaded with submissions on disk and hands out
+// signed, expiring download links instead of attaching them to emails.
+package upload
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// ErrNotFound is returned for unknown or deleted uploads.
+var ErrNotFound = errors.New("upload not found")
+
+// File is a stored upload.
+type File struct {
+	ID   string
+	Name string
+	Size int64
+}
+
+// Store keeps uploads in a directory, one subdirectory per file.
+type Store struct {
+	dir    string
+	secret []byte
+	images *ImageOptions
+	ids    *clock.IDs
+}
+
+// Open creates dir if needed. secret signs download links.
+func Open(dir string, secret []byte) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &Store{dir: dir, secret: secret}, nil
+}
+
+// Save stores the contents of r under name, which is reduced to its base
+// name. Images are processed first if ProcessImages was called. A positive
+// retention keeps the file that long instead of the retention passed to
+// Prune.
+func (s *Store) Save(name string, r io.Reader, retention time.Duration) (File, error) {
+	if s.images != nil {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return File{}, fmt.Errorf("failed to read upload: %w", err)
+		}
+		r = bytes.NewReader(s.Process(name, data))
+	}
+
+	id, err := s.newID()
+	if err != nil {
+		return File{}, err
+	}
+	f := File{ID: id, Name: CleanName(name)}
+
+	dir := filepath.Join(s.dir, f.ID)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return File{}, fmt.Errorf("failed to store upload: %w", err)
+	}
+	out, err := os.OpenFile(filepath.Join(dir, f.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to store upload: %w", err)
+	}
+	f.Size, err = io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && retention > 0 {
+		expires := []byte(time.Now().Add(retention).Format(time.RFC3339))
+		err = os.WriteFile(s.expiresPath(f.ID), expires, 0o600)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return File{}, fmt.Errorf("failed to store upload: %w", err)
+	}
+	return f, nil
+}
+
+// Process returns data with images processed as configured through
+// ProcessImages, and anything else unchanged.
+func (s *Store) Process(name string, data []byte) []byte {
+	if s.images == nil {
+		return data
+	}
+	processed, err := processImage(data, *s.images)
+	if err != nil {
+		log.Printf("Storing %s unprocessed: %v", CleanName(name), err)
+		return data
+	}
+	return processed
+}
+
+// expiresPath is the file next to an upload's directory that records when
+// an upload with its own retention expires.
+func (s *Store) expiresPath(id string) string {
+	return filepath.Join(s.dir, id+".expires")
+}
+
+// UseIDs draws upload IDs from ids instead of crypto/rand, so tests get the
+// same download links on every run.
+func (s *Store) UseIDs(ids *clock.IDs) {
+	s.ids = ids
+}
+
+func (s *Store) newID() (string, error) {
+	if s.ids != nil {
+		return s.ids.New(16), nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Open returns the upload with id.
+func (s *Store) Open(id string) (*os.File, string, error) {
+	if !validID(id) {
+		return nil, "", ErrNotFound
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, id))
+	if err != nil || len(entries) != 1 {
+		return nil, "", ErrNotFound
+	}
+	name := entries[0].Name()
+	f, err := os.Open(filepath.Join(s.dir, id, name))
+	if err != nil {
+		return nil, "", ErrNotFound
+	}
+	return f, name, nil
+}
+
+// Link returns the query string that authorizes downloading id until
+// expires.
+func (s *Store) Link(id string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return url.Values{"expires": {exp}, "sig": {s.sign(id, exp)}}.Encode()
+}
+
+// Authorized reports whether q carries a valid, unexpired signature for id.
+func (s *Store) Authorized(id string, q url.Values) bool {
+	exp := q.Get("expires")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(q.Get("sig")), []byte(s.sign(id, exp)))
+}
+
+func (s *Store) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Prune deletes uploads older than retention at now, or past their own
+// retention, and returns how many.
+func (s *Store) Prune(now time.Time, retention time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !e.IsDir() || !validID(e.Name()) {
+			continue
+		}
+		expires := info.ModTime().Add(retention)
+		if data, err := os.ReadFile(s.expiresPath(e.Name())); err == nil {
+			if t, err := time.Parse(time.RFC3339, string(data)); err == nil {
+				expires = t
+			}
+		}
+		if now.Before(expires) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, e.Name())); err != nil {
+			return removed, err
+		}
+		os.Remove(s.expiresPath(e.Name()))
+		removed++
+	}
+	return removed, nil
+}
+
+// PruneExpired deletes uploads older than retention, or past their own
+// retention, and logs how many.
+func (s *Store) PruneExpired(retention time.Duration) error {
+	n, err := s.Prune(time.Now(), retention)
+	if n > 0 {
+		log.Printf("Deleted %d expired upload(s)", n)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune uploads: %w", err)
+	}
+	return nil
+}
+
+// CleanName strips directories and characters that are awkward in file
+// names and headers.
+func CleanName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "upload"
+	}
+	return name
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
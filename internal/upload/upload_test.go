@@ -0,0 +1,154 @@
+package upload
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveAndOpen(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "uploads"), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := s.Save("C:\\Users\\ada\\cv.pdf", strings.NewReader("%PDF-1.7"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "cv.pdf" || f.Size != 8 || !validID(f.ID) {
+		t.Errorf("saved %+v", f)
+	}
+
+	file, name, err := s.Open(f.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "cv.pdf" || string(data) != "%PDF-1.7" {
+		t.Errorf("opened %s with %q", name, data)
+	}
+
+	for _, id := range []string{"", "../uploads", strings.Repeat("0", 32), strings.Repeat("z", 32)} {
+		if _, _, err := s.Open(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	s, err := Open(t.TempDir(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.Repeat("ab", 16)
+	link, err := url.ParseQuery(s.Link(id, time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Authorized(id, link) {
+		t.Error("own link refused")
+	}
+
+	expired, _ := url.ParseQuery(s.Link(id, time.Now().Add(-time.Second)))
+	later := url.Values{"expires": {"9999999999"}, "sig": link["sig"]}
+	other, _ := Open(t.TempDir(), []byte("other secret"))
+	tests := []struct {
+		name string
+		id   string
+		q    url.Values
+		s    *Store
+	}{
+		{"expired", id, expired, s},
+		{"other upload", strings.Repeat("cd", 16), link, s},
+		{"extended expiry", id, later, s},
+		{"no signature", id, url.Values{"expires": link["expires"]}, s},
+		{"unparsable expiry", id, url.Values{"expires": {"soon"}, "sig": link["sig"]}, s},
+		{"other secret", id, link, other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.s.Authorized(tt.id, tt.q) {
+				t.Error("link accepted")
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s, err := Open(t.TempDir(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := s.Save("kept.txt", strings.NewReader("a"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, err := s.Save("short.txt", strings.NewReader("b"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := s.Save("long.txt", strings.NewReader("c"), 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not an upload, so never pruned
+	if err := os.Mkdir(filepath.Join(s.dir, "lost+found"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Prune(time.Now().Add(time.Hour), 30*24*time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("Prune after an hour = %d, %v; want the upload with its own short retention", n, err)
+	}
+	if _, _, err := s.Open(short.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("short retention upload kept")
+	}
+	if _, err := os.Stat(s.expiresPath(short.ID)); !os.IsNotExist(err) {
+		t.Error("expiry record left behind")
+	}
+
+	n, err = s.Prune(time.Now().Add(31*24*time.Hour), 30*24*time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("Prune after a month = %d, %v; want the upload under the default retention", n, err)
+	}
+	if _, _, err := s.Open(kept.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("upload past the retention kept")
+	}
+	if f, _, err := s.Open(long.ID); err != nil {
+		t.Error("upload with a longer retention of its own deleted")
+	} else {
+		f.Close()
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "lost+found")); err != nil {
+		t.Error("unrelated directory deleted")
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	tests := map[string]string{
+		"cv.pdf":                 "cv.pdf",
+		"/etc/passwd":            "passwd",
+		"..\\..\\boot.ini":       "boot.ini",
+		"C:\\Users\\ada\\cv.pdf": "cv.pdf",
+		"a:b*c?d\"e<f>g|h.txt":   "a_b_c_d_e_f_g_h.txt",
+		"line\r\nbreak.txt":      "line__break.txt",
+		"":                       "upload",
+		".":                      "upload",
+		"..":                     "upload",
+		"lebenslauf-müller.pdf":  "lebenslauf-müller.pdf",
+	}
+	for in, want := range tests {
+		if got := CleanName(in); got != want {
+			t.Errorf("CleanName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package usage counts submissions and emails per tenant and calendar
+// month, for quotas and billing.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+// Counts are one tenant's usage in one month.
+type Counts struct {
+	Submissions int `json:"submissions"`
+	Emails      int `json:"emails"`
+}
+
+// Tracker counts usage per tenant and month. Counts are kept in memory and,
+// if a path is given, saved to that file after every change so they survive
+// restarts.
+type Tracker struct {
+	loc   *time.Location
+	path  string
+	clock clock.Clock
+
+	mu    sync.Mutex
+	state state
+}
+
+type state struct {
+	// Months maps "2006-01" to counts by tenant.
+	Months map[string]map[string]*Counts `json:"months"`
+	// Notified maps "2006-01" to the tenants told about an exceeded quota.
+	Notified map[string]map[string]bool `json:"notified,omitempty"`
+}
+
+// NewTracker returns a tracker whose months follow loc, loading earlier
+// counts from path if it exists. path may be empty to keep counts in
+// memory only.
+func NewTracker(loc *time.Location, path string) (*Tracker, error) {
+	t := &Tracker{loc: loc, path: path, clock: clock.System, state: state{
+		Months:   make(map[string]map[string]*Counts),
+		Notified: make(map[string]map[string]bool),
+	}}
+	if path == "" {
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.state); err != nil {
+		return nil, fmt.Errorf("failed to parse usage file: %w", err)
+	}
+	if t.state.Months == nil {
+		t.state.Months = make(map[string]map[string]*Counts)
+	}
+	if t.state.Notified == nil {
+		t.state.Notified = make(map[string]map[string]bool)
+	}
+	return t, nil
+}
+
+// UseClock makes t count into the months of c instead of the system
+// clock. It must be called before t is used.
+func (t *Tracker) UseClock(c clock.Clock) {
+	t.clock = c
+}
+
+// Month returns the month t falls in, as used by Usage and Report.
+func (t *Tracker) Month(at time.Time) string {
+	return at.In(t.loc).Format("2006-01")
+}
+
+// CurrentMonth returns the month usage is currently counted in.
+func (t *Tracker) CurrentMonth() string {
+	return t.Month(t.clock.Now())
+}
+
+// Usage returns the tenant's counts in the current month.
+func (t *Tracker) Usage(tenant string) Counts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.state.Months[t.CurrentMonth()][tenant]; ok {
+		return *c
+	}
+	return Counts{}
+}
+
+// Report returns the counts of every tenant with usage in month.
+func (t *Tracker) Report(month string) map[string]Counts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make(map[string]Counts)
+	for tenant, c := range t.state.Months[month] {
+		report[tenant] = *c
+	}
+	return report
+}
+
+// AddSubmission counts an accepted submission for tenant.
+func (t *Tracker) AddSubmission(tenant string) {
+	t.update(tenant, func(c *Counts) { c.Submissions++ })
+}
+
+// AddEmail counts an email sent on tenant's behalf.
+func (t *Tracker) AddEmail(tenant string) {
+	t.update(tenant, func(c *Counts) { c.Emails++ })
+}
+
+// Notify reports whether tenant should be told about an exceeded quota,
+// which is the case once per month.
+func (t *Tracker) Notify(tenant string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	month := t.CurrentMonth()
+	if t.state.Notified[month][tenant] {
+		return false
+	}
+	if t.state.Notified[month] == nil {
+		t.state.Notified[month] = make(map[string]bool)
+	}
+	t.state.Notified[month][tenant] = true
+	t.save()
+	return true
+}
+
+func (t *Tracker) update(tenant string, fn func(*Counts)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	month := t.CurrentMonth()
+	tenants, ok := t.state.Months[month]
+	if !ok {
+		tenants = make(map[string]*Counts)
+		t.state.Months[month] = tenants
+	}
+	c, ok := tenants[tenant]
+	if !ok {
+		c = &Counts{}
+		tenants[tenant] = c
+	}
+	fn(c)
+	t.save()
+}
+
+// save writes the counts to the file, if any. On failure the in-memory
+// counts stay authoritative and the next change tries again.
+func (t *Tracker) save() {
+	if t.path == "" {
+		return
+	}
+	data, err := json.Marshal(t.state)
+	if err == nil {
+		tmp := t.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, t.path)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save usage: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"form2mail/internal/clock"
+)
+
+func TestMonthRollover(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	tracker, err := NewTracker(berlin, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 23:30 on January 31 in Berlin, still January an hour later in UTC
+	now := clock.NewManual(time.Date(2025, 1, 31, 22, 30, 0, 0, time.UTC))
+	tracker.UseClock(now)
+
+	tracker.AddSubmission("acme")
+	tracker.AddSubmission("acme")
+	tracker.AddEmail("acme")
+	tracker.AddSubmission("globex")
+	if got := tracker.Usage("acme"); got != (Counts{Submissions: 2, Emails: 1}) {
+		t.Errorf("January usage %+v", got)
+	}
+	if !tracker.Notify("acme") || tracker.Notify("acme") {
+		t.Error("want one quota notice per month")
+	}
+
+	now.Advance(time.Hour)
+	if got := tracker.CurrentMonth(); got != "2025-02" {
+		t.Fatalf("month %s, want 2025-02 by Berlin time", got)
+	}
+	if got := tracker.Usage("acme"); got != (Counts{}) {
+		t.Errorf("usage %+v in a new month, want none", got)
+	}
+	if !tracker.Notify("acme") {
+		t.Error("no quota notice in the new month")
+	}
+	tracker.AddSubmission("acme")
+
+	january := tracker.Report("2025-01")
+	if len(january) != 2 || january["acme"] != (Counts{Submissions: 2, Emails: 1}) || january["globex"] != (Counts{Submissions: 1}) {
+		t.Errorf("January report %+v", january)
+	}
+	if february := tracker.Report("2025-02"); len(february) != 1 || february["acme"] != (Counts{Submissions: 1}) {
+		t.Errorf("February report %+v", february)
+	}
+	if got := tracker.Report("2024-12"); len(got) != 0 {
+		t.Errorf("report of a month without usage %+v", got)
+	}
+}
+
+func TestTrackerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	now := clock.NewManual(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	tracker, err := NewTracker(time.UTC, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.UseClock(now)
+	tracker.AddSubmission("acme")
+	tracker.AddEmail("acme")
+	tracker.Notify("acme")
+
+	restarted, err := NewTracker(time.UTC, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.UseClock(now)
+	if got := restarted.Usage("acme"); got != (Counts{Submissions: 1, Emails: 1}) {
+		t.Errorf("usage after restart %+v", got)
+	}
+	if restarted.Notify("acme") {
+		t.Error("quota notice sent again after restart")
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTracker(time.UTC, path); err == nil {
+		t.Error("NewTracker accepted a corrupt usage file")
+	}
+	if _, err := NewTracker(time.UTC, filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("NewTracker without a usage file = %v", err)
+	}
+}